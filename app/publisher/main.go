@@ -86,6 +86,9 @@ type config struct {
 	SourceRoot     string `json:"sourceRoot,omitempty"`     // Path to the app's resources dir, such as html and css files.
 	GoTemplate     string `json:"goTemplate"`               // Go html template to render the publication.
 	CacheRoot      string `json:"cacheRoot,omitempty"`      // Root path for the caching blobserver. No caching if empty.
+
+	DescribeCacheSize int `json:"describeCacheSize,omitempty"` // Max number of describe results to cache; see describeCacheSize.
+	DescribeCacheTTL  int `json:"describeCacheTTL,omitempty"`  // TTL, in seconds, of a describe cache entry; see describeCacheTTL.
 }
 
 // appConfig keeps on trying to fetch the extra config from the app handler. If
@@ -398,6 +401,15 @@ func newPublishHandler(conf *config) *publishHandler {
 		thumbMeta = server.NewThumbMeta(kv)
 	}
 
+	cacheSize := describeCacheSize
+	if conf.DescribeCacheSize > 0 {
+		cacheSize = conf.DescribeCacheSize
+	}
+	cacheTTL := describeCacheTTL
+	if conf.DescribeCacheTTL > 0 {
+		cacheTTL = time.Duration(conf.DescribeCacheTTL) * time.Second
+	}
+
 	return &publishHandler{
 		rootName:       conf.RootName,
 		cl:             cl,
@@ -406,7 +418,7 @@ func newPublishHandler(conf *config) *publishHandler {
 		goTemplate:     goTemplate,
 		CSSFiles:       CSSFiles,
 		JSDeps:         JSDeps,
-		describedCache: make(map[string]*search.DescribedBlob),
+		describedCache: search.NewDescribedCache(cacheSize, cacheTTL),
 		cache:          cache,
 		thumbMeta:      thumbMeta,
 	}
@@ -432,6 +444,8 @@ type client interface {
 	GetJSON(ctx context.Context, url string, data interface{}) error
 	Post(ctx context.Context, url string, bodyType string, body io.Reader) error
 	blob.Fetcher
+
+	GetPathTarget(ctx context.Context, req *search.PathTargetRequest) (*search.PathTargetResponse, error)
 }
 
 type publishHandler struct {
@@ -452,8 +466,7 @@ type publishHandler struct {
 	JSDeps      []string
 	resizeSem   *syncutil.Sem // Limit peak RAM used by concurrent image thumbnail calls.
 
-	describedCacheMu sync.RWMutex
-	describedCache   map[string]*search.DescribedBlob // So that each item in a gallery does not actually require a describe round-trip.
+	describedCache *search.DescribedCache // So that each item in a gallery does not actually require a describe round-trip.
 
 	cache     blobserver.Storage // For caching images and files, or nil.
 	thumbMeta *server.ThumbMeta  // For keeping track of cached images, or nil.
@@ -528,29 +541,17 @@ func (ph *publishHandler) lookupPathTarget(root blob.Ref, suffix string) (blob.R
 	if suffix == "" {
 		return root, nil
 	}
-	// TODO: verify it's optimized: http://perkeep.org/issue/405
-	result, err := ph.cl.Query(context.TODO(), &search.SearchQuery{
-		Limit: 1,
-		Constraint: &search.Constraint{
-			Permanode: &search.PermanodeConstraint{
-				SkipHidden: true,
-				Relation: &search.RelationConstraint{
-					Relation: "parent",
-					EdgeType: "camliPath:" + suffix,
-					Any: &search.Constraint{
-						BlobRefPrefix: root.String(),
-					},
-				},
-			},
-		},
+	res, err := ph.cl.GetPathTarget(context.TODO(), &search.PathTargetRequest{
+		Base:   root,
+		Suffix: suffix,
 	})
 	if err != nil {
 		return blob.Ref{}, err
 	}
-	if len(result.Blobs) == 0 || !result.Blobs[0].Blob.Valid() {
+	if !res.Target.Valid() {
 		return blob.Ref{}, os.ErrNotExist
 	}
-	return result.Blobs[0].Blob, nil
+	return res.Target, nil
 }
 
 // Given a blobref and a few hex characters of the digest of the next hop, return the complete
@@ -595,12 +596,9 @@ func (ph *publishHandler) resolvePrefixHop(parent blob.Ref, prefix string) (chil
 }
 
 func (ph *publishHandler) describe(br blob.Ref) (*search.DescribedBlob, error) {
-	ph.describedCacheMu.RLock()
-	if des, ok := ph.describedCache[br.String()]; ok {
-		ph.describedCacheMu.RUnlock()
+	if des, ok := ph.describedCache.Get(br); ok {
 		return des, nil
 	}
-	ph.describedCacheMu.RUnlock()
 	ctx := context.TODO()
 	res, err := ph.cl.Describe(ctx, &search.DescribeRequest{
 		BlobRef: br,
@@ -894,17 +892,16 @@ func (pr *publishRequest) serveSubjectTemplate() {
 	}
 }
 
-const cacheSize = 1000
+// describeCacheSize and describeCacheTTL are the defaults used when the
+// app configuration doesn't specify DescribeCacheSize/DescribeCacheTTL.
+const (
+	describeCacheSize = 1000
+	describeCacheTTL  = 5 * time.Minute
+)
 
 func (ph *publishHandler) cacheDescribed(described map[string]*search.DescribedBlob) {
-	ph.describedCacheMu.Lock()
-	defer ph.describedCacheMu.Unlock()
-	if len(ph.describedCache) > cacheSize {
-		ph.describedCache = described
-		return
-	}
-	for k, v := range described {
-		ph.describedCache[k] = v
+	for _, des := range described {
+		ph.describedCache.Add(des)
 	}
 }
 
@@ -153,6 +153,10 @@ func (fc *fakeClient) Fetch(context.Context, blob.Ref) (blob io.ReadCloser, size
 	return
 }
 
+func (fc *fakeClient) GetPathTarget(ctx context.Context, req *search.PathTargetRequest) (*search.PathTargetResponse, error) {
+	return fc.sh.GetPathTarget(ctx, req)
+}
+
 func TestPublishURLs(t *testing.T) {
 	rootName := "foo"
 	idxd := setupContent(rootName)
@@ -166,8 +170,9 @@ func TestPublishURLs(t *testing.T) {
 	sh.SetCorpus(corpus)
 	fcl := &fakeClient{sh}
 	ph := &publishHandler{
-		rootName: rootName,
-		cl:       fcl,
+		rootName:       rootName,
+		cl:             fcl,
+		describedCache: search.NewDescribedCache(0, 0),
 	}
 	if err := ph.initRootNode(); err != nil {
 		t.Fatalf("initRootNode: %v", err)
@@ -223,8 +228,9 @@ func TestPublishMembers(t *testing.T) {
 	sh.SetCorpus(corpus)
 	fcl := &fakeClient{sh}
 	ph := &publishHandler{
-		rootName: rootName,
-		cl:       fcl,
+		rootName:       rootName,
+		cl:             fcl,
+		describedCache: search.NewDescribedCache(0, 0),
 	}
 
 	rw := httptest.NewRecorder()
@@ -55,11 +55,15 @@ var (
 	flagCheck         = flag.Bool("check", false, "just check for the existence of listed blobs; returning 0 if all are present")
 	flagOutput        = flag.String("o", "-", "Output file/directory to create.  Use -f to overwrite.")
 	flagGraph         = flag.Bool("graph", false, "Output a graphviz directed graph .dot file of the provided root schema blob, to be rendered with 'dot -Tsvg -o graph.svg graph.dot'")
+	flagManifest      = flag.Bool("manifest", false, "For a directory (or file) schema blobref, print its full tree of paths, types, and sizes without downloading any file contents.")
+	flagManifestJSON  = flag.Bool("manifest_json", false, "With --manifest, print one JSON object per line instead of an indented tree, for use by other tools.")
 	flagContents      = flag.Bool("contents", false, "If true and the target blobref is a 'bytes' or 'file' schema blob, the contents of that file are output instead.")
 	flagShared        = flag.String("shared", "", "If non-empty, the URL of a \"share\" blob. The URL will be used as the root of future fetches. Only \"haveref\" shares are currently supported.")
 	flagTrustedCert   = flag.String("cert", "", "If non-empty, the fingerprint (20 digits lowercase prefix of the SHA256 of the complete certificate) of the TLS certificate we trust for the share URL. Requires --shared.")
 	flagInsecureTLS   = flag.Bool("insecure", false, "If set, when using TLS, the server's certificates verification is disabled, and they are not checked against the trustedCerts in the client configuration either.")
 	flagSkipIrregular = flag.Bool("skip_irregular", false, "If true, symlinks, device files, and other special file types are skipped.")
+	flagVerify        = flag.Bool("verify", false, "Verify blob digests as they're fetched, recomputing each one from its blobref's hash scheme and failing loudly, naming the blobref, on any mismatch; with -contents, this covers the whole file's chunk tree end to end. If -o is also given, additionally re-read each written file back from disk afterward and verify it against the schema's chunk digests and sizes, catching corruption introduced by the local write itself.")
+	flagJobs          = flag.Int("j", 4, "Number of concurrent workers to use when fetching directory entries and files. Each file's own chunks are still written to it in order.")
 )
 
 func main() {
@@ -83,6 +87,15 @@ func main() {
 	if *flagGraph && flag.NArg() != 1 {
 		log.Fatalf("The --graph option requires exactly one parameter.")
 	}
+	if *flagManifest && flag.NArg() != 1 {
+		log.Fatalf("The --manifest option requires exactly one parameter.")
+	}
+	if *flagManifestJSON && !*flagManifest {
+		log.Fatalf("The --manifest_json option requires --manifest.")
+	}
+	if *flagJobs < 1 {
+		log.Fatalf("-j must be at least 1.")
+	}
 
 	var cl *client.Client
 	var items []blob.Ref
@@ -126,7 +139,11 @@ func main() {
 
 	httpStats := cl.HTTPStats()
 
-	diskCacheFetcher, err := cacher.NewDiskCache(cl)
+	var blobFetcher blob.Fetcher = cl
+	if *flagVerify {
+		blobFetcher = verifyingFetcher{cl}
+	}
+	diskCacheFetcher, err := cacher.NewDiskCache(blobFetcher)
 	if err != nil {
 		log.Fatalf("Error setting up local disk cache: %v", err)
 	}
@@ -153,6 +170,12 @@ func main() {
 			printGraph(diskCacheFetcher, br)
 			return
 		}
+		if *flagManifest {
+			if err := printManifest(ctx, diskCacheFetcher, br, *flagManifestJSON); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 		if *flagCheck {
 			// TODO: do HEAD requests checking if the blobs exists.
 			log.Fatal("not implemented")
@@ -263,7 +286,7 @@ func smartFetch(ctx context.Context, src blob.Fetcher, targ string, br blob.Ref)
 		}
 
 		// directory entries
-		const numWorkers = 10
+		numWorkers := *flagJobs
 		type work struct {
 			br   blob.Ref
 			errc chan<- error
@@ -323,6 +346,17 @@ func smartFetch(ctx context.Context, src blob.Fetcher, targ string, br blob.Ref)
 		if err := setFileMeta(name, b); err != nil {
 			log.Print(err)
 		}
+		if *flagVerify {
+			if err := f.Sync(); err != nil {
+				return fmt.Errorf("verify: syncing %s: %v", name, err)
+			}
+			if err := verifyFile(ctx, name, fr); err != nil {
+				return err
+			}
+			if *flagVerbose {
+				log.Printf("Verified %s", name)
+			}
+		}
 		return nil
 
 	case schema.TypeSymlink:
@@ -436,10 +470,18 @@ func setFileMeta(name string, blob *schema.Blob) error {
 	if mt := blob.ModTime(); !mt.IsZero() {
 		err2 = os.Chtimes(name, mt, mt)
 	}
-	// TODO: we previously did os.Chown here, but it's rarely wanted,
-	// then the schema.Blob refactor broke it, so it's gone.
-	// Add it back later once we care?
-	for _, err := range []error{err1, err2} {
+	var err3 error
+	if blob.HasUnixOwner() || blob.HasUnixGroup() {
+		uid, gid := -1, -1 // -1 means "leave unchanged" to os.Chown
+		if blob.HasUnixOwner() {
+			uid = blob.MapUid()
+		}
+		if blob.HasUnixGroup() {
+			gid = blob.MapGid()
+		}
+		err3 = os.Chown(name, uid, gid)
+	}
+	for _, err := range []error{err1, err2, err3} {
 		if err != nil {
 			return err
 		}
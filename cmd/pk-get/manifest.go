@@ -0,0 +1,93 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/schema"
+)
+
+// manifestEntry describes one entry of a --manifest listing, for the
+// -manifest_json output.
+type manifestEntry struct {
+	Path    string           `json:"path"`
+	Type    schema.CamliType `json:"type"`
+	Size    int64            `json:"size,omitempty"`
+	BlobRef blob.Ref         `json:"blobRef"`
+}
+
+// printManifest walks the directory tree rooted at br, printing each
+// entry's path, type, and size to stdout as it's discovered, without
+// fetching any file contents. br may also be a non-directory (file,
+// symlink, etc.), in which case a single entry is printed for it.
+func printManifest(ctx context.Context, fetcher blob.Fetcher, br blob.Ref, asJSON bool) error {
+	root, err := schema.NewDirectoryEntryFromBlobRef(ctx, fetcher, br)
+	if err != nil {
+		return fmt.Errorf("--manifest: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return walkManifest(ctx, root, root.FileName(), asJSON, enc)
+}
+
+func walkManifest(ctx context.Context, ent schema.DirectoryEntry, path string, asJSON bool, enc *json.Encoder) error {
+	var size int64
+	if ent.CamliType() == schema.TypeFile {
+		f, err := ent.File(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		size = f.Size()
+		f.Close()
+	}
+	if asJSON {
+		if err := enc.Encode(manifestEntry{
+			Path:    path,
+			Type:    ent.CamliType(),
+			Size:    size,
+			BlobRef: ent.BlobRef(),
+		}); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	} else if ent.CamliType() == schema.TypeDirectory {
+		fmt.Printf("%10s  %s/\n", "", path)
+	} else {
+		fmt.Printf("%10d  %s\n", size, path)
+	}
+
+	if ent.CamliType() != schema.TypeDirectory {
+		return nil
+	}
+	dir, err := ent.Directory(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	entries, err := dir.Readdir(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, sub := range entries {
+		if err := walkManifest(ctx, sub, path+"/"+sub.FileName(), asJSON, enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
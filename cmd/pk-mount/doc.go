@@ -97,10 +97,18 @@ Full Command Line Usage
 	pk-mount [opts] [<mountpoint> [<root-blobref>|<share URL>|<root-name>]]
 	-debug
 	      print debugging messages.
+	-foreground
+	      Stay attached to the terminal and log to stderr, instead of
+	      daemonizing. Set this when running under a service manager
+	      (e.g. systemd) that already supervises pk-mount as a
+	      foreground process.
 	-help
 	      print usage
 	-legal
 	      show licenses
+	-logfile string
+	      File to append log output to. If empty, logs go to stderr
+	      when -foreground is set, and are discarded otherwise.
 	-open
 	      Open a GUI window
 	-secret-keyring string
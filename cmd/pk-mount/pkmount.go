@@ -47,10 +47,24 @@ import (
 var (
 	debug = flag.Bool("debug", false, "print debugging messages.")
 	xterm = flag.Bool("xterm", false, "Run an xterm in the mounted directory. Shut down when xterm ends.")
+
+	maxOpenFiles   = flag.Int("maxopenfiles", 0, "maximum number of mutable files that may be open for writing at once; 0 means unlimited.")
+	maxWriteMemory = flag.Int64("maxwritemem", 0, "maximum bytes of in-flight FUSE write data across all open files at once; 0 means unlimited.")
+
+	foreground = flag.Bool("foreground", false, "Stay attached to the terminal and log to stderr, instead of daemonizing. Set this when running under a service manager (e.g. systemd) that already supervises pk-mount as a foreground process.")
+	logFile    = flag.String("logfile", "", "File to append log output to. If empty, logs go to stderr when -foreground is set, and are discarded otherwise.")
+
+	query = flag.String("query", "", "If non-empty, a search expression (as accepted by the search UI) whose matching permanodes are mounted as a flat directory of files, instead of the usual root/tag/date/etc. hierarchy. Mutually exclusive with a <root-blobref>|<share URL>|<root-name> argument.")
 )
 
+// daemonizedEnv, when set in the environment, marks this process as
+// the detached child re-exec'd by daemonize; it must not be set by
+// users directly.
+const daemonizedEnv = "CAMLI_PKMOUNT_DAEMONIZED"
+
 func usage() {
 	fmt.Fprint(os.Stderr, "usage: pk-mount [opts] [<mountpoint> [<root-blobref>|<share URL>|<root-name>]]\n")
+	fmt.Fprint(os.Stderr, "       pk-mount -query=<expr> [opts] [<mountpoint>]\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -83,6 +97,16 @@ func main() {
 		usage()
 	}
 
+	if !*foreground && os.Getenv(daemonizedEnv) == "" {
+		if err := daemonize(); err != nil {
+			log.Fatalf("daemonize: %v", err)
+		}
+		return
+	}
+	if err := applyLogFile(); err != nil {
+		log.Fatalf("logfile: %v", err)
+	}
+
 	narg := flag.NArg()
 	if narg > 2 {
 		usage()
@@ -117,6 +141,10 @@ func main() {
 		root  blob.Ref // nil if only one arg
 		camfs *fs.CamliFileSystem
 	)
+	if *query != "" && narg == 2 {
+		errorf("Can't use -query with a <root-blobref>|<share URL>|<root-name> argument.")
+	}
+
 	if narg == 2 {
 		rootArg := flag.Arg(1)
 		// not trying very hard since NewFromShareRoot will do it better with a regex
@@ -161,15 +189,20 @@ func main() {
 		log.Fatalf("Error setting up local disk cache: %v", err)
 	}
 	defer diskCacheFetcher.Clean()
-	if root.Valid() {
+	switch {
+	case *query != "":
+		camfs = fs.NewQueryCamliFileSystem(cl, diskCacheFetcher, *query)
+	case root.Valid():
 		var err error
 		camfs, err = fs.NewRootedCamliFileSystem(cl, diskCacheFetcher, root)
 		if err != nil {
 			log.Fatalf("Error creating root with %v: %v", root, err)
 		}
-	} else {
+	default:
 		camfs = fs.NewDefaultCamliFileSystem(cl, diskCacheFetcher)
 	}
+	camfs.MaxOpenFiles = *maxOpenFiles
+	camfs.MaxWriteMemory = *maxWriteMemory
 
 	if *debug {
 		fuse.Debug = func(msg interface{}) { log.Print(msg) }
@@ -231,6 +264,49 @@ func main() {
 	log.Printf("pk-mount FUSE process ending.")
 }
 
+// daemonize re-execs the current process, detached from the
+// controlling terminal in its own session, and returns once the
+// detached process has started. The child re-runs with the same
+// flags and args, plus daemonizedEnv set so it knows not to
+// daemonize again.
+func daemonize() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding own executable: %v", err)
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonizedEnv+"=1")
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting daemonized process: %v", err)
+	}
+	log.Printf("pk-mount daemonized as pid %d", cmd.Process.Pid)
+	return nil
+}
+
+// applyLogFile redirects the log package's output to *logFile, if
+// set. It is a no-op when running in the foreground with no
+// -logfile, since log already writes to cmdmain.Stderr by default;
+// when daemonized with no -logfile, log output is discarded, since
+// the daemonized process has no terminal to write to.
+func applyLogFile() error {
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("opening log file %q: %v", *logFile, err)
+		}
+		log.SetOutput(f)
+		return nil
+	}
+	if !*foreground {
+		log.SetOutput(io.Discard)
+	}
+	return nil
+}
+
 func awaitQuitKey(done chan<- bool) {
 	var buf [1]byte
 	for {
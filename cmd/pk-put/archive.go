@@ -0,0 +1,275 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/schema"
+)
+
+// archiveEntry is one file, directory, or symlink read from an archive,
+// abstracted away from the underlying archive format (tar or zip).
+type archiveEntry struct {
+	path string                        // slash-separated, relative, no leading slash
+	fi   os.FileInfo                   // mode, size, and mtime as recorded in the archive
+	link string                        // symlink target; only valid if fi.Mode()&os.ModeSymlink != 0
+	open func() (io.ReadCloser, error) // nil for directories and symlinks
+}
+
+// archiveNode is one file or directory of the tree being assembled from
+// an archive's entries, keyed by path component. Directories may be
+// implicit (never listed as their own entry, only inferred from the
+// path of a deeper entry).
+type archiveNode struct {
+	entry    *archiveEntry // nil for implicit directories
+	children map[string]*archiveNode
+}
+
+func (n *archiveNode) isDir() bool {
+	return n.entry == nil || n.entry.fi.IsDir()
+}
+
+// UploadFileFromArchive reads the tar or zip archive at archivePath and
+// uploads its contents as a tree of file and directory schema blobs,
+// mirroring the archive's internal structure, without ever extracting
+// it to disk. It returns the PutResult of the synthesized root
+// directory schema blob.
+func (up *Uploader) UploadFileFromArchive(ctx context.Context, archivePath string) (*client.PutResult, error) {
+	root := &archiveNode{children: map[string]*archiveNode{}}
+	if err := walkArchive(archivePath, func(ae *archiveEntry) error {
+		return root.addEntry(ae)
+	}); err != nil {
+		return nil, err
+	}
+	return up.uploadArchiveNode(ctx, root)
+}
+
+// walkArchive opens archivePath and calls fn once per entry, in the
+// order the underlying archive format returns them. The archive type is
+// determined from the filename: ".zip" is read as a zip file; anything
+// else is read as a tar file, transparently gunzipped if it ends in
+// ".gz" or ".tgz".
+func walkArchive(archivePath string, fn func(*archiveEntry) error) error {
+	if strings.EqualFold(path.Ext(archivePath), ".zip") {
+		return walkZipArchive(archivePath, fn)
+	}
+	return walkTarArchive(archivePath, fn)
+}
+
+func walkZipArchive(archivePath string, fn func(*archiveEntry) error) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("opening zip archive %v: %v", archivePath, err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		f := f
+		ae := &archiveEntry{
+			path: path.Clean(f.Name),
+			fi:   f.FileInfo(),
+		}
+		if ae.fi.Mode()&os.ModeSymlink != 0 {
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("reading symlink %v in %v: %v", f.Name, archivePath, err)
+			}
+			target, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("reading symlink %v in %v: %v", f.Name, archivePath, err)
+			}
+			ae.link = string(target)
+		} else if !ae.fi.IsDir() {
+			ae.open = func() (io.ReadCloser, error) { return f.Open() }
+		}
+		if err := fn(ae); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTarArchive(archivePath string, fn func(*archiveEntry) error) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(strings.ToLower(archivePath), ".gz") || strings.HasSuffix(strings.ToLower(archivePath), ".tgz") {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("opening gzipped tar archive %v: %v", archivePath, err)
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar archive %v: %v", archivePath, err)
+		}
+		ae := &archiveEntry{
+			path: path.Clean(hdr.Name),
+			fi:   hdr.FileInfo(),
+		}
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			ae.link = hdr.Linkname
+		case tar.TypeReg:
+			// tr is only valid for the current entry; capture what
+			// we need to stream it now, rather than a reference to
+			// tr, which the loop is about to advance.
+			entryReader := io.LimitReader(tr, hdr.Size)
+			ae.open = func() (io.ReadCloser, error) { return io.NopCloser(entryReader), nil }
+		case tar.TypeDir:
+			// nothing more to do; ae.fi.IsDir() already true.
+		default:
+			return fmt.Errorf("pk-put: unsupported entry type %v for %v in %v", hdr.Typeflag, hdr.Name, archivePath)
+		}
+		if err := fn(ae); err != nil {
+			return err
+		}
+		if ae.open != nil {
+			// The tar reader can't skip ahead on its own once we've
+			// handed out a reader for this entry's contents; make
+			// sure fn actually consumed it before advancing.
+			io.Copy(io.Discard, tr)
+		}
+	}
+}
+
+// addEntry inserts ae into the tree rooted at n, creating any
+// intermediate implicit directories along the way.
+func (n *archiveNode) addEntry(ae *archiveEntry) error {
+	parts := strings.Split(ae.path, "/")
+	cur := n
+	for _, part := range parts[:len(parts)-1] {
+		if part == "" || part == "." {
+			continue
+		}
+		child, ok := cur.children[part]
+		if !ok {
+			child = &archiveNode{children: map[string]*archiveNode{}}
+			cur.children[part] = child
+		}
+		cur = child
+	}
+	name := parts[len(parts)-1]
+	if name == "" || name == "." {
+		// The entry is the archive root itself; nothing to record.
+		return nil
+	}
+	child, ok := cur.children[name]
+	if !ok {
+		child = &archiveNode{children: map[string]*archiveNode{}}
+		cur.children[name] = child
+	}
+	child.entry = ae
+	return nil
+}
+
+// uploadArchiveNode uploads n (and, recursively, all its children),
+// returning the PutResult of n's schema blob.
+func (up *Uploader) uploadArchiveNode(ctx context.Context, n *archiveNode) (*client.PutResult, error) {
+	if !n.isDir() {
+		return up.uploadArchiveFile(ctx, n.entry)
+	}
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	members := make([]blob.Ref, 0, len(names))
+	for _, name := range names {
+		pr, err := up.uploadArchiveNode(ctx, n.children[name])
+		if err != nil {
+			return nil, fmt.Errorf("uploading %v: %v", name, err)
+		}
+		members = append(members, pr.BlobRef)
+	}
+
+	ss := schema.NewStaticSet()
+	subsets := ss.SetStaticSetMembers(members)
+	for _, v := range subsets {
+		if _, err := up.UploadBlob(ctx, v); err != nil {
+			return nil, err
+		}
+	}
+	sspr, err := up.UploadBlob(ctx, ss.Blob())
+	if err != nil {
+		return nil, err
+	}
+
+	var bb *schema.Builder
+	if n.entry != nil {
+		bb = schema.NewCommonFileMap(n.entry.path, n.entry.fi)
+		if !up.fileOpts.captureMetadata {
+			bb.UnsetUnixMetadata()
+		}
+	} else {
+		bb = schema.NewDirMap("")
+	}
+	bb.PopulateDirectoryMap(sspr.BlobRef)
+	return up.UploadBlob(ctx, bb)
+}
+
+// uploadArchiveFile uploads the contents of a regular-file or symlink
+// archive entry, streaming its content straight from the archive
+// reader rather than buffering it.
+func (up *Uploader) uploadArchiveFile(ctx context.Context, ae *archiveEntry) (*client.PutResult, error) {
+	bb := schema.NewCommonFileMap(ae.path, ae.fi)
+	if !up.fileOpts.captureMetadata {
+		bb.UnsetUnixMetadata()
+	}
+	if ae.fi.Mode()&os.ModeSymlink != 0 {
+		bb.SetSymlinkTarget(ae.link)
+		return up.UploadBlob(ctx, bb)
+	}
+	bb.SetType(schema.TypeFile)
+	rc, err := ae.open()
+	if err != nil {
+		return nil, fmt.Errorf("opening %v: %v", ae.path, err)
+	}
+	defer rc.Close()
+	br, err := schema.WriteFileMap(ctx, up.noStatReceiver(up.statReceiver(nil)), bb, rc)
+	if err != nil {
+		return nil, fmt.Errorf("writing %v: %v", ae.path, err)
+	}
+	json, err := bb.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return &client.PutResult{BlobRef: br, Size: uint32(len(json))}, nil
+}
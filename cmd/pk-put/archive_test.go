@@ -0,0 +1,170 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func writeTestTar(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	defer tw.Close()
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"a.txt", "hello"},
+		{"sub/b.txt", "world"},
+	}
+	for _, tf := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: tf.name,
+			Mode: 0644,
+			Size: int64(len(tf.content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(tf.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWalkTarArchive(t *testing.T) {
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "test.tar")
+	writeTestTar(t, tarPath)
+
+	var got []string
+	err := walkTarArchive(tarPath, func(ae *archiveEntry) error {
+		got = append(got, ae.path)
+		if ae.open != nil {
+			rc, err := ae.open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			if _, err := io.ReadAll(rc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"a.txt", "sub/b.txt"}
+	if !equalStrings(got, want) {
+		t.Errorf("walkTarArchive entries = %v; want %v", got, want)
+	}
+}
+
+func TestWalkZipArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "test.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"a.txt":     "hello",
+		"sub/b.txt": "world",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var got []string
+	err = walkZipArchive(zipPath, func(ae *archiveEntry) error {
+		got = append(got, ae.path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"a.txt", "sub/b.txt"}
+	if !equalStrings(got, want) {
+		t.Errorf("walkZipArchive entries = %v; want %v", got, want)
+	}
+}
+
+func TestArchiveNodeAddEntry(t *testing.T) {
+	root := &archiveNode{children: map[string]*archiveNode{}}
+	for _, p := range []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt"} {
+		if err := root.addEntry(&archiveEntry{path: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok := root.children["a.txt"]; !ok {
+		t.Error("expected root child a.txt")
+	}
+	sub, ok := root.children["sub"]
+	if !ok {
+		t.Fatal("expected root child sub")
+	}
+	if sub.entry != nil {
+		t.Error("sub should be an implicit directory (no entry)")
+	}
+	if _, ok := sub.children["b.txt"]; !ok {
+		t.Error("expected sub/b.txt")
+	}
+	deeper, ok := sub.children["deeper"]
+	if !ok {
+		t.Fatal("expected sub/deeper")
+	}
+	if _, ok := deeper.children["c.txt"]; !ok {
+		t.Error("expected sub/deeper/c.txt")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/search"
+)
+
+type deleteQueryCmd struct {
+	confirm  bool
+	max      int
+	allowMax bool
+}
+
+func init() {
+	cmdmain.RegisterMode("delete-query", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(deleteQueryCmd)
+		flags.BoolVar(&cmd.confirm, "confirm", false, "Actually upload delete claims. Without this, the matches are only reported.")
+		flags.IntVar(&cmd.max, "max", 50, "Maximum number of matches to delete. Deletion is refused if the query matches more than this, unless -allow-more is also given.")
+		flags.BoolVar(&cmd.allowMax, "allow-more", false, "Allow deleting more than -max matches.")
+		return cmd
+	})
+}
+
+func (c *deleteQueryCmd) Describe() string {
+	return "Delete every permanode matched by a search query."
+}
+
+func (c *deleteQueryCmd) Usage() {
+	cmdmain.Errorf("Usage: pk-put [globalopts] delete-query [--confirm] [--max=n] <expr or Constraint JSON>")
+}
+
+func (c *deleteQueryCmd) Examples() []string {
+	return []string{
+		`"tag:obsolete"          # reports what tag:obsolete would delete`,
+		`--confirm "tag:obsolete" # actually deletes them`,
+	}
+}
+
+func (c *deleteQueryCmd) RunCommand(args []string) error {
+	if len(args) != 1 {
+		return cmdmain.UsageError("requires a search expression or Constraint JSON")
+	}
+	up := getUploader()
+	res, err := up.Query(ctxbg, &search.SearchQuery{Expression: args[0], Limit: -1})
+	if err != nil {
+		return fmt.Errorf("resolving query: %v", err)
+	}
+	if len(res.Blobs) == 0 {
+		fmt.Fprintln(cmdmain.Stdout, "Query matched nothing; nothing to delete.")
+		return nil
+	}
+	if len(res.Blobs) > c.max && !c.allowMax {
+		return fmt.Errorf("query matched %d permanodes, more than -max=%d; refusing to delete. Pass -allow-more to override.", len(res.Blobs), c.max)
+	}
+
+	if !c.confirm {
+		fmt.Fprintf(cmdmain.Stdout, "Dry run: query matched %d permanode(s); pass -confirm to delete them:\n", len(res.Blobs))
+		for _, sb := range res.Blobs {
+			fmt.Fprintln(cmdmain.Stdout, sb.Blob)
+		}
+		return nil
+	}
+
+	var failed []blob.Ref
+	for _, sb := range res.Blobs {
+		bb := schema.NewDeleteClaim(sb.Blob)
+		put, err := up.UploadAndSignBlob(ctxbg, bb)
+		if err := handleResult(bb.Type(), put, err); err != nil {
+			cmdmain.Errorf("delete-query: failed to delete %v: %v", sb.Blob, err)
+			failed = append(failed, sb.Blob)
+		}
+	}
+	fmt.Fprintf(cmdmain.Stdout, "Deleted %d of %d matched permanode(s).\n", len(res.Blobs)-len(failed), len(res.Blobs))
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d permanode(s); see errors above", len(failed))
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// excludeMatcher decides whether a path, relative to some walk root,
+// should be excluded from an upload, using .gitignore-style patterns:
+// "*" and "?" match within a path component, "**" matches across
+// components, a trailing "/" restricts a pattern to directories, and a
+// leading "!" re-includes a path that an earlier pattern excluded.
+//
+// As in .gitignore, patterns are matched in order and the last matching
+// pattern wins, so a later "!" pattern can carve out exceptions to an
+// earlier exclusion.
+type excludeMatcher struct {
+	patterns []excludePattern
+}
+
+type excludePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// newExcludeMatcher compiles patterns (as found in --exclude or an
+// --exclude-from file) into an excludeMatcher. Blank patterns and those
+// starting with "#" are ignored, as in .gitignore.
+func newExcludeMatcher(patterns []string) (*excludeMatcher, error) {
+	m := &excludeMatcher{}
+	for _, raw := range patterns {
+		p := strings.TrimSpace(raw)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		pat, err := compileExcludePattern(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %v", raw, err)
+		}
+		m.patterns = append(m.patterns, pat)
+	}
+	return m, nil
+}
+
+// readExcludeFile reads newline-separated patterns from filename, in the
+// same format accepted by newExcludeMatcher.
+func readExcludeFile(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		patterns = append(patterns, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// match reports whether relPath (slash-separated, relative to the walk
+// root) should be excluded. isDir indicates whether relPath is a
+// directory, for matching directory-only ("trailing slash") patterns.
+func (m *excludeMatcher) match(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	excluded := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// compileExcludePattern compiles a single .gitignore-style pattern into
+// a regular expression matched against a slash-separated relative path.
+func compileExcludePattern(raw string) (excludePattern, error) {
+	p := raw
+	var negate bool
+	if strings.HasPrefix(p, "!") {
+		negate = true
+		p = p[1:]
+	}
+	var dirOnly bool
+	if strings.HasSuffix(p, "/") {
+		dirOnly = true
+		p = strings.TrimSuffix(p, "/")
+	}
+	if p == "" {
+		return excludePattern{}, fmt.Errorf("empty pattern")
+	}
+	anchored := strings.HasPrefix(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if strings.Contains(p, "/") {
+		anchored = true
+	}
+	body := excludePatternToRegexpBody(p)
+	var full string
+	if anchored {
+		full = "^" + body + "$"
+	} else {
+		// Unanchored patterns (no slash but a trailing one) may match
+		// at any depth, as if "**/" had been prepended.
+		full = "^(?:.*/)?" + body + "$"
+	}
+	re, err := regexp.Compile(full)
+	if err != nil {
+		return excludePattern{}, err
+	}
+	return excludePattern{re: re, negate: negate, dirOnly: dirOnly}, nil
+}
+
+// excludePatternToRegexpBody translates the glob syntax of a single
+// .gitignore-style pattern (without its leading/trailing slash) into the
+// body of a regular expression.
+func excludePatternToRegexpBody(p string) string {
+	var b strings.Builder
+	n := len(p)
+	for i := 0; i < n; i++ {
+		switch c := p[i]; {
+		case c == '*' && i+1 < n && p[i+1] == '*':
+			if i+2 < n && p[i+2] == '/' {
+				b.WriteString("(?:.*/)?")
+				i += 2 // loop's i++ skips the consumed "/"
+			} else {
+				b.WriteString(".*")
+				i++
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			j := i + 1
+			for j < n && p[j] != ']' {
+				j++
+			}
+			if j < n {
+				b.WriteString("[" + p[i+1:j] + "]")
+				i = j
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// vcsExcludePatterns are the directory-only patterns added by
+// --exclude-vcs.
+var vcsExcludePatterns = []string{".git/", ".hg/", ".svn/"}
+
+// relExcludePath returns fullPath relative to root, slash-separated, for
+// matching against an excludeMatcher. If fullPath can't be made relative
+// to root, fullPath itself (also slash-separated) is used, so patterns
+// can still match by base name or path component.
+func relExcludePath(root, fullPath string) string {
+	if root != "" {
+		if rel, err := filepath.Rel(root, fullPath); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(fullPath)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func TestExcludeMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{
+			name:     "no patterns",
+			patterns: nil,
+			path:     "node_modules/foo.js",
+			want:     false,
+		},
+		{
+			name:     "basename glob",
+			patterns: []string{"*.log"},
+			path:     "logs/server.log",
+			want:     true,
+		},
+		{
+			name:     "basename glob no match",
+			patterns: []string{"*.log"},
+			path:     "logs/server.txt",
+			want:     false,
+		},
+		{
+			name:     "directory-only pattern matches directory",
+			patterns: []string{"node_modules/"},
+			path:     "src/node_modules",
+			isDir:    true,
+			want:     true,
+		},
+		{
+			name:     "directory-only pattern does not match file",
+			patterns: []string{"node_modules/"},
+			path:     "src/node_modules",
+			isDir:    false,
+			want:     false,
+		},
+		{
+			name:     "anchored pattern only matches from root",
+			patterns: []string{"/build"},
+			path:     "vendor/build",
+			isDir:    true,
+			want:     false,
+		},
+		{
+			name:     "anchored pattern matches at root",
+			patterns: []string{"/build"},
+			path:     "build",
+			isDir:    true,
+			want:     true,
+		},
+		{
+			name:     "double star matches any depth",
+			patterns: []string{"**/node_modules"},
+			path:     "a/b/c/node_modules",
+			isDir:    true,
+			want:     true,
+		},
+		{
+			name:     "double star matches everything under a directory",
+			patterns: []string{"cache/**"},
+			path:     "cache/a/b/thumb.png",
+			want:     true,
+		},
+		{
+			name:     "negated pattern re-includes",
+			patterns: []string{"*.o", "!important.o"},
+			path:     "important.o",
+			want:     false,
+		},
+		{
+			name:     "later pattern wins",
+			patterns: []string{"!important.o", "*.o"},
+			path:     "important.o",
+			want:     true,
+		},
+		{
+			name:     "comments and blank lines ignored",
+			patterns: []string{"# a comment", "", "*.tmp"},
+			path:     "scratch.tmp",
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newExcludeMatcher(tt.patterns)
+			if err != nil {
+				t.Fatalf("newExcludeMatcher: %v", err)
+			}
+			if got := m.match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("match(%q, isDir=%v) = %v; want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeMatcherNil(t *testing.T) {
+	var m *excludeMatcher
+	if m.match("anything", false) {
+		t.Error("nil *excludeMatcher should never match")
+	}
+}
+
+func TestVCSExcludePatterns(t *testing.T) {
+	m, err := newExcludeMatcher(vcsExcludePatterns)
+	if err != nil {
+		t.Fatalf("newExcludeMatcher(vcsExcludePatterns): %v", err)
+	}
+	if !m.match(".git", true) {
+		t.Error(".git directory should be excluded by --exclude-vcs patterns")
+	}
+	if m.match("README.git", true) {
+		t.Error("README.git should not be excluded by --exclude-vcs patterns")
+	}
+}
+
+func TestFileOptionsIsExcluded(t *testing.T) {
+	m, err := newExcludeMatcher([]string{"*.log"})
+	if err != nil {
+		t.Fatalf("newExcludeMatcher: %v", err)
+	}
+	o := &fileOptions{exclude: m, excludeRoot: "/home/user"}
+	if !o.isExcluded("/home/user/logs/server.log", false) {
+		t.Error("expected server.log to be excluded")
+	}
+	if o.isExcluded("/home/user/logs/server.txt", false) {
+		t.Error("did not expect server.txt to be excluded")
+	}
+
+	var nilOpts *fileOptions
+	if nilOpts.isExcluded("/home/user/logs/server.log", false) {
+		t.Error("nil *fileOptions should never report excluded")
+	}
+}
@@ -51,18 +51,36 @@ type fileCmd struct {
 	makePermanode     bool // make new, unique permanode of the root (dir or file)
 	filePermanodes    bool // make planned permanodes for each file (based on their digest)
 	vivify            bool
-	exifTime          bool // use metadata (such as in EXIF) to find the creation time of the file
-	capCtime          bool // use mtime as creation time of the file, if it would be bigger than modification time
-	diskUsage         bool // show "du" disk usage only (dry run mode), don't actually upload
-	argsFromInput     bool // Android mode: filenames piped into stdin, one at a time.
-	deleteAfterUpload bool // with fileNodes, deletes the input file once uploaded
-	contentsOnly      bool // do not store any of the file's attributes, only its contents.
+	exifTime          bool   // use metadata (such as in EXIF) to find the creation time of the file
+	capCtime          bool   // use mtime as creation time of the file, if it would be bigger than modification time
+	diskUsage         bool   // show "du" disk usage only (dry run mode), don't actually upload
+	argsFromInput     bool   // Android mode: filenames piped into stdin, one at a time.
+	deleteAfterUpload bool   // with fileNodes, deletes the input file once uploaded
+	contentsOnly      bool   // do not store any of the file's attributes, only its contents.
+	fromArchive       string // path to a tar or zip archive to upload the contents of, instead of files/directories on disk
+	fromURL           string // URL to fetch and upload the contents of, instead of files/directories on disk
 
 	statcache bool
 
 	// Go into in-memory stats mode only; doesn't actually upload.
 	memstats bool
 	histo    string // optional histogram output filename
+
+	tagFromPath       bool // derive tags from each file's directory components
+	tagFromPathLevels int  // if positive, only the deepest N directory components are used
+
+	skipDerived string // comma-separated shell patterns matching derived files (thumbnails, sidecars) to skip
+
+	exclude     string // comma-separated .gitignore-style patterns to exclude from the walk
+	excludeFrom string // file of newline-separated exclude patterns, one per line
+	excludeVCS  bool   // convenience for --exclude='.git/,.hg/,.svn/'
+
+	postHook        string        // shell command to run after a successful upload, with created blobrefs on stdin as JSON
+	postHookTimeout time.Duration // how long to let postHook run before killing it
+
+	resume bool // re-drive an interrupted upload of the same file(s), skipping chunks and files already uploaded
+
+	captureMetadata bool // record POSIX permission bits and owner/group in the file schema
 }
 
 var flagUseSQLiteChildCache bool // Use sqlite for the statcache and havecache.
@@ -85,9 +103,28 @@ func init() {
 		flags.BoolVar(&cmd.exifTime, "exiftime", false, "Try to use metadata (such as EXIF) to get a stable creation time. If found, used as the replacement for the modtime. Mainly useful with vivify or filenodes.")
 		flags.StringVar(&cmd.title, "title", "", "Optional title attribute to set on permanode when using -permanode.")
 		flags.StringVar(&cmd.tag, "tag", "", "Optional tag(s) to set on permanode when using -permanode or -filenodes. Single value or comma separated.")
+		flags.BoolVar(&cmd.tagFromPath, "tag-from-path", false, "With -filenodes, additionally tag each file's permanode with the names of its containing directories, relative to the directory/directories given on the command line (e.g. a file at 2023/Italy/Rome/photo.jpg gets tags \"2023\", \"Italy\", and \"Rome\").")
+		flags.IntVar(&cmd.tagFromPathLevels, "tag-from-path-levels", 0, "With -tag-from-path, only tag with the deepest N directory components instead of all of them. 0 means no limit.")
+
+		flags.StringVar(&cmd.skipDerived, "skip-derived", "", "Comma-separated shell patterns (as in filepath.Match) matched against each file's base name; matching files are treated as already-derived (e.g. auto-generated thumbnails or sidecars) and are skipped, instead of being uploaded and re-permanoded on every import. Example: --skip-derived='*_thumb.*,*.thumbnail'")
+
+		flags.StringVar(&cmd.exclude, "exclude", "", "Comma-separated .gitignore-style patterns matched against each file or directory's path, relative to the file/directory argument being walked; matching paths (and, for a matching directory, everything under it) are skipped and never hashed or uploaded. Supports '**' for recursive matches and a leading '!' to re-include a path an earlier pattern excluded. Example: --exclude='node_modules/,*.o,!important.o'")
+		flags.StringVar(&cmd.excludeFrom, "exclude-from", "", "Path to a file of newline-separated --exclude patterns, one per line; blank lines and lines starting with '#' are ignored, as in a .gitignore file.")
+		flags.BoolVar(&cmd.excludeVCS, "exclude-vcs", false, "Exclude .git, .hg, and .svn directories, as a shorthand for --exclude='.git/,.hg/,.svn/'.")
+
+		flags.StringVar(&cmd.postHook, "post-hook", "", "Shell command to run after a successful upload (never run if the upload failed), for chaining into other automation. The blobrefs and permanode refs created by the upload are written to the command's stdin as a JSON object: {\"blobRefs\": [\"sha224-...\", ...]}.")
+		flags.DurationVar(&cmd.postHookTimeout, "post-hook-timeout", 30*time.Second, "How long to let --post-hook run before killing it and failing the command.")
+
+		flags.BoolVar(&cmd.resume, "resume", false, "Re-drive an interrupted upload of the same file(s). Since files are split into content-addressed chunks, and the stat and have caches (see -statcache and -havecache) already remember which files and chunks this client has confirmed the server has, re-running with the same arguments only rechunks and re-uploads what's missing. Requires both caches to be enabled. If a file's size or modification time changed since the interrupted run, it's treated as new and rechunked from scratch, rather than resumed.")
+
+		flags.BoolVar(&cmd.captureMetadata, "capture-metadata", true, "Record each file's POSIX permission bits (including setuid, setgid, and sticky) and owner/group, by name and numeric id, in its file schema, so pk-get and pk-mount can restore them. Symlink targets are always recorded regardless of this flag, since they're required to recreate the symlink.")
 
 		flags.BoolVar(&cmd.diskUsage, "du", false, "Dry run mode: only show disk usage information, without upload or statting dest. Used for testing ignoredFiles configs, mostly.")
 
+		flags.StringVar(&cmd.fromArchive, "from-archive", "", "Path to a tar, tar.gz, or zip archive whose contents to upload as a directory tree, mirroring the archive's structure, without extracting it to disk. Excludes any file/directory arguments.")
+
+		flags.StringVar(&cmd.fromURL, "from-url", "", "URL to fetch and upload as a file, instead of a file/directory argument. Implies --permanode, and records the source URL, fetch time, and HTTP content type as attributes on it. Excludes any file/directory arguments.")
+
 		if debug, _ := strconv.ParseBool(os.Getenv("CAMLI_DEBUG")); debug {
 			flags.BoolVar(&cmd.statcache, "statcache", true, "(debug flag) Use the stat cache, assuming unchanged files already uploaded in the past are still there. Fast, but potentially dangerous.")
 			flags.BoolVar(&cmd.memstats, "debug-memstats", false, "(debug flag) Enter debug in-memory mode; collecting stats only. Doesn't upload anything.")
@@ -122,6 +159,8 @@ func (c *fileCmd) Examples() []string {
 		"[opts] <file(s)/director(ies)",
 		"--permanode --title='Homedir backup' --tag=backup,homedir $HOME",
 		"--filenodes /mnt/camera/DCIM",
+		"--from-archive backup.tar.gz",
+		"--from-url https://example.com/some/file.pdf",
 	}
 }
 
@@ -146,6 +185,26 @@ func (c *fileCmd) RunCommand(args []string) error {
 	if c.filePermanodes && c.contentsOnly {
 		return cmdmain.UsageError("--contents_only and --filenodes are exclusive. Use --permanode instead.")
 	}
+	if c.tagFromPath && !c.filePermanodes {
+		return cmdmain.UsageError("Can't use --tag-from-path without --filenodes")
+	}
+	if c.tagFromPathLevels < 0 {
+		return cmdmain.UsageError("--tag-from-path-levels can't be negative")
+	}
+	if c.fromURL != "" {
+		if c.vivify || c.fromArchive != "" || c.filePermanodes || c.argsFromInput {
+			return cmdmain.UsageError("--from-url excludes --vivify, --from-archive, --filenodes, and --stdinargs")
+		}
+		c.makePermanode = true
+	}
+	if c.resume {
+		if !c.statcache {
+			return cmdmain.UsageError("--resume requires the stat cache; can't be used with --statcache=false")
+		}
+		if !flagHaveCache {
+			return cmdmain.UsageError("--resume requires the have cache; can't be used with --havecache=false")
+		}
+	}
 
 	up := getUploader()
 	if c.memstats {
@@ -162,22 +221,55 @@ func (c *fileCmd) RunCommand(args []string) error {
 		}
 	}
 	up.fileOpts = &fileOptions{
-		permanode:    c.filePermanodes,
-		tag:          c.tag,
-		vivify:       c.vivify,
-		exifTime:     c.exifTime,
-		capCtime:     c.capCtime,
-		contentsOnly: c.contentsOnly,
+		permanode:         c.filePermanodes,
+		tag:               c.tag,
+		vivify:            c.vivify,
+		exifTime:          c.exifTime,
+		capCtime:          c.capCtime,
+		contentsOnly:      c.contentsOnly,
+		captureMetadata:   c.captureMetadata,
+		tagFromPath:       c.tagFromPath,
+		tagFromPathLevels: c.tagFromPathLevels,
+	}
+	if c.skipDerived != "" {
+		up.fileOpts.skipDerived = strings.Split(c.skipDerived, ",")
+	}
+	if c.exclude != "" || c.excludeFrom != "" || c.excludeVCS {
+		var patterns []string
+		if c.exclude != "" {
+			patterns = append(patterns, strings.Split(c.exclude, ",")...)
+		}
+		if c.excludeFrom != "" {
+			fromFile, err := readExcludeFile(c.excludeFrom)
+			if err != nil {
+				return fmt.Errorf("--exclude-from: %v", err)
+			}
+			patterns = append(patterns, fromFile...)
+		}
+		if c.excludeVCS {
+			patterns = append(patterns, vcsExcludePatterns...)
+		}
+		m, err := newExcludeMatcher(patterns)
+		if err != nil {
+			return err
+		}
+		up.fileOpts.exclude = m
 	}
 
 	var (
-		permaNode *client.PutResult
-		lastPut   *client.PutResult
-		err       error
+		permaNode   *client.PutResult
+		lastPut     *client.PutResult
+		err         error
+		fetchTime   time.Time // set when --from-url is used
+		contentType string    // set when --from-url is used
+
+		// createdRefs collects every blob successfully created by this
+		// invocation, for --post-hook.
+		createdRefs []blob.Ref
 	)
 	if c.makePermanode {
-		if len(args) != 1 {
-			return fmt.Errorf("The --permanode flag can only be used with exactly one file or directory argument")
+		if len(args) != 1 && c.fromArchive == "" && c.fromURL == "" {
+			return fmt.Errorf("The --permanode flag can only be used with exactly one file or directory argument, or with --from-archive or --from-url")
 		}
 		permaNode, err = up.UploadNewPermanode(ctxbg)
 		if err != nil {
@@ -206,7 +298,27 @@ func (c *fileCmd) RunCommand(args []string) error {
 		handleResult("tree-upload", pr, err)
 		return nil
 	}
-	if c.argsFromInput {
+	switch {
+	case c.fromArchive != "":
+		if len(args) != 0 {
+			return cmdmain.UsageError("--from-archive doesn't take any file or directory arguments")
+		}
+		lastPut, err = up.UploadFileFromArchive(ctxbg, c.fromArchive)
+		if handleResult("archive", lastPut, err) != nil {
+			return err
+		}
+		createdRefs = append(createdRefs, lastPut.BlobRef)
+	case c.fromURL != "":
+		if len(args) != 0 {
+			return cmdmain.UsageError("--from-url doesn't take any file or directory arguments")
+		}
+		fetchTime = time.Now()
+		lastPut, contentType, err = up.UploadFileFromURL(ctxbg, c.fromURL)
+		if handleResult("url", lastPut, err) != nil {
+			return err
+		}
+		createdRefs = append(createdRefs, lastPut.BlobRef)
+	case c.argsFromInput:
 		if len(args) > 0 {
 			return errors.New("args not supported with -argsfrominput")
 		}
@@ -226,45 +338,54 @@ func (c *fileCmd) RunCommand(args []string) error {
 				log.Fatal(err)
 			}
 		}
-	}
-
-	if len(args) == 0 {
-		return cmdmain.UsageError("No files or directories given.")
-	}
-	if up.statCache != nil {
-		defer up.statCache.Close()
-	}
-	for _, filename := range args {
-		fi, err := os.Stat(filename)
-		if err != nil {
-			return err
+	default:
+		if len(args) == 0 {
+			return cmdmain.UsageError("No files or directories given.")
 		}
-		// Skip ignored files or base directories.  Failing to skip the
-		// latter results in a panic.
-		if up.Client.IsIgnoredFile(filename) {
-			cmdmain.Logf("Client configured to ignore %s; skipping.", filename)
-			continue
+		if up.statCache != nil {
+			defer up.statCache.Close()
 		}
-		if fi.IsDir() {
-			if up.fileOpts.wantVivify() {
-				log.Printf("Directories not supported in vivify mode; skipping %v\n", filename)
+		for _, filename := range args {
+			fi, err := os.Stat(filename)
+			if err != nil {
+				return err
+			}
+			// Skip ignored files or base directories.  Failing to skip the
+			// latter results in a panic.
+			if up.Client.IsIgnoredFile(filename) {
+				cmdmain.Logf("Client configured to ignore %s; skipping.", filename)
 				continue
 			}
-			t := up.NewTreeUpload(filename)
-			t.Start()
-			lastPut, err = t.Wait()
-		} else {
-			lastPut, err = up.UploadFile(ctxbg, filename)
-			if err == nil && c.deleteAfterUpload {
-				if err := os.Remove(filename); err != nil {
-					log.Printf("Error deleting %v: %v", filename, err)
-				} else {
-					cmdmain.Logf("Deleted %v", filename)
+			if up.fileOpts.isDerived(filename) {
+				cmdmain.Logf("%s matches --skip-derived; skipping.", filename)
+				continue
+			}
+			if fi.IsDir() {
+				if up.fileOpts.wantVivify() {
+					log.Printf("Directories not supported in vivify mode; skipping %v\n", filename)
+					continue
+				}
+				up.fileOpts.tagFromPathRoot = filename
+				up.fileOpts.excludeRoot = filename
+				t := up.NewTreeUpload(filename)
+				t.Start()
+				lastPut, err = t.Wait()
+			} else {
+				up.fileOpts.tagFromPathRoot = filepath.Dir(filename)
+				up.fileOpts.excludeRoot = filepath.Dir(filename)
+				lastPut, err = up.UploadFile(ctxbg, filename)
+				if err == nil && c.deleteAfterUpload {
+					if err := os.Remove(filename); err != nil {
+						log.Printf("Error deleting %v: %v", filename, err)
+					} else {
+						cmdmain.Logf("Deleted %v", filename)
+					}
 				}
 			}
-		}
-		if handleResult("file", lastPut, err) != nil {
-			return err
+			if handleResult("file", lastPut, err, filename) != nil {
+				return err
+			}
+			createdRefs = append(createdRefs, lastPut.BlobRef)
 		}
 	}
 
@@ -285,7 +406,21 @@ func (c *fileCmd) RunCommand(args []string) error {
 				handleResult("claim-permanode-tag", put, err)
 			}
 		}
+		if c.fromURL != "" {
+			put, err := up.UploadAndSignBlob(ctxbg, schema.NewSetAttributeClaim(permaNode.BlobRef, "url", c.fromURL))
+			handleResult("claim-permanode-url", put, err)
+			put, err = up.UploadAndSignBlob(ctxbg, schema.NewSetAttributeClaim(permaNode.BlobRef, "fetchTime", fetchTime.UTC().Format(time.RFC3339)))
+			handleResult("claim-permanode-fetchtime", put, err)
+			if contentType != "" {
+				put, err := up.UploadAndSignBlob(ctxbg, schema.NewSetAttributeClaim(permaNode.BlobRef, "contentType", contentType))
+				handleResult("claim-permanode-contenttype", put, err)
+			}
+		}
 		handleResult("permanode", permaNode, nil)
+		createdRefs = append(createdRefs, permaNode.BlobRef)
+	}
+	if err := runPostHook(ctxbg, c.postHook, createdRefs, c.postHookTimeout); err != nil {
+		return err
 	}
 	return nil
 }
@@ -378,6 +513,9 @@ func (up *Uploader) uploadNode(ctx context.Context, n *node) (*client.PutResult,
 		return up.uploadNodeRegularFile(ctx, n)
 	}
 	bb := schema.NewCommonFileMap(n.fullPath, fi)
+	if !up.fileOpts.captureMetadata {
+		bb.UnsetUnixMetadata()
+	}
 	switch {
 	default:
 		return nil, fmt.Errorf("pk-put.files: unsupported file type %v for file %v", mode, n.fullPath)
@@ -560,6 +698,9 @@ func (up *Uploader) uploadNodeRegularFile(ctx context.Context, n *node) (*client
 		filebb = schema.NewFileMap("")
 	} else {
 		filebb = schema.NewCommonFileMap(n.fullPath, n.fi)
+		if !up.fileOpts.captureMetadata {
+			filebb.UnsetUnixMetadata()
+		}
 	}
 	filebb.SetType("file")
 
@@ -675,7 +816,7 @@ func (up *Uploader) uploadNodeRegularFile(ctx context.Context, n *node) (*client
 		if !ok {
 			return nil, fmt.Errorf("couldn't get modtime for file %v", n.fullPath)
 		}
-		err = up.uploadFilePermanode(ctx, wholeRef[0].String(), br, claimTime)
+		err = up.uploadFilePermanode(ctx, wholeRef[0].String(), br, claimTime, n.fullPath)
 		if err != nil {
 			return nil, fmt.Errorf("Error uploading permanode for node %v: %v", n, err)
 		}
@@ -693,8 +834,9 @@ func (up *Uploader) uploadNodeRegularFile(ctx context.Context, n *node) (*client
 
 // uploadFilePermanode creates and uploads the planned permanode (with sum as a
 // fixed key) associated with the file blobref fileRef.
-// It also sets the optional tags for this permanode.
-func (up *Uploader) uploadFilePermanode(ctx context.Context, sum string, fileRef blob.Ref, claimTime time.Time) error {
+// It also sets the optional tags for this permanode, including any derived
+// from fullPath's directory components if --tag-from-path is in use.
+func (up *Uploader) uploadFilePermanode(ctx context.Context, sum string, fileRef blob.Ref, claimTime time.Time, fullPath string) error {
 	if sum == "" {
 		panic("invalid empty string for sum")
 	}
@@ -724,7 +866,7 @@ func (up *Uploader) uploadFilePermanode(ctx context.Context, sum string, fileRef
 	}
 
 	handleResult("node-permanode-contentattr", put, nil)
-	if tags := up.fileOpts.tags(); len(tags) > 0 {
+	if tags := dedupTags(append(up.fileOpts.tags(), up.fileOpts.tagsFromPath(fullPath)...)); len(tags) > 0 {
 		errch := make(chan error)
 		for _, tag := range tags {
 			go func(tag string) {
@@ -942,12 +1084,18 @@ func (t *TreeUpload) statPath(fullPath string, fi os.FileInfo) (nod *node, err e
 	if t.up.Client.IsIgnoredFile(fullPath) {
 		return nil, nil
 	}
+	if t.up.fileOpts.isDerived(fullPath) {
+		return nil, nil
+	}
 	if fi == nil {
 		fi, err = t.up.lstat(fullPath)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if t.up.fileOpts.isExcluded(fullPath, fi.IsDir()) {
+		return nil, nil
+	}
 	n := &node{
 		tu:       t,
 		fullPath: fullPath,
@@ -1174,6 +1322,24 @@ func (t *TreeUpload) Wait() (*client.PutResult, error) {
 	return t.finalPutRes, t.err
 }
 
+// dedupTags returns tags with duplicate values removed, preserving the
+// order of first occurrence.
+func dedupTags(tags []string) []string {
+	if len(tags) == 0 {
+		return tags
+	}
+	seen := make(map[string]bool, len(tags))
+	out := tags[:0]
+	for _, t := range tags {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
 type byTypeAndName []os.FileInfo
 
 func (s byTypeAndName) Len() int { return len(s) }
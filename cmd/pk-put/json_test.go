@@ -0,0 +1,87 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/cmdmain"
+)
+
+func TestHandleResultJSON(t *testing.T) {
+	*flagJSON = true
+	defer func() { *flagJSON = false }()
+
+	var buf bytes.Buffer
+	old := cmdmain.Stdout
+	cmdmain.Stdout = &buf
+	defer func() { cmdmain.Stdout = old }()
+
+	pr := &client.PutResult{
+		BlobRef: blob.RefFromString("hello"),
+		Size:    5,
+		Skipped: true,
+	}
+	if err := handleResult("file", pr, nil, "/tmp/hello.txt"); err != nil {
+		t.Fatalf("handleResult: %v", err)
+	}
+
+	var got jsonResult
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", buf.Bytes(), err)
+	}
+	want := jsonResult{
+		BlobRef:       pr.BlobRef.String(),
+		CamliType:     "file",
+		Path:          "/tmp/hello.txt",
+		Size:          5,
+		NewlyUploaded: false,
+	}
+	if got != want {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestHandleResultJSONNoPath(t *testing.T) {
+	*flagJSON = true
+	defer func() { *flagJSON = false }()
+
+	var buf bytes.Buffer
+	old := cmdmain.Stdout
+	cmdmain.Stdout = &buf
+	defer func() { cmdmain.Stdout = old }()
+
+	pr := &client.PutResult{BlobRef: blob.RefFromString("x"), Size: 1}
+	if err := handleResult("permanode", pr, nil); err != nil {
+		t.Fatalf("handleResult: %v", err)
+	}
+
+	var got jsonResult
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", buf.Bytes(), err)
+	}
+	if got.Path != "" {
+		t.Errorf("Path = %q; want empty", got.Path)
+	}
+	if !got.NewlyUploaded {
+		t.Error("NewlyUploaded = false; want true for an unskipped upload")
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"perkeep.org/pkg/client"
 	"perkeep.org/pkg/cmdmain"
 	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/search"
 )
 
 type permanodeCmd struct {
@@ -33,6 +34,9 @@ type permanodeCmd struct {
 	tag     string
 	key     string // else random
 	sigTime string
+
+	ifNotExists bool
+	attr        string // "attrName=value", used with ifNotExists
 }
 
 func init() {
@@ -42,6 +46,8 @@ func init() {
 		flags.StringVar(&cmd.tag, "tag", "", "Optional tag(s) to set on new permanode; comma separated.")
 		flags.StringVar(&cmd.key, "key", "", "Optional key to create deterministic ('planned') permanodes. Must also use --sigtime.")
 		flags.StringVar(&cmd.sigTime, "sigtime", "", "Optional time to put in the OpenPGP signature packet instead of the current time. Required when producing a deterministic permanode (with --key). In format YYYY-MM-DD HH:MM:SS")
+		flags.BoolVar(&cmd.ifNotExists, "if-not-exists", false, "Instead of unconditionally creating a new permanode, first search for an existing one with the attribute given by --attr, and reuse it if found. Must be used with --attr.")
+		flags.StringVar(&cmd.attr, "attr", "", "Attribute in \"name=value\" form to search for (with --if-not-exists) and to set on a newly created permanode.")
 		return cmd
 	})
 }
@@ -74,6 +80,44 @@ func (c *permanodeCmd) RunCommand(args []string) error {
 	if (c.key != "") != (c.sigTime != "") {
 		return errors.New("both --key and --sigtime must be used to produce deterministic permanodes")
 	}
+	if c.ifNotExists && c.key != "" {
+		return errors.New("--if-not-exists can't be used with --key")
+	}
+	if c.ifNotExists && c.attr == "" {
+		return errors.New("--if-not-exists requires --attr")
+	}
+	var attrName, attrValue string
+	if c.attr != "" {
+		var ok bool
+		attrName, attrValue, ok = strings.Cut(c.attr, "=")
+		if !ok {
+			return fmt.Errorf("--attr value %q is not in \"name=value\" form", c.attr)
+		}
+	}
+	if c.ifNotExists {
+		// Best-effort: a concurrent writer could create a matching
+		// permanode between this search and our upload below, in which
+		// case both survive as duplicates. Perkeep has no global
+		// compare-and-swap for permanode creation.
+		wa, err := up.GetPermanodesWithAttr(ctxbg, &search.WithAttrRequest{
+			N:     2,
+			Attr:  attrName,
+			Value: attrValue,
+		})
+		if err != nil {
+			return fmt.Errorf("searching for existing permanode with %s=%s: %v", attrName, attrValue, err)
+		}
+		switch len(wa.WithAttr) {
+		case 0:
+			// Fall through and create it below.
+		case 1:
+			fmt.Fprintln(cmdmain.Stdout, wa.WithAttr[0].Permanode.String())
+			return nil
+		default:
+			return fmt.Errorf("ambiguous: %d permanodes already have %s=%s", len(wa.WithAttr), attrName, attrValue)
+		}
+	}
+
 	if c.key == "" {
 		// Normal case, with a random permanode.
 		permaNode, err = up.UploadNewPermanode(ctxbg)
@@ -101,5 +145,9 @@ func (c *permanodeCmd) RunCommand(args []string) error {
 			handleResult("claim-permanode-tag", put, err)
 		}
 	}
+	if c.attr != "" {
+		put, err := up.UploadAndSignBlob(ctxbg, schema.NewSetAttributeClaim(permaNode.BlobRef, attrName, attrValue))
+		handleResult("claim-permanode-attr", put, err)
+	}
 	return nil
 }
@@ -0,0 +1,78 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/cmdmain"
+)
+
+// postHookPayload is written as JSON to a --post-hook command's stdin.
+type postHookPayload struct {
+	// BlobRefs holds the top-level blobs created by the upload: the
+	// file or directory schema blob of each argument (or of the
+	// --from-archive or --from-url source), in argument order, followed
+	// by the permanode of a --permanode upload, if any. Chunk, static-set
+	// and claim blobs are not included. The last element is usually the
+	// most interesting one (e.g. the root permanode of a --permanode upload).
+	BlobRefs []string `json:"blobRefs"`
+}
+
+// runPostHook runs command (via the shell, so it may use pipes,
+// redirection, etc.) after a successful upload, passing refs as a JSON
+// object on its stdin. It is never called after a failed upload.
+//
+// The hook is killed and an error returned if it doesn't finish within
+// timeout.
+func runPostHook(ctx context.Context, command string, refs []blob.Ref, timeout time.Duration) error {
+	if command == "" {
+		return nil
+	}
+	payload := postHookPayload{BlobRefs: make([]string, len(refs))}
+	for i, ref := range refs {
+		payload.BlobRefs[i] = ref.String()
+	}
+	stdin, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("post-hook: encoding payload: %v", err)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = cmdmain.Stdout
+	cmd.Stderr = cmdmain.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("post-hook: %q timed out after %v", command, timeout)
+		}
+		return fmt.Errorf("post-hook: %q: %v", command, err)
+	}
+	return nil
+}
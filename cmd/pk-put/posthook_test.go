@@ -0,0 +1,72 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"perkeep.org/pkg/blob"
+)
+
+func TestRunPostHookEmptyCommandIsNoop(t *testing.T) {
+	if err := runPostHook(context.Background(), "", []blob.Ref{blob.RefFromString("x")}, time.Second); err != nil {
+		t.Fatalf("runPostHook with empty command: %v", err)
+	}
+}
+
+func TestRunPostHookReceivesRefsOnStdin(t *testing.T) {
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "out.json")
+
+	refs := []blob.Ref{blob.RefFromString("one"), blob.RefFromString("two")}
+	err := runPostHook(context.Background(), "cat > "+outFile, refs, time.Second)
+	if err != nil {
+		t.Fatalf("runPostHook: %v", err)
+	}
+
+	got, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("reading hook output: %v", err)
+	}
+	for _, ref := range refs {
+		if !strings.Contains(string(got), ref.String()) {
+			t.Errorf("hook stdin payload = %s; want it to contain %v", got, ref)
+		}
+	}
+}
+
+func TestRunPostHookFailure(t *testing.T) {
+	err := runPostHook(context.Background(), "exit 1", nil, time.Second)
+	if err == nil {
+		t.Fatal("runPostHook: got nil error for a failing command; want an error")
+	}
+}
+
+func TestRunPostHookTimeout(t *testing.T) {
+	// A busy loop, rather than e.g. "sleep 5", so the shell doesn't fork a
+	// child process that would outlive the killed shell and hold this
+	// test's stdout pipe open.
+	err := runPostHook(context.Background(), "while :; do :; done", nil, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("runPostHook: got nil error for a command exceeding its timeout; want an error")
+	}
+}
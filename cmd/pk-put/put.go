@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -36,6 +37,7 @@ import (
 	"perkeep.org/pkg/schema"
 
 	"go4.org/syncutil"
+	"golang.org/x/time/rate"
 )
 
 const buffered = 16 // arbitrary
@@ -48,6 +50,8 @@ var (
 	flagHaveCache  = true
 	flagBlobDir    = flag.String("blobdir", "", "If non-empty, the local directory to put blobs, instead of sending them over the network. If the string \"discard\", no blobs are written or sent over the network anywhere.")
 	flagCacheLog   = flag.Bool("logcache", false, "log caching details")
+	flagLimitRate  = flag.String("limit-rate", "", "Cap the aggregate upload throughput to the server, e.g. \"500k\" or \"2m\" (bytes per second; k/m/g suffixes mean ×1024, ×1024², ×1024³). The limit is shared across all concurrent upload goroutines, not per-goroutine. Empty or \"0\" means unlimited.")
+	flagJSON       = flag.Bool("json", false, "Write one JSON object per line to stdout for each object created (blobref, camliType, source path for file mode, size, and whether it was newly uploaded or already present), instead of the human-readable log lines. Intended for scripting.")
 )
 
 var (
@@ -120,16 +124,52 @@ func initUploader() {
 	uploader = up
 }
 
-func handleResult(what schema.CamliType, pr *client.PutResult, err error) error {
+// handleResult logs pr, the result of uploading an object of type what, or
+// records err if it's non-nil. path is the source file path that produced
+// pr, if any (only meaningful in file mode); callers that don't have one
+// may omit it.
+func handleResult(what schema.CamliType, pr *client.PutResult, err error, path ...string) error {
 	if err != nil {
 		cmdmain.Errorf("Error putting %s: %s\n", what, err)
 		cmdmain.ExitWithFailure = true
 		return err
 	}
+	if *flagJSON {
+		var srcPath string
+		if len(path) > 0 {
+			srcPath = path[0]
+		}
+		printJSONResult(what, pr, srcPath)
+		return nil
+	}
 	fmt.Fprintln(cmdmain.Stdout, pr.BlobRef.String())
 	return nil
 }
 
+// jsonResult is the shape of each line of --json output.
+type jsonResult struct {
+	BlobRef       string           `json:"blobRef"`
+	CamliType     schema.CamliType `json:"camliType"`
+	Path          string           `json:"path,omitempty"`
+	Size          uint32           `json:"size"`
+	NewlyUploaded bool             `json:"newlyUploaded"`
+}
+
+func printJSONResult(what schema.CamliType, pr *client.PutResult, path string) {
+	b, err := json.Marshal(jsonResult{
+		BlobRef:       pr.BlobRef.String(),
+		CamliType:     what,
+		Path:          path,
+		Size:          pr.Size,
+		NewlyUploaded: !pr.Skipped,
+	})
+	if err != nil {
+		// Shouldn't happen: everything above is directly marshalable.
+		log.Fatalf("--json: %v", err)
+	}
+	fmt.Fprintln(cmdmain.Stdout, string(b))
+}
+
 func getenvEitherCase(k string) string {
 	if v := os.Getenv(strings.ToUpper(k)); v != "" {
 		return v
@@ -185,6 +225,12 @@ func newUploader() *Uploader {
 	cc.Verbose = *cmdmain.FlagVerbose
 	cc.Logger = log.New(cmdmain.Stderr, "", log.LstdFlags)
 
+	if lim, err := parseRateLimit(*flagLimitRate); err != nil {
+		log.Fatalf("Invalid -limit-rate: %v", err)
+	} else if lim > 0 {
+		cc.SetUploadRateLimiter(rate.NewLimiter(rate.Limit(lim), rateLimitBurst(lim)))
+	}
+
 	pwd, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("os.Getwd: %v", err)
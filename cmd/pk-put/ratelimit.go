@@ -0,0 +1,76 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	minRateLimitBurst = 4 << 10 // 4 KiB
+	maxRateLimitBurst = 1 << 20 // 1 MiB
+)
+
+// parseRateLimit parses a --limit-rate value such as "500k" or "2m" into a
+// number of bytes per second. A trailing k/K, m/M, or g/G suffix means
+// ×1024, ×1024², or ×1024³ respectively; a trailing b/B is ignored. An
+// empty string, or "0", returns 0, meaning unlimited.
+func parseRateLimit(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		mult = 1 << 10
+		s = s[:len(s)-1]
+	case 'm', 'M':
+		mult = 1 << 20
+		s = s[:len(s)-1]
+	case 'g', 'G':
+		mult = 1 << 30
+		s = s[:len(s)-1]
+	case 'b', 'B':
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %v", s, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid rate %q: must not be negative", s)
+	}
+	return n * mult, nil
+}
+
+// rateLimitBurst picks a token-bucket burst size for a limiter enforcing
+// bytesPerSec, clamped to a range that keeps the resulting Read chunking
+// (see rateLimitedReader) reasonably fine-grained without capping
+// throughput at very high rates.
+func rateLimitBurst(bytesPerSec int64) int {
+	b := bytesPerSec
+	if b < minRateLimitBurst {
+		b = minRateLimitBurst
+	}
+	if b > maxRateLimitBurst {
+		b = maxRateLimitBurst
+	}
+	return int(b)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFileOptionsIsDerived(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *fileOptions
+		path string
+		want bool
+	}{
+		{
+			name: "disabled",
+			opts: &fileOptions{},
+			path: "photo_thumb.jpg",
+			want: false,
+		},
+		{
+			name: "matches pattern",
+			opts: &fileOptions{skipDerived: []string{"*_thumb.*"}},
+			path: filepath.Join("2023", "Italy", "photo_thumb.jpg"),
+			want: true,
+		},
+		{
+			name: "does not match pattern",
+			opts: &fileOptions{skipDerived: []string{"*_thumb.*"}},
+			path: filepath.Join("2023", "Italy", "photo.jpg"),
+			want: false,
+		},
+		{
+			name: "matches one of several patterns",
+			opts: &fileOptions{skipDerived: []string{"*_thumb.*", "*.sidecar"}},
+			path: "photo.jpg.sidecar",
+			want: true,
+		},
+		{
+			name: "nil fileOptions",
+			opts: nil,
+			path: "photo_thumb.jpg",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.isDerived(tt.path); got != tt.want {
+				t.Errorf("isDerived(%q) = %v; want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTagsFromPath(t *testing.T) {
+	root := filepath.FromSlash("/photos")
+	tests := []struct {
+		name string
+		opts *fileOptions
+		path string
+		want []string
+	}{
+		{
+			name: "disabled",
+			opts: &fileOptions{tagFromPathRoot: root},
+			path: filepath.Join(root, "2023/Italy/Rome/photo.jpg"),
+			want: nil,
+		},
+		{
+			name: "all levels",
+			opts: &fileOptions{tagFromPath: true, tagFromPathRoot: root},
+			path: filepath.Join(root, "2023/Italy/Rome/photo.jpg"),
+			want: []string{"2023", "Italy", "Rome"},
+		},
+		{
+			name: "limited levels",
+			opts: &fileOptions{tagFromPath: true, tagFromPathLevels: 2, tagFromPathRoot: root},
+			path: filepath.Join(root, "2023/Italy/Rome/photo.jpg"),
+			want: []string{"Italy", "Rome"},
+		},
+		{
+			name: "file directly under root",
+			opts: &fileOptions{tagFromPath: true, tagFromPathRoot: root},
+			path: filepath.Join(root, "photo.jpg"),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.opts.tagsFromPath(tt.path)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tagsFromPath(%q) = %v; want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupTags(t *testing.T) {
+	got := dedupTags([]string{"a", "b", "a", "c", "b"})
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupTags = %v; want %v", got, want)
+	}
+}
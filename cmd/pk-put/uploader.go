@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 
 	"perkeep.org/internal/httputil"
@@ -64,6 +65,62 @@ type fileOptions struct {
 	exifTime     bool // use the time in exif metadata as the modtime if possible.
 	capCtime     bool // use mtime as ctime if ctime > mtime
 	contentsOnly bool // do not store any of the file's attributes, only its contents.
+
+	// captureMetadata implements --capture-metadata: whether to record a
+	// file's POSIX permission bits and owner/group (name and numeric id)
+	// in its file schema. On by default; symlink targets are always
+	// recorded regardless, since they're needed to recreate the symlink.
+	captureMetadata bool
+
+	// tagFromPath and tagFromPathLevels implement -tag-from-path; see its
+	// flag help in cmd/pk-put/files.go.
+	tagFromPath       bool
+	tagFromPathLevels int
+	// tagFromPathRoot is the directory or file argument, as given on the
+	// command line, that the current upload came from. It's updated
+	// between top-level arguments, not per file.
+	tagFromPathRoot string
+
+	// skipDerived holds the --skip-derived shell patterns (as in
+	// filepath.Match), matched against each file's base name to
+	// recognize already-derived files (e.g. thumbnails or sidecars)
+	// that shouldn't be uploaded or repermanoded.
+	skipDerived []string
+
+	// exclude implements --exclude, --exclude-from, and --exclude-vcs;
+	// nil if none of those were given.
+	exclude *excludeMatcher
+	// excludeRoot is the directory or file argument, as given on the
+	// command line, that the current upload came from, used to compute
+	// the path exclude's patterns are matched against. Updated between
+	// top-level arguments, not per file, exactly like tagFromPathRoot.
+	excludeRoot string
+}
+
+// isDerived reports whether fullPath's base name matches one of the
+// --skip-derived patterns, meaning it should be skipped as an
+// already-derived file rather than uploaded.
+func (o *fileOptions) isDerived(fullPath string) bool {
+	if o == nil {
+		return false
+	}
+	base := filepath.Base(fullPath)
+	for _, pattern := range o.skipDerived {
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded reports whether fullPath, a directory or file under
+// excludeRoot, matches one of the --exclude patterns and should be
+// skipped.
+func (o *fileOptions) isExcluded(fullPath string, isDir bool) bool {
+	if o == nil || o.exclude == nil {
+		return false
+	}
+	return o.exclude.match(relExcludePath(o.excludeRoot, fullPath), isDir)
 }
 
 func (o *fileOptions) tags() []string {
@@ -73,6 +130,35 @@ func (o *fileOptions) tags() []string {
 	return strings.Split(o.tag, ",")
 }
 
+// tagsFromPath returns the tags derived from fullPath's directory
+// components, relative to tagFromPathRoot, if tagFromPath is enabled.
+func (o *fileOptions) tagsFromPath(fullPath string) []string {
+	if o == nil || !o.tagFromPath {
+		return nil
+	}
+	rel := fullPath
+	if o.tagFromPathRoot != "" {
+		if r, err := filepath.Rel(o.tagFromPathRoot, fullPath); err == nil {
+			rel = r
+		}
+	}
+	dir := filepath.Dir(rel)
+	if dir == "." || dir == string(filepath.Separator) {
+		return nil
+	}
+	var tags []string
+	for _, part := range strings.Split(filepath.ToSlash(dir), "/") {
+		if part == "" || part == ".." {
+			continue
+		}
+		tags = append(tags, part)
+	}
+	if n := o.tagFromPathLevels; n > 0 && len(tags) > n {
+		tags = tags[len(tags)-n:]
+	}
+	return tags
+}
+
 func (o *fileOptions) wantFilePermanode() bool {
 	return o != nil && o.permanode
 }
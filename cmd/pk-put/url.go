@@ -0,0 +1,86 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/schema"
+)
+
+// UploadFileFromURL fetches urlStr (following redirects, per
+// http.DefaultClient's default policy) and uploads its body as a file
+// schema blob, streaming the response directly into blob storage rather
+// than buffering the whole download in memory. It returns the PutResult
+// of the file schema blob, along with the HTTP Content-Type of the
+// response, if any.
+func (up *Uploader) UploadFileFromURL(ctx context.Context, urlStr string) (pr *client.PutResult, contentType string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("building request for %v: %v", urlStr, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching %v: %v", urlStr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %v: %v", urlStr, resp.Status)
+	}
+	contentType = resp.Header.Get("Content-Type")
+	if ct, _, ok := strings.Cut(contentType, ";"); ok {
+		contentType = strings.TrimSpace(ct)
+	}
+
+	bb := schema.NewFileMap(filenameFromResponse(urlStr, resp))
+	bb.SetType(schema.TypeFile)
+	br, err := schema.WriteFileMap(ctx, up.noStatReceiver(up.statReceiver(nil)), bb, resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("writing %v: %v", urlStr, err)
+	}
+	json, err := bb.JSON()
+	if err != nil {
+		return nil, "", err
+	}
+	return &client.PutResult{BlobRef: br, Size: uint32(len(json))}, contentType, nil
+}
+
+// filenameFromResponse picks a filename for content fetched from urlStr:
+// the filename from a Content-Disposition header if the server sent one,
+// else the last path segment of the URL, else a generic fallback.
+func filenameFromResponse(urlStr string, resp *http.Response) string {
+	if cd := resp.Header.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil {
+			if name := params["filename"]; name != "" {
+				return path.Base(name)
+			}
+		}
+	}
+	if u, err := url.Parse(urlStr); err == nil {
+		if name := path.Base(u.Path); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	return "download"
+}
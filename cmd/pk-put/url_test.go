@@ -0,0 +1,58 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFilenameFromResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		hdr  http.Header
+		want string
+	}{
+		{
+			name: "content-disposition wins",
+			url:  "https://example.com/download?id=123",
+			hdr:  http.Header{"Content-Disposition": {`attachment; filename="report.pdf"`}},
+			want: "report.pdf",
+		},
+		{
+			name: "falls back to url path",
+			url:  "https://example.com/some/dir/photo.jpg",
+			hdr:  http.Header{},
+			want: "photo.jpg",
+		},
+		{
+			name: "falls back to generic name for a bare url",
+			url:  "https://example.com/",
+			hdr:  http.Header{},
+			want: "download",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: tt.hdr}
+			if got := filenameFromResponse(tt.url, resp); got != tt.want {
+				t.Errorf("filenameFromResponse(%q) = %q; want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
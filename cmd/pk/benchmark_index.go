@@ -0,0 +1,167 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/search"
+)
+
+type benchmarkIndexCmd struct {
+	server     string
+	iterations int
+	warm       bool
+}
+
+func init() {
+	cmdmain.RegisterMode("benchmark-index", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(benchmarkIndexCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server to benchmark. "+serverFlagHelp)
+		flags.IntVar(&cmd.iterations, "n", 20, "Number of timed iterations to run per query.")
+		flags.BoolVar(&cmd.warm, "warm", true, "Run each query once, untimed, before the timed iterations, so the measurements reflect a warm cache rather than cold disk reads.")
+		return cmd
+	})
+}
+
+func (c *benchmarkIndexCmd) Describe() string {
+	return "Profile query performance against a server's index."
+}
+
+func (c *benchmarkIndexCmd) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pk [globalopts] benchmark-index\n")
+}
+
+func (c *benchmarkIndexCmd) Examples() []string {
+	return []string{
+		"",
+		"-n=100 -warm=false",
+	}
+}
+
+// A benchmarkQuery is one representative read-only operation exercised
+// against the server's index. None of them mutate data, so the benchmark
+// is safe to run against a live server.
+type benchmarkQuery struct {
+	name string
+	run  func(ctx context.Context, cl *client.Client) error
+}
+
+var benchmarkQueries = []benchmarkQuery{
+	{
+		name: "attribute lookup",
+		run: func(ctx context.Context, cl *client.Client) error {
+			_, err := cl.Query(ctx, &search.SearchQuery{
+				Expression: "tag:benchmark-index",
+				Limit:      20,
+			})
+			return err
+		},
+	},
+	{
+		name: "recent permanodes",
+		run: func(ctx context.Context, cl *client.Client) error {
+			_, err := cl.Query(ctx, &search.SearchQuery{
+				Constraint: &search.Constraint{Permanode: &search.PermanodeConstraint{}},
+				Sort:       search.CreatedDesc,
+				Limit:      20,
+			})
+			return err
+		},
+	},
+	{
+		name: "full describe",
+		run: func(ctx context.Context, cl *client.Client) error {
+			res, err := cl.Query(ctx, &search.SearchQuery{
+				Constraint: &search.Constraint{Permanode: &search.PermanodeConstraint{}},
+				Sort:       search.CreatedDesc,
+				Limit:      1,
+			})
+			if err != nil {
+				return err
+			}
+			if len(res.Blobs) == 0 {
+				return nil // nothing to describe on an empty index
+			}
+			_, err = cl.Describe(ctx, &search.DescribeRequest{
+				BlobRef:     res.Blobs[0].Blob,
+				MemberDepth: 3,
+			})
+			return err
+		},
+	},
+	{
+		name: "path resolution",
+		run: func(ctx context.Context, cl *client.Client) error {
+			_, err := cl.Query(ctx, &search.SearchQuery{
+				Expression: "is:directory",
+				Limit:      20,
+			})
+			return err
+		},
+	},
+}
+
+func (c *benchmarkIndexCmd) RunCommand(args []string) error {
+	if len(args) > 0 {
+		return cmdmain.UsageError("doesn't take any arguments")
+	}
+	if c.iterations < 1 {
+		return cmdmain.UsageError("-n must be at least 1")
+	}
+
+	cl := newClient(c.server)
+
+	w := new(tabwriter.Writer)
+	w.Init(cmdmain.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Query\tRuns\tMin\tMean\tMax\tThroughput")
+	for _, bq := range benchmarkQueries {
+		if c.warm {
+			if err := bq.run(ctxbg, cl); err != nil {
+				return fmt.Errorf("warming %q: %v", bq.name, err)
+			}
+		}
+
+		var total, min, max time.Duration
+		for i := 0; i < c.iterations; i++ {
+			t0 := time.Now()
+			if err := bq.run(ctxbg, cl); err != nil {
+				return fmt.Errorf("running %q: %v", bq.name, err)
+			}
+			d := time.Since(t0)
+			total += d
+			if min == 0 || d < min {
+				min = d
+			}
+			if d > max {
+				max = d
+			}
+		}
+		mean := total / time.Duration(c.iterations)
+		throughput := float64(c.iterations) / total.Seconds()
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%.1f/s\n",
+			bq.name, c.iterations, min, mean, max, throughput)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"perkeep.org/internal/osutil"
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/cmdmain"
+)
+
+type doctorCmd struct {
+	server string
+}
+
+func init() {
+	cmdmain.RegisterMode("doctor", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(doctorCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server to diagnose. "+serverFlagHelp)
+		return cmd
+	})
+}
+
+func (c *doctorCmd) Describe() string {
+	return "Diagnose common client and server setup problems."
+}
+
+func (c *doctorCmd) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pk [globalopts] doctor\n")
+}
+
+func (c *doctorCmd) Examples() []string {
+	return []string{""}
+}
+
+// client returns a Client for c.server, like newClient, but without
+// dying on error: doctor's whole point is to report failures, not to
+// exit at the first one.
+func (c *doctorCmd) client() (*client.Client, error) {
+	if c.server == "" {
+		return client.New()
+	}
+	cl, err := client.New(client.OptionServer(c.server))
+	if err != nil {
+		return nil, err
+	}
+	if err := cl.SetupAuth(); err != nil {
+		return nil, fmt.Errorf("could not set up auth: %v", err)
+	}
+	return cl, nil
+}
+
+func (c *doctorCmd) RunCommand(args []string) error {
+	if len(args) > 0 {
+		return cmdmain.UsageError("doesn't take any arguments")
+	}
+
+	healthy := true
+	report := func(name string, err error, hint string) {
+		if err == nil {
+			fmt.Printf("[ OK ]   %s\n", name)
+			return
+		}
+		healthy = false
+		fmt.Printf("[FAIL]   %s: %v\n", name, err)
+		if hint != "" {
+			fmt.Printf("         hint: %s\n", hint)
+		}
+	}
+	skip := func(name, reason string) {
+		healthy = false
+		fmt.Printf("[SKIP]   %s: %s\n", name, reason)
+	}
+
+	cl, err := c.client()
+	report("config", err, fmt.Sprintf("check that %s exists and is valid; see https://perkeep.org/doc/client-config", osutil.UserClientConfigPath()))
+	if err != nil {
+		skip("keyring", "requires a valid config")
+		skip("server reachability", "requires a valid config")
+		skip("discovery", "requires a valid config")
+		skip("index", "requires a valid config")
+		return doctorErr(healthy)
+	}
+
+	_, err = cl.Signer()
+	report("keyring", err, `run "pk newkey" or check the identity and secretRing paths in your client config`)
+
+	if _, err := cl.HTTPVersion(ctxbg); err != nil {
+		report("server reachability", err, "check that the server is running, reachable, and that its TLS certificate is trusted (see the trustedCerts config option for self-signed certs)")
+		skip("discovery", "requires a reachable server")
+		skip("index", "requires a reachable server")
+		return doctorErr(healthy)
+	}
+	report("server reachability", nil, "")
+
+	_, err = cl.DiscoveryDoc(ctxbg)
+	report("discovery", err, "check the server's configuration and logs for handler errors")
+	if err != nil {
+		skip("index", "requires successful discovery")
+		return doctorErr(healthy)
+	}
+
+	_, err = cl.SearchRoot()
+	report("index", err, "make sure a search handler backed by a working index is configured on the server")
+
+	return doctorErr(healthy)
+}
+
+func doctorErr(healthy bool) error {
+	if !healthy {
+		return errors.New("doctor found problems; see above")
+	}
+	return nil
+}
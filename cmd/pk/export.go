@@ -0,0 +1,343 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/search"
+	"perkeep.org/pkg/types/camtypes"
+)
+
+type exportCmd struct {
+	server string
+	zip    bool
+	out    string
+}
+
+func init() {
+	cmdmain.RegisterMode("export", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(exportCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server to export from. "+serverFlagHelp)
+		flags.BoolVar(&cmd.zip, "zip", false, "Write a zip archive instead of a tar.")
+		flags.StringVar(&cmd.out, "o", "-", "Output file to write the archive to, or '-' for stdout.")
+		return cmd
+	})
+}
+
+func (c *exportCmd) Describe() string {
+	return "Export a permanode subtree (or camliRoot) to a portable tar or zip archive."
+}
+
+func (c *exportCmd) Usage() {
+	fmt.Fprintln(cmdmain.Stderr, "Usage: pk [globalopts] export [--zip] [-o=out.tar] <root-blobref>|<root-name>")
+}
+
+func (c *exportCmd) Examples() []string {
+	return []string{
+		"sha224-abcdef... > gallery.tar",
+		"--zip -o gallery.zip mygallery",
+	}
+}
+
+func (c *exportCmd) RunCommand(args []string) error {
+	if len(args) != 1 {
+		return cmdmain.UsageError("requires a single <root-blobref>|<root-name> argument")
+	}
+	cl := newClient(c.server)
+	root, err := resolveRoot(cl, args[0])
+	if err != nil {
+		return err
+	}
+
+	files, err := exportFileList(cl, root)
+	if err != nil {
+		return fmt.Errorf("could not list files under %v: %v", root, err)
+	}
+	files = renameDuplicateExportPaths(files)
+
+	var out io.Writer = cmdmain.Stdout
+	if c.out != "-" {
+		f, err := os.Create(c.out)
+		if err != nil {
+			return fmt.Errorf("creating %s: %v", c.out, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if c.zip {
+		return writeExportZip(cl, out, files)
+	}
+	return writeExportTar(cl, out, files)
+}
+
+// resolveRoot returns the permanode that arg refers to, either directly
+// as a blobref or, if arg doesn't parse as one, as the value of a
+// camliRoot attribute (as set by `pk-put` or the web UI's "publish"
+// feature). It's the same fallback pk-mount uses to resolve its
+// <root-blobref>|<root-name> argument.
+func resolveRoot(cl *client.Client, arg string) (blob.Ref, error) {
+	if br, ok := blob.Parse(arg); ok {
+		return br, nil
+	}
+	wres, err := cl.GetPermanodesWithAttr(ctxbg, &search.WithAttrRequest{N: 1, Attr: "camliRoot", Value: arg})
+	if err != nil {
+		return blob.Ref{}, fmt.Errorf("could not query for camliRoot %q: %v", arg, err)
+	}
+	if len(wres.WithAttr) == 0 {
+		return blob.Ref{}, fmt.Errorf("%q is neither a blobref nor the name of a known camliRoot", arg)
+	}
+	return wres.WithAttr[0].Permanode, nil
+}
+
+// exportFile is a file to be added to the export archive.
+type exportFile struct {
+	blobRef blob.Ref
+	// path is the file's full path within the archive, always with
+	// forward slashes.
+	path string
+}
+
+// exportFileList returns the list of file blobs "under" root, the same
+// way app/publisher's zip download does: recursing through permanode
+// directories, permanodes with camliMember children, and directory
+// schema blobs, using each level's title (or, lacking one, its
+// blobref's digest prefix) as its directory or file name.
+func exportFileList(cl *client.Client, root blob.Ref) ([]exportFile, error) {
+	return exportBlobList(cl, "", root)
+}
+
+func exportBlobList(cl *client.Client, dirPath string, dirBlob blob.Ref) ([]exportFile, error) {
+	res, err := cl.Query(ctxbg, &search.SearchQuery{
+		Constraint: &search.Constraint{
+			BlobRefPrefix: dirBlob.String(),
+			CamliType:     "permanode",
+		},
+		Describe: &search.DescribeRequest{
+			Depth: 1,
+			Rules: []*search.DescribeRule{
+				{Attrs: []string{"camliContent", "camliContentImage", "camliMember"}},
+			},
+		},
+		Limit: -1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describing %v: %v", dirBlob, err)
+	}
+	if res == nil || res.Describe == nil {
+		return nil, fmt.Errorf("no describe result for %v", dirBlob)
+	}
+
+	described := res.Describe.Meta[dirBlob.String()]
+	members := described.Members()
+	dirBlobPath, _, isDir := described.PermanodeDir()
+	if len(members) == 0 && !isDir {
+		return nil, nil
+	}
+	if isDir {
+		return exportDirBlobs(cl, dirPath, dirBlobPath[1])
+	}
+
+	var list []exportFile
+	for _, member := range members {
+		if fileBlobPath, fi, ok := exportGetFileInfo(member.BlobRef, res.Describe.Meta); ok {
+			list = append(list, exportFile{fileBlobPath[1], path.Join(dirPath, exportFileName(fi, fileBlobPath[1]))})
+			continue
+		}
+		if dirBlobPath, di, ok := exportGetDirInfo(member.BlobRef, res.Describe.Meta); ok {
+			children, err := exportDirBlobs(cl, path.Join(dirPath, exportFileName(di, dirBlobPath[1])), dirBlobPath[1])
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, children...)
+			continue
+		}
+		// Neither a file nor a directory: it might itself have members,
+		// so recurse into it as a pseudo directory, named for its title
+		// (or its blobref's digest prefix, lacking one).
+		name := member.Title()
+		if name == "" {
+			name = member.BlobRef.DigestPrefix(10)
+		}
+		children, err := exportBlobList(cl, path.Join(dirPath, name), member.BlobRef)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, children...)
+	}
+	return list, nil
+}
+
+// exportDirBlobs returns the list of file blobs in the directory schema
+// blob dirBlob, recursing into subdirectories.
+func exportDirBlobs(cl *client.Client, dirPath string, dirBlob blob.Ref) ([]exportFile, error) {
+	dr, err := schema.NewDirReader(ctxbg, cl, dirBlob)
+	if err != nil {
+		return nil, fmt.Errorf("reading dir blob %v: %v", dirBlob, err)
+	}
+	ents, err := dr.Readdir(ctxbg, -1)
+	if err != nil {
+		return nil, fmt.Errorf("reading dir entries of %v: %v", dirBlob, err)
+	}
+	var list []exportFile
+	for _, ent := range ents {
+		fullpath := path.Join(dirPath, ent.FileName())
+		switch ent.CamliType() {
+		case schema.TypeFile:
+			list = append(list, exportFile{ent.BlobRef(), fullpath})
+		case schema.TypeDirectory:
+			children, err := exportDirBlobs(cl, fullpath, ent.BlobRef())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, children...)
+		}
+	}
+	return list, nil
+}
+
+// exportFileName returns fi's file name, falling back to blobRef's
+// digest prefix when the file schema has none, per the "files without
+// filenames" edge case: a file permanode need not carry a name.
+func exportFileName(fi *camtypes.FileInfo, blobRef blob.Ref) string {
+	if fi.FileName != "" {
+		return fi.FileName
+	}
+	return blobRef.DigestPrefix(10)
+}
+
+func exportGetFileInfo(item blob.Ref, peers map[string]*search.DescribedBlob) (path []blob.Ref, fi *camtypes.FileInfo, ok bool) {
+	described := peers[item.String()]
+	if described == nil || described.Permanode == nil || described.Permanode.Attr == nil {
+		return
+	}
+	contentRef := described.Permanode.Attr.Get("camliContent")
+	if contentRef == "" {
+		return
+	}
+	if cdes := peers[contentRef]; cdes != nil && cdes.File != nil {
+		return []blob.Ref{described.BlobRef, cdes.BlobRef}, cdes.File, true
+	}
+	return
+}
+
+func exportGetDirInfo(item blob.Ref, peers map[string]*search.DescribedBlob) (path []blob.Ref, di *camtypes.FileInfo, ok bool) {
+	described := peers[item.String()]
+	if described == nil || described.Permanode == nil || described.Permanode.Attr == nil {
+		return
+	}
+	contentRef := described.Permanode.Attr.Get("camliContent")
+	if contentRef == "" {
+		return
+	}
+	if cdes := peers[contentRef]; cdes != nil && cdes.Dir != nil {
+		return []blob.Ref{described.BlobRef, cdes.BlobRef}, cdes.Dir, true
+	}
+	return
+}
+
+// renameDuplicateExportPaths appends a "(n)" suffix to whichever of two
+// or more files that would otherwise land at the same archive path,
+// then returns the files sorted by path.
+func renameDuplicateExportPaths(files []exportFile) []exportFile {
+	noDup := make(map[string]blob.Ref)
+	for _, f := range files {
+		if _, ok := noDup[f.path]; !ok {
+			noDup[f.path] = f.blobRef
+			continue
+		}
+		suffix := 0
+		var newName string
+		for {
+			suffix++
+			ext := path.Ext(f.path)
+			newName = fmt.Sprintf("%s(%d)%s", f.path[:len(f.path)-len(ext)], suffix, ext)
+			if _, ok := noDup[newName]; !ok {
+				break
+			}
+		}
+		noDup[newName] = f.blobRef
+	}
+	out := make([]exportFile, 0, len(noDup))
+	for p, br := range noDup {
+		out = append(out, exportFile{path: p, blobRef: br})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out
+}
+
+func writeExportTar(cl *client.Client, w io.Writer, files []exportFile) error {
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		fr, err := schema.NewFileReader(ctxbg, cl, f.blobRef)
+		if err != nil {
+			return fmt.Errorf("opening %v for %q: %v", f.blobRef, f.path, err)
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    f.path,
+			Size:    fr.Size(),
+			Mode:    0644,
+			ModTime: fr.ModTime(),
+		}); err != nil {
+			fr.Close()
+			return fmt.Errorf("writing tar header for %q: %v", f.path, err)
+		}
+		_, err = io.Copy(tw, fr)
+		fr.Close()
+		if err != nil {
+			return fmt.Errorf("writing %q to tar: %v", f.path, err)
+		}
+	}
+	return tw.Close()
+}
+
+func writeExportZip(cl *client.Client, w io.Writer, files []exportFile) error {
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fr, err := schema.NewFileReader(ctxbg, cl, f.blobRef)
+		if err != nil {
+			return fmt.Errorf("opening %v for %q: %v", f.blobRef, f.path, err)
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.path,
+			Method:   zip.Store,
+			Modified: fr.ModTime().UTC(),
+		})
+		if err != nil {
+			fr.Close()
+			return fmt.Errorf("creating %q in zip: %v", f.path, err)
+		}
+		_, err = io.Copy(fw, fr)
+		fr.Close()
+		if err != nil {
+			return fmt.Errorf("writing %q to zip: %v", f.path, err)
+		}
+	}
+	return zw.Close()
+}
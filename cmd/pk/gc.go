@@ -0,0 +1,344 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/internal/osutil"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/blobserver/localdisk"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/index"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/schema/nodeattr"
+	"perkeep.org/pkg/serverinit"
+	"perkeep.org/pkg/sorted"
+	"perkeep.org/pkg/types/camtypes"
+)
+
+type gcCmd struct {
+	prefix string
+	delete bool
+	grace  time.Duration
+}
+
+func init() {
+	cmdmain.RegisterMode("gc", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(gcCmd)
+		flags.StringVar(&cmd.prefix, "prefix", "", "Prefix (as it appears in the server config) of the storage-index handler to garbage collect. Required if the config has more than one.")
+		flags.BoolVar(&cmd.delete, "delete", false, "Actually remove unreferenced blobs. Without this, gc only lists what it would remove.")
+		flags.DurationVar(&cmd.grace, "grace", time.Hour, "Only remove blobs that have looked unreferenced for at least this long, so a blob mid-upload (whose referencing claim hasn't landed yet) isn't reaped out from under it.")
+		return cmd
+	})
+}
+
+func (c *gcCmd) Demote() bool { return true }
+
+func (c *gcCmd) Describe() string {
+	return "List (or, with --delete, remove) blobs that are no longer reachable from any permanode."
+}
+
+func (c *gcCmd) Usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pk [globalopts] gc [--prefix=/index/] [--delete] [--grace=1h]")
+}
+
+// This tool only understands storage-index handlers whose blobSource is a
+// plain "filesystem" (localdisk) storage, for the same reason repair-index
+// is so limited: it's the only kind it can construct without running the
+// whole server.
+func (c *gcCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("doesn't take arguments")
+	}
+	cfg, err := serverinit.LoadFile(osutil.UserServerConfigPath())
+	if err != nil {
+		return err
+	}
+	low := cfg.LowLevelJSONConfig() //lint:ignore SA1019 we use it
+	prefixes, ok := low["prefixes"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("no 'prefixes' object in low-level (or converted) config file %s", osutil.UserServerConfigPath())
+	}
+	var found []string
+	var handlerArgs jsonconfig.Obj
+	for prefix, vei := range prefixes {
+		pmap, ok := vei.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pconf := jsonconfig.Obj(pmap)
+		if pconf.RequiredString("handler") != "storage-index" {
+			continue
+		}
+		if c.prefix != "" && prefix != c.prefix {
+			continue
+		}
+		found = append(found, prefix)
+		handlerArgs = jsonconfig.Obj(pconf.OptionalObject("handlerArgs"))
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no storage-index handler found in server config file %s", osutil.UserServerConfigPath())
+	}
+	if len(found) > 1 {
+		return fmt.Errorf("ambiguity: server config file %s has more than one storage-index handler; pass -prefix to pick one of: %v", osutil.UserServerConfigPath(), found)
+	}
+
+	blobSourcePrefix := handlerArgs.RequiredString("blobSource")
+	bconf, ok := prefixes[blobSourcePrefix].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("blobSource %q of index %q not found in server config", blobSourcePrefix, found[0])
+	}
+	bpconf := jsonconfig.Obj(bconf)
+	if bpconf.RequiredString("handler") != "filesystem" {
+		return fmt.Errorf("blobSource %q of index %q is a %q handler; gc only supports \"filesystem\" blob sources", blobSourcePrefix, found[0], bpconf.RequiredString("handler"))
+	}
+	path := jsonconfig.Obj(bpconf.OptionalObject("handlerArgs")).RequiredString("path")
+	if path == "" {
+		return fmt.Errorf("blobSource %q of index %q has no path", blobSourcePrefix, found[0])
+	}
+	blobSource, err := localdisk.New(path)
+	if err != nil {
+		return fmt.Errorf("opening blobSource %q at %q: %v", blobSourcePrefix, path, err)
+	}
+
+	kv, err := sorted.NewKeyValue(jsonconfig.Obj(handlerArgs.RequiredObject("storage")))
+	if err != nil {
+		return fmt.Errorf("opening index storage of %q: %v", found[0], err)
+	}
+	defer kv.Close()
+	idx, err := index.New(kv)
+	if err != nil {
+		return fmt.Errorf("opening index %q: %v", found[0], err)
+	}
+	defer idx.Close()
+
+	if !c.delete {
+		log.Printf("dry run (pass -delete to actually remove unreferenced blobs)")
+	}
+
+	ctx := context.Background()
+	reachable, err := reachableBlobs(ctx, idx, blobSource)
+	if err != nil {
+		return fmt.Errorf("computing reachable set: %v", err)
+	}
+
+	var unreferenced []blob.SizedRef
+	var total int
+	if err := blobserver.EnumerateAll(ctx, blobSource, func(sb blob.SizedRef) error {
+		total++
+		if !reachable[sb.Ref] {
+			unreferenced = append(unreferenced, sb)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("enumerating blobSource %q: %v", blobSourcePrefix, err)
+	}
+
+	firstSeen, statePath := c.loadUnreferencedState(path)
+	now := time.Now()
+	newState := make(map[string]time.Time, len(unreferenced))
+	var eligible []blob.Ref
+	for _, sb := range unreferenced {
+		key := sb.Ref.String()
+		since, ok := firstSeen[key]
+		if !ok {
+			since = now
+		}
+		newState[key] = since
+		log.Printf("unreferenced blob %v (%d bytes)", sb.Ref, sb.Size)
+		if now.Sub(since) >= c.grace {
+			eligible = append(eligible, sb.Ref)
+		}
+	}
+	if statePath != "" {
+		if err := writeUnreferencedState(statePath, newState); err != nil {
+			log.Printf("warning: failed to persist gc state to %s: %v", statePath, err)
+		}
+	}
+
+	log.Printf("checked %d blobs: %d reachable, %d unreferenced (%d past the %v grace period)",
+		total, total-len(unreferenced), len(unreferenced), len(eligible), c.grace)
+
+	if !c.delete || len(eligible) == 0 {
+		return nil
+	}
+	if err := blobSource.RemoveBlobs(ctx, eligible); err != nil {
+		return fmt.Errorf("removing unreferenced blobs: %v", err)
+	}
+	log.Printf("removed %d unreferenced blobs", len(eligible))
+	return nil
+}
+
+// reachableBlobs returns the set of blobs reachable from any permanode:
+// the permanodes themselves, their non-deleted claims (AppendClaims
+// already excludes claims that have themselves been superseded by a
+// delete claim), the signing public key of each claim, and whatever
+// those claims point to (camliContent and camliMember/camliPath:*
+// targets), walked recursively through file and directory schema blobs
+// down to their raw data parts and static-set members.
+func reachableBlobs(ctx context.Context, idx *index.Index, fetcher blob.Fetcher) (map[blob.Ref]bool, error) {
+	reachable := make(map[blob.Ref]bool)
+	var queue []blob.Ref
+	mark := func(br blob.Ref) {
+		if !br.Valid() || reachable[br] {
+			return
+		}
+		reachable[br] = true
+		queue = append(queue, br)
+	}
+
+	var permanodes []blob.Ref
+	if err := idx.EnumerateBlobMeta(ctx, func(bm camtypes.BlobMeta) bool {
+		if bm.CamliType == schema.TypePermanode {
+			permanodes = append(permanodes, bm.Ref)
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	for _, pn := range permanodes {
+		if idx.IsDeleted(pn) {
+			// A deleted permanode (via a delete claim on the permanode
+			// blobref itself, as trash-purge issues) is no longer a
+			// live root: its blob stays in the index, but nothing it
+			// points to should be kept reachable through it.
+			continue
+		}
+		mark(pn)
+	}
+
+	for len(queue) > 0 {
+		br := queue[0]
+		queue = queue[1:]
+
+		bm, err := idx.GetBlobMeta(ctx, br)
+		if err != nil {
+			// Already marked reachable by something pointing to it, but
+			// gone from the index (or never a schema blob); nothing more
+			// to walk from here.
+			continue
+		}
+		switch bm.CamliType {
+		case schema.TypePermanode:
+			claims, err := idx.AppendClaims(ctx, nil, br, "", "")
+			if err != nil {
+				return nil, fmt.Errorf("listing claims of %v: %v", br, err)
+			}
+			for _, cl := range claims {
+				mark(cl.BlobRef)
+				mark(cl.Signer)
+				mark(cl.Target)
+				if cl.Attr == nodeattr.CamliContent || cl.Attr == nodeattr.CamliContentImage || cl.Attr == "camliMember" {
+					if v, ok := blob.Parse(cl.Value); ok {
+						mark(v)
+					}
+					continue
+				}
+				if strings.HasPrefix(cl.Attr, "camliPath:") {
+					if v, ok := blob.Parse(cl.Value); ok {
+						mark(v)
+					}
+				}
+			}
+		case schema.TypeFile, schema.TypeBytes:
+			b, err := fetchSchemaBlob(ctx, fetcher, br)
+			if err != nil {
+				log.Printf("gc: skipping unreadable %v schema blob %v: %v", bm.CamliType, br, err)
+				continue
+			}
+			for _, part := range b.ByteParts() {
+				mark(part.BlobRef)
+				mark(part.BytesRef)
+			}
+		case schema.TypeDirectory:
+			b, err := fetchSchemaBlob(ctx, fetcher, br)
+			if err != nil {
+				log.Printf("gc: skipping unreadable directory schema blob %v: %v", br, err)
+				continue
+			}
+			if staticSet, ok := b.DirectoryEntries(); ok {
+				mark(staticSet)
+			}
+		case schema.TypeStaticSet:
+			b, err := fetchSchemaBlob(ctx, fetcher, br)
+			if err != nil {
+				log.Printf("gc: skipping unreadable static-set schema blob %v: %v", br, err)
+				continue
+			}
+			for _, member := range b.StaticSetMembers() {
+				mark(member)
+			}
+			for _, sub := range b.StaticSetMergeSets() {
+				mark(sub)
+			}
+		}
+	}
+	return reachable, nil
+}
+
+// fetchSchemaBlob fetches and parses br's content as a schema blob,
+// from the same blobSource that backs the index being walked.
+func fetchSchemaBlob(ctx context.Context, fetcher blob.Fetcher, br blob.Ref) (*schema.Blob, error) {
+	rc, _, err := fetcher.Fetch(ctx, br)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return schema.BlobFromReader(br, rc)
+}
+
+// loadUnreferencedState reads gc's persisted record of when each
+// currently-unreferenced blob was first seen as such, keyed by the
+// blobSource path so multiple servers' indexes don't collide. It
+// returns an empty map (not an error) if there's no prior state, since
+// that just means every unreferenced blob found now is newly so.
+func (c *gcCmd) loadUnreferencedState(blobSourcePath string) (map[string]time.Time, string) {
+	h := sha1.Sum([]byte(blobSourcePath))
+	statePath := filepath.Join(osutil.CacheDir(), "camtool-gc", fmt.Sprintf("%x.json", h))
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, statePath
+	}
+	var state map[string]time.Time
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, statePath
+	}
+	return state, statePath
+}
+
+func writeUnreferencedState(statePath string, state map[string]time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(statePath), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, data, 0600)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/search"
+)
+
+type importMetadataCmd struct {
+	server string
+	mapFn  string
+	dryRun bool
+}
+
+func init() {
+	cmdmain.RegisterMode("import-metadata", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(importMetadataCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server to import to. "+serverFlagHelp)
+		flags.StringVar(&cmd.mapFn, "map", "", "Path to a JSON sidecar file mapping already-uploaded files to the attributes to attach to their permanodes. Required.")
+		flags.BoolVar(&cmd.dryRun, "dry-run", false, "Report what would be done, without writing any claims.")
+		return cmd
+	})
+}
+
+func (c *importMetadataCmd) Describe() string {
+	return "Attach attribute claims to existing permanodes, as described by a sidecar metadata file."
+}
+
+func (c *importMetadataCmd) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pk [globalopts] import-metadata --map=metadata.json\n")
+}
+
+func (c *importMetadataCmd) Examples() []string {
+	return []string{
+		"--map=metadata.json",
+		"--map=metadata.json --dry-run",
+	}
+}
+
+// importRecord is one entry of the --map sidecar file. It identifies a
+// permanode to update, either by the name of the file it's the content of,
+// or by an existing attribute it already carries (e.g. an external ID
+// attached by some other system), and lists the attributes to attach.
+type importRecord struct {
+	// FileName matches the permanode whose camliContent file has this
+	// name. Mutually exclusive with MatchAttr/MatchValue.
+	FileName string `json:"fileName,omitempty"`
+
+	// MatchAttr and MatchValue together match the permanode that already
+	// has attribute MatchAttr set to MatchValue (for example, an external
+	// ID attribute set during a previous import). Mutually exclusive with
+	// FileName.
+	MatchAttr  string `json:"matchAttr,omitempty"`
+	MatchValue string `json:"matchValue,omitempty"`
+
+	// Attrs are the attributes to attach to the matched permanode. A
+	// single value sets the attribute (replacing any previous value); a
+	// list of values sets the first value and adds the rest, for
+	// multi-valued attributes such as "tag".
+	Attrs map[string][]string `json:"attrs"`
+}
+
+func (c *importMetadataCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("import-metadata takes no arguments, use --map")
+	}
+	if c.mapFn == "" {
+		return cmdmain.UsageError("--map is required")
+	}
+	f, err := os.Open(c.mapFn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var records []*importRecord
+	if err := json.NewDecoder(f).Decode(&records); err != nil {
+		return fmt.Errorf("parsing %s: %v", c.mapFn, err)
+	}
+
+	cl := newClient(c.server)
+	var unmatched, errored int
+	for _, rec := range records {
+		pn, err := resolvePermanode(cl, rec)
+		if err != nil {
+			errored++
+			fmt.Fprintf(cmdmain.Stderr, "error resolving record %+v: %v\n", rec, err)
+			continue
+		}
+		if !pn.Valid() {
+			unmatched++
+			fmt.Fprintf(cmdmain.Stderr, "no matching permanode for record %+v\n", rec)
+			continue
+		}
+		errored += c.applyAttrs(cl, pn, rec)
+	}
+	if unmatched > 0 || errored > 0 {
+		return fmt.Errorf("import-metadata: %d unmatched, %d errored", unmatched, errored)
+	}
+	return nil
+}
+
+// resolvePermanode finds the single permanode matching rec, or a zero
+// (invalid) blob.Ref if none was found.
+func resolvePermanode(cl *client.Client, rec *importRecord) (blob.Ref, error) {
+	switch {
+	case rec.FileName != "" && rec.MatchAttr != "":
+		return blob.Ref{}, fmt.Errorf("record has both fileName and matchAttr/matchValue set")
+	case rec.FileName != "":
+		return resolvePermanodeByFileName(cl, rec.FileName)
+	case rec.MatchAttr != "":
+		if rec.MatchValue == "" {
+			return blob.Ref{}, fmt.Errorf("matchAttr %q set without matchValue", rec.MatchAttr)
+		}
+		return resolvePermanodeByAttr(cl, rec.MatchAttr, rec.MatchValue)
+	default:
+		return blob.Ref{}, fmt.Errorf("record has neither fileName nor matchAttr/matchValue")
+	}
+}
+
+func resolvePermanodeByFileName(cl *client.Client, fileName string) (blob.Ref, error) {
+	res, err := cl.Query(ctxbg, &search.SearchQuery{
+		Constraint: &search.Constraint{
+			Permanode: &search.PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &search.Constraint{
+					File: &search.FileConstraint{
+						FileName: &search.StringConstraint{Equals: fileName},
+					},
+				},
+			},
+		},
+		Limit: 2,
+	})
+	if err != nil {
+		return blob.Ref{}, err
+	}
+	switch len(res.Blobs) {
+	case 0:
+		return blob.Ref{}, nil
+	case 1:
+		return res.Blobs[0].Blob, nil
+	default:
+		return blob.Ref{}, fmt.Errorf("ambiguous: %d permanodes have a file named %q", len(res.Blobs), fileName)
+	}
+}
+
+func resolvePermanodeByAttr(cl *client.Client, attr, value string) (blob.Ref, error) {
+	res, err := cl.GetPermanodesWithAttr(ctxbg, &search.WithAttrRequest{
+		N:     2,
+		Attr:  attr,
+		Value: value,
+	})
+	if err != nil {
+		return blob.Ref{}, err
+	}
+	switch len(res.WithAttr) {
+	case 0:
+		return blob.Ref{}, nil
+	case 1:
+		return res.WithAttr[0].Permanode, nil
+	default:
+		return blob.Ref{}, fmt.Errorf("ambiguous: %d permanodes already have %s=%s", len(res.WithAttr), attr, value)
+	}
+}
+
+// applyAttrs writes the attribute claims in rec.Attrs to pn, or just
+// reports what it would do if c.dryRun is set. It returns the number of
+// claims that failed to write.
+func (c *importMetadataCmd) applyAttrs(cl *client.Client, pn blob.Ref, rec *importRecord) (errored int) {
+	for attr, values := range rec.Attrs {
+		for i, value := range values {
+			if c.dryRun {
+				verb := "add"
+				if i == 0 {
+					verb = "set"
+				}
+				fmt.Fprintf(cmdmain.Stdout, "would %s %s=%q on %v\n", verb, attr, value, pn)
+				continue
+			}
+			var claim *schema.Builder
+			if i == 0 {
+				claim = schema.NewSetAttributeClaim(pn, attr, value)
+			} else {
+				claim = schema.NewAddAttributeClaim(pn, attr, value)
+			}
+			if _, err := cl.UploadAndSignBlob(ctxbg, claim); err != nil {
+				errored++
+				fmt.Fprintf(cmdmain.Stderr, "error setting %s=%q on %v: %v\n", attr, value, pn, err)
+			}
+		}
+	}
+	return errored
+}
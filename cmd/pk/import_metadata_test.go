@@ -0,0 +1,68 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestImportRecordJSON(t *testing.T) {
+	const in = `[
+		{"fileName": "IMG_0001.jpg", "attrs": {"tag": ["vacation", "family"]}},
+		{"matchAttr": "externalId", "matchValue": "ext-42", "attrs": {"title": ["Some title"]}}
+	]`
+	var got []*importRecord
+	if err := json.Unmarshal([]byte(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []*importRecord{
+		{
+			FileName: "IMG_0001.jpg",
+			Attrs:    map[string][]string{"tag": {"vacation", "family"}},
+		},
+		{
+			MatchAttr:  "externalId",
+			MatchValue: "ext-42",
+			Attrs:      map[string][]string{"title": {"Some title"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestResolvePermanodeValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		rec     *importRecord
+		wantErr bool
+	}{
+		{"both-set", &importRecord{FileName: "a.jpg", MatchAttr: "externalId"}, true},
+		{"attr-without-value", &importRecord{MatchAttr: "externalId"}, true},
+		{"neither-set", &importRecord{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolvePermanode(nil, tt.rec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolvePermanode(%+v) error = %v; wantErr %v", tt.rec, err, tt.wantErr)
+			}
+		})
+	}
+}
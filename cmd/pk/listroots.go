@@ -0,0 +1,146 @@
+/*
+Copyright 2026 The Perkeep Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/search"
+)
+
+type listRootsCmd struct {
+	server string
+	limit  int
+}
+
+func init() {
+	cmdmain.RegisterMode("list-roots", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(listRootsCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server to find the root permanodes on. "+serverFlagHelp)
+		flags.IntVar(&cmd.limit, "limit", 1000, "Maximum number of roots to list.")
+		return cmd
+	})
+}
+
+func (c *listRootsCmd) Describe() string {
+	return "List the named roots (camliRoot permanodes) known to a server."
+}
+
+func (c *listRootsCmd) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pk [globalopts] list-roots\n")
+}
+
+func (c *listRootsCmd) Examples() []string {
+	return []string{}
+}
+
+func (c *listRootsCmd) RunCommand(args []string) error {
+	if len(args) > 0 {
+		return cmdmain.UsageError("doesn't take args")
+	}
+	cl := newClient(c.server)
+
+	withAttr, err := cl.GetPermanodesWithAttr(ctxbg, &search.WithAttrRequest{
+		N:    c.limit,
+		Attr: "camliRoot",
+	})
+	if err != nil {
+		return fmt.Errorf("could not find camliRoot permanodes: %v", err)
+	}
+	if len(withAttr.WithAttr) == c.limit {
+		fmt.Fprintf(os.Stderr, "warning: result was truncated at -limit=%d roots; there may be more\n", c.limit)
+	}
+
+	dr := &search.DescribeRequest{Depth: 2}
+	for _, wi := range withAttr.WithAttr {
+		dr.BlobRefs = append(dr.BlobRefs, wi.Permanode)
+	}
+	if len(dr.BlobRefs) == 0 {
+		return nil
+	}
+	described, err := cl.Describe(ctxbg, dr)
+	if err != nil {
+		return fmt.Errorf("could not describe root permanodes: %v", err)
+	}
+
+	var roots []rootInfo
+	for _, wi := range withAttr.WithAttr {
+		pn := wi.Permanode
+		db := described.Meta[pn.String()]
+		if db == nil || db.Permanode == nil {
+			continue
+		}
+		ri := rootInfo{
+			name:      db.Permanode.Attr.Get("camliRoot"),
+			permanode: pn,
+		}
+		if contentStr := db.Permanode.Attr.Get("camliContent"); contentStr != "" {
+			if content, ok := blob.Parse(contentStr); ok {
+				ri.content = content
+				ri.summary = summarizeRootContent(described.Meta[content.String()])
+			}
+		}
+		if ri.summary == "" {
+			ri.summary = "(no content)"
+		}
+		roots = append(roots, ri)
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i].name < roots[j].name })
+	for _, ri := range roots {
+		fmt.Fprintf(cmdmain.Stdout, "%s\t%s\t%s\t%s\n", ri.name, ri.permanode, ri.content, ri.summary)
+	}
+	return nil
+}
+
+type rootInfo struct {
+	name      string
+	permanode blob.Ref
+	content   blob.Ref // zero if the root has no camliContent
+	summary   string
+}
+
+// summarizeRootContent describes db, a root's content blob, as a short
+// "type" or "type (N members)" string. It returns "" if db is nil.
+func summarizeRootContent(db *search.DescribedBlob) string {
+	if db == nil {
+		return ""
+	}
+	if db.Permanode != nil && db.Permanode.IsContainer() {
+		return fmt.Sprintf("permanode (%d members)", len(memberRefs(db.Permanode)))
+	}
+	return string(db.CamliType)
+}
+
+// memberRefs returns the blobref strings of dp's unnamed (camliMember)
+// and named (camliPath:*) members.
+func memberRefs(dp *search.DescribedPermanode) []string {
+	var refs []string
+	refs = append(refs, dp.Attr["camliMember"]...)
+	for k, vv := range dp.Attr {
+		if strings.HasPrefix(k, "camliPath:") {
+			refs = append(refs, vv...)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/url"
+	"sort"
+	"testing"
+
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/search"
+)
+
+func TestSummarizeRootContent(t *testing.T) {
+	if got := summarizeRootContent(nil); got != "" {
+		t.Errorf("summarizeRootContent(nil) = %q; want empty", got)
+	}
+
+	file := &search.DescribedBlob{CamliType: schema.TypeFile}
+	if got, want := summarizeRootContent(file), "file"; got != want {
+		t.Errorf("summarizeRootContent(file) = %q; want %q", got, want)
+	}
+
+	container := &search.DescribedBlob{
+		CamliType: schema.TypePermanode,
+		Permanode: &search.DescribedPermanode{
+			Attr: url.Values{"camliMember": {"a", "b"}},
+		},
+	}
+	if got, want := summarizeRootContent(container), "permanode (2 members)"; got != want {
+		t.Errorf("summarizeRootContent(container) = %q; want %q", got, want)
+	}
+}
+
+func TestMemberRefs(t *testing.T) {
+	dp := &search.DescribedPermanode{
+		Attr: url.Values{
+			"camliMember":   {"a", "b"},
+			"camliPath:foo": {"c"},
+			"camliPath:bar": {"d"},
+			"somethingElse": {"e"},
+		},
+	}
+	got := memberRefs(dp)
+	sort.Strings(got)
+	want := []string{"a", "b", "c", "d"}
+	if len(got) != len(want) {
+		t.Fatalf("memberRefs = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("memberRefs = %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver/manifest"
+	"perkeep.org/pkg/cmdmain"
+)
+
+type manifestCmd struct {
+	server string
+	out    string
+}
+
+func init() {
+	cmdmain.RegisterMode("integrity-manifest", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(manifestCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server whose storage to snapshot. "+serverFlagHelp)
+		flags.StringVar(&cmd.out, "out", "", "Output file for the manifest. If empty, written to stdout.")
+		return cmd
+	})
+}
+
+func (c *manifestCmd) Describe() string {
+	return "Generate a signed integrity manifest listing every blobref and size in a storage backend, for later comparison with verify-integrity-manifest."
+}
+
+func (c *manifestCmd) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pk [globalopts] integrity-manifest [--out=manifest.txt]\n")
+}
+
+func (c *manifestCmd) Examples() []string {
+	return []string{
+		"--out=backup-2026-08-08.manifest",
+	}
+}
+
+func (c *manifestCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("integrity-manifest takes no arguments")
+	}
+	cl := newClient(c.server)
+	signer, err := cl.Signer()
+	if err != nil {
+		return fmt.Errorf("no configured Signer: %v", err)
+	}
+
+	w := cmdmain.Stdout
+	if c.out != "" {
+		f, err := os.Create(c.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		bw := bufio.NewWriter(f)
+		defer bw.Flush()
+		w = bw
+	}
+
+	return manifest.Write(ctxbg, cl, signer, w)
+}
+
+// verifyManifestCmd is registered by manifest.go too, since it shares
+// the manifest format and flags with manifestCmd.
+type verifyManifestCmd struct {
+	server string
+	in     string
+}
+
+func init() {
+	cmdmain.RegisterMode("verify-integrity-manifest", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(verifyManifestCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server whose storage to check against the manifest. "+serverFlagHelp)
+		flags.StringVar(&cmd.in, "in", "", "Manifest file to verify against. Required.")
+		return cmd
+	})
+}
+
+func (c *verifyManifestCmd) Describe() string {
+	return "Verify a signed integrity manifest's signature, then diff it against a storage backend's current contents to report added, removed, and changed blobs."
+}
+
+func (c *verifyManifestCmd) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pk [globalopts] verify-integrity-manifest --in=manifest.txt\n")
+}
+
+func (c *verifyManifestCmd) Examples() []string {
+	return []string{
+		"--in=backup-2026-08-08.manifest",
+	}
+}
+
+func (c *verifyManifestCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("verify-integrity-manifest takes no arguments")
+	}
+	if c.in == "" {
+		return cmdmain.UsageError("--in is required")
+	}
+	f, err := os.Open(c.in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cl := newClient(c.server)
+
+	live := make(chan blob.SizedRef, 16)
+	liveErrc := make(chan error, 1)
+	go func() { liveErrc <- cl.SimpleEnumerateBlobs(ctxbg, live) }()
+
+	var added, removed, changed int
+	mr := manifest.NewReader(f)
+	mSb, mErr := mr.Next()
+	lSb, lOK := <-live
+	for mErr != io.EOF || lOK {
+		switch {
+		case mErr != nil && mErr != io.EOF:
+			return fmt.Errorf("reading manifest: %v", mErr)
+		case mErr == io.EOF:
+			// Manifest exhausted: everything remaining live is new.
+			fmt.Fprintf(cmdmain.Stdout, "+ %v (%d bytes)\n", lSb.Ref, lSb.Size)
+			added++
+			lSb, lOK = <-live
+		case !lOK:
+			// Live storage exhausted: everything remaining in the manifest is gone.
+			fmt.Fprintf(cmdmain.Stdout, "- %v (%d bytes)\n", mSb.Ref, mSb.Size)
+			removed++
+			mSb, mErr = mr.Next()
+		case mSb.Ref == lSb.Ref:
+			if mSb.Size != lSb.Size {
+				fmt.Fprintf(cmdmain.Stdout, "! %v (was %d bytes, now %d bytes)\n", mSb.Ref, mSb.Size, lSb.Size)
+				changed++
+			}
+			mSb, mErr = mr.Next()
+			lSb, lOK = <-live
+		case mSb.Ref.Less(lSb.Ref):
+			fmt.Fprintf(cmdmain.Stdout, "- %v (%d bytes)\n", mSb.Ref, mSb.Size)
+			removed++
+			mSb, mErr = mr.Next()
+		default:
+			fmt.Fprintf(cmdmain.Stdout, "+ %v (%d bytes)\n", lSb.Ref, lSb.Size)
+			added++
+			lSb, lOK = <-live
+		}
+	}
+	if err := <-liveErrc; err != nil {
+		return fmt.Errorf("enumerating live storage: %v", err)
+	}
+
+	footer, err := mr.Verify(ctxbg, cl)
+	if err != nil {
+		return fmt.Errorf("manifest signature invalid: %v", err)
+	}
+	fmt.Fprintf(cmdmain.Stdout, "Manifest signature OK (created %s, %d blobs, %d bytes).\n", footer.CreatedTime, footer.BlobCount, footer.TotalSize)
+	fmt.Fprintf(cmdmain.Stdout, "%d added, %d removed, %d changed.\n", added, removed, changed)
+	if added > 0 || removed > 0 || changed > 0 {
+		return fmt.Errorf("storage differs from manifest: %d added, %d removed, %d changed", added, removed, changed)
+	}
+	return nil
+}
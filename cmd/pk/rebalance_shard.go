@@ -0,0 +1,135 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/internal/osutil"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/blobserver/localdisk"
+	"perkeep.org/pkg/blobserver/shard"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/serverinit"
+)
+
+type rebalanceShardCmd struct {
+	prefix string
+}
+
+func init() {
+	cmdmain.RegisterMode("rebalance-shard", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(rebalanceShardCmd)
+		flags.StringVar(&cmd.prefix, "prefix", "", "Prefix (as it appears in the server config) of the storage-shard handler to rebalance. Required if the config has more than one.")
+		return cmd
+	})
+}
+
+func (c *rebalanceShardCmd) Demote() bool { return true }
+
+func (c *rebalanceShardCmd) Describe() string {
+	return "Move blobs to their correct shard after a consistentHash shard config change"
+}
+
+func (c *rebalanceShardCmd) Usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pk [globalopts] rebalance-shard [--prefix=/foo/]")
+}
+
+// This tool only understands shards whose backends are plain "filesystem"
+// (localdisk) storages, since that's what it can construct without running
+// the whole server. Shards with other kinds of backends (encrypted,
+// replicated, remote, etc.) need to be rebalanced by an administrative
+// program that can instantiate arbitrary blobserver.Storage values, using
+// shard.Rebalance directly.
+func (c *rebalanceShardCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("doesn't take arguments")
+	}
+	cfg, err := serverinit.LoadFile(osutil.UserServerConfigPath())
+	if err != nil {
+		return err
+	}
+	low := cfg.LowLevelJSONConfig() //lint:ignore SA1019 we use it
+	prefixes, ok := low["prefixes"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("no 'prefixes' object in low-level (or converted) config file %s", osutil.UserServerConfigPath())
+	}
+	var found []string
+	var handlerArgs jsonconfig.Obj
+	for prefix, vei := range prefixes {
+		pmap, ok := vei.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pconf := jsonconfig.Obj(pmap)
+		if pconf.RequiredString("handler") != "storage-shard" {
+			continue
+		}
+		if c.prefix != "" && prefix != c.prefix {
+			continue
+		}
+		found = append(found, prefix)
+		handlerArgs = jsonconfig.Obj(pconf.OptionalObject("handlerArgs"))
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no storage-shard handler found in server config file %s", osutil.UserServerConfigPath())
+	}
+	if len(found) > 1 {
+		return fmt.Errorf("ambiguity: server config file %s has more than one storage-shard handler; pass -prefix to pick one of: %v", osutil.UserServerConfigPath(), found)
+	}
+	if !handlerArgs.OptionalBool("consistentHash", false) {
+		return fmt.Errorf("shard %q isn't configured with \"consistentHash\": true, so there's nothing to rebalance", found[0])
+	}
+	backendPrefixes := handlerArgs.RequiredList("backends")
+	virtualNodes := handlerArgs.OptionalInt("virtualNodes", 0)
+	backends := make([]blobserver.Storage, len(backendPrefixes))
+	for i, bp := range backendPrefixes {
+		bconf, ok := prefixes[bp].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("backend %q of shard %q not found in server config", bp, found[0])
+		}
+		bpconf := jsonconfig.Obj(bconf)
+		if bpconf.RequiredString("handler") != "filesystem" {
+			return fmt.Errorf("backend %q of shard %q is a %q handler; rebalance-shard only supports \"filesystem\" backends", bp, found[0], bpconf.RequiredString("handler"))
+		}
+		path := jsonconfig.Obj(bpconf.OptionalObject("handlerArgs")).RequiredString("path")
+		if path == "" {
+			return fmt.Errorf("backend %q of shard %q has no path", bp, found[0])
+		}
+		disk, err := localdisk.New(path)
+		if err != nil {
+			return fmt.Errorf("opening backend %q at %q: %v", bp, path, err)
+		}
+		backends[i] = disk
+	}
+	sto, err := shard.NewForRebalance(backends, virtualNodes)
+	if err != nil {
+		return err
+	}
+	log.Printf("rebalancing shard %q (%d backends)...", found[0], len(backends))
+	moved, err := shard.Rebalance(context.Background(), sto)
+	if err != nil {
+		return err
+	}
+	log.Printf("rebalance complete: moved %d blobs", moved)
+	return nil
+}
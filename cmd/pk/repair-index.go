@@ -0,0 +1,180 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/internal/osutil"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/blobserver/localdisk"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/index"
+	"perkeep.org/pkg/serverinit"
+	"perkeep.org/pkg/sorted"
+	"perkeep.org/pkg/types/camtypes"
+)
+
+type repairIndexCmd struct {
+	prefix string
+	dryRun bool
+}
+
+func init() {
+	cmdmain.RegisterMode("repair-index", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(repairIndexCmd)
+		flags.StringVar(&cmd.prefix, "prefix", "", "Prefix (as it appears in the server config) of the storage-index handler to repair. Required if the config has more than one.")
+		flags.BoolVar(&cmd.dryRun, "dry-run", true, "Report stale index entries without removing them. Pass -dry-run=false to actually remove them.")
+		return cmd
+	})
+}
+
+func (c *repairIndexCmd) Demote() bool { return true }
+
+func (c *repairIndexCmd) Describe() string {
+	return "Remove index entries for blobs that no longer exist in the blob storage backing the index."
+}
+
+func (c *repairIndexCmd) Usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pk [globalopts] repair-index [--prefix=/index/] [--dry-run=false]")
+}
+
+// This tool only understands storage-index handlers whose blobSource is a
+// plain "filesystem" (localdisk) storage, since that's what it can construct
+// without running the whole server. Indexes whose blobSource is some other
+// kind of storage (encrypted, replicated, remote, etc.) need repairing with
+// an administrative program that can instantiate arbitrary
+// blobserver.Storage values.
+func (c *repairIndexCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("doesn't take arguments")
+	}
+	cfg, err := serverinit.LoadFile(osutil.UserServerConfigPath())
+	if err != nil {
+		return err
+	}
+	low := cfg.LowLevelJSONConfig() //lint:ignore SA1019 we use it
+	prefixes, ok := low["prefixes"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("no 'prefixes' object in low-level (or converted) config file %s", osutil.UserServerConfigPath())
+	}
+	var found []string
+	var handlerArgs jsonconfig.Obj
+	for prefix, vei := range prefixes {
+		pmap, ok := vei.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pconf := jsonconfig.Obj(pmap)
+		if pconf.RequiredString("handler") != "storage-index" {
+			continue
+		}
+		if c.prefix != "" && prefix != c.prefix {
+			continue
+		}
+		found = append(found, prefix)
+		handlerArgs = jsonconfig.Obj(pconf.OptionalObject("handlerArgs"))
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no storage-index handler found in server config file %s", osutil.UserServerConfigPath())
+	}
+	if len(found) > 1 {
+		return fmt.Errorf("ambiguity: server config file %s has more than one storage-index handler; pass -prefix to pick one of: %v", osutil.UserServerConfigPath(), found)
+	}
+
+	blobSourcePrefix := handlerArgs.RequiredString("blobSource")
+	bconf, ok := prefixes[blobSourcePrefix].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("blobSource %q of index %q not found in server config", blobSourcePrefix, found[0])
+	}
+	bpconf := jsonconfig.Obj(bconf)
+	if bpconf.RequiredString("handler") != "filesystem" {
+		return fmt.Errorf("blobSource %q of index %q is a %q handler; repair-index only supports \"filesystem\" blob sources", blobSourcePrefix, found[0], bpconf.RequiredString("handler"))
+	}
+	path := jsonconfig.Obj(bpconf.OptionalObject("handlerArgs")).RequiredString("path")
+	if path == "" {
+		return fmt.Errorf("blobSource %q of index %q has no path", blobSourcePrefix, found[0])
+	}
+	blobSource, err := localdisk.New(path)
+	if err != nil {
+		return fmt.Errorf("opening blobSource %q at %q: %v", blobSourcePrefix, path, err)
+	}
+
+	kv, err := sorted.NewKeyValue(jsonconfig.Obj(handlerArgs.RequiredObject("storage")))
+	if err != nil {
+		return fmt.Errorf("opening index storage of %q: %v", found[0], err)
+	}
+	defer kv.Close()
+	idx, err := index.New(kv)
+	if err != nil {
+		return fmt.Errorf("opening index %q: %v", found[0], err)
+	}
+	defer idx.Close()
+
+	if c.dryRun {
+		log.Printf("dry run (pass -dry-run=false to actually remove stale entries)")
+	}
+
+	ctx := context.Background()
+	var stale []blob.Ref
+	var checked, skipped int
+	err = idx.EnumerateBlobMeta(ctx, func(bm camtypes.BlobMeta) bool {
+		checked++
+		exists, statErr := blobExists(ctx, blobSource, bm.Ref)
+		if statErr != nil {
+			// The backend may just be temporarily unavailable; don't
+			// treat that as evidence the blob is gone.
+			log.Printf("stat error for %v: %v (skipping)", bm.Ref, statErr)
+			skipped++
+			return true
+		}
+		if !exists {
+			stale = append(stale, bm.Ref)
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("enumerating index: %v", err)
+	}
+
+	for _, br := range stale {
+		log.Printf("stale index entry for missing blob %v", br)
+		if c.dryRun {
+			continue
+		}
+		if err := idx.DeleteBlobMeta(ctx, br); err != nil {
+			log.Printf("error removing stale index entry for %v: %v", br, err)
+		}
+	}
+
+	log.Printf("checked %d index entries: %d stale, %d skipped (stat errors)", checked, len(stale), skipped)
+	return nil
+}
+
+func blobExists(ctx context.Context, src blobserver.BlobStatter, br blob.Ref) (exists bool, err error) {
+	err = src.StatBlobs(ctx, []blob.Ref{br}, func(blob.SizedRef) error {
+		exists = true
+		return nil
+	})
+	return exists, err
+}
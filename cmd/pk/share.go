@@ -0,0 +1,265 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/search"
+)
+
+// shareRegistryAttr is the (multi-valued) permanode attribute used to
+// remember the share blobs created by "pk share create", so "pk share
+// list" and "pk share revoke" have something to enumerate. The search
+// index doesn't otherwise expose a way to look up a signer's share
+// claims, so a registry permanode is kept instead, the same way
+// named-search-set/-get keep one for named searches (see
+// searchnames.go).
+const shareRegistryAttr = "camliShare"
+
+var shareSubModes = map[string]*shareSubMode{
+	"create": {
+		doc: "Create a new share link for a blobref or search.",
+		fun: (*shareCmd).runCreate,
+	},
+	"list": {
+		doc: "List the share links created with \"pk share create\".",
+		fun: (*shareCmd).runList,
+	},
+	"revoke": {
+		doc: "Revoke a previously created share link.",
+		fun: (*shareCmd).runRevoke,
+	},
+}
+
+type shareSubMode struct {
+	doc string
+	fun func(*shareCmd, []string) error
+}
+
+type shareCmd struct {
+	server     string
+	search     string
+	transitive bool
+	duration   time.Duration // zero means forever
+	password   string        // unsupported; see runCreate
+	maxUses    int           // unsupported; see runCreate
+}
+
+func init() {
+	cmdmain.RegisterMode("share", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(shareCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server to share from/query. "+serverFlagHelp)
+		flags.StringVar(&cmd.search, "search", "", "For \"create\": share a search result, rather than a single blob. Should be the JSON representation of a search.SearchQuery. Exclusive with, and overrides, the <blobref> argument.")
+		flags.BoolVar(&cmd.transitive, "transitive", false, "For \"create\": share everything reachable from the given blobref.")
+		flags.DurationVar(&cmd.duration, "duration", 0, "For \"create\": how long the share is valid for. Zero means forever.")
+		flags.StringVar(&cmd.password, "password", "", "For \"create\": require this password to redeem the share. Not currently supported; see the pk-put/share.go schema. Passing this flag returns an error.")
+		flags.IntVar(&cmd.maxUses, "max-uses", 0, "For \"create\": limit the number of times the share may be redeemed. Not currently supported by the share schema. Passing this flag returns an error.")
+		return cmd
+	})
+}
+
+func (c *shareCmd) Describe() string {
+	return "Create and manage share links."
+}
+
+func (c *shareCmd) Usage() {
+	var subModes, docs string
+	for k, v := range shareSubModes {
+		subModes += k + "|"
+		docs += fmt.Sprintf("	%s: %s\n", k, v.doc)
+	}
+	subModes = strings.TrimRight(subModes, "|")
+	fmt.Fprintf(os.Stderr,
+		"Usage: pk [globalopts] share [%s] ...\n%s", subModes, docs)
+}
+
+func (c *shareCmd) Examples() []string {
+	return []string{
+		"create -transitive sha1-83896fcb182db73b653181652129d739280766b5",
+		`create -search='{"expression":"tag:blogphotos is:image"}'`,
+		"list",
+		"revoke sha1-388a90c73a5eb45123ca566d3ded9de3c15b9d38",
+	}
+}
+
+func (c *shareCmd) RunCommand(args []string) error {
+	if len(args) < 1 {
+		return cmdmain.UsageError("share requires a submode: create, list, or revoke")
+	}
+	subMode, ok := shareSubModes[args[0]]
+	if !ok {
+		return cmdmain.UsageError(fmt.Sprintf("invalid share submode: %v", args[0]))
+	}
+	return subMode.fun(c, args[1:])
+}
+
+// runCreate implements "pk share create", the equivalent of "pk-put
+// share" but additionally recording the new share on a registry
+// permanode so it can later be listed or revoked by "pk share".
+func (c *shareCmd) runCreate(args []string) error {
+	if c.password != "" || c.maxUses != 0 {
+		return cmdmain.UsageError("share create does not support -password or -max-uses: the share schema (see pkg/schema/blob.go's Share type) has no field for either yet")
+	}
+
+	unsigned := schema.NewShareRef(schema.ShareHaveRef, c.transitive)
+	if c.search != "" {
+		if len(args) != 0 {
+			return cmdmain.UsageError("when using -search, share create takes zero arguments")
+		}
+		var q search.SearchQuery
+		if err := json.Unmarshal([]byte(c.search), &q); err != nil {
+			return cmdmain.UsageError(fmt.Sprintf("invalid search: %v", err))
+		}
+		unsigned.SetShareSearch(&q)
+	} else {
+		if len(args) != 1 {
+			return cmdmain.UsageError("share create takes exactly one <blobref> argument, or -search")
+		}
+		target, ok := blob.Parse(args[0])
+		if !ok {
+			return cmdmain.UsageError("invalid blobref: " + args[0])
+		}
+		unsigned.SetShareTarget(target)
+	}
+	if c.duration != 0 {
+		unsigned.SetShareExpiration(time.Now().Add(c.duration))
+	}
+
+	cl := newClient(c.server)
+	shareRoot, err := cl.ShareRoot()
+	if err != nil {
+		return err
+	}
+	pr, err := cl.UploadAndSignBlob(ctxbg, unsigned)
+	if err != nil {
+		return err
+	}
+
+	pn, err := shareRegistryPermanode(cl)
+	if err != nil {
+		return fmt.Errorf("share was created (%s) but could not be recorded for \"pk share list\"/\"revoke\": %v", pr.BlobRef, err)
+	}
+	if _, err := cl.UploadAndSignBlob(ctxbg, schema.NewAddAttributeClaim(pn, shareRegistryAttr, pr.BlobRef.String())); err != nil {
+		return fmt.Errorf("share was created (%s) but could not be recorded for \"pk share list\"/\"revoke\": %v", pr.BlobRef, err)
+	}
+
+	fmt.Fprintf(cmdmain.Stdout, "%s%s\n", shareRoot, pr.BlobRef)
+	return nil
+}
+
+// runList implements "pk share list": it describes the registry
+// permanode populated by runCreate and prints each recorded share
+// blob along with its target and expiration.
+func (c *shareCmd) runList(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("share list takes no arguments")
+	}
+	cl := newClient(c.server)
+	pn, err := shareRegistryPermanode(cl)
+	if err != nil {
+		return err
+	}
+	dr, err := cl.Describe(ctxbg, &search.DescribeRequest{BlobRef: pn})
+	if err != nil {
+		return err
+	}
+	db := dr.Meta.Get(pn)
+	if db == nil || db.Permanode == nil {
+		return nil
+	}
+	for _, v := range db.Permanode.Attr[shareRegistryAttr] {
+		shareRef, ok := blob.Parse(v)
+		if !ok {
+			continue
+		}
+		sb, err := cl.FetchSchemaBlob(ctxbg, shareRef)
+		if err != nil {
+			fmt.Fprintf(cmdmain.Stdout, "%s\terror: %v\n", shareRef, err)
+			continue
+		}
+		share, ok := sb.AsShare()
+		if !ok {
+			fmt.Fprintf(cmdmain.Stdout, "%s\tnot a share blob (already revoked?)\n", shareRef)
+			continue
+		}
+		status := "active"
+		if share.IsExpired() {
+			status = "expired"
+		}
+		fmt.Fprintf(cmdmain.Stdout, "%s\ttarget=%s\ttransitive=%v\t%s\n", shareRef, share.Target(), share.IsTransitive(), status)
+	}
+	return nil
+}
+
+// runRevoke implements "pk share revoke": it deletes the share claim
+// (so the server stops honoring it) and removes it from the registry
+// permanode so it no longer shows up in "pk share list".
+func (c *shareCmd) runRevoke(args []string) error {
+	if len(args) != 1 {
+		return cmdmain.UsageError("share revoke takes exactly one <shareblobref> argument")
+	}
+	shareRef, ok := blob.Parse(args[0])
+	if !ok {
+		return cmdmain.UsageError("invalid blobref: " + args[0])
+	}
+	cl := newClient(c.server)
+	if _, err := cl.UploadAndSignBlob(ctxbg, schema.NewDeleteClaim(shareRef)); err != nil {
+		return fmt.Errorf("failed to revoke %s: %v", shareRef, err)
+	}
+	pn, err := shareRegistryPermanode(cl)
+	if err != nil {
+		return err
+	}
+	if _, err := cl.UploadAndSignBlob(ctxbg, schema.NewDelAttributeClaim(pn, shareRegistryAttr, shareRef.String())); err != nil {
+		return fmt.Errorf("%s was revoked, but could not be removed from \"pk share list\": %v", shareRef, err)
+	}
+	return nil
+}
+
+// shareRegistryPermanode returns the blobref of the permanode used to
+// remember created shares, creating it (and giving it a title) the
+// first time it's needed.
+func shareRegistryPermanode(cl *client.Client) (blob.Ref, error) {
+	sr, err := cl.Query(ctxbg, search.NamedSearch("camli-share-registry"))
+	if err == nil && len(sr.Blobs) > 0 {
+		return sr.Blobs[0].Blob, nil
+	}
+	pr, err := cl.UploadAndSignBlob(ctxbg, schema.NewUnsignedPermanode())
+	if err != nil {
+		return blob.Ref{}, err
+	}
+	pn := pr.BlobRef
+	for _, claimBuilder := range []*schema.Builder{
+		schema.NewSetAttributeClaim(pn, "camliNamedSearch", "camli-share-registry"),
+		schema.NewSetAttributeClaim(pn, "title", "pk share registry"),
+	} {
+		if _, err := cl.UploadAndSignBlob(ctxbg, claimBuilder); err != nil {
+			return blob.Ref{}, err
+		}
+	}
+	return pn, nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+	"perkeep.org/pkg/client/credhelper"
+	"perkeep.org/pkg/cmdmain"
+)
+
+type storeCredentialCmd struct {
+	server string
+	user   string
+}
+
+func init() {
+	cmdmain.RegisterMode("store-credential", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(storeCredentialCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server to store credentials for. "+serverFlagHelp)
+		flags.StringVar(&cmd.user, "user", "", "Username, for the \"userpass\" auth scheme. Required.")
+		return cmd
+	})
+}
+
+func (c *storeCredentialCmd) Describe() string {
+	return "Store this server's password in the OS credential helper (Keychain, Secret Service, ...), instead of the plaintext client config file."
+}
+
+func (c *storeCredentialCmd) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: pk [globalopts] store-credential --user=joe\n")
+}
+
+func (c *storeCredentialCmd) Examples() []string {
+	return []string{
+		"--user=joe",
+	}
+}
+
+func (c *storeCredentialCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("store-credential takes no arguments")
+	}
+	if c.user == "" {
+		return cmdmain.UsageError("--user is required")
+	}
+
+	cl := newClient(c.server)
+
+	fmt.Fprintf(cmdmain.Stderr, "Password for %s: ", c.user)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(cmdmain.Stderr)
+	if err != nil {
+		return fmt.Errorf("reading password: %v", err)
+	}
+
+	if err := credhelper.Set(cl.Server(), "userpass:"+c.user+":"+string(password)); err != nil {
+		if err == credhelper.ErrUnavailable {
+			return fmt.Errorf("no OS credential helper available on this platform; add \"auth\" to your client config instead")
+		}
+		return fmt.Errorf("storing credential: %v", err)
+	}
+	fmt.Fprintf(cmdmain.Stdout, "Stored credentials for %s.\n", cl.Server())
+	return nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/cmdmain"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/search"
+)
+
+type swapRootCmd struct {
+	server string
+}
+
+func init() {
+	cmdmain.RegisterMode("swap-root", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(swapRootCmd)
+		flags.StringVar(&cmd.server, "server", "", "Server whose root to swap. "+serverFlagHelp)
+		return cmd
+	})
+}
+
+func (c *swapRootCmd) Describe() string {
+	return "Point a named root (camliRoot) at a new content directory, printing the previous content ref for rollback."
+}
+
+func (c *swapRootCmd) Usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pk [globalopts] swap-root <root-name> <new-content-blobref>")
+}
+
+func (c *swapRootCmd) Examples() []string {
+	return []string{
+		"mysite sha224-abc123...",
+	}
+}
+
+// RunCommand points the camliRoot named by args[0] at the directory blobref
+// in args[1], via a single camliContent claim, and prints the root's
+// previous camliContent ref (or "" if it had none) so a caller can roll
+// back with another swap-root call.
+//
+// Perkeep's permanode attribute claims are already ordered and resolved
+// deterministically by claim time (see schema.Claim and the index's claim
+// processing), so two concurrent swap-root calls against the same root
+// don't need any extra locking here: whichever claim has the later claim
+// date wins, and the loser's caller still gets back an accurate "previous
+// content" value to retry or roll back with. That's why this is
+// implemented as a CLI command against the existing search and
+// claim-signing handlers, rather than a new server-side endpoint: the
+// atomicity guarantee it needs (one claim swaps the whole tree at once)
+// is already provided by camliContent being a single-valued attribute.
+func (c *swapRootCmd) RunCommand(args []string) error {
+	if len(args) != 2 {
+		return cmdmain.UsageError("expected 2 arguments: <root-name> <new-content-blobref>")
+	}
+	rootName, contentStr := args[0], args[1]
+	content, ok := blob.Parse(contentStr)
+	if !ok {
+		return cmdmain.UsageError(fmt.Sprintf("invalid blobref %q", contentStr))
+	}
+
+	cl := newClient(c.server)
+
+	described, err := cl.Describe(ctxbg, &search.DescribeRequest{BlobRef: content})
+	if err != nil {
+		return fmt.Errorf("looking up new content %v: %v", content, err)
+	}
+	db := described.Meta[content.String()]
+	if db == nil {
+		return fmt.Errorf("new content blob %v does not exist on the server", content)
+	}
+	if db.CamliType != schema.TypeDirectory {
+		return fmt.Errorf("new content %v is a %q, not a directory", content, db.CamliType)
+	}
+
+	withAttr, err := cl.GetPermanodesWithAttr(ctxbg, &search.WithAttrRequest{
+		N:     1,
+		Attr:  "camliRoot",
+		Value: rootName,
+	})
+	if err != nil {
+		return fmt.Errorf("looking up root %q: %v", rootName, err)
+	}
+	if len(withAttr.WithAttr) == 0 {
+		return fmt.Errorf("no camliRoot permanode named %q", rootName)
+	}
+	pn := withAttr.WithAttr[0].Permanode
+
+	pnDescribed, err := cl.Describe(ctxbg, &search.DescribeRequest{BlobRef: pn})
+	if err != nil {
+		return fmt.Errorf("describing root permanode %v: %v", pn, err)
+	}
+	var previous string
+	if rootDB := pnDescribed.Meta[pn.String()]; rootDB != nil && rootDB.Permanode != nil {
+		previous = rootDB.Permanode.Attr.Get("camliContent")
+	}
+
+	if _, err := cl.UploadAndSignBlob(ctxbg, schema.NewSetAttributeClaim(pn, "camliContent", content.String())); err != nil {
+		return fmt.Errorf("setting camliContent on %v: %v", pn, err)
+	}
+
+	out, err := json.MarshalIndent(swapRootResponse{
+		Root:            rootName,
+		Permanode:       pn,
+		Content:         content,
+		PreviousContent: previous,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmdmain.Stdout, string(out))
+	return nil
+}
+
+type swapRootResponse struct {
+	Root            string   `json:"root"`
+	Permanode       blob.Ref `json:"permanode"`
+	Content         blob.Ref `json:"content"`
+	PreviousContent string   `json:"previousContent,omitempty"`
+}
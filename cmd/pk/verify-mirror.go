@@ -0,0 +1,84 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"perkeep.org/pkg/blobserver/localdisk"
+	"perkeep.org/pkg/client"
+	"perkeep.org/pkg/cmdmain"
+)
+
+type verifyMirrorCmd struct {
+	local  string
+	server string
+	sample float64
+}
+
+func init() {
+	cmdmain.RegisterMode("verify-mirror", func(flags *flag.FlagSet) cmdmain.CommandRunner {
+		cmd := new(verifyMirrorCmd)
+		flags.StringVar(&cmd.local, "local", "", "Path to the local disk mirror to verify and repair. Required.")
+		flags.StringVar(&cmd.server, "server", "", "Server to repair the mirror from. "+serverFlagHelp)
+		flags.Float64Var(&cmd.sample, "sample", 0, "Fraction, between 0 and 1, of blobs already present at the right size to additionally re-hash and compare against their digest, to catch bit rot. 0 (the default) only catches blobs that are missing or truncated, which is much cheaper for a large mirror.")
+		return cmd
+	})
+}
+
+func (c *verifyMirrorCmd) Describe() string {
+	return "Verify a local disk mirror against a server, and re-fetch anything missing or corrupt."
+}
+
+func (c *verifyMirrorCmd) Usage() {
+	fmt.Fprintln(cmdmain.Stderr, "Usage: pk [globalopts] verify-mirror --local=/path/to/mirror [--server=host] [--sample=0.01]")
+}
+
+func (c *verifyMirrorCmd) RunCommand(args []string) error {
+	if len(args) != 0 {
+		return cmdmain.UsageError("doesn't take arguments")
+	}
+	if c.local == "" {
+		return cmdmain.UsageError("--local is required")
+	}
+	if c.sample < 0 || c.sample > 1 {
+		return cmdmain.UsageError("--sample must be between 0 and 1")
+	}
+	local, err := localdisk.New(c.local)
+	if err != nil {
+		return fmt.Errorf("opening local mirror %q: %v", c.local, err)
+	}
+
+	cl := newClient(c.server)
+
+	stats, err := cl.VerifyAndRepairMirror(context.Background(), local, client.MirrorRepairOpts{
+		SampleRate: c.sample,
+		Logf:       log.Printf,
+	})
+	cmdmain.Logf("verify-mirror: checked %d blobs; %d missing, %d corrupt, %d repaired, %d failed to repair",
+		stats.Checked, stats.Missing, stats.Corrupt, stats.Repaired, stats.Failed)
+	if err != nil {
+		return err
+	}
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d blobs could not be repaired", stats.Failed)
+	}
+	return nil
+}
@@ -104,6 +104,18 @@ func (c *Cache) RemoveOldest() (key string, value interface{}) {
 	return c.removeOldest()
 }
 
+// RemoveKey removes the provided key from the cache, if present.
+func (c *Cache) RemoveKey(key string) {
+	if !c.nolock {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.ll.Remove(ele)
+		delete(c.cache, key)
+	}
+}
+
 // note: must hold c.mu
 func (c *Cache) removeOldest() (key string, value interface{}) {
 	ele := c.ll.Back()
@@ -55,6 +55,21 @@ func TestLRU(t *testing.T) {
 	expectMiss("1")
 }
 
+func TestRemoveKey(t *testing.T) {
+	c := New(2)
+	c.Add("1", "one")
+	c.Add("2", "two")
+	c.RemoveKey("1")
+	if v, ok := c.Get("1"); ok {
+		t.Fatalf("expected cache miss on key \"1\" but hit value %v", v)
+	}
+	if v, ok := c.Get("2"); !ok || v != "two" {
+		t.Fatalf("cache(\"2\") = %v, %v; want \"two\", true", v, ok)
+	}
+	// Removing an absent key is a no-op.
+	c.RemoveKey("no-such-key")
+}
+
 func TestRemoveOldest(t *testing.T) {
 	c := New(2)
 	c.Add("1", "one")
@@ -0,0 +1,73 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package appendonly registers the "appendonly" blobserver storage
+// type, a transparent wrapper around another storage target that
+// rejects every RemoveBlobs call, so no blob can ever be deleted
+// through it.
+//
+// It's meant for operators who want a strictly immutable store: once a
+// blob is written, it stays until the underlying storage is edited by
+// hand, outside of Perkeep. Note that this doesn't make the store
+// read-only in a data sense: share revocation and attribute changes
+// are still possible, since those work by writing new delete-claim
+// blobs (see doc/schema/), not by removing existing ones.
+//
+// Example config:
+//
+//	"/bs/": {
+//	    "handler": "storage-appendonly",
+//	    "handlerArgs": {
+//	        "from": "/bs-disk/"
+//	    }
+//	}
+package appendonly // import "perkeep.org/pkg/blobserver/appendonly"
+
+import (
+	"context"
+	"fmt"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+// ErrReadOnly is returned by RemoveBlobs on an appendonly storage.
+var ErrReadOnly = fmt.Errorf("appendonly: blob removal is disabled on this storage")
+
+type storage struct {
+	blobserver.Storage // the wrapped storage; all other methods pass through
+}
+
+func newFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (blobserver.Storage, error) {
+	fromName := conf.RequiredString("from")
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	from, err := ld.GetStorage(fromName)
+	if err != nil {
+		return nil, fmt.Errorf("appendonly: invalid 'from' storage %q: %v", fromName, err)
+	}
+	return &storage{Storage: from}, nil
+}
+
+func (s *storage) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
+	return ErrReadOnly
+}
+
+func init() {
+	blobserver.RegisterStorageConstructor("appendonly", blobserver.StorageConstructor(newFromConfig))
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appendonly
+
+import (
+	"context"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/blobserver/memory"
+)
+
+var ctxbg = context.Background()
+
+func TestRemoveBlobsRejected(t *testing.T) {
+	from := new(memory.Storage)
+	sto := &storage{Storage: from}
+
+	br := blob.RefFromString("hello")
+	if err := sto.RemoveBlobs(ctxbg, []blob.Ref{br}); err != ErrReadOnly {
+		t.Fatalf("RemoveBlobs error = %v; want %v", err, ErrReadOnly)
+	}
+}
+
+func TestOtherMethodsPassThrough(t *testing.T) {
+	from := new(memory.Storage)
+	sto := &storage{Storage: from}
+
+	var _ blobserver.Storage = sto
+
+	br, err := blobserver.ReceiveString(ctxbg, sto, "hello")
+	if err != nil {
+		t.Fatalf("ReceiveBlob: %v", err)
+	}
+	rc, _, err := sto.Fetch(ctxbg, br.Ref)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	rc.Close()
+
+	// Removal must still fail even though the underlying storage has
+	// the blob and would otherwise happily remove it.
+	if err := sto.RemoveBlobs(ctxbg, []blob.Ref{br.Ref}); err != ErrReadOnly {
+		t.Fatalf("RemoveBlobs error = %v; want %v", err, ErrReadOnly)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package b2 registers the "b2" blobserver storage type, storing blobs in
+a Backblaze B2 bucket.
+
+B2 exposes an S3-compatible API, so this package is a thin wrapper
+around pkg/blobserver/s3: it translates B2's native "key ID" /
+"application key" credential terminology into the s3 package's
+configuration, and raises the default retry budget to better tolerate
+B2's 429 (rate limited) and 503 (unavailable) responses. Large blobs are
+uploaded using the same s3manager-driven multipart ("part API") upload
+path as pkg/blobserver/s3, and, as with S3, an object only becomes
+visible to readers once its upload (or multipart completion) succeeds,
+so partial uploads are never observed by other clients.
+
+Example low-level config:
+
+	"/r1/": {
+	    "handler": "storage-b2",
+	    "handlerArgs": {
+	       "bucket": "foo",
+	       "hostname": "s3.us-west-004.backblazeb2.com",
+	       "key_id": "...",
+	       "application_key": "..."
+	     }
+	},
+*/
+package b2 // import "perkeep.org/pkg/blobserver/b2"
+
+import (
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/blobserver/s3"
+
+	"go4.org/jsonconfig"
+)
+
+// defaultMaxRetries is used in place of the s3 package's own default (3)
+// because B2 has been observed to return 429s more readily than AWS S3
+// under bursty upload traffic, and each retry is cheap relative to
+// failing the whole upload.
+const defaultMaxRetries = 8
+
+func newFromConfig(loader blobserver.Loader, config jsonconfig.Obj) (blobserver.Storage, error) {
+	keyID := config.RequiredString("key_id")
+	appKey := config.RequiredString("application_key")
+	bucket := config.RequiredString("bucket")
+	hostname := config.RequiredString("hostname")
+	cacheSize := config.OptionalInt64("cacheSize", 32<<20)
+	skipStartupCheck := config.OptionalBool("skipStartupCheck", false)
+	maxRetries := config.OptionalInt("maxRetries", defaultMaxRetries)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfigWithTransport(loader, jsonconfig.Obj{
+		"aws_access_key":        keyID,
+		"aws_secret_access_key": appKey,
+		"bucket":                bucket,
+		"hostname":              hostname,
+		"cacheSize":             float64(cacheSize),
+		"skipStartupCheck":      skipStartupCheck,
+		"maxRetries":            float64(maxRetries),
+	}, nil)
+}
+
+func init() {
+	blobserver.RegisterStorageConstructor("b2", blobserver.StorageConstructor(newFromConfig))
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package b2
+
+import (
+	"testing"
+
+	"go4.org/jsonconfig"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	_, err := newFromConfig(nil, jsonconfig.Obj{
+		"key_id":           "some-key-id",
+		"application_key":  "some-application-key",
+		"bucket":           "my-bucket",
+		"hostname":         "s3.us-west-004.backblazeb2.com",
+		"skipStartupCheck": true,
+	})
+	if err != nil {
+		t.Fatalf("newFromConfig: %v", err)
+	}
+}
+
+func TestNewFromConfigMissingRequired(t *testing.T) {
+	for _, key := range []string{"key_id", "application_key", "bucket", "hostname"} {
+		config := jsonconfig.Obj{
+			"key_id":          "some-key-id",
+			"application_key": "some-application-key",
+			"bucket":          "my-bucket",
+			"hostname":        "s3.us-west-004.backblazeb2.com",
+		}
+		delete(config, key)
+		if _, err := newFromConfig(nil, config); err == nil {
+			t.Errorf("newFromConfig with %q missing: got no error, want one", key)
+		}
+	}
+}
@@ -1065,6 +1065,12 @@ func (s *storage) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
 
 var statGate = syncutil.NewGate(50) // arbitrary
 
+// StatBlobs answers from the meta index (or, for blobs not yet packed,
+// by delegating to the small storage's own cheap Stat), without ever
+// opening a large blob to verify it. If a large blob has gone missing
+// from underlying storage despite meta claiming it exists, that's a
+// storage-integrity problem caught by checkLargeIntegrity, not
+// something every Stat call should pay to re-verify.
 func (s *storage) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.SizedRef) error) error {
 	var (
 		trySmallMu sync.Mutex
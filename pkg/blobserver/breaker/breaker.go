@@ -0,0 +1,263 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package breaker registers the "breaker" blobserver storage type, a
+// transparent wrapper around another storage target (typically a remote
+// backend like "storage-remote", "storage-s3", or "storage-googlecloudstorage")
+// that adds a per-operation timeout and a circuit breaker.
+//
+// Without a breaker, a hanging or overloaded remote backend can pile up
+// blocked goroutines and make the whole server unresponsive. With a
+// breaker, an operation that exceeds timeout is aborted, and once
+// failures pile up, the breaker "opens" and fast-fails further requests
+// for cooldown before trying again (a single "half-open" probe request),
+// instead of continuing to hammer (and wait on) a backend that's down.
+//
+// Example config:
+//
+//	"/cloud-blobs/": {
+//	    "handler": "storage-breaker",
+//	    "handlerArgs": {
+//	        "from": "/cloud-blobs-real/",
+//	        "timeoutSeconds": 30,
+//	        "failureThreshold": 5,
+//	        "cooldownSeconds": 60
+//	    }
+//	}
+package breaker // import "perkeep.org/pkg/blobserver/breaker"
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+// Defaults used when the config doesn't specify a value.
+const (
+	defaultTimeout          = 30 * time.Second
+	defaultFailureThreshold = 5
+	defaultCooldown         = time.Minute
+)
+
+var (
+	opensVar    = expvar.NewInt("breaker-opens")
+	timeoutsVar = expvar.NewInt("breaker-timeouts")
+)
+
+type storage struct {
+	from blobserver.Storage
+
+	timeout time.Duration
+	cb      *circuitBreaker
+}
+
+var _ blobserver.Storage = (*storage)(nil)
+
+func newFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (blobserver.Storage, error) {
+	fromName := conf.RequiredString("from")
+	timeoutSeconds := conf.OptionalInt("timeoutSeconds", int(defaultTimeout/time.Second))
+	failureThreshold := conf.OptionalInt("failureThreshold", defaultFailureThreshold)
+	cooldownSeconds := conf.OptionalInt("cooldownSeconds", int(defaultCooldown/time.Second))
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	from, err := ld.GetStorage(fromName)
+	if err != nil {
+		return nil, fmt.Errorf("breaker: invalid 'from' storage %q: %v", fromName, err)
+	}
+	return &storage{
+		from:    from,
+		timeout: time.Duration(timeoutSeconds) * time.Second,
+		cb: &circuitBreaker{
+			failureThreshold: failureThreshold,
+			cooldown:         time.Duration(cooldownSeconds) * time.Second,
+		},
+	}, nil
+}
+
+// call runs fn with a context bounded by s.timeout, unless the circuit
+// breaker is currently open, in which case it fails fast without calling
+// fn at all.
+func (s *storage) call(ctx context.Context, op string, fn func(context.Context) error) error {
+	if !s.cb.Allow() {
+		return fmt.Errorf("breaker: circuit open for %q operation; backend presumed down", op)
+	}
+	cctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	err := fn(cctx)
+	if err == context.DeadlineExceeded {
+		timeoutsVar.Add(1)
+	}
+	s.cb.RecordResult(err)
+	return err
+}
+
+// Fetch is handled specially: unlike the other operations, its context
+// must stay alive for as long as the caller reads from the returned
+// blob, not just for the initial call, so s.timeout only bounds opening
+// the fetch (getting rc back), not the whole read.
+func (s *storage) Fetch(ctx context.Context, br blob.Ref) (rc io.ReadCloser, size uint32, err error) {
+	if !s.cb.Allow() {
+		return nil, 0, fmt.Errorf("breaker: circuit open for %q operation; backend presumed down", "Fetch")
+	}
+	cctx, cancel := context.WithTimeout(ctx, s.timeout)
+	rc, size, err = s.from.Fetch(cctx, br)
+	if err == context.DeadlineExceeded {
+		timeoutsVar.Add(1)
+	}
+	s.cb.RecordResult(err)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	return &cancelOnCloseReader{ReadCloser: rc, cancel: cancel}, size, nil
+}
+
+// cancelOnCloseReader releases a Fetch's timeout context once the caller
+// is done reading the blob.
+type cancelOnCloseReader struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelOnCloseReader) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+func (s *storage) ReceiveBlob(ctx context.Context, br blob.Ref, source io.Reader) (sb blob.SizedRef, err error) {
+	err = s.call(ctx, "ReceiveBlob", func(cctx context.Context) error {
+		var rerr error
+		sb, rerr = s.from.ReceiveBlob(cctx, br, source)
+		return rerr
+	})
+	return
+}
+
+func (s *storage) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.SizedRef) error) error {
+	return s.call(ctx, "StatBlobs", func(cctx context.Context) error {
+		return s.from.StatBlobs(cctx, blobs, fn)
+	})
+}
+
+func (s *storage) EnumerateBlobs(ctx context.Context, dest chan<- blob.SizedRef, after string, limit int) error {
+	return s.call(ctx, "EnumerateBlobs", func(cctx context.Context) error {
+		return s.from.EnumerateBlobs(cctx, dest, after, limit)
+	})
+}
+
+func (s *storage) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
+	return s.call(ctx, "RemoveBlobs", func(cctx context.Context) error {
+		return s.from.RemoveBlobs(cctx, blobs)
+	})
+}
+
+// breakerState is the state of a circuitBreaker.
+type breakerState int
+
+const (
+	stateClosed breakerState = iota // requests pass through normally
+	stateOpen                       // requests fail fast, without calling the backend
+	stateHalfOpen                   // a single probe request is allowed through, to test recovery
+)
+
+// circuitBreaker implements a standard closed/open/half-open circuit
+// breaker: after failureThreshold consecutive failures it opens, fails
+// fast for cooldown, then lets a single probe request through
+// (half-open) to decide whether to close again or reopen.
+type circuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu            sync.Mutex
+	state         breakerState
+	failures      int
+	openedAt      time.Time
+	probeInFlight bool
+}
+
+// Allow reports whether a request may proceed, transitioning the breaker
+// from open to half-open once cooldown has elapsed.
+func (cb *circuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case stateClosed:
+		return true
+	case stateHalfOpen:
+		// Only let one probe through at a time.
+		if cb.probeInFlight {
+			return false
+		}
+		cb.probeInFlight = true
+		return true
+	default: // stateOpen
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = stateHalfOpen
+		cb.probeInFlight = true
+		log.Printf("breaker: cooldown elapsed, allowing a probe request")
+		return true
+	}
+}
+
+// RecordResult updates the breaker's state based on the outcome of a
+// request previously allowed by Allow.
+func (cb *circuitBreaker) RecordResult(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	wasProbe := cb.probeInFlight
+	cb.probeInFlight = false
+	if err == nil {
+		cb.failures = 0
+		if cb.state != stateClosed {
+			log.Printf("breaker: backend recovered, closing circuit")
+		}
+		cb.state = stateClosed
+		return
+	}
+	if wasProbe {
+		// The recovery probe failed; go back to open for another cooldown.
+		cb.open()
+		return
+	}
+	cb.failures++
+	if cb.state == stateClosed && cb.failures >= cb.failureThreshold {
+		cb.open()
+	}
+}
+
+// open transitions the breaker to the open state. The caller must hold cb.mu.
+func (cb *circuitBreaker) open() {
+	cb.state = stateOpen
+	cb.openedAt = time.Now()
+	cb.failures = 0
+	opensVar.Add(1)
+	log.Printf("breaker: opening circuit for %v after repeated failures", cb.cooldown)
+}
+
+func init() {
+	blobserver.RegisterStorageConstructor("breaker", blobserver.StorageConstructor(newFromConfig))
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+var ctxbg = context.Background()
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	cb := &circuitBreaker{failureThreshold: 3, cooldown: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		if !cb.Allow() {
+			t.Fatalf("Allow() = false before threshold reached")
+		}
+		cb.RecordResult(errors.New("boom"))
+	}
+	if cb.state != stateClosed {
+		t.Fatalf("state = %v after 2 failures; want closed", cb.state)
+	}
+
+	if !cb.Allow() {
+		t.Fatalf("Allow() = false before threshold reached")
+	}
+	cb.RecordResult(errors.New("boom"))
+	if cb.state != stateOpen {
+		t.Fatalf("state = %v after %d failures; want open", cb.state, cb.failureThreshold)
+	}
+	if cb.Allow() {
+		t.Error("Allow() = true immediately after opening; want false")
+	}
+}
+
+func TestCircuitBreakerHalfOpenRecovery(t *testing.T) {
+	cb := &circuitBreaker{failureThreshold: 1, cooldown: 0}
+
+	if !cb.Allow() {
+		t.Fatal("Allow() = false; want true")
+	}
+	cb.RecordResult(errors.New("boom"))
+	if cb.state != stateOpen {
+		t.Fatalf("state = %v; want open", cb.state)
+	}
+
+	// cooldown is 0, so the next Allow should transition to half-open and
+	// let exactly one probe through.
+	if !cb.Allow() {
+		t.Fatal("Allow() after cooldown = false; want true (probe)")
+	}
+	if cb.state != stateHalfOpen {
+		t.Fatalf("state = %v; want half-open", cb.state)
+	}
+	if cb.Allow() {
+		t.Error("Allow() during in-flight probe = true; want false")
+	}
+
+	cb.RecordResult(nil)
+	if cb.state != stateClosed {
+		t.Fatalf("state after successful probe = %v; want closed", cb.state)
+	}
+}
+
+func TestCircuitBreakerHalfOpenFailureReopens(t *testing.T) {
+	cb := &circuitBreaker{failureThreshold: 1, cooldown: 0}
+
+	cb.Allow()
+	cb.RecordResult(errors.New("boom"))
+	cb.Allow() // transitions to half-open, probe in flight
+	cb.RecordResult(errors.New("still down"))
+	if cb.state != stateOpen {
+		t.Fatalf("state after failed probe = %v; want open", cb.state)
+	}
+}
+
+// fakeStorage is a blobserver.Storage whose Fetch can be made to hang or
+// fail on demand, for exercising storage.call's timeout and breaker logic.
+type fakeStorage struct {
+	blobserver.Storage // nil; panics if any other method is called
+	fetchErr           error
+	fetchDelay         time.Duration
+}
+
+func (f fakeStorage) Fetch(ctx context.Context, br blob.Ref) (io.ReadCloser, uint32, error) {
+	if f.fetchDelay > 0 {
+		select {
+		case <-time.After(f.fetchDelay):
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+	if f.fetchErr != nil {
+		return nil, 0, f.fetchErr
+	}
+	return io.NopCloser(strings.NewReader("hi")), 2, nil
+}
+
+func TestStorageFetchTimesOut(t *testing.T) {
+	s := &storage{
+		from:    fakeStorage{fetchDelay: 50 * time.Millisecond},
+		timeout: time.Millisecond,
+		cb:      &circuitBreaker{failureThreshold: 100, cooldown: time.Hour},
+	}
+	_, _, err := s.Fetch(ctxbg, blob.RefFromString("x"))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v; want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStorageFailsFastWhenOpen(t *testing.T) {
+	boom := errors.New("boom")
+	s := &storage{
+		from:    fakeStorage{fetchErr: boom},
+		timeout: time.Second,
+		cb:      &circuitBreaker{failureThreshold: 1, cooldown: time.Hour},
+	}
+	if _, _, err := s.Fetch(ctxbg, blob.RefFromString("x")); err != boom {
+		t.Fatalf("first Fetch err = %v; want %v", err, boom)
+	}
+	// The circuit should now be open, so this call must fail without ever
+	// reaching fakeStorage.Fetch (which would return boom, not this error).
+	_, _, err := s.Fetch(ctxbg, blob.RefFromString("x"))
+	if err == nil || err == boom {
+		t.Fatalf("second Fetch err = %v; want a circuit-open error", err)
+	}
+}
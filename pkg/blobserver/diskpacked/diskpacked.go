@@ -639,8 +639,11 @@ func (s *storage) StreamBlobs(ctx context.Context, dest chan<- blobserver.BlobAn
 }
 
 func (s *storage) ReceiveBlob(ctx context.Context, br blob.Ref, source io.Reader) (sbr blob.SizedRef, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	var b bytes.Buffer
-	n, err := b.ReadFrom(source)
+	n, err := blobserver.CopyContext(ctx, &b, source)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,178 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package faultinject registers the "faultinject" blobserver storage
+// type, a transparent wrapper around another storage target that
+// injects artificial latency and errors into every operation. It exists
+// so client code, apps, and importers can be exercised against a flaky
+// or slow backend without needing an actual flaky or slow backend, e.g.
+// to verify retry and timeout logic behaves under degraded conditions.
+//
+// It is a testing tool, not a production feature: newFromConfig refuses
+// to construct one unless the server is running with CAMLI_DEV_CAMLI_ROOT
+// set (see perkeep.org/pkg/env), so it can't end up wired into a
+// production config by accident.
+//
+// Example config:
+//
+//	"/blobs/": {
+//	    "handler": "storage-faultinject",
+//	    "handlerArgs": {
+//	        "from": "/blobs-real/",
+//	        "maxDelayMs": 500,
+//	        "failurePercent": 10
+//	    }
+//	}
+package faultinject // import "perkeep.org/pkg/blobserver/faultinject"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/env"
+)
+
+type storage struct {
+	from        blobserver.Storage
+	maxDelay    time.Duration
+	failureRate float64
+
+	mu  sync.Mutex // guards rnd, which is not safe for concurrent use
+	rnd *rand.Rand
+}
+
+var _ blobserver.Storage = (*storage)(nil)
+
+func newFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (blobserver.Storage, error) {
+	if !env.IsDev() {
+		return nil, fmt.Errorf("faultinject: refusing to configure; this storage type is for development use only and requires CAMLI_DEV_CAMLI_ROOT to be set")
+	}
+	fromName := conf.RequiredString("from")
+	maxDelayMs := conf.OptionalInt("maxDelayMs", 0)
+	failurePercent := conf.OptionalInt("failurePercent", 0)
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	if failurePercent < 0 || failurePercent > 100 {
+		return nil, fmt.Errorf("faultinject: failurePercent must be between 0 and 100, got %v", failurePercent)
+	}
+	failureRate := float64(failurePercent) / 100
+	from, err := ld.GetStorage(fromName)
+	if err != nil {
+		return nil, fmt.Errorf("faultinject: invalid 'from' storage %q: %v", fromName, err)
+	}
+	return &storage{
+		from:        from,
+		maxDelay:    time.Duration(maxDelayMs) * time.Millisecond,
+		failureRate: failureRate,
+		rnd:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// inject sleeps for a random duration up to s.maxDelay (returning early
+// if ctx is done) and then, with probability s.failureRate, returns an
+// injected error for op instead of calling fn.
+func (s *storage) inject(ctx context.Context, op string, fn func() error) error {
+	if err := s.delay(ctx); err != nil {
+		return err
+	}
+	if s.shouldFail() {
+		return fmt.Errorf("faultinject: injected failure for %q operation", op)
+	}
+	return fn()
+}
+
+func (s *storage) delay(ctx context.Context) error {
+	if s.maxDelay <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	d := time.Duration(s.rnd.Int63n(int64(s.maxDelay) + 1))
+	s.mu.Unlock()
+	if d == 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *storage) shouldFail() bool {
+	if s.failureRate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64() < s.failureRate
+}
+
+// Fetch is handled specially, like in the breaker package: the injected
+// delay and failure rate apply to opening the fetch, but once a read
+// succeeds the returned reader is passed straight through, since
+// corrupting or delaying the stream itself is outside this package's
+// scope.
+func (s *storage) Fetch(ctx context.Context, br blob.Ref) (rc io.ReadCloser, size uint32, err error) {
+	if err := s.delay(ctx); err != nil {
+		return nil, 0, err
+	}
+	if s.shouldFail() {
+		return nil, 0, fmt.Errorf("faultinject: injected failure for %q operation", "Fetch")
+	}
+	return s.from.Fetch(ctx, br)
+}
+
+func (s *storage) ReceiveBlob(ctx context.Context, br blob.Ref, source io.Reader) (sb blob.SizedRef, err error) {
+	err = s.inject(ctx, "ReceiveBlob", func() error {
+		var rerr error
+		sb, rerr = s.from.ReceiveBlob(ctx, br, source)
+		return rerr
+	})
+	return
+}
+
+func (s *storage) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.SizedRef) error) error {
+	return s.inject(ctx, "StatBlobs", func() error {
+		return s.from.StatBlobs(ctx, blobs, fn)
+	})
+}
+
+func (s *storage) EnumerateBlobs(ctx context.Context, dest chan<- blob.SizedRef, after string, limit int) error {
+	return s.inject(ctx, "EnumerateBlobs", func() error {
+		return s.from.EnumerateBlobs(ctx, dest, after, limit)
+	})
+}
+
+func (s *storage) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
+	return s.inject(ctx, "RemoveBlobs", func() error {
+		return s.from.RemoveBlobs(ctx, blobs)
+	})
+}
+
+func init() {
+	blobserver.RegisterStorageConstructor("faultinject", blobserver.StorageConstructor(newFromConfig))
+}
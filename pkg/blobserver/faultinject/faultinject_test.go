@@ -0,0 +1,102 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var ctxbg = context.Background()
+
+func TestShouldFailZeroRate(t *testing.T) {
+	s := &storage{failureRate: 0, rnd: rand.New(rand.NewSource(1))}
+	for i := 0; i < 100; i++ {
+		if s.shouldFail() {
+			t.Fatal("shouldFail() = true with failureRate 0")
+		}
+	}
+}
+
+func TestShouldFailFullRate(t *testing.T) {
+	s := &storage{failureRate: 1, rnd: rand.New(rand.NewSource(1))}
+	for i := 0; i < 100; i++ {
+		if !s.shouldFail() {
+			t.Fatal("shouldFail() = false with failureRate 1")
+		}
+	}
+}
+
+func TestDelayZeroIsNoop(t *testing.T) {
+	s := &storage{maxDelay: 0, rnd: rand.New(rand.NewSource(1))}
+	start := time.Now()
+	if err := s.delay(ctxbg); err != nil {
+		t.Fatalf("delay() = %v; want nil", err)
+	}
+	if time.Since(start) > 10*time.Millisecond {
+		t.Fatal("delay() with maxDelay 0 should return immediately")
+	}
+}
+
+func TestDelayRespectsContextCancellation(t *testing.T) {
+	s := &storage{maxDelay: time.Hour, rnd: rand.New(rand.NewSource(1))}
+	ctx, cancel := context.WithTimeout(ctxbg, 10*time.Millisecond)
+	defer cancel()
+	if err := s.delay(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("delay() = %v; want context.DeadlineExceeded", err)
+	}
+}
+
+func TestInjectCallsFnOnSuccess(t *testing.T) {
+	s := &storage{rnd: rand.New(rand.NewSource(1))}
+	called := false
+	if err := s.inject(ctxbg, "Test", func() error {
+		called = true
+		return nil
+	}); err != nil {
+		t.Fatalf("inject() = %v; want nil", err)
+	}
+	if !called {
+		t.Fatal("inject() didn't call fn")
+	}
+}
+
+func TestInjectSkipsFnOnFailure(t *testing.T) {
+	s := &storage{failureRate: 1, rnd: rand.New(rand.NewSource(1))}
+	called := false
+	err := s.inject(ctxbg, "Test", func() error {
+		called = true
+		return nil
+	})
+	if err == nil {
+		t.Fatal("inject() = nil; want an injected error")
+	}
+	if called {
+		t.Fatal("inject() called fn despite failureRate 1")
+	}
+}
+
+func TestInjectPropagatesFnError(t *testing.T) {
+	s := &storage{rnd: rand.New(rand.NewSource(1))}
+	boom := errors.New("boom")
+	if err := s.inject(ctxbg, "Test", func() error { return boom }); err != boom {
+		t.Fatalf("inject() = %v; want %v", err, boom)
+	}
+}
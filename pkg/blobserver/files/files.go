@@ -26,9 +26,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 
 	"perkeep.org/pkg/blob"
@@ -94,12 +97,130 @@ type Storage struct {
 	// systems (Windows) where we don't know the maximum number of open
 	// file descriptors.
 	tmpFileGate *syncutil.Gate
+
+	// shardLevels is the number of nested two-hex-character directory
+	// levels new blobs are sharded into under root. Zero means
+	// defaultShardLevels.
+	shardLevels int
 }
 
+// defaultShardLevels is the number of nested two-hex-character
+// directory levels used to shard blobs by digest prefix, unless
+// overridden with SetShardLevels. It matches the historical on-disk
+// layout.
+const defaultShardLevels = 2
+
 // SetNewFileGate sets a gate (counting semaphore) on the number of new files
 // that may be opened for writing at a time.
 func (ds *Storage) SetNewFileGate(g *syncutil.Gate) { ds.tmpFileGate = g }
 
+// SetShardLevels overrides the number of nested two-hex-character
+// directory levels that newly-received blobs are sharded into, in
+// place of defaultShardLevels. It's meant for stores expected to hold
+// enough blobs that the default sharding would create directories
+// with too many entries for the underlying filesystem.
+//
+// It only affects where new blobs are written. Blobs already on disk
+// under a different number of shard levels, including the default,
+// remain readable; see resolveBlobPath. Use the "reshard-localdisk" pk
+// mode to migrate an existing store onto the new layout.
+//
+// It must be called, if at all, before any blobs are received.
+func (ds *Storage) SetShardLevels(n int) {
+	ds.shardLevels = n
+	ds.recordShardLevel(n)
+}
+
+// shardLevelsHistoryFile holds every value SetShardLevels has ever been
+// called with for this store, one per line, so resolveBlobPath can find
+// blobs left behind at an intermediate depth by a multi-step shardLevels
+// change (e.g. 2 -> 4, then later 4 -> 6) that "reshard-localdisk" never
+// got to run to completion between. It lives at the store's root, next
+// to (not under) the per-hash-algorithm blob directories.
+const shardLevelsHistoryFile = ".shardlevels"
+
+func (ds *Storage) shardLevelsHistoryPath() string {
+	return filepath.Join(ds.root, shardLevelsHistoryFile)
+}
+
+// recordShardLevel best-effort adds n to shardLevelsHistoryFile, unless
+// it's already there. Errors are logged, not returned: SetShardLevels
+// has no error return (and is called on bare Storage values in tests,
+// with ds.fs left nil), and this bookkeeping only ever affects a
+// fallback lookup path, not the store's actual data.
+func (ds *Storage) recordShardLevel(n int) {
+	if ds.fs == nil {
+		return
+	}
+	history, err := ds.shardLevelHistory()
+	if err != nil {
+		log.Printf("files: reading %s: %v", shardLevelsHistoryFile, err)
+		return
+	}
+	for _, v := range history {
+		if v == n {
+			return
+		}
+	}
+	history = append(history, n)
+	var buf strings.Builder
+	for _, v := range history {
+		fmt.Fprintln(&buf, v)
+	}
+	tmp, err := ds.fs.TempFile(ds.root, shardLevelsHistoryFile+".tmp")
+	if err != nil {
+		log.Printf("files: recording shard level %d: %v", n, err)
+		return
+	}
+	if _, err := tmp.Write([]byte(buf.String())); err != nil {
+		tmp.Close()
+		log.Printf("files: recording shard level %d: %v", n, err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("files: recording shard level %d: %v", n, err)
+		return
+	}
+	if err := ds.fs.Rename(tmp.Name(), ds.shardLevelsHistoryPath()); err != nil {
+		log.Printf("files: recording shard level %d: %v", n, err)
+	}
+}
+
+// shardLevelHistory returns every shard level this store has ever been
+// configured with, per shardLevelsHistoryFile, or nil if that store
+// predates this file (only defaultShardLevels and the current
+// shardLevels have ever applied to it).
+func (ds *Storage) shardLevelHistory() ([]int, error) {
+	f, err := ds.fs.Open(ds.shardLevelsHistoryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var levels []int
+	for _, line := range strings.Fields(string(data)) {
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			continue
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+func (ds *Storage) shardLevelsOrDefault() int {
+	if ds.shardLevels <= 0 {
+		return defaultShardLevels
+	}
+	return ds.shardLevels
+}
+
 func NewStorage(fs VFS, root string) *Storage {
 	return &Storage{
 		fs:        fs,
@@ -138,7 +259,10 @@ func u32(n int64) uint32 {
 // length -1 means entire file
 func (ds *Storage) fetch(ctx context.Context, br blob.Ref, offset, length int64) (rc io.ReadCloser, size uint32, err error) {
 	// TODO: use ctx, if the os package ever supports that.
-	fileName := ds.blobPath(br)
+	fileName, err := ds.resolveBlobPath(br)
+	if err != nil {
+		return nil, 0, os.ErrNotExist
+	}
 	stat, err := ds.fs.Stat(fileName)
 	if os.IsNotExist(err) {
 		return nil, 0, os.ErrNotExist
@@ -179,8 +303,15 @@ func (ds *Storage) fetch(ctx context.Context, br blob.Ref, offset, length int64)
 
 func (ds *Storage) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
 	for _, blob := range blobs {
-		fileName := ds.blobPath(blob)
-		err := ds.fs.Remove(fileName)
+		fileName, err := ds.resolveBlobPath(blob)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// deleting already-deleted file; harmless.
+				continue
+			}
+			return err
+		}
+		err = ds.fs.Remove(fileName)
 		switch {
 		case err == nil:
 			continue
@@ -198,25 +329,77 @@ func blobFileBaseName(b blob.Ref) string {
 	return fmt.Sprintf("%s-%s.dat", b.HashName(), b.Digest())
 }
 
-func (ds *Storage) blobDirectory(b blob.Ref) string {
+// blobDirectoryLevels returns the directory a blob is stored in when
+// sharded into the given number of nested two-hex-character levels.
+func (ds *Storage) blobDirectoryLevels(b blob.Ref, levels int) string {
 	d := b.Digest()
-	if len(d) < 4 {
-		d = d + "____"
+	for len(d) < levels*2 {
+		d += "_"
 	}
-	return filepath.Join(ds.root, b.HashName(), d[0:2], d[2:4])
+	parts := make([]string, 0, levels+2)
+	parts = append(parts, ds.root, b.HashName())
+	for i := 0; i < levels; i++ {
+		parts = append(parts, d[i*2:i*2+2])
+	}
+	return filepath.Join(parts...)
+}
+
+func (ds *Storage) blobDirectory(b blob.Ref) string {
+	return ds.blobDirectoryLevels(b, ds.shardLevelsOrDefault())
 }
 
 func (ds *Storage) blobPath(b blob.Ref) string {
 	return filepath.Join(ds.blobDirectory(b), blobFileBaseName(b))
 }
 
+// resolveBlobPath returns the path b currently lives at on disk. It
+// tries the storage's configured shard-directory depth first, then
+// defaultShardLevels, then every other depth shardLevelsHistoryFile
+// records this store having ever used, so blobs written before a
+// shard-level change (or stuck at an intermediate depth by a
+// multi-step change "reshard-localdisk" never finished migrating
+// between) remain readable. It returns os.ErrNotExist if b isn't found
+// under any of them.
+func (ds *Storage) resolveBlobPath(b blob.Ref) (path string, err error) {
+	primary := ds.blobPath(b)
+	if _, err := ds.fs.Stat(primary); err == nil {
+		return primary, nil
+	}
+	tried := ds.shardLevelsOrDefault()
+	fallbacks := []int{defaultShardLevels}
+	if history, err := ds.shardLevelHistory(); err != nil {
+		log.Printf("files: reading %s: %v", shardLevelsHistoryFile, err)
+	} else {
+		fallbacks = append(fallbacks, history...)
+	}
+	seen := map[int]bool{tried: true}
+	for _, levels := range fallbacks {
+		if seen[levels] {
+			continue
+		}
+		seen[levels] = true
+		candidate := filepath.Join(ds.blobDirectoryLevels(b, levels), blobFileBaseName(b))
+		if _, err := ds.fs.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return primary, os.ErrNotExist
+}
+
 const maxParallelStats = 20
 
 var statGate = syncutil.NewGate(maxParallelStats)
 
 func (ds *Storage) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.SizedRef) error) error {
 	return blobserver.StatBlobsParallelHelper(ctx, blobs, fn, statGate, func(ref blob.Ref) (sb blob.SizedRef, err error) {
-		fi, err := ds.fs.Stat(ds.blobPath(ref))
+		path, err := ds.resolveBlobPath(ref)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return sb, nil
+			}
+			return sb, err
+		}
+		fi, err := ds.fs.Stat(path)
 		switch {
 		case err == nil && fi.Mode().IsRegular():
 			return blob.SizedRef{Ref: ref, Size: u32(fi.Size())}, nil
@@ -35,3 +35,24 @@ func TestPaths(t *testing.T) {
 		t.Errorf("short blobref path; expected path %q; got %q", e, g)
 	}
 }
+
+func TestPathsShardLevels(t *testing.T) {
+	br := blob.MustParse("digalg-abcdef")
+	ds := &Storage{root: "/tmp/dir"}
+	ds.SetShardLevels(3)
+
+	slash := filepath.ToSlash
+	if e, g := "/tmp/dir/digalg/ab/cd/ef", slash(ds.blobDirectory(br)); e != g {
+		t.Errorf("3-level blobref dir; expected path %q; got %q", e, g)
+	}
+	if e, g := "/tmp/dir/digalg/ab/cd/ef/digalg-abcdef.dat", slash(ds.blobPath(br)); e != g {
+		t.Errorf("3-level blobref path; expected path %q; got %q", e, g)
+	}
+
+	// A digest shorter than shardLevels*2 hex characters is padded with
+	// underscores, same as the default 2-level layout does.
+	short := blob.MustParse("digalg-ab")
+	if e, g := "/tmp/dir/digalg/ab/__/__", slash(ds.blobDirectory(short)); e != g {
+		t.Errorf("short digest 3-level dir; expected path %q; got %q", e, g)
+	}
+}
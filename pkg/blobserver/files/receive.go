@@ -23,6 +23,7 @@ import (
 	"log"
 
 	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
 )
 
 func (ds *Storage) startGate() {
@@ -40,6 +41,9 @@ func (ds *Storage) doneGate() {
 }
 
 func (ds *Storage) ReceiveBlob(ctx context.Context, blobRef blob.Ref, source io.Reader) (blob.SizedRef, error) {
+	if err := ctx.Err(); err != nil {
+		return blob.SizedRef{}, err
+	}
 	ds.dirLockMu.RLock()
 	defer ds.dirLockMu.RUnlock()
 
@@ -67,7 +71,7 @@ func (ds *Storage) ReceiveBlob(ctx context.Context, blobRef blob.Ref, source io.
 		ds.doneGate()
 	}()
 
-	written, err := io.Copy(tempFile, source)
+	written, err := blobserver.CopyContext(ctx, tempFile, source)
 	if err != nil {
 		return blob.SizedRef{}, err
 	}
@@ -0,0 +1,55 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"context"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+// Reshard moves every blob under ds's root onto ds's currently
+// configured shard-directory depth (see SetShardLevels), so an
+// existing store can be migrated onto a new depth.
+//
+// It's safe to run against a live store: resolveBlobPath already falls
+// back to every shard-directory depth this store has ever been
+// configured with (see shardLevelsHistoryFile) for blobs Reshard
+// hasn't gotten to yet, and moving a blob that's already at its target
+// path is a no-op. It returns the number of blobs actually moved.
+func (ds *Storage) Reshard(ctx context.Context) (moved int, err error) {
+	err = blobserver.EnumerateAll(ctx, ds, func(sb blob.SizedRef) error {
+		target := ds.blobPath(sb.Ref)
+		current, err := ds.resolveBlobPath(sb.Ref)
+		if err != nil {
+			return err
+		}
+		if current == target {
+			return nil
+		}
+		if err := ds.fs.MkdirAll(ds.blobDirectory(sb.Ref), 0700); err != nil {
+			return err
+		}
+		if err := ds.fs.Rename(current, target); err != nil {
+			return err
+		}
+		moved++
+		return nil
+	})
+	return moved, err
+}
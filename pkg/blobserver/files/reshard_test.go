@@ -0,0 +1,98 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files
+
+import (
+	"os"
+	"testing"
+
+	"perkeep.org/pkg/test"
+)
+
+// TestResolveBlobPathAcrossShardLevelHistory covers a store whose
+// shardLevels is changed more than once (2 -> 4 -> 6) without running
+// Reshard to completion in between: blobs written at every intermediate
+// depth must all stay readable, not just ones written at
+// defaultShardLevels or the current depth.
+func TestResolveBlobPathAcrossShardLevelHistory(t *testing.T) {
+	root := t.TempDir()
+	ds := NewStorage(OSFS(), root)
+
+	atLevel2 := &test.Blob{Contents: "written at the historical default depth"}
+	atLevel2.MustUpload(t, ds)
+
+	ds.SetShardLevels(4)
+	atLevel4 := &test.Blob{Contents: "written after the first reshard, at depth 4"}
+	atLevel4.MustUpload(t, ds)
+
+	ds.SetShardLevels(6)
+	atLevel6 := &test.Blob{Contents: "written after the second reshard, at depth 6"}
+	atLevel6.MustUpload(t, ds)
+
+	for _, want := range []*test.Blob{atLevel2, atLevel4, atLevel6} {
+		br := want.BlobRef()
+		path, err := ds.resolveBlobPath(br)
+		if err != nil {
+			t.Errorf("resolveBlobPath(%v): %v", br, err)
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("resolveBlobPath(%v) = %q, which doesn't exist: %v", br, path, err)
+		}
+	}
+}
+
+// TestShardLevelHistoryPersistsAcrossProcesses covers the actual bug
+// scenario: a store reopened as a fresh *Storage (as happens on a
+// process restart) must still find blobs left behind at a depth from
+// before the most recent shardLevels change, via the on-disk
+// shardLevelsHistoryFile rather than any in-memory state.
+func TestShardLevelHistoryPersistsAcrossProcesses(t *testing.T) {
+	root := t.TempDir()
+
+	ds1 := NewStorage(OSFS(), root)
+	ds1.SetShardLevels(4)
+	blob4 := &test.Blob{Contents: "depth 4, before the restart"}
+	blob4.MustUpload(t, ds1)
+
+	// Simulate a restart: a new *Storage, configured with the new
+	// depth only, knowing nothing SetShardLevels was ever called with
+	// in the previous process except what's on disk.
+	ds2 := NewStorage(OSFS(), root)
+	ds2.SetShardLevels(6)
+
+	br := blob4.BlobRef()
+	path, err := ds2.resolveBlobPath(br)
+	if err != nil {
+		t.Fatalf("resolveBlobPath(%v) on the reopened store: %v", br, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("resolveBlobPath(%v) = %q, which doesn't exist: %v", br, path, err)
+	}
+}
+
+// TestRecordShardLevelNilFS covers SetShardLevels on a bare Storage
+// value with no fs set, the way path_test.go's TestPathsShardLevels
+// constructs one: recording history must be a silent no-op, not a nil
+// pointer dereference.
+func TestRecordShardLevelNilFS(t *testing.T) {
+	ds := &Storage{root: "/tmp/does-not-exist"}
+	ds.SetShardLevels(3) // must not panic
+	if ds.shardLevels != 3 {
+		t.Errorf("shardLevels = %d, want 3", ds.shardLevels)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package files_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver/files"
+	"perkeep.org/pkg/test"
+)
+
+// countingOpenFS wraps a VFS and counts calls to Open, so tests can
+// assert that a code path never opens (and thus never reads) blob
+// content.
+type countingOpenFS struct {
+	files.VFS
+	opens int32
+}
+
+func (fs *countingOpenFS) Open(path string) (files.ReadableFile, error) {
+	atomic.AddInt32(&fs.opens, 1)
+	return fs.VFS.Open(path)
+}
+
+// TestStatBlobsDoesNotOpen verifies that StatBlobs stats blobs via
+// os.Stat only, without ever opening (and so without reading) the
+// blob's contents.
+func TestStatBlobsDoesNotOpen(t *testing.T) {
+	root := fmt.Sprintf("%s/camli-testroot-stat-%d", os.TempDir(), os.Getpid())
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatalf("Failed to create temp directory %q: %v", root, err)
+	}
+	defer os.RemoveAll(root)
+
+	fs := &countingOpenFS{VFS: files.OSFS()}
+	ds := files.NewStorage(fs, root)
+
+	b := &test.Blob{Contents: "some blob content"}
+	b.MustUpload(t, ds)
+
+	atomic.StoreInt32(&fs.opens, 0)
+
+	var got blob.SizedRef
+	err := ds.StatBlobs(context.Background(), []blob.Ref{b.BlobRef()}, func(sb blob.SizedRef) error {
+		got = sb
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StatBlobs: %v", err)
+	}
+	if want := b.SizedRef(); got != want {
+		t.Errorf("StatBlobs result = %+v; want %+v", got, want)
+	}
+	if n := atomic.LoadInt32(&fs.opens); n != 0 {
+		t.Errorf("StatBlobs called Open %d times; want 0", n)
+	}
+}
+
+// BenchmarkStatBlobs measures the cost of statting an existing blob,
+// to catch regressions that would make it as expensive as a Fetch.
+func BenchmarkStatBlobs(b *testing.B) {
+	root := fmt.Sprintf("%s/camli-testroot-statbench-%d", os.TempDir(), os.Getpid())
+	if err := os.MkdirAll(root, 0755); err != nil {
+		b.Fatalf("Failed to create temp directory %q: %v", root, err)
+	}
+	defer os.RemoveAll(root)
+
+	ds := files.NewStorage(files.OSFS(), root)
+	tb := &test.Blob{Contents: "some blob content"}
+	ctx := context.Background()
+	if _, err := ds.ReceiveBlob(ctx, tb.BlobRef(), tb.Reader()); err != nil {
+		b.Fatalf("failed to upload blob: %v", err)
+	}
+	refs := []blob.Ref{tb.BlobRef()}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ds.StatBlobs(ctx, refs, func(blob.SizedRef) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
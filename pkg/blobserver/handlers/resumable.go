@@ -0,0 +1,259 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"perkeep.org/internal/httputil"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+// partialUploadTTL is how long an in-progress resumable upload may sit
+// idle before it's considered abandoned. Once expired, its staging
+// file is discarded, and a subsequent status query or chunk for that
+// blobref starts over from offset 0.
+const partialUploadTTL = 1 * time.Hour
+
+// partialUpload is the server-side staging state for one in-progress
+// resumable upload, keyed by its final blobref in partialUploads.
+type partialUpload struct {
+	mu       sync.Mutex // guards f and received
+	f        *os.File
+	size     int64 // total declared size, from Content-Range's "/<total>"
+	received int64 // bytes written to f so far, always contiguous from 0
+	lastUsed time.Time
+}
+
+// partialUploads tracks in-progress resumable uploads across separate
+// PUT requests, so a client can ask how much of a blob the server
+// already has staged (Content-Range: bytes */<total>, no body) and
+// resume sending only the rest (Content-Range: bytes <start>-<end>/<total>)
+// after a dropped connection, instead of restarting the whole blob.
+type partialUploads struct {
+	mu    sync.Mutex
+	byRef map[blob.Ref]*partialUpload
+}
+
+var resumableUploads = &partialUploads{byRef: make(map[blob.Ref]*partialUpload)}
+
+// expireLocked discards partials that haven't been touched in
+// partialUploadTTL. p.mu must be held.
+func (p *partialUploads) expireLocked() {
+	now := time.Now()
+	for br, pu := range p.byRef {
+		if now.Sub(pu.lastUsed) > partialUploadTTL {
+			pu.f.Close()
+			os.Remove(pu.f.Name())
+			delete(p.byRef, br)
+		}
+	}
+}
+
+// get returns the in-progress partial upload for br, or nil if there
+// isn't one (or it just expired).
+func (p *partialUploads) get(br blob.Ref) *partialUpload {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expireLocked()
+	return p.byRef[br]
+}
+
+// getOrStart returns the in-progress partial upload for br, creating a
+// new empty one (staged in a temp file) declared to be size bytes long
+// if none exists yet.
+func (p *partialUploads) getOrStart(br blob.Ref, size int64) (*partialUpload, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expireLocked()
+	if pu, ok := p.byRef[br]; ok {
+		return pu, nil
+	}
+	f, err := os.CreateTemp("", "perkeep-resumable-"+br.String()+"-")
+	if err != nil {
+		return nil, err
+	}
+	pu := &partialUpload{f: f, size: size, lastUsed: time.Now()}
+	p.byRef[br] = pu
+	return pu, nil
+}
+
+// remove discards pu's staging file and forgets it, if it's still the
+// current partial upload for br.
+func (p *partialUploads) remove(br blob.Ref, pu *partialUpload) {
+	p.mu.Lock()
+	if p.byRef[br] == pu {
+		delete(p.byRef, br)
+	}
+	p.mu.Unlock()
+	pu.f.Close()
+	os.Remove(pu.f.Name())
+}
+
+// contentRangeRx matches the Content-Range request header values this
+// package understands: either a status query ("bytes */<total>") or a
+// chunk of the blob ("bytes <start>-<end>/<total>").
+var contentRangeRx = regexp.MustCompile(`^bytes (\*|(\d+)-(\d+))/(\d+)$`)
+
+// parseContentRange parses the value of a Content-Range request
+// header as used by the resumable-upload extension. If statusQuery is
+// true, the request is a status query and start/end are zero.
+func parseContentRange(v string) (start, end, total int64, statusQuery, ok bool) {
+	m := contentRangeRx.FindStringSubmatch(v)
+	if m == nil {
+		return 0, 0, 0, false, false
+	}
+	total, err := strconv.ParseInt(m[4], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false, false
+	}
+	if m[1] == "*" {
+		return 0, 0, total, true, true
+	}
+	start, err1 := strconv.ParseInt(m[2], 10, 64)
+	end, err2 := strconv.ParseInt(m[3], 10, 64)
+	if err1 != nil || err2 != nil || start > end {
+		return 0, 0, 0, false, false
+	}
+	return start, end, total, false, true
+}
+
+// serveResumablePut handles a PUT request to br's URL that carries a
+// Content-Range header, implementing the resumable-upload extension
+// to the single-blob PUT protocol described in
+// doc/protocol/blob-upload-protocol.txt.
+//
+// A "bytes */<total>" request with no body is a status query: it
+// reports, via a Range response header, how many bytes of br the
+// server already has staged. A "bytes <start>-<end>/<total>" request
+// appends that range to the server's staging file for br; once bytes
+// 0-total have all been received, the assembled data is verified
+// against br's digest and committed to storage exactly as a
+// non-resumable PUT would be.
+//
+// The response is 308, following the convention used by comparable
+// resumable-upload protocols such as Google Cloud Storage's, while
+// the upload is incomplete, with a Range header giving the offset to
+// resume from. It's 204, as for a whole-blob PUT, once br is fully
+// received and committed.
+func serveResumablePut(ctx context.Context, rw http.ResponseWriter, req *http.Request, storage blobserver.BlobReceiver, br blob.Ref, contentRange string) {
+	start, end, total, statusQuery, ok := parseContentRange(contentRange)
+	if !ok {
+		httputil.BadRequestError(rw, "malformed Content-Range")
+		return
+	}
+	if total > blobserver.MaxBlobSize {
+		httputil.BadRequestError(rw, "blob too big")
+		return
+	}
+
+	if statusQuery {
+		pu := resumableUploads.get(br)
+		if pu == nil {
+			// Nothing staged yet; the client should start at offset 0.
+			rw.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		pu.mu.Lock()
+		received := pu.received
+		pu.mu.Unlock()
+		if received > 0 {
+			rw.Header().Set("Range", fmt.Sprintf("bytes=0-%d", received-1))
+		}
+		rw.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+
+	if end+1 > total {
+		httputil.BadRequestError(rw, "Content-Range end past declared total")
+		return
+	}
+
+	var pu *partialUpload
+	if start == 0 {
+		var err error
+		pu, err = resumableUploads.getOrStart(br, total)
+		if err != nil {
+			httputil.ServeError(rw, req, err)
+			return
+		}
+	} else {
+		pu = resumableUploads.get(br)
+		if pu == nil {
+			httputil.BadRequestError(rw, "no partial upload in progress; must start at offset 0")
+			return
+		}
+	}
+
+	pu.mu.Lock()
+	defer pu.mu.Unlock()
+
+	if pu.size != total {
+		resumableUploads.remove(br, pu)
+		httputil.BadRequestError(rw, "Content-Range total doesn't match in-progress upload")
+		return
+	}
+	if start != pu.received {
+		httputil.BadRequestError(rw, fmt.Sprintf("expected chunk at offset %d, got %d", pu.received, start))
+		return
+	}
+
+	n, err := io.Copy(pu.f, io.LimitReader(req.Body, end-start+1))
+	pu.received += n
+	if err != nil {
+		httputil.ServeError(rw, req, err)
+		return
+	}
+	if pu.received != end+1 {
+		httputil.BadRequestError(rw, "short chunk body")
+		return
+	}
+	pu.lastUsed = time.Now()
+
+	if pu.received < pu.size {
+		rw.Header().Set("Range", fmt.Sprintf("bytes=0-%d", pu.received-1))
+		rw.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+
+	// The whole blob is staged: verify it against br's digest and
+	// commit it to storage, exactly as a non-resumable PUT would.
+	if _, err := pu.f.Seek(0, io.SeekStart); err != nil {
+		httputil.ServeError(rw, req, err)
+		return
+	}
+	_, err = blobserver.Receive(ctx, storage, br, pu.f)
+	resumableUploads.remove(br, pu)
+	if err == blobserver.ErrCorruptBlob {
+		httputil.BadRequestError(rw, "data doesn't match declared digest")
+		return
+	}
+	if err != nil {
+		httputil.ServeError(rw, req, err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver/localdisk"
+)
+
+func newTestPutHandler(t *testing.T) http.Handler {
+	t.Helper()
+	sto, err := localdisk.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return CreatePutUploadHandler(sto)
+}
+
+func doPut(t *testing.T, h http.Handler, br blob.Ref, contentRange, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("PUT", "http://example.com/camli/"+br.String(), strings.NewReader(body))
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestResumablePutStatusQueryNoPartial(t *testing.T) {
+	h := newTestPutHandler(t)
+	br := blob.RefFromString("hello resumable")
+	rec := doPut(t, h, br, fmt.Sprintf("bytes */%d", len("hello resumable")), "")
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Fatalf("status query on unstarted upload: got %d, want %d", rec.Code, http.StatusPermanentRedirect)
+	}
+	if got := rec.Header().Get("Range"); got != "" {
+		t.Errorf("Range header = %q; want none", got)
+	}
+}
+
+func TestResumablePutSingleChunk(t *testing.T) {
+	h := newTestPutHandler(t)
+	data := "hello resumable, all in one chunk"
+	br := blob.RefFromString(data)
+
+	rec := doPut(t, h, br, fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data)), data)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("chunk upload: got %d (%s), want %d", rec.Code, rec.Body.String(), http.StatusNoContent)
+	}
+
+	// The blob should now be gone from the partial-upload tracker.
+	if pu := resumableUploads.get(br); pu != nil {
+		t.Errorf("partial upload for %v still tracked after completion", br)
+	}
+}
+
+func TestResumablePutResumeAfterDrop(t *testing.T) {
+	h := newTestPutHandler(t)
+	data := "the quick brown fox jumps over the lazy dog"
+	br := blob.RefFromString(data)
+	total := len(data)
+	split := 10
+
+	// Send the first part of the blob.
+	rec := doPut(t, h, br, fmt.Sprintf("bytes 0-%d/%d", split-1, total), data[:split])
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Fatalf("first chunk: got %d (%s), want %d", rec.Code, rec.Body.String(), http.StatusPermanentRedirect)
+	}
+	if got, want := rec.Header().Get("Range"), fmt.Sprintf("bytes=0-%d", split-1); got != want {
+		t.Errorf("first chunk Range header = %q; want %q", got, want)
+	}
+
+	// A status query should now report the same offset.
+	rec = doPut(t, h, br, fmt.Sprintf("bytes */%d", total), "")
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Fatalf("status query: got %d, want %d", rec.Code, http.StatusPermanentRedirect)
+	}
+	if got, want := rec.Header().Get("Range"), fmt.Sprintf("bytes=0-%d", split-1); got != want {
+		t.Errorf("status query Range header = %q; want %q", got, want)
+	}
+
+	// Send the rest of the blob, resuming from the reported offset.
+	rec = doPut(t, h, br, fmt.Sprintf("bytes %d-%d/%d", split, total-1, total), data[split:])
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("final chunk: got %d (%s), want %d", rec.Code, rec.Body.String(), http.StatusNoContent)
+	}
+}
+
+func TestResumablePutWrongOffset(t *testing.T) {
+	h := newTestPutHandler(t)
+	data := "some data that arrives out of order"
+	br := blob.RefFromString(data)
+	total := len(data)
+
+	rec := doPut(t, h, br, fmt.Sprintf("bytes 5-%d/%d", total-1, total), data[5:])
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("chunk at non-zero offset with no partial: got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestResumablePutCorruptBlob(t *testing.T) {
+	h := newTestPutHandler(t)
+	data := "the declared digest won't match this"
+	br := blob.RefFromString("something else entirely")
+
+	rec := doPut(t, h, br, fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data)), data)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("corrupt blob: got %d (%s), want %d", rec.Code, rec.Body.String(), http.StatusBadRequest)
+	}
+}
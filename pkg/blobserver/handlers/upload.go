@@ -49,6 +49,9 @@ func CreateBatchUploadHandler(storage blobserver.BlobReceiveConfiger) http.Handl
 // CreatePutUploadHandler returns the handler that receives a single
 // blob at the blob's final URL, via the PUT method.  See
 // doc/protocol/blob-upload-protocol.txt.
+//
+// A request bearing a Content-Range header is handled as a resumable
+// upload instead of a whole-blob PUT; see serveResumablePut.
 func CreatePutUploadHandler(storage blobserver.BlobReceiver) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
@@ -57,12 +60,6 @@ func CreatePutUploadHandler(storage blobserver.BlobReceiver) http.Handler {
 			httputil.BadRequestError(rw, "Inconfigured handler.")
 			return
 		}
-		// For non-chunked uploads, we catch it here. For chunked uploads, it's caught
-		// by blobserver.Receive's LimitReader.
-		if req.ContentLength > blobserver.MaxBlobSize {
-			httputil.BadRequestError(rw, "blob too big")
-			return
-		}
 		blobrefStr := path.Base(req.URL.Path)
 		br, ok := blob.Parse(blobrefStr)
 		if !ok {
@@ -74,6 +71,16 @@ func CreatePutUploadHandler(storage blobserver.BlobReceiver) http.Handler {
 			httputil.BadRequestError(rw, "unsupported object hash function")
 			return
 		}
+		if cr := req.Header.Get("Content-Range"); cr != "" {
+			serveResumablePut(ctx, rw, req, storage, br, cr)
+			return
+		}
+		// For non-chunked uploads, we catch it here. For chunked uploads, it's caught
+		// by blobserver.Receive's LimitReader.
+		if req.ContentLength > blobserver.MaxBlobSize {
+			httputil.BadRequestError(rw, "blob too big")
+			return
+		}
 		_, err := blobserver.Receive(ctx, storage, br, req.Body)
 		if err == blobserver.ErrCorruptBlob {
 			httputil.BadRequestError(rw, "data doesn't match declared digest")
@@ -82,6 +82,19 @@ type BlobEnumerator interface {
 	// EnumerateBlobs must close the channel.  (even if limit
 	// was hit and more blobs remain, or an error is returned, or
 	// the ctx is canceled)
+	//
+	// Implementations must stream results into dest as they're
+	// discovered rather than buffering the whole result set, so that
+	// enumerating a store uses memory bounded by limit, not by the
+	// number of blobs in the store. Callers wanting to walk an
+	// entire (possibly huge) store should therefore prefer a small
+	// limit per call, as EnumerateAll and EnumerateAllFrom do, or use
+	// a per-backend continuation cursor.
+	//
+	// It is safe to run multiple EnumerateBlobs calls against the
+	// same BlobEnumerator concurrently, each with its own dest
+	// channel and after cursor; implementations must not share
+	// mutable per-call state between them.
 	EnumerateBlobs(ctx context.Context,
 		dest chan<- blob.SizedRef,
 		after string,
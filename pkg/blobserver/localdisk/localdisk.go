@@ -26,11 +26,20 @@ Example low-level config:
 	       "path": "/var/camlistore/blobs"
 	     }
 	},
+
+By default blobs are sharded into two nested levels of two-hex-character
+directories under path. Stores expected to hold enough blobs to hit
+per-directory inode/dir-entry limits on some filesystems can set
+"shardLevels" (an int) in handlerArgs to shard more deeply, e.g. 3. An
+existing store can be migrated to a new shardLevels value with the pk
+"reshard-localdisk" mode; until migrated, blobs written under the old
+layout remain readable.
 */
 package localdisk // import "perkeep.org/pkg/blobserver/localdisk"
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -56,6 +65,10 @@ type DiskStorage struct {
 
 	root string
 
+	// fileSto is the same value as Storage, kept concretely typed so
+	// Reshard can call through to it.
+	fileSto *files.Storage
+
 	// gen will be nil if partition != ""
 	gen *local.Generationer
 }
@@ -70,6 +83,13 @@ func (ds *DiskStorage) String() string {
 	return fmt.Sprintf("\"filesystem\" file-per-blob at %s", ds.root)
 }
 
+// Reshard moves every blob in ds onto the shard-directory depth ds
+// was constructed with (see NewWithShardLevels), so a store can be
+// migrated onto a new depth. It returns the number of blobs moved.
+func (ds *DiskStorage) Reshard(ctx context.Context) (moved int, err error) {
+	return ds.fileSto.Reshard(ctx)
+}
+
 // IsDir reports whether root is a localdisk (file-per-blob) storage directory.
 func IsDir(root string) (bool, error) {
 	if osutil.DirExists(filepath.Join(root, "sha1")) {
@@ -92,6 +112,24 @@ const (
 // New returns a new local disk storage implementation at the provided
 // root directory, which must already exist.
 func New(root string) (*DiskStorage, error) {
+	return newDiskStorage(root, 0)
+}
+
+// NewWithShardLevels is like New but overrides the number of nested
+// two-hex-character shard directory levels newly-written blobs are
+// sharded into, in place of the historical default of two. It's
+// exported for the "reshard-localdisk" pk mode, which migrates an
+// existing store onto a new depth with Reshard; regular callers
+// should configure this via the "shardLevels" handlerArgs instead.
+func NewWithShardLevels(root string, shardLevels int) (*DiskStorage, error) {
+	return newDiskStorage(root, shardLevels)
+}
+
+// newDiskStorage is like New but overrides the number of nested
+// two-hex-character shard directory levels new blobs are written
+// under, in place of the historical default of two. shardLevels of 0
+// means use the default.
+func newDiskStorage(root string, shardLevels int) (*DiskStorage, error) {
 	// Local disk.
 	fi, err := os.Stat(root)
 	if os.IsNotExist(err) {
@@ -112,10 +150,14 @@ func New(root string) (*DiskStorage, error) {
 		return nil, fmt.Errorf("storage root %q exists but is not a directory", root)
 	}
 	fileSto := files.NewStorage(files.OSFS(), root)
+	if shardLevels > 0 {
+		fileSto.SetShardLevels(shardLevels)
+	}
 	ds := &DiskStorage{
 		Storage:    fileSto,
 		SubFetcher: fileSto,
 		root:       root,
+		fileSto:    fileSto,
 		gen:        local.NewGenerationer(root),
 	}
 	if _, _, err := ds.StorageGeneration(); err != nil {
@@ -145,10 +187,11 @@ func New(root string) (*DiskStorage, error) {
 
 func newFromConfig(_ blobserver.Loader, config jsonconfig.Obj) (storage blobserver.Storage, err error) {
 	path := config.RequiredString("path")
+	shardLevels := config.OptionalInt("shardLevels", 0)
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
-	return New(path)
+	return newDiskStorage(path, shardLevels)
 }
 
 func init() {
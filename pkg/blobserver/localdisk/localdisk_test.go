@@ -142,6 +142,55 @@ func TestMissingGetReturnsNoEnt(t *testing.T) {
 	}
 }
 
+func TestReshard(t *testing.T) {
+	path := fmt.Sprintf("%s/camli-testroot-reshard-%d", os.TempDir(), os.Getpid())
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatalf("Failed to create temp directory %q: %v", path, err)
+	}
+	ds, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer cleanUp(ds)
+
+	tb := &test.Blob{Contents: "Foo"}
+	tb.MustUpload(t, ds)
+
+	ctx := context.Background()
+	sharded, err := NewWithShardLevels(path, 3)
+	if err != nil {
+		t.Fatalf("NewWithShardLevels: %v", err)
+	}
+
+	// The blob was written under the default two-level layout; it
+	// should still be readable through the newly-configured storage,
+	// even before Reshard has moved it.
+	if _, _, err := sharded.Fetch(ctx, tb.BlobRef()); err != nil {
+		t.Fatalf("Fetch before Reshard: %v", err)
+	}
+
+	moved, err := sharded.Reshard(ctx)
+	if err != nil {
+		t.Fatalf("Reshard: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("Reshard moved %d blobs; want 1", moved)
+	}
+
+	if _, _, err := sharded.Fetch(ctx, tb.BlobRef()); err != nil {
+		t.Fatalf("Fetch after Reshard: %v", err)
+	}
+
+	// Running it again should be a no-op.
+	moved, err = sharded.Reshard(ctx)
+	if err != nil {
+		t.Fatalf("second Reshard: %v", err)
+	}
+	if moved != 0 {
+		t.Errorf("second Reshard moved %d blobs; want 0", moved)
+	}
+}
+
 func TestLocaldisk(t *testing.T) {
 	storagetest.Test(t, func(t *testing.T) blobserver.Storage {
 		ds := NewStorage(t)
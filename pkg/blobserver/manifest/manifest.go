@@ -0,0 +1,203 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manifest generates and verifies signed "integrity manifests":
+// point-in-time exports of every blobref and size in a blobserver
+// storage backend, for later comparison against a live storage backend
+// to detect added, removed, or changed blobs without re-hashing
+// everything.
+//
+// A manifest is a text file: one "ref size" line per blob, sorted by
+// ref, followed by a blank line and a single-line signed JSON Footer
+// covering a SHA-256 digest of the preceding lines. Write streams the
+// body directly from a BlobEnumerator as it's enumerated, and Reader
+// reads it back the same way, so neither generating nor verifying a
+// manifest requires buffering the full blob list in memory, even for
+// huge stores.
+package manifest // import "perkeep.org/pkg/blobserver/manifest"
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/jsonsign"
+	"perkeep.org/pkg/schema"
+)
+
+// FooterType is the Footer.Type value identifying a storage integrity
+// manifest, as opposed to some other kind of signed JSON blob.
+const FooterType = "storage-integrity-manifest"
+
+// Footer is the trailing signed record of a manifest, covering the
+// body of blobref/size lines that precedes it.
+type Footer struct {
+	CamliVersion int    `json:"camliVersion"`
+	Type         string `json:"type"`
+	CreatedTime  string `json:"createdTime"`
+	BlobCount    int64  `json:"blobCount"`
+	TotalSize    int64  `json:"totalSize"`
+	BodySHA256   string `json:"bodySha256"`
+	CamliSigner  string `json:"camliSigner"`
+}
+
+// Write streams a signed integrity manifest for every blob in src to w.
+// See the package doc for the file format. The manifest is signed with
+// signer, so a later Reader.Verify can detect tampering.
+func Write(ctx context.Context, src blobserver.BlobEnumerator, signer *schema.Signer, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	h := sha256.New()
+	body := io.MultiWriter(bw, h)
+
+	var count, total int64
+	err := blobserver.EnumerateAll(ctx, src, func(sb blob.SizedRef) error {
+		count++
+		total += int64(sb.Size)
+		_, err := fmt.Fprintf(body, "%s %d\n", sb.Ref, sb.Size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := bw.WriteString("\n"); err != nil {
+		return err
+	}
+
+	footer := &Footer{
+		CamliVersion: 1,
+		Type:         FooterType,
+		CreatedTime:  time.Now().UTC().Format(time.RFC3339),
+		BlobCount:    count,
+		TotalSize:    total,
+		BodySHA256:   hex.EncodeToString(h.Sum(nil)),
+		CamliSigner:  signer.PublicKeyBlobRef().String(),
+	}
+	footerJSON, err := json.Marshal(footer)
+	if err != nil {
+		return err
+	}
+	signed, err := signer.SignJSON(ctx, string(footerJSON), time.Time{})
+	if err != nil {
+		return fmt.Errorf("signing manifest footer: %v", err)
+	}
+	if _, err := fmt.Fprintln(bw, strings.TrimSpace(signed)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Reader reads back a manifest written by Write, one entry at a time.
+type Reader struct {
+	sc    *bufio.Scanner
+	h     hash.Hash
+	count int64
+	total int64
+}
+
+// NewReader returns a Reader that reads a manifest from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		sc: bufio.NewScanner(r),
+		h:  sha256.New(),
+	}
+}
+
+// Next returns the next blob entry in the manifest body, or io.EOF once
+// the body's terminating blank line is reached. Callers must exhaust
+// Next before calling Verify.
+func (r *Reader) Next() (blob.SizedRef, error) {
+	if !r.sc.Scan() {
+		if err := r.sc.Err(); err != nil {
+			return blob.SizedRef{}, err
+		}
+		return blob.SizedRef{}, io.ErrUnexpectedEOF
+	}
+	line := r.sc.Text()
+	if line == "" {
+		return blob.SizedRef{}, io.EOF
+	}
+	fmt.Fprintf(r.h, "%s\n", line)
+
+	sp := strings.LastIndexByte(line, ' ')
+	if sp < 0 {
+		return blob.SizedRef{}, fmt.Errorf("manifest: malformed entry line %q", line)
+	}
+	ref, ok := blob.Parse(line[:sp])
+	if !ok {
+		return blob.SizedRef{}, fmt.Errorf("manifest: malformed blobref in line %q", line)
+	}
+	size, err := strconv.ParseUint(line[sp+1:], 10, 32)
+	if err != nil {
+		return blob.SizedRef{}, fmt.Errorf("manifest: malformed size in line %q: %v", line, err)
+	}
+	r.count++
+	r.total += int64(size)
+	return blob.SizedRef{Ref: ref, Size: uint32(size)}, nil
+}
+
+// Verify reads the manifest's signed footer, verifies its signature
+// (fetching the signer's public key via fetcher) and checks that the
+// digest, blob count, and total size it records match the entries
+// previously returned by Next. It returns the verified Footer.
+//
+// Verify must be called after Next has returned io.EOF.
+func (r *Reader) Verify(ctx context.Context, fetcher blob.Fetcher) (*Footer, error) {
+	if !r.sc.Scan() {
+		if err := r.sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("manifest: missing signed footer")
+	}
+	sjson := r.sc.Text()
+
+	vr := jsonsign.NewVerificationRequest(sjson, fetcher)
+	if _, err := vr.Verify(ctx); err != nil {
+		return nil, fmt.Errorf("manifest: invalid footer signature: %v", err)
+	}
+
+	payloadJSON, err := json.Marshal(vr.PayloadMap)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: re-marshaling verified footer payload: %v", err)
+	}
+	var footer Footer
+	if err := json.Unmarshal(payloadJSON, &footer); err != nil {
+		return nil, fmt.Errorf("manifest: malformed footer payload: %v", err)
+	}
+	if footer.Type != FooterType {
+		return nil, fmt.Errorf("manifest: unexpected footer type %q", footer.Type)
+	}
+	if got, want := hex.EncodeToString(r.h.Sum(nil)), footer.BodySHA256; got != want {
+		return nil, fmt.Errorf("manifest: body digest mismatch: computed %s, footer says %s", got, want)
+	}
+	if r.count != footer.BlobCount {
+		return nil, fmt.Errorf("manifest: blob count mismatch: read %d entries, footer says %d", r.count, footer.BlobCount)
+	}
+	if r.total != footer.TotalSize {
+		return nil, fmt.Errorf("manifest: total size mismatch: read %d bytes, footer says %d", r.total, footer.TotalSize)
+	}
+	return &footer, nil
+}
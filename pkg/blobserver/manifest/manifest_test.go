@@ -0,0 +1,152 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver/memory"
+	"perkeep.org/pkg/jsonsign"
+	"perkeep.org/pkg/schema"
+)
+
+var ctxbg = context.Background()
+
+// memFetcher is a blob.Fetcher serving a single in-memory blob, enough
+// to resolve a Signer's own public key blob during verification.
+type memFetcher struct {
+	ref     blob.Ref
+	content string
+}
+
+func (f memFetcher) Fetch(ctx context.Context, br blob.Ref) (io.ReadCloser, uint32, error) {
+	if br != f.ref {
+		return nil, 0, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(f.content)), uint32(len(f.content)), nil
+}
+
+func testSigner(t *testing.T) (*schema.Signer, memFetcher) {
+	t.Helper()
+	ent, err := jsonsign.NewEntity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	armorPub, err := jsonsign.ArmoredPublicKey(ent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubRef := blob.RefFromString(armorPub)
+	signer, err := schema.NewSigner(pubRef, strings.NewReader(armorPub), ent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, memFetcher{ref: pubRef, content: armorPub}
+}
+
+func TestWriteAndVerify(t *testing.T) {
+	signer, fetcher := testSigner(t)
+
+	sto := memory.NewCache(1 << 20)
+	const content1, content2 = "hello", "world, a bit longer"
+	sb1 := writeTestBlob(t, sto, content1)
+	sb2 := writeTestBlob(t, sto, content2)
+
+	var buf bytes.Buffer
+	if err := Write(ctxbg, sto, signer, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	var got []blob.SizedRef
+	for {
+		sb, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, sb)
+	}
+	want := []blob.SizedRef{sb1, sb2}
+	if sb2.Ref.Less(sb1.Ref) {
+		want = []blob.SizedRef{sb2, sb1}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries; want %d", len(got), len(want))
+	}
+	for i, sb := range got {
+		if sb != want[i] {
+			t.Errorf("entry %d = %+v; want %+v", i, sb, want[i])
+		}
+	}
+
+	footer, err := r.Verify(ctxbg, fetcher)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if footer.BlobCount != 2 {
+		t.Errorf("BlobCount = %d; want 2", footer.BlobCount)
+	}
+	if footer.Type != FooterType {
+		t.Errorf("Type = %q; want %q", footer.Type, FooterType)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	signer, fetcher := testSigner(t)
+	sto := memory.NewCache(1 << 20)
+	writeTestBlob(t, sto, "hello")
+
+	var buf bytes.Buffer
+	if err := Write(ctxbg, sto, signer, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the recorded size of the one entry line, simulating a
+	// blob that grew since the manifest was generated.
+	tampered := strings.Replace(buf.String(), " 5\n", " 6\n", 1)
+
+	r := NewReader(strings.NewReader(tampered))
+	for {
+		if _, err := r.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Verify(ctxbg, fetcher); err == nil {
+		t.Error("Verify succeeded on a tampered manifest; want error")
+	}
+}
+
+func writeTestBlob(t *testing.T, sto *memory.Storage, content string) blob.SizedRef {
+	t.Helper()
+	ref := blob.RefFromString(content)
+	sb, err := sto.ReceiveBlob(ctxbg, ref, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sb
+}
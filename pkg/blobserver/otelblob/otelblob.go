@@ -0,0 +1,150 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package otelblob adds OpenTelemetry tracing spans around blobserver
+// Storage operations and the HTTP handlers that serve them, so an
+// operator with a tracing backend configured can see where time goes
+// across a request, from the incoming HTTP call through to whichever
+// storage backend (disk, s3, encrypt, replica, ...) ultimately serves
+// it.
+//
+// Both WrapStorage and Middleware use otel.Tracer, which is a global,
+// no-op tracer until a process configures a real
+// go.opentelemetry.io/otel/sdk/trace TracerProvider (e.g. in
+// server/perkeepd's main). Until then, starting a span is a few cheap
+// no-op interface calls, so this package adds negligible overhead when
+// tracing isn't configured.
+//
+// Span attributes are limited to blob refs (content hashes, not
+// sensitive), counts, and sizes; blob contents and request bodies are
+// never recorded as attributes.
+package otelblob // import "perkeep.org/pkg/blobserver/otelblob"
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+var tracer = otel.Tracer("perkeep.org/pkg/blobserver")
+
+// WrapStorage returns a Storage that behaves like sto, except that each
+// operation is wrapped in an OpenTelemetry span named
+// "blobserver.<Method>", as a child of any span already in the context
+// passed to it (see Middleware for how that gets there from an incoming
+// HTTP request).
+//
+// Like blobserver/readonly's wrapper, this exposes exactly the Storage
+// methods and nothing more: optional interfaces sto might additionally
+// implement (blobserver.MaxEnumerateConfig, blobserver.Generationer,
+// etc.) aren't promoted, so callers that type-assert for them against
+// the wrapped value will fall back to their own defaults instead of
+// seeing sto's. This is only a behavior change for the handful of
+// storage types that implement one of those optional interfaces.
+func WrapStorage(sto blobserver.Storage) blobserver.Storage {
+	return &storage{sto}
+}
+
+type storage struct {
+	blobserver.Storage
+}
+
+func (s *storage) Fetch(ctx context.Context, br blob.Ref) (io.ReadCloser, uint32, error) {
+	ctx, span := tracer.Start(ctx, "blobserver.Fetch", trace.WithAttributes(
+		attribute.String("blob.ref", br.String()),
+	))
+	defer span.End()
+	rc, size, err := s.Storage.Fetch(ctx, br)
+	endSpan(span, err)
+	return rc, size, err
+}
+
+func (s *storage) ReceiveBlob(ctx context.Context, br blob.Ref, source io.Reader) (blob.SizedRef, error) {
+	ctx, span := tracer.Start(ctx, "blobserver.ReceiveBlob", trace.WithAttributes(
+		attribute.String("blob.ref", br.String()),
+	))
+	defer span.End()
+	sb, err := s.Storage.ReceiveBlob(ctx, br, source)
+	span.SetAttributes(attribute.Int64("blob.size", int64(sb.Size)))
+	endSpan(span, err)
+	return sb, err
+}
+
+func (s *storage) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.SizedRef) error) error {
+	ctx, span := tracer.Start(ctx, "blobserver.StatBlobs", trace.WithAttributes(
+		attribute.Int("blob.count", len(blobs)),
+	))
+	defer span.End()
+	err := s.Storage.StatBlobs(ctx, blobs, fn)
+	endSpan(span, err)
+	return err
+}
+
+func (s *storage) EnumerateBlobs(ctx context.Context, dest chan<- blob.SizedRef, after string, limit int) error {
+	ctx, span := tracer.Start(ctx, "blobserver.EnumerateBlobs", trace.WithAttributes(
+		attribute.Int("blob.limit", limit),
+	))
+	defer span.End()
+	err := s.Storage.EnumerateBlobs(ctx, dest, after, limit)
+	endSpan(span, err)
+	return err
+}
+
+func (s *storage) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
+	ctx, span := tracer.Start(ctx, "blobserver.RemoveBlobs", trace.WithAttributes(
+		attribute.Int("blob.count", len(blobs)),
+	))
+	defer span.End()
+	err := s.Storage.RemoveBlobs(ctx, blobs)
+	endSpan(span, err)
+	return err
+}
+
+// endSpan records err on span, if any, using the OpenTelemetry
+// convention of setting an error status and recording the error event.
+func endSpan(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.SetStatus(codes.Error, err.Error())
+	span.RecordError(err)
+}
+
+// Middleware wraps next in an OpenTelemetry span named "blobserver.<op>",
+// extracting any trace context propagated in req's headers (via
+// go.opentelemetry.io/otel/propagation, typically W3C traceparent) so the
+// span is linked into the caller's trace instead of starting a new one.
+// The span is available to next (and anything it calls, including a
+// Storage wrapped with WrapStorage) via req.Context().
+func Middleware(op string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracer.Start(ctx, "blobserver."+op, trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+		))
+		defer span.End()
+		next.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package readonly registers the "readonly" blobserver storage type, a
+// transparent wrapper around another storage target that rejects every
+// ReceiveBlob and RemoveBlobs call, so the wrapped storage can only ever
+// be read from, never written to or deleted from.
+//
+// It's meant for safely exposing a storage prefix for browsing: mount
+// the same backend twice, once normally and once through readonly, and
+// hand out access to only the readonly one.
+//
+// Because the wrapper embeds blobserver.Storage as an interface value
+// rather than the underlying concrete storage, it exposes exactly the
+// five Storage methods (and no more): optional interfaces the wrapped
+// storage might implement, such as blobserver.WholeRefFetcher or
+// blobserver.Generationer, are not promoted and so can't be reached by
+// type-asserting a *storage, even if they'd otherwise offer a write
+// path around ReceiveBlob and RemoveBlobs.
+//
+// Example config:
+//
+//	"/bs/": {
+//	    "handler": "storage-readonly",
+//	    "handlerArgs": {
+//	        "from": "/bs-disk/"
+//	    }
+//	}
+package readonly // import "perkeep.org/pkg/blobserver/readonly"
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+// ErrReadOnly is returned by ReceiveBlob and RemoveBlobs on a readonly storage.
+var ErrReadOnly = fmt.Errorf("readonly: storage is read-only")
+
+type storage struct {
+	blobserver.Storage // the wrapped storage; Fetch/StatBlobs/EnumerateBlobs pass through
+}
+
+func newFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (blobserver.Storage, error) {
+	fromName := conf.RequiredString("from")
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	from, err := ld.GetStorage(fromName)
+	if err != nil {
+		return nil, fmt.Errorf("readonly: invalid 'from' storage %q: %v", fromName, err)
+	}
+	return &storage{Storage: from}, nil
+}
+
+func (s *storage) ReceiveBlob(ctx context.Context, br blob.Ref, source io.Reader) (blob.SizedRef, error) {
+	return blob.SizedRef{}, ErrReadOnly
+}
+
+func (s *storage) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
+	return ErrReadOnly
+}
+
+func init() {
+	blobserver.RegisterStorageConstructor("readonly", blobserver.StorageConstructor(newFromConfig))
+}
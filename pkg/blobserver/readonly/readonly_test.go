@@ -0,0 +1,91 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readonly
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/blobserver/memory"
+)
+
+var ctxbg = context.Background()
+
+func TestReceiveBlobRejected(t *testing.T) {
+	from := new(memory.Storage)
+	sto := &storage{Storage: from}
+
+	br := blob.RefFromString("hello")
+	if _, err := sto.ReceiveBlob(ctxbg, br, strings.NewReader("hello")); err != ErrReadOnly {
+		t.Fatalf("ReceiveBlob error = %v; want %v", err, ErrReadOnly)
+	}
+}
+
+func TestRemoveBlobsRejected(t *testing.T) {
+	from := new(memory.Storage)
+	sto := &storage{Storage: from}
+
+	br := blob.RefFromString("hello")
+	if err := sto.RemoveBlobs(ctxbg, []blob.Ref{br}); err != ErrReadOnly {
+		t.Fatalf("RemoveBlobs error = %v; want %v", err, ErrReadOnly)
+	}
+}
+
+func TestOtherMethodsPassThrough(t *testing.T) {
+	from := new(memory.Storage)
+	sto := &storage{Storage: from}
+
+	var _ blobserver.Storage = sto
+
+	br, err := blobserver.ReceiveString(ctxbg, from, "hello")
+	if err != nil {
+		t.Fatalf("ReceiveBlob on underlying storage: %v", err)
+	}
+	rc, _, err := sto.Fetch(ctxbg, br.Ref)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	rc.Close()
+
+	var gotRef blob.SizedRef
+	if err := sto.StatBlobs(ctxbg, []blob.Ref{br.Ref}, func(sb blob.SizedRef) error {
+		gotRef = sb
+		return nil
+	}); err != nil {
+		t.Fatalf("StatBlobs: %v", err)
+	}
+	if gotRef.Ref != br.Ref {
+		t.Fatalf("StatBlobs did not find %v", br.Ref)
+	}
+}
+
+func TestNoWriteCapableSubinterfaces(t *testing.T) {
+	from := new(memory.Storage)
+	sto := &storage{Storage: from}
+
+	// memory.Storage doesn't implement any optional write-capable
+	// interfaces itself, but this documents the invariant relied on by
+	// the package doc comment: type-asserting the wrapper for anything
+	// beyond blobserver.Storage must never succeed just because the
+	// wrapped storage happens to support it.
+	if _, ok := interface{}(sto).(blobserver.ShutdownStorage); ok {
+		t.Error("storage unexpectedly implements ShutdownStorage")
+	}
+}
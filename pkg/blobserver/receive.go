@@ -30,6 +30,35 @@ import (
 // ErrReadonly is the error value returned by read-only blobservers.
 var ErrReadonly = errors.New("this blobserver is read only")
 
+// CopyContext copies from src to dst like io.Copy, but returns ctx.Err()
+// as soon as ctx is done, instead of waiting for the copy to finish.
+//
+// It's meant for Storage implementations (like ones backed by a local
+// disk) whose Receive path has no other way to notice that the caller
+// has gone away, so a slow or stalled src doesn't tie up server
+// resources for longer than the caller is willing to wait.
+//
+// If ctx is done first, the copy goroutine keeps running in the
+// background until src returns an error or EOF; callers must not reuse
+// dst until they're sure that's happened.
+func CopyContext(ctx context.Context, dst io.Writer, src io.Reader) (written int64, err error) {
+	type result struct {
+		written int64
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := io.Copy(dst, src)
+		done <- result{n, err}
+	}()
+	select {
+	case r := <-done:
+		return r.written, r.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
 // ReceiveString uploads the blob given by the string s to dst
 // and returns its blobref and size.
 func ReceiveString(ctx context.Context, dst BlobReceiver, s string) (blob.SizedRef, error) {
@@ -19,6 +19,7 @@ package blobserver_test
 import (
 	"bytes"
 	"context"
+	"io"
 	"testing"
 	"time"
 
@@ -71,3 +72,51 @@ func TestReceiveCorrupt(t *testing.T) {
 		t.Errorf("nothing should be stored. Got %q", sto.BlobrefStrings())
 	}
 }
+
+// blockingReader never returns from Read until closed, simulating a
+// stalled or slow client.
+type blockingReader struct {
+	closed chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	<-r.closed
+	return 0, io.ErrClosedPipe
+}
+
+func TestCopyContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(ctxbg)
+	src := &blockingReader{closed: make(chan struct{})}
+	defer close(src.closed) // let the leaked goroutine finish
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := blobserver.CopyContext(ctx, io.Discard, src)
+		done <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("CopyContext error = %v; want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for CopyContext to return after cancel")
+	}
+}
+
+func TestCopyContextSuccess(t *testing.T) {
+	data := []byte("hello, world")
+	var buf bytes.Buffer
+	n, err := blobserver.CopyContext(ctxbg, &buf, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("wrote %d bytes; want %d", n, len(data))
+	}
+	if buf.String() != string(data) {
+		t.Errorf("buf = %q; want %q", buf.String(), string(data))
+	}
+}
@@ -90,14 +90,17 @@ func (sto *s3Storage) String() string {
 }
 
 func newFromConfig(l blobserver.Loader, config jsonconfig.Obj) (blobserver.Storage, error) {
-	return newFromConfigWithTransport(l, config, nil)
+	return NewFromConfigWithTransport(l, config, nil)
 }
 
-// newFromConfigWithTransport constructs a s3 blobserver using the given
-// transport for all s3 requests.  The transport may be set to 'nil' to use a
+// NewFromConfigWithTransport constructs a s3 blobserver using the given
+// transport for all s3 requests. The transport may be set to nil to use a
 // default transport.
-// This is used for unit tests.
-func newFromConfigWithTransport(_ blobserver.Loader, config jsonconfig.Obj, transport http.RoundTripper) (blobserver.Storage, error) {
+// It is exported so that other packages backed by an S3-compatible API
+// (such as pkg/blobserver/b2) can reuse this implementation while
+// providing their own transport, e.g. to add provider-specific retry
+// behavior.
+func NewFromConfigWithTransport(_ blobserver.Loader, config jsonconfig.Obj, transport http.RoundTripper) (blobserver.Storage, error) {
 	hostname := config.OptionalString("hostname", "")
 	region := config.OptionalString("aws_region", "us-east-1")
 
@@ -111,6 +114,12 @@ func newFromConfigWithTransport(_ blobserver.Loader, config jsonconfig.Obj, tran
 		s3Cfg.WithEndpoint(hostname)
 	}
 	s3Cfg.WithRegion(region)
+	// maxRetries defaults to -1, which leaves the SDK's own default (3) in
+	// place; it's overridable for S3-compatible providers that need a more
+	// aggressive retry budget for their own rate limiting (e.g. B2's 429s).
+	if maxRetries := config.OptionalInt("maxRetries", -1); maxRetries >= 0 {
+		s3Cfg.WithMaxRetries(maxRetries)
+	}
 	if transport != nil {
 		httpClient := *http.DefaultClient
 		httpClient.Transport = transport
@@ -170,7 +179,6 @@ func newFromConfigWithTransport(_ blobserver.Loader, config jsonconfig.Obj, tran
 
 func init() {
 	blobserver.RegisterStorageConstructor("s3", blobserver.StorageConstructor(newFromConfig))
-	blobserver.RegisterStorageConstructor("b2", blobserver.StorageConstructor(newFromConfig))
 }
 
 // isNotFound checks for s3 errors which indicate the object doesn't exist.
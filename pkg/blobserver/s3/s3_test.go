@@ -248,7 +248,7 @@ func TestS3EndpointRedirect(t *testing.T) {
 		panic(err)
 	}
 
-	_, err = newFromConfigWithTransport(nil, jsonconfig.Obj{
+	_, err = NewFromConfigWithTransport(nil, jsonconfig.Obj{
 		"aws_access_key":        "key",
 		"aws_secret_access_key": "secret",
 		"bucket":                "mock_bucket",
@@ -293,7 +293,7 @@ func TestNonS3Endpoints(t *testing.T) {
 
 	for _, hostname := range testValidHostnames {
 		t.Run(hostname, func(t *testing.T) {
-			_, err := newFromConfigWithTransport(nil, jsonconfig.Obj{
+			_, err := NewFromConfigWithTransport(nil, jsonconfig.Obj{
 				"aws_access_key":        "key",
 				"aws_secret_access_key": "secret",
 				"bucket":                "mock_bucket",
@@ -307,7 +307,7 @@ func TestNonS3Endpoints(t *testing.T) {
 
 	for _, hostname := range testInvalidHostnames {
 		t.Run(hostname, func(t *testing.T) {
-			_, err := newFromConfigWithTransport(nil, jsonconfig.Obj{
+			_, err := NewFromConfigWithTransport(nil, jsonconfig.Obj{
 				"aws_access_key":        "key",
 				"aws_secret_access_key": "secret",
 				"bucket":                "mock_bucket",
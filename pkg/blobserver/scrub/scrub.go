@@ -0,0 +1,174 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scrub registers the "scrub" blobserver storage type, a
+// transparent wrapper around another storage target that, in the
+// background, slowly re-reads every blob and verifies its content
+// against its blobref digest. It's meant for long-lived stores, to
+// catch bit-rot before it's noticed the hard way.
+//
+// Example config:
+//
+//	"/bs/": {
+//	    "handler": "storage-scrub",
+//	    "handlerArgs": {
+//	        "from": "/bs-disk/",
+//	        "blobsPerSecond": 5,
+//	        "cursor": {
+//	            "type": "file",
+//	            "file": "/perkeep/scrub-cursor.kv"
+//	        }
+//	    }
+//	}
+//
+// "cursor" is optional; without it, every server restart begins a new
+// scrub cycle from the start instead of resuming where the last one
+// left off.
+package scrub // import "perkeep.org/pkg/blobserver/scrub"
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"go4.org/jsonconfig"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/sorted"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultBlobsPerSecond is the scrub rate used when the config doesn't
+// specify one. It's deliberately low: scrubbing is a background,
+// best-effort activity that shouldn't compete with real traffic.
+const defaultBlobsPerSecond = 5
+
+// cursorKey is the single key used in the optional cursor KeyValue to
+// remember how far the current scrub cycle has gotten.
+const cursorKey = "scrub-after"
+
+var (
+	blobsScrubbedVar = expvar.NewInt("scrub-blobs-checked")
+	blobsCorruptVar  = expvar.NewInt("scrub-blobs-corrupt")
+)
+
+type storage struct {
+	blobserver.Storage // the wrapped storage; all Storage methods pass through
+
+	rate   *rate.Limiter
+	cursor sorted.KeyValue // optional; nil if scrubbing isn't resumable across restarts
+}
+
+func newFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (blobserver.Storage, error) {
+	fromName := conf.RequiredString("from")
+	blobsPerSecond := conf.OptionalInt("blobsPerSecond", defaultBlobsPerSecond)
+	cursorConf := conf.OptionalObject("cursor")
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	from, err := ld.GetStorage(fromName)
+	if err != nil {
+		return nil, fmt.Errorf("scrub: invalid 'from' storage %q: %v", fromName, err)
+	}
+	sto := &storage{
+		Storage: from,
+		rate:    rate.NewLimiter(rate.Limit(blobsPerSecond), 1),
+	}
+	if len(cursorConf) > 0 {
+		sto.cursor, err = sorted.NewKeyValue(cursorConf)
+		if err != nil {
+			return nil, fmt.Errorf("scrub: invalid 'cursor' configuration: %v", err)
+		}
+	}
+	go sto.scrubLoop(context.Background())
+	return sto, nil
+}
+
+// scrubLoop runs forever, verifying every blob in the wrapped storage
+// at the configured rate and then, on reaching the end, starting a new
+// cycle from the beginning.
+func (s *storage) scrubLoop(ctx context.Context) {
+	for {
+		after := s.loadCursor()
+		err := blobserver.EnumerateAllFrom(ctx, s.Storage, after, func(sb blob.SizedRef) error {
+			if err := s.rate.Wait(ctx); err != nil {
+				return err
+			}
+			s.scrubOne(ctx, sb)
+			s.saveCursor(sb.Ref.String())
+			return nil
+		})
+		if err != nil {
+			log.Printf("scrub: enumeration error, will retry: %v", err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		// Reached the end of this cycle; start over from the beginning.
+		s.saveCursor("")
+	}
+}
+
+// scrubOne fetches and re-hashes a single blob, logging (and counting)
+// any digest mismatch. It never returns an error; a failure to even
+// read the blob is logged as a possible corruption too.
+func (s *storage) scrubOne(ctx context.Context, sb blob.SizedRef) {
+	blobsScrubbedVar.Add(1)
+	rc, _, err := s.Storage.Fetch(ctx, sb.Ref)
+	if err != nil {
+		log.Printf("scrub: error reading blob %v: %v", sb.Ref, err)
+		blobsCorruptVar.Add(1)
+		return
+	}
+	defer rc.Close()
+	h := sb.Ref.Hash()
+	if _, err := io.Copy(h, rc); err != nil {
+		log.Printf("scrub: error reading blob %v: %v", sb.Ref, err)
+		blobsCorruptVar.Add(1)
+		return
+	}
+	if !sb.Ref.HashMatches(h) {
+		log.Printf("scrub: CORRUPTION detected: blob %v does not match its digest", sb.Ref)
+		blobsCorruptVar.Add(1)
+	}
+}
+
+func (s *storage) loadCursor() string {
+	if s.cursor == nil {
+		return ""
+	}
+	v, err := s.cursor.Get(cursorKey)
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+func (s *storage) saveCursor(after string) {
+	if s.cursor == nil {
+		return
+	}
+	if err := s.cursor.Set(cursorKey, after); err != nil {
+		log.Printf("scrub: error saving cursor: %v", err)
+	}
+}
+
+func init() {
+	blobserver.RegisterStorageConstructor("scrub", blobserver.StorageConstructor(newFromConfig))
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scrub
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/sorted"
+)
+
+var ctxbg = context.Background()
+
+// fetcherStorage is a blobserver.Storage that only implements Fetch,
+// returning content regardless of what blobref was asked for. It's
+// enough to exercise scrubOne, which only calls Fetch.
+type fetcherStorage struct {
+	blobserver.Storage // nil; panics if any other method is called
+	content            string
+}
+
+func (f fetcherStorage) Fetch(ctx context.Context, br blob.Ref) (io.ReadCloser, uint32, error) {
+	return io.NopCloser(strings.NewReader(f.content)), uint32(len(f.content)), nil
+}
+
+func TestScrubOneDetectsCorruption(t *testing.T) {
+	good := blob.RefFromString("hello")
+	sto := &storage{Storage: fetcherStorage{content: "goodbye"}}
+
+	blobsCorruptVar.Set(0)
+	sto.scrubOne(ctxbg, blob.SizedRef{Ref: good, Size: 5})
+	if got := blobsCorruptVar.Value(); got != 1 {
+		t.Errorf("blobsCorruptVar = %d; want 1", got)
+	}
+}
+
+func TestScrubOneAcceptsGoodBlob(t *testing.T) {
+	good := blob.RefFromString("hello")
+	sto := &storage{Storage: fetcherStorage{content: "hello"}}
+
+	blobsCorruptVar.Set(0)
+	sto.scrubOne(ctxbg, blob.SizedRef{Ref: good, Size: 5})
+	if got := blobsCorruptVar.Value(); got != 0 {
+		t.Errorf("blobsCorruptVar = %d; want 0", got)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	kv, err := sorted.NewKeyValue(map[string]interface{}{"type": "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sto := &storage{cursor: kv}
+	if got := sto.loadCursor(); got != "" {
+		t.Fatalf("loadCursor before save = %q; want empty", got)
+	}
+	sto.saveCursor("sha224-abc")
+	if got := sto.loadCursor(); got != "sha224-abc" {
+		t.Fatalf("loadCursor = %q; want sha224-abc", got)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shard
+
+import (
+	"sort"
+	"strconv"
+
+	"perkeep.org/pkg/blob"
+)
+
+// defaultVirtualNodes is the number of points each shard gets on the
+// ring. More points give a smoother (more even) distribution at the
+// cost of a bit more memory and lookup time.
+const defaultVirtualNodes = 150
+
+// hashRing implements consistent hashing over a fixed number of
+// shards. Unlike plain modulo sharding, adding or removing a shard
+// and rebuilding the ring only reassigns roughly a 1/nShards fraction
+// of the keyspace, instead of reshuffling nearly everything.
+type hashRing struct {
+	points []uint32       // sorted ring positions
+	owner  map[uint32]int // ring position -> shard index
+}
+
+func newHashRing(nShards, virtualNodes int) *hashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+	r := &hashRing{
+		owner: make(map[uint32]int, nShards*virtualNodes),
+	}
+	for shard := 0; shard < nShards; shard++ {
+		for v := 0; v < virtualNodes; v++ {
+			p := ringPoint(shard, v)
+			r.owner[p] = shard
+			r.points = append(r.points, p)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+func ringPoint(shard, vnode int) uint32 {
+	return blob.RefFromString(strconv.Itoa(shard) + "-" + strconv.Itoa(vnode)).Sum32()
+}
+
+// shardFor returns the index of the shard that owns b under the ring.
+func (r *hashRing) shardFor(b blob.Ref) int {
+	h := b.Sum32()
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owner[r.points[i]]
+}
@@ -27,13 +27,30 @@ Example low-level config:
 	        "backends": ["/s1/", "/s2/"]
 	     }
 	},
+
+By default, shard routes each blob using its ref modulo the number of
+backends. That means adding or removing a backend reshuffles nearly
+every blob's owner. Setting "consistentHash" instead routes blobs
+using a consistent-hashing ring, so changing the number of backends
+only moves roughly a 1/nShards fraction of blobs. See Rebalance for
+moving blobs to their new owners after such a change.
+
+	"/foo/": {
+	    "handler": "storage-shard",
+	    "handlerArgs": {
+	        "backends": ["/s1/", "/s2/", "/s3/"],
+	        "consistentHash": true
+	     }
+	},
 */
 package shard // import "perkeep.org/pkg/blobserver/shard"
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"os"
 	"sync"
 
 	"go4.org/jsonconfig"
@@ -44,12 +61,18 @@ import (
 type shardStorage struct {
 	shardPrefixes []string
 	shards        []blobserver.Storage
+
+	// ring is non-nil when consistentHash is enabled, in which case
+	// it (rather than plain modulo) decides each blob's owning shard.
+	ring *hashRing
 }
 
 func newFromConfig(ld blobserver.Loader, config jsonconfig.Obj) (storage blobserver.Storage, err error) {
 	sto := &shardStorage{
 		shardPrefixes: config.RequiredList("backends"),
 	}
+	consistentHash := config.OptionalBool("consistentHash", false)
+	virtualNodes := config.OptionalInt("virtualNodes", 0)
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
@@ -64,19 +87,59 @@ func newFromConfig(ld blobserver.Loader, config jsonconfig.Obj) (storage blobser
 		}
 		sto.shards[i] = shardSto
 	}
+	if consistentHash {
+		sto.ring = newHashRing(len(sto.shards), virtualNodes)
+	}
 	return sto, nil
 }
 
+// NewForRebalance builds a consistent-hashing shard storage directly from
+// already-constructed backends, for use by administrative tools that need
+// to call Rebalance but can't go through the usual jsonconfig-based
+// newFromConfig (which resolves backends by prefix through a
+// blobserver.Loader tied to a running server).
+//
+// virtualNodes is as in the "virtualNodes" config option; 0 means use the
+// default.
+func NewForRebalance(backends []blobserver.Storage, virtualNodes int) (blobserver.Storage, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("shard: need at least one shard")
+	}
+	return &shardStorage{
+		shards: backends,
+		ring:   newHashRing(len(backends), virtualNodes),
+	}, nil
+}
+
 func (sto *shardStorage) shard(b blob.Ref) blobserver.Storage {
 	return sto.shards[int(sto.shardNum(b))]
 }
 
 func (sto *shardStorage) shardNum(b blob.Ref) uint32 {
+	if sto.ring != nil {
+		return uint32(sto.ring.shardFor(b))
+	}
 	return b.Sum32() % uint32(len(sto.shards))
 }
 
 func (sto *shardStorage) Fetch(ctx context.Context, b blob.Ref) (file io.ReadCloser, size uint32, err error) {
-	return sto.shard(b).Fetch(ctx, b)
+	file, size, err = sto.shard(b).Fetch(ctx, b)
+	if err != os.ErrNotExist || sto.ring == nil {
+		return file, size, err
+	}
+	// The ring says b belongs on a particular shard, but a rebalance
+	// may not have moved it there yet. Fall back to checking the
+	// other shards so reads keep working mid-rebalance.
+	owner := sto.shardNum(b)
+	for i, s := range sto.shards {
+		if uint32(i) == owner {
+			continue
+		}
+		if file, size, err = s.Fetch(ctx, b); err == nil {
+			return file, size, nil
+		}
+	}
+	return nil, 0, os.ErrNotExist
 }
 
 func (sto *shardStorage) ReceiveBlob(ctx context.Context, b blob.Ref, source io.Reader) (sb blob.SizedRef, err error) {
@@ -147,6 +210,57 @@ func (sto *shardStorage) EnumerateBlobs(ctx context.Context, dest chan<- blob.Si
 	return blobserver.MergedEnumerateStorage(ctx, dest, sto.shards, after, limit)
 }
 
+// Rebalance moves any blob that's stored on the wrong shard, according to
+// sto's current consistent-hashing ring, to its correct shard, removing it
+// from the old one once the copy succeeds. It returns the number of blobs
+// moved.
+//
+// Rebalance is a no-op if sto wasn't configured with "consistentHash", since
+// plain modulo sharding has no stable notion of a blob's "correct" shard
+// independent of the current shard count.
+//
+// Callers, such as an administrative tool, are expected to run Rebalance
+// after adding or removing shards, since only ~1/nShards of blobs move at a
+// time and reads keep working in the meantime via Fetch's fallback scan.
+func Rebalance(ctx context.Context, sto blobserver.Storage) (moved int, err error) {
+	ss, ok := sto.(*shardStorage)
+	if !ok {
+		return 0, fmt.Errorf("shard.Rebalance: %T is not a shard storage", sto)
+	}
+	if ss.ring == nil {
+		return 0, nil
+	}
+	for i, s := range ss.shards {
+		var misplaced []blob.SizedRef
+		err := blobserver.EnumerateAll(ctx, s, func(sb blob.SizedRef) error {
+			if int(ss.shardNum(sb.Ref)) != i {
+				misplaced = append(misplaced, sb)
+			}
+			return nil
+		})
+		if err != nil {
+			return moved, fmt.Errorf("shard.Rebalance: enumerating shard %d: %v", i, err)
+		}
+		for _, sb := range misplaced {
+			rc, _, err := s.Fetch(ctx, sb.Ref)
+			if err != nil {
+				return moved, fmt.Errorf("shard.Rebalance: fetching %v from shard %d: %v", sb.Ref, i, err)
+			}
+			dest := ss.shards[ss.shardNum(sb.Ref)]
+			_, err = blobserver.Receive(ctx, dest, sb.Ref, rc)
+			rc.Close()
+			if err != nil {
+				return moved, fmt.Errorf("shard.Rebalance: copying %v off shard %d: %v", sb.Ref, i, err)
+			}
+			if err := s.RemoveBlobs(ctx, []blob.Ref{sb.Ref}); err != nil {
+				return moved, fmt.Errorf("shard.Rebalance: removing %v from old shard %d: %v", sb.Ref, i, err)
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
+
 func init() {
 	blobserver.RegisterStorageConstructor("shard", blobserver.StorageConstructor(newFromConfig))
 }
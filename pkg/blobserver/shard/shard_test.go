@@ -18,6 +18,7 @@ package shard
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"perkeep.org/pkg/blobserver"
@@ -71,6 +72,70 @@ func TestShard(t *testing.T) {
 	ts.checkShard(thingB, 0)
 }
 
+func newRingTestStorage(t *testing.T, n int) *testStorage {
+	shards := make([]blobserver.Storage, n)
+	fetchers := make([]*test.Fetcher, n)
+	prefixes := make([]string, n)
+	for i := range shards {
+		f := &test.Fetcher{}
+		shards[i] = f
+		fetchers[i] = f
+		prefixes[i] = fmt.Sprintf("/s%d/", i)
+	}
+	return &testStorage{
+		sto: &shardStorage{
+			shardPrefixes: prefixes,
+			shards:        shards,
+			ring:          newHashRing(n, 0),
+		},
+		shards: fetchers,
+		t:      t,
+	}
+}
+
+func TestConsistentHashRing(t *testing.T) {
+	ts := newRingTestStorage(t, 3)
+	var blobs []*test.Blob
+	for i := 0; i < 20; i++ {
+		b := &test.Blob{Contents: fmt.Sprintf("blob %d", i)}
+		if _, err := ts.sto.ReceiveBlob(ctxbg, b.BlobRef(), b.Reader()); err != nil {
+			t.Fatal(err)
+		}
+		blobs = append(blobs, b)
+	}
+	for _, b := range blobs {
+		want := int(ts.sto.shardNum(b.BlobRef()))
+		if _, _, err := ts.shards[want].Fetch(ctxbg, b.BlobRef()); err != nil {
+			t.Errorf("blob %v not found on its ring-assigned shard %d", b.BlobRef(), want)
+		}
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	ts := newRingTestStorage(t, 2)
+	b := &test.Blob{Contents: "misplaced blob"}
+	correct := int(ts.sto.shardNum(b.BlobRef()))
+	wrong := (correct + 1) % 2
+	// Put it directly on the wrong shard, bypassing sto's routing, to
+	// simulate a blob left behind by a ring change (e.g. a shard added).
+	if _, err := ts.shards[wrong].ReceiveBlob(ctxbg, b.BlobRef(), b.Reader()); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := Rebalance(ctxbg, ts.sto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 {
+		t.Errorf("Rebalance moved %d blobs, want 1", moved)
+	}
+	if _, _, err := ts.shards[correct].Fetch(ctxbg, b.BlobRef()); err != nil {
+		t.Errorf("blob not found on correct shard %d after rebalance", correct)
+	}
+	if _, _, err := ts.shards[wrong].Fetch(ctxbg, b.BlobRef()); err == nil {
+		t.Errorf("blob still present on old shard %d after rebalance", wrong)
+	}
+}
+
 // checkShard iterates through shards and find the blob. error if it is not found in expectShard, found somewhere else, or not found at all
 func (sto testStorage) checkShard(b *test.Blob, expectShard int) {
 	for shardN, shard := range sto.shards {
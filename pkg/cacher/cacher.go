@@ -23,6 +23,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"perkeep.org/internal/osutil"
 	"perkeep.org/pkg/blob"
@@ -103,6 +104,14 @@ type DiskCache struct {
 	Root string
 
 	cleanAll bool // cleaning policy. TODO: something better.
+
+	// disk is the same underlying storage as CachingFetcher.c, kept
+	// here with its concrete type so Clean can enumerate and
+	// selectively remove blobs from it.
+	disk *localdisk.DiskStorage
+
+	pinnedMu sync.Mutex
+	pinned   map[blob.Ref]bool // blobs protected from Clean, set via MarkPinned
 }
 
 // NewDiskCache returns a new DiskCache from a Fetcher, which
@@ -128,15 +137,59 @@ func NewDiskCache(fetcher blob.Fetcher) (*DiskCache, error) {
 	dc := &DiskCache{
 		CachingFetcher: NewCachingFetcher(diskcache, fetcher),
 		Root:           cacheDir,
+		disk:           diskcache,
 	}
 	return dc, nil
 }
 
-// Clean cleans some or all of the DiskCache.
+// MarkPinned marks refs as pinned, protecting them from removal by a
+// future Clean call until the process restarts (pins aren't persisted).
+func (dc *DiskCache) MarkPinned(refs ...blob.Ref) {
+	dc.pinnedMu.Lock()
+	defer dc.pinnedMu.Unlock()
+	if dc.pinned == nil {
+		dc.pinned = make(map[blob.Ref]bool)
+	}
+	for _, br := range refs {
+		dc.pinned[br] = true
+	}
+}
+
+// IsPinned reports whether br was previously marked pinned via MarkPinned.
+func (dc *DiskCache) IsPinned(br blob.Ref) bool {
+	dc.pinnedMu.Lock()
+	defer dc.pinnedMu.Unlock()
+	return dc.pinned[br]
+}
+
+// Clean cleans some or all of the DiskCache, preserving any blobs
+// previously marked pinned via MarkPinned.
 func (dc *DiskCache) Clean() {
 	// TODO: something between nothing and deleting everything.
-	if dc.cleanAll {
+	if !dc.cleanAll {
+		return
+	}
+	dc.pinnedMu.Lock()
+	nPinned := len(dc.pinned)
+	dc.pinnedMu.Unlock()
+	if nPinned == 0 {
 		os.RemoveAll(dc.Root)
+		return
+	}
+	ctx := context.Background()
+	var toDelete []blob.Ref
+	err := blobserver.EnumerateAll(ctx, dc.disk, func(sb blob.SizedRef) error {
+		if !dc.IsPinned(sb.Ref) {
+			toDelete = append(toDelete, sb.Ref)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("cacher: error enumerating disk cache for cleaning: %v", err)
+		return
+	}
+	if err := dc.disk.RemoveBlobs(ctx, toDelete); err != nil {
+		log.Printf("cacher: error cleaning disk cache: %v", err)
 	}
 }
 
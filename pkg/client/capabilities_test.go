@@ -0,0 +1,39 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import "testing"
+
+func TestHasCapability(t *testing.T) {
+	c := &Client{features: map[string]bool{"batchUpload": true}}
+	c.discoOnce.Do(noop) // pretend discovery already happened
+
+	if got, err := c.HasCapability("batchUpload"); err != nil || !got {
+		t.Errorf("HasCapability(%q) = %v, %v; want true, nil", "batchUpload", got, err)
+	}
+	if got, err := c.HasCapability("streamingSearch"); err != nil || got {
+		t.Errorf("HasCapability(%q) = %v, %v; want false, nil", "streamingSearch", got, err)
+	}
+}
+
+func TestHasCapabilityNoFeatures(t *testing.T) {
+	c := &Client{}
+	c.discoOnce.Do(noop)
+	if got, err := c.HasCapability("batchUpload"); err != nil || got {
+		t.Errorf("HasCapability with no advertised features = %v, %v; want false, nil", got, err)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
@@ -49,6 +50,7 @@ import (
 
 	"go4.org/syncutil"
 	"golang.org/x/net/http2"
+	"golang.org/x/time/rate"
 )
 
 // A Client provides access to a Perkeep server.
@@ -69,15 +71,18 @@ type Client struct {
 	isSharePrefix bool          // URL is a request for a share blob
 
 	discoOnce              syncutil.Once
-	searchRoot             string      // Handler prefix, or "" if none
-	downloadHelper         string      // or "" if none
-	storageGen             string      // storage generation, or "" if not reported
-	hasLegacySHA1          bool        // Whether server has SHA-1 blobs indexed.
-	syncHandlers           []*SyncInfo // "from" and "to" url prefix for each syncHandler
-	serverKeyID            string      // Server's GPG public key ID.
-	helpRoot               string      // Handler prefix, or "" if none
-	shareRoot              string      // Share handler prefix, or "" if none
-	serverPublicKeyBlobRef blob.Ref    // Server's public key blobRef
+	discoCacheTTL          time.Duration   // 0 unless discoCacheTTLSet
+	discoCacheTTLSet       bool            // whether OptionDiscoveryCacheTTL was used
+	searchRoot             string          // Handler prefix, or "" if none
+	downloadHelper         string          // or "" if none
+	storageGen             string          // storage generation, or "" if not reported
+	hasLegacySHA1          bool            // Whether server has SHA-1 blobs indexed.
+	features               map[string]bool // server-advertised feature flags; see Capabilities
+	syncHandlers           []*SyncInfo     // "from" and "to" url prefix for each syncHandler
+	serverKeyID            string          // Server's GPG public key ID.
+	helpRoot               string          // Handler prefix, or "" if none
+	shareRoot              string          // Share handler prefix, or "" if none
+	serverPublicKeyBlobRef blob.Ref        // Server's public key blobRef
 
 	signerOnce  sync.Once
 	signer      *schema.Signer
@@ -91,6 +96,12 @@ type Client struct {
 
 	httpClient *http.Client
 	haveCache  HaveCache
+	opLog      *OpLog // or nil, if offline queueing isn't enabled
+
+	// uploadLimiter, if non-nil, caps the aggregate throughput of blob
+	// bodies uploaded by Upload, shared across all concurrent uploads.
+	// See SetUploadRateLimiter.
+	uploadLimiter *rate.Limiter
 
 	// If sto is set, it's used before the httpClient or other network operations.
 	sto blobserver.Storage
@@ -153,11 +164,46 @@ type Client struct {
 	// through gopherjs in the web UI. Because we'll run into CORS errors if
 	// requests have a Host part.
 	sameOrigin bool
+
+	// userAgent is sent as the User-Agent header on every request.
+	// Defaults to a value identifying this package and the running
+	// binary; see OptionUserAgent.
+	userAgent string
+
+	// requestID is sent as the requestIDHeader on every request, so a
+	// server operator can grep their logs for all the requests made by
+	// one client operation. Defaults to a random value generated once
+	// per Client; see OptionRequestID.
+	requestID string
 }
 
 const maxParallelHTTP_h1 = 5
 const maxParallelHTTP_h2 = 50
 
+// defaultConnectTimeout is the TCP connect timeout used when a
+// TransportConfig doesn't specify one.
+const defaultConnectTimeout = 30 * time.Second
+
+// happyEyeballsDelay is how long the dialer waits for a preferred address
+// family (IPv6) to connect before also racing the fallback family (IPv4),
+// per RFC 8305 ("Happy Eyeballs"). It matches net.Dialer's own zero-value
+// default, but is set explicitly here so it isn't tied to a stdlib default
+// that could silently change.
+const happyEyeballsDelay = 300 * time.Millisecond
+
+// requestIDHeader is the HTTP header the client sets on every request
+// so a server operator can correlate a client operation with the
+// requests it made, across the client's and server's logs.
+const requestIDHeader = "X-Camlistore-Request-Id"
+
+// defaultUserAgent is the User-Agent sent on every request, unless
+// overridden with OptionUserAgent. It identifies the running binary
+// and this package's version, so a server operator can tell which
+// tool (pk-put, pk-mount, etc.) made a given request.
+func defaultUserAgent() string {
+	return fmt.Sprintf("%s (perkeep.org/pkg/client %s)", filepath.Base(os.Args[0]), buildinfo.Summary())
+}
+
 // New returns a new Perkeep Client.
 //
 // By default, with no options, it uses the client as configured in
@@ -167,6 +213,8 @@ func New(opts ...ClientOption) (*Client, error) {
 		haveCache: noHaveCache{},
 		Logger:    log.New(os.Stderr, "", log.Ldate|log.Ltime),
 		authMode:  auth.None{},
+		userAgent: defaultUserAgent(),
+		requestID: auth.RandToken(8),
 	}
 	for _, v := range opts {
 		v.modifyClient(c)
@@ -246,6 +294,17 @@ type TransportConfig struct {
 	// pk-put for debugging even localhost requests.
 	Proxy   func(*http.Request) (*url.URL, error)
 	Verbose bool // Verbose enables verbose logging of HTTP requests.
+
+	// ConnectTimeout optionally overrides defaultConnectTimeout as the
+	// timeout for establishing new TCP connections. Servers with both
+	// IPv4 and IPv6 addresses are dialed using Happy Eyeballs (RFC 8305),
+	// so a working address family is used even if the other is broken or
+	// slow. The request's own context deadline, if any, is respected in
+	// addition to this timeout. NO_PROXY is honored, since it's handled
+	// by the Proxy func (http.ProxyFromEnvironment by default) rather
+	// than by the dialer. ConnectTimeout is only used for plain-HTTP
+	// (non-TLS) connections made outside android; see dialContextFunc.
+	ConnectTimeout time.Duration
 }
 
 func (c *Client) useHTTP2(tc *TransportConfig) bool {
@@ -286,6 +345,7 @@ func (c *Client) transportForConfig(tc *TransportConfig) http.RoundTripper {
 		transport = &http.Transport{
 			DialTLS:             c.DialTLSFunc(),
 			Dial:                c.DialFunc(),
+			DialContext:         c.dialContextFunc(tc),
 			Proxy:               proxy,
 			MaxIdleConnsPerHost: maxParallelHTTP_h1,
 		}
@@ -420,6 +480,31 @@ func OptionAuthMode(m auth.AuthMode) ClientOption {
 	return optionAuthMode{m}
 }
 
+type optionUserAgent string
+
+func (o optionUserAgent) modifyClient(c *Client) { c.userAgent = string(o) }
+
+// OptionUserAgent returns a Client constructor option that sets the
+// User-Agent header sent on every request, overriding the default of
+// the running binary's name and this package's version.
+func OptionUserAgent(ua string) ClientOption {
+	return optionUserAgent(ua)
+}
+
+type optionRequestID string
+
+func (o optionRequestID) modifyClient(c *Client) { c.requestID = string(o) }
+
+// OptionRequestID returns a Client constructor option that sets the
+// value of requestIDHeader sent on every request, overriding the
+// default of a random value generated once per Client. This lets a
+// caller propagate an ID from some other tracing system so a single
+// operation can be correlated across systems, not just within the
+// server's own logs.
+func OptionRequestID(id string) ClientOption {
+	return optionRequestID(id)
+}
+
 // noop is for use with syncutil.Onces.
 func noop() error { return nil }
 
@@ -503,6 +588,65 @@ func (c *Client) SetHaveCache(cache HaveCache) {
 	c.haveCache = cache
 }
 
+// SetOpLog enables offline queueing of failed uploads to log: when the
+// server can't be reached, UploadOrQueue durably queues the blob to log
+// instead of failing, for FlushOpLog to retry once connectivity returns.
+// A nil log disables queueing, which is the default.
+func (c *Client) SetOpLog(log *OpLog) {
+	c.opLog = log
+}
+
+// isConnectivityError reports whether err looks like the server couldn't
+// be reached at all, as opposed to a request it understood and rejected
+// (bad auth, bad request, 5xx, etc.), which should still be returned to
+// the caller as-is rather than queued for a later retry that would just
+// fail the same way.
+func isConnectivityError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// UploadOrQueue is like Upload, except that if c has an OpLog set (via
+// SetOpLog) and the upload fails because the server is unreachable, the
+// blob is durably queued instead of the error being returned. The
+// returned PutResult has Queued set to true in that case.
+func (c *Client) UploadOrQueue(ctx context.Context, h *UploadHandle) (*PutResult, error) {
+	pr, err := c.Upload(ctx, h)
+	if err == nil || c.opLog == nil || !isConnectivityError(err) {
+		return pr, err
+	}
+
+	bodyReader, bodySize, rerr := h.readerAndSize()
+	if rerr != nil {
+		return nil, err // the original connectivity error is the interesting one
+	}
+	blob, rerr := io.ReadAll(io.LimitReader(bodyReader, bodySize))
+	if rerr != nil {
+		return nil, err
+	}
+	if _, qerr := c.opLog.Enqueue(blob); qerr != nil {
+		return nil, fmt.Errorf("upload failed (%v) and could not be queued: %v", err, qerr)
+	}
+	return &PutResult{BlobRef: h.BlobRef, Size: uint32(bodySize), Queued: true}, nil
+}
+
+// FlushOpLog replays c's queued blobs, in the order they were queued,
+// uploading each with c.Upload. It stops at the first blob that still
+// fails, leaving it and everything after it queued for the next call.
+// It's a no-op if c has no OpLog set.
+func (c *Client) FlushOpLog(ctx context.Context) error {
+	if c.opLog == nil {
+		return nil
+	}
+	return c.opLog.Flush(ctx, func(ctx context.Context, blob []byte) error {
+		_, err := c.Upload(ctx, NewUploadHandleFromString(string(blob)))
+		return err
+	})
+}
+
 func (c *Client) printf(format string, v ...interface{}) {
 	if c.Verbose && c.Logger != nil {
 		c.Logger.Printf(format, v...)
@@ -651,6 +795,32 @@ func (c *Client) HasLegacySHA1() (bool, error) {
 	return c.hasLegacySHA1, nil
 }
 
+// Capabilities returns the server's advertised feature flags, from its
+// discovery document's "features" map. Callers should treat an absent
+// flag as false: older servers won't have advertised it at all, and
+// this method returns a nil-safe empty map rather than an error if the
+// server didn't send one.
+//
+// Unrecognized flags should be ignored by callers, for forward
+// compatibility with servers newer than the client.
+func (c *Client) Capabilities() (map[string]bool, error) {
+	if err := c.condDiscovery(); err != nil {
+		return nil, err
+	}
+	return c.features, nil
+}
+
+// HasCapability reports whether the server has advertised support for
+// the named feature. It's a convenience wrapper around Capabilities for
+// the common case of checking a single flag.
+func (c *Client) HasCapability(feature string) (bool, error) {
+	features, err := c.Capabilities()
+	if err != nil {
+		return false, err
+	}
+	return features[feature], nil
+}
+
 // SyncInfo holds the data that were acquired with a discovery
 // and that are relevant to a syncHandler.
 type SyncInfo struct {
@@ -762,6 +932,38 @@ func (c *Client) GetClaims(ctx context.Context, req *search.ClaimsRequest) (*sea
 	return res, nil
 }
 
+// GetPathTarget resolves a camliPath:suffix relation directly against
+// the search handler's maintained path index, which is cheaper than a
+// general Query for the common case of resolving one path component
+// (e.g. what the publisher does for every URL it serves).
+func (c *Client) GetPathTarget(ctx context.Context, req *search.PathTargetRequest) (*search.PathTargetResponse, error) {
+	sr, err := c.SearchRoot()
+	if err != nil {
+		return nil, err
+	}
+	url := sr + req.URLSuffix()
+	hreq := c.newRequest(ctx, "GET", url)
+	hres, err := c.doReqGated(hreq)
+	if err != nil {
+		return nil, err
+	}
+	if hres.StatusCode == http.StatusNotFound {
+		hres.Body.Close()
+		return nil, os.ErrNotExist
+	}
+	if hres.StatusCode < 200 || hres.StatusCode > 299 {
+		buf := new(bytes.Buffer)
+		io.CopyN(buf, hres.Body, 1<<20)
+		hres.Body.Close()
+		return nil, fmt.Errorf("client: got status code %d from URL %s; body %s", hres.StatusCode, hreq.URL.String(), buf.String())
+	}
+	res := new(search.PathTargetResponse)
+	if err := httputil.DecodeJSON(hres, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (c *Client) query(ctx context.Context, req *search.SearchQuery) (*http.Response, error) {
 	sr, err := c.SearchRoot()
 	if err != nil {
@@ -950,6 +1152,13 @@ func (c *Client) blobPrefix() (string, error) {
 	return pfx, nil
 }
 
+// Server returns the resolved server URL for this client, i.e. the value
+// of the "server" flag or config alias after resolution, as opposed to
+// the possibly-unresolved alias name passed to OptionServer.
+func (c *Client) Server() string {
+	return c.server
+}
+
 // discoRoot returns the user defined server for this client. It prepends "https://" if no scheme was specified.
 func (c *Client) discoRoot() string {
 	s := c.server
@@ -997,7 +1206,7 @@ func (c *Client) condDiscovery() error {
 // This method exists purely for the "camtool discovery" command.
 // Clients shouldn't have to parse this themselves.
 func (c *Client) DiscoveryDoc(ctx context.Context) (io.Reader, error) {
-	res, err := c.discoveryResp(ctx)
+	res, err := c.discoveryResp(ctx, "")
 	if err != nil {
 		return nil, err
 	}
@@ -1026,15 +1235,24 @@ func (c *Client) HTTPVersion(ctx context.Context) (string, error) {
 	return res.Proto, err
 }
 
-func (c *Client) discoveryResp(ctx context.Context) (*http.Response, error) {
+// discoveryResp fetches the discovery document. If ifNoneMatch is
+// non-empty, it's sent as the If-None-Match header, and the caller must
+// handle a resulting 304 response (the body is empty in that case).
+func (c *Client) discoveryResp(ctx context.Context, ifNoneMatch string) (*http.Response, error) {
 	// If the path is just "" or "/", do discovery against
 	// the URL to see which path we should actually use.
 	req := c.newRequest(ctx, "GET", c.discoRoot(), nil)
 	req.Header.Set("Accept", "text/x-camli-configuration")
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
 	res, err := c.doReqGated(req)
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode == http.StatusNotModified {
+		return res, nil
+	}
 	if res.StatusCode != 200 {
 		res.Body.Close()
 		errMsg := fmt.Sprintf("got status %q from blobserver URL %q during configuration discovery", res.Status, c.discoRoot())
@@ -1054,22 +1272,49 @@ func (c *Client) discoveryResp(ctx context.Context) (*http.Response, error) {
 }
 
 func (c *Client) doDiscovery() error {
-	ctx := context.TODO()
 	root, err := url.Parse(c.discoRoot())
 	if err != nil {
 		return err
 	}
 
-	res, err := c.discoveryResp(ctx)
+	if disco, ok := c.cachedDiscovery(); ok {
+		return c.applyDiscovery(root, disco)
+	}
+
+	// The on-disk cache may be stale but still have a usable ETag: send
+	// it as If-None-Match so the server can tell us nothing changed
+	// without resending (and us re-parsing) the whole document.
+	stale, haveStale := c.staleDiscoveryCache()
+	ifNoneMatch := ""
+	if haveStale {
+		ifNoneMatch = stale.ETag
+	}
+
+	ctx := context.TODO()
+	res, err := c.discoveryResp(ctx, ifNoneMatch)
 	if err != nil {
 		return err
 	}
+	if res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		c.touchDiscoveryCache(stale)
+		return c.applyDiscovery(root, &stale.Disco)
+	}
 
 	var disco camtypes.Discovery
 	if err := httputil.DecodeJSON(res, &disco); err != nil {
 		return err
 	}
+	if err := c.applyDiscovery(root, &disco); err != nil {
+		return err
+	}
+	c.writeDiscoveryCache(&disco, res.Header.Get("ETag"))
+	return nil
+}
 
+// applyDiscovery updates the client's fields from a discovery document,
+// resolving its handler paths against root (the server's discovery URL).
+func (c *Client) applyDiscovery(root *url.URL, disco *camtypes.Discovery) error {
 	if disco.SearchRoot == "" {
 		c.searchRoot = ""
 	} else {
@@ -1094,6 +1339,7 @@ func (c *Client) doDiscovery() error {
 
 	c.storageGen = disco.StorageGeneration
 	c.hasLegacySHA1 = disco.HasLegacySHA1Index
+	c.features = disco.Features
 
 	u, err = root.Parse(disco.BlobRoot)
 	if err != nil {
@@ -1210,6 +1456,12 @@ func (c *Client) newRequest(ctx context.Context, method, url string, body ...io.
 		req.ContentLength = int64(br.Len())
 	}
 	c.authMode.AddAuthHeader(req)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.requestID != "" {
+		req.Header.Set(requestIDHeader, c.requestID)
+	}
 	return req.WithContext(ctx)
 }
 
@@ -1245,6 +1497,27 @@ func (c *Client) DialFunc() func(network, addr string) (net.Conn, error) {
 	return nil
 }
 
+// dialContextFunc returns the context-aware dial function used for plain
+// (non-TLS) connections when not running as an android child. Unlike
+// DialFunc, it honors both the request's context deadline and tc's
+// ConnectTimeout, and races IPv4/IPv6 addresses with Happy Eyeballs.
+// android.Dial has no context-aware variant, so on android this returns
+// nil and DialFunc is used instead.
+func (c *Client) dialContextFunc(tc *TransportConfig) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.useTLS() || android.IsChild() {
+		return nil
+	}
+	timeout := defaultConnectTimeout
+	if tc != nil && tc.ConnectTimeout > 0 {
+		timeout = tc.ConnectTimeout
+	}
+	d := &net.Dialer{
+		Timeout:       timeout,
+		FallbackDelay: happyEyeballsDelay,
+	}
+	return d.DialContext
+}
+
 func (c *Client) http2DialTLSFunc() func(network, addr string, cfg *tls.Config) (net.Conn, error) {
 	trustedCerts := c.getTrustedCerts()
 	if !c.insecureAnyTLSCert && len(trustedCerts) == 0 {
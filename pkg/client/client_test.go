@@ -0,0 +1,62 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	"perkeep.org/pkg/auth"
+)
+
+func TestNewRequestUserAgentAndRequestID(t *testing.T) {
+	c := &Client{
+		authMode:  auth.None{},
+		userAgent: "some-tool/1.2",
+		requestID: "abc123",
+	}
+	req := c.newRequest(context.Background(), "GET", "http://example.com/camli/foo")
+	if got := req.Header.Get("User-Agent"); got != "some-tool/1.2" {
+		t.Errorf("User-Agent = %q; want %q", got, "some-tool/1.2")
+	}
+	if got := req.Header.Get(requestIDHeader); got != "abc123" {
+		t.Errorf("%s = %q; want %q", requestIDHeader, got, "abc123")
+	}
+}
+
+func TestNewRequestNoUserAgentOrRequestID(t *testing.T) {
+	c := &Client{authMode: auth.None{}}
+	req := c.newRequest(context.Background(), "GET", "http://example.com/camli/foo")
+	if got := req.Header.Get("User-Agent"); got != "" {
+		t.Errorf("User-Agent = %q; want empty", got)
+	}
+	if got := req.Header.Get(requestIDHeader); got != "" {
+		t.Errorf("%s = %q; want empty", requestIDHeader, got)
+	}
+}
+
+func TestOptionUserAgentAndRequestID(t *testing.T) {
+	c := &Client{}
+	OptionUserAgent("my-ua").modifyClient(c)
+	OptionRequestID("my-id").modifyClient(c)
+	if c.userAgent != "my-ua" {
+		t.Errorf("userAgent = %q; want %q", c.userAgent, "my-ua")
+	}
+	if c.requestID != "my-id" {
+		t.Errorf("requestID = %q; want %q", c.requestID, "my-id")
+	}
+}
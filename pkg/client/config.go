@@ -33,6 +33,7 @@ import (
 	"perkeep.org/pkg/blob"
 	"perkeep.org/pkg/buildinfo"
 	"perkeep.org/pkg/client/android"
+	"perkeep.org/pkg/client/credhelper"
 	"perkeep.org/pkg/env"
 	"perkeep.org/pkg/jsonsign"
 	"perkeep.org/pkg/types/camtypes"
@@ -333,13 +334,22 @@ func (c *Client) SetupAuth() error {
 	if c.server == "" {
 		return fmt.Errorf("no server defined for this client: can not set up auth")
 	}
-	authConf := serverAuth(c.server)
+	// Prefer the OS credential helper (Keychain, Secret Service, ...) over
+	// the plaintext config file, falling back to the config file when no
+	// helper is available on this platform or it has nothing stored for
+	// this server.
+	authConf, err := credhelper.Get(c.server)
+	if err != nil && err != credhelper.ErrUnavailable {
+		return fmt.Errorf("could not get auth for server %q from credential helper: %v", c.server, err)
+	}
+	if authConf == "" {
+		authConf = serverAuth(c.server)
+	}
 	if authConf == "" {
 		c.authErr = fmt.Errorf("could not find auth key for server %q in config, defaulting to no auth", c.server)
 		c.authMode = auth.None{}
 		return nil
 	}
-	var err error
 	c.authMode, err = auth.FromConfig(authConf)
 	return err
 }
@@ -0,0 +1,74 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package credhelper looks up and stores Perkeep client credentials in
+// the operating system's secret store (macOS Keychain, Linux Secret
+// Service via libsecret's secret-tool) instead of the plaintext client
+// config file. It's used by pkg/client as an optional, higher-priority
+// source of a server's auth config string, ahead of the config file.
+//
+// There's no helper for Windows Credential Manager yet; on Windows (and
+// any other platform without a helper implementation) Get and Set both
+// return ErrUnavailable.
+package credhelper // import "perkeep.org/pkg/client/credhelper"
+
+import "errors"
+
+// ErrUnavailable is returned by Get and Set when no OS-specific
+// credential helper is available on the current platform (or its
+// backing tool isn't installed). Callers should fall back to another
+// source of credentials, such as the client config file.
+var ErrUnavailable = errors.New("credhelper: no OS credential helper available")
+
+// service is the name credentials are filed under in the OS secret
+// store, so they don't collide with unrelated entries.
+const service = "perkeep"
+
+// osHelper is implemented by each platform-specific credential store
+// backend in this package.
+type osHelper interface {
+	get(server string) (authConfig string, err error)
+	set(server, authConfig string) error
+}
+
+// helper is set by exactly one of the platform-specific files in this
+// package (credhelper_darwin.go, credhelper_linux.go, ...), or left nil
+// on platforms with no supported helper.
+var helper osHelper
+
+// Get returns the auth config string (e.g. "userpass:joe:ponies")
+// stored for server in the OS credential helper.
+//
+// It returns ErrUnavailable if no helper is available on this platform.
+// If the helper is available but has no entry for server, it returns
+// ("", nil); callers should treat that the same as "not configured"
+// rather than as an error.
+func Get(server string) (authConfig string, err error) {
+	if helper == nil {
+		return "", ErrUnavailable
+	}
+	return helper.get(server)
+}
+
+// Set stores authConfig as the auth config string for server in the OS
+// credential helper, creating or overwriting its entry. It returns
+// ErrUnavailable if no helper is available on this platform.
+func Set(server, authConfig string) error {
+	if helper == nil {
+		return ErrUnavailable
+	}
+	return helper.set(server, authConfig)
+}
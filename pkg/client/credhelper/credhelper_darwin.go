@@ -0,0 +1,55 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credhelper
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	helper = keychainHelper{}
+}
+
+// keychainHelper stores credentials in the macOS login Keychain, via the
+// "security" command line tool that ships with macOS.
+type keychainHelper struct{}
+
+func (keychainHelper) get(server string) (string, error) {
+	cmd := exec.Command("security", "find-generic-password", "-s", service, "-a", server, "-w")
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			// Item not found.
+			return "", nil
+		}
+		return "", fmt.Errorf("credhelper: security find-generic-password: %v", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (keychainHelper) set(server, authConfig string) error {
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", server, "-w", authConfig)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("credhelper: security add-generic-password: %v: %s", err, stderr.Bytes())
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credhelper
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		// libsecret's CLI isn't installed; leave helper nil so callers
+		// fall back to the config file.
+		return
+	}
+	helper = secretServiceHelper{}
+}
+
+// secretServiceHelper stores credentials in the freedesktop.org Secret
+// Service (e.g. GNOME Keyring, KWallet), via libsecret's secret-tool
+// command line tool.
+type secretServiceHelper struct{}
+
+func (secretServiceHelper) get(server string) (string, error) {
+	cmd := exec.Command("secret-tool", "lookup", "service", service, "server", server)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			// Not found.
+			return "", nil
+		}
+		return "", fmt.Errorf("credhelper: secret-tool lookup: %v", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (secretServiceHelper) set(server, authConfig string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=Perkeep ("+server+")", "service", service, "server", server)
+	cmd.Stdin = strings.NewReader(authConfig)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("credhelper: secret-tool store: %v: %s", err, stderr.Bytes())
+	}
+	return nil
+}
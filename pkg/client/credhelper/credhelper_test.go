@@ -0,0 +1,66 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credhelper
+
+import "testing"
+
+// fakeHelper is an in-memory stand-in for a real OS credential helper,
+// used to test Get/Set's dispatch without touching the real one.
+type fakeHelper struct {
+	creds map[string]string
+}
+
+func (f *fakeHelper) get(server string) (string, error) {
+	return f.creds[server], nil
+}
+
+func (f *fakeHelper) set(server, authConfig string) error {
+	f.creds[server] = authConfig
+	return nil
+}
+
+func TestUnavailableWithNoHelper(t *testing.T) {
+	defer func(old osHelper) { helper = old }(helper)
+	helper = nil
+
+	if _, err := Get("example.com"); err != ErrUnavailable {
+		t.Errorf("Get with no helper: got err %v; want ErrUnavailable", err)
+	}
+	if err := Set("example.com", "userpass:joe:ponies"); err != ErrUnavailable {
+		t.Errorf("Set with no helper: got err %v; want ErrUnavailable", err)
+	}
+}
+
+func TestGetSetRoundTrip(t *testing.T) {
+	defer func(old osHelper) { helper = old }(helper)
+	helper = &fakeHelper{creds: map[string]string{}}
+
+	const server = "https://example.com"
+	if got, err := Get(server); err != nil || got != "" {
+		t.Fatalf("Get before Set = (%q, %v); want (\"\", nil)", got, err)
+	}
+	if err := Set(server, "userpass:joe:ponies"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Get(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "userpass:joe:ponies"; got != want {
+		t.Errorf("Get after Set = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"perkeep.org/internal/osutil"
+	"perkeep.org/pkg/types/camtypes"
+
+	"go4.org/syncutil"
+)
+
+// defaultDiscoveryCacheTTL is how long a cached discovery document is
+// considered fresh, for clients that don't override it with
+// OptionDiscoveryCacheTTL. It's kept short enough that a server
+// restarted with a new configuration is picked up promptly, while
+// still saving a round-trip for the common case of several short-lived
+// CLI invocations in a row.
+const defaultDiscoveryCacheTTL = 5 * time.Minute
+
+// OptionDiscoveryCacheTTL returns a ClientOption that overrides how long
+// the on-disk discovery cache is trusted before the client re-discovers
+// against the server. A TTL of 0 disables the on-disk cache entirely.
+func OptionDiscoveryCacheTTL(d time.Duration) ClientOption {
+	return optionDiscoveryCacheTTL(d)
+}
+
+type optionDiscoveryCacheTTL time.Duration
+
+func (o optionDiscoveryCacheTTL) modifyClient(c *Client) {
+	c.discoCacheTTL = time.Duration(o)
+	c.discoCacheTTLSet = true
+}
+
+type discoveryCacheEntry struct {
+	FetchedAt time.Time
+	ETag      string // the server's ETag for Disco, if any
+	Disco     camtypes.Discovery
+}
+
+// discoveryCacheTTL returns the effective TTL for the on-disk discovery
+// cache, taking OptionDiscoveryCacheTTL into account.
+func (c *Client) discoveryCacheTTL() time.Duration {
+	if c.discoCacheTTLSet {
+		return c.discoCacheTTL
+	}
+	return defaultDiscoveryCacheTTL
+}
+
+// discoveryCachePath returns the path of the on-disk cache file for this
+// client's server, or "" if discovery caching is disabled.
+func (c *Client) discoveryCachePath() string {
+	if c.discoveryCacheTTL() <= 0 {
+		return ""
+	}
+	h := sha1.Sum([]byte(c.discoRoot()))
+	return filepath.Join(osutil.CacheDir(), "discovery", fmt.Sprintf("%x.json", h))
+}
+
+// readDiscoveryCache reads this client's on-disk discovery cache entry,
+// regardless of whether it's within the TTL.
+func (c *Client) readDiscoveryCache() (*discoveryCacheEntry, bool) {
+	path := c.discoveryCachePath()
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var ent discoveryCacheEntry
+	if err := json.Unmarshal(data, &ent); err != nil {
+		return nil, false
+	}
+	return &ent, true
+}
+
+// cachedDiscovery returns the cached discovery document for this
+// client's server, if one exists on disk and is not older than the
+// configured TTL.
+func (c *Client) cachedDiscovery() (*camtypes.Discovery, bool) {
+	ent, ok := c.readDiscoveryCache()
+	if !ok || time.Since(ent.FetchedAt) > c.discoveryCacheTTL() {
+		return nil, false
+	}
+	return &ent.Disco, true
+}
+
+// staleDiscoveryCache returns this client's on-disk discovery cache
+// entry even if it's past its TTL, as long as it has an ETag. It's used
+// to make a conditional GET once the cache goes stale, so an unchanged
+// server doesn't have to resend (and the client doesn't have to
+// re-parse) the whole discovery document.
+func (c *Client) staleDiscoveryCache() (*discoveryCacheEntry, bool) {
+	ent, ok := c.readDiscoveryCache()
+	if !ok || ent.ETag == "" {
+		return nil, false
+	}
+	return ent, true
+}
+
+// writeDiscoveryCache persists disco to disk so future clients talking
+// to the same server can skip a round-trip until it goes stale.
+// Errors are ignored: the cache is purely an optimization.
+func (c *Client) writeDiscoveryCache(disco *camtypes.Discovery, etag string) {
+	path := c.discoveryCachePath()
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	data, err := json.Marshal(discoveryCacheEntry{
+		FetchedAt: time.Now(),
+		ETag:      etag,
+		Disco:     *disco,
+	})
+	if err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// touchDiscoveryCache rewrites ent to disk with an updated FetchedAt,
+// after the server has confirmed via a 304 response that ent.Disco is
+// still current.
+func (c *Client) touchDiscoveryCache(ent *discoveryCacheEntry) {
+	ent.FetchedAt = time.Now()
+	c.writeDiscoveryCache(&ent.Disco, ent.ETag)
+}
+
+// RefreshDiscovery discards any cached discovery result (in memory and
+// on disk) so the next operation re-discovers against the server. It's
+// meant to be called after a request fails in a way that suggests the
+// server's endpoints changed since the client last discovered them
+// (e.g. an unexpected 404 from a previously-discovered handler prefix).
+func (c *Client) RefreshDiscovery() {
+	if path := c.discoveryCachePath(); path != "" {
+		os.Remove(path)
+	}
+	c.discoOnce = syncutil.Once{}
+}
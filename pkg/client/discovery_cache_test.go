@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"perkeep.org/pkg/types/camtypes"
+)
+
+func newTestClientForDiscoCache(t *testing.T) *Client {
+	t.Setenv("PERKEEP_CACHE_DIR", t.TempDir())
+	c := &Client{server: "http://example.com"}
+	return c
+}
+
+func TestDiscoveryCacheRoundTrip(t *testing.T) {
+	c := newTestClientForDiscoCache(t)
+	if _, ok := c.cachedDiscovery(); ok {
+		t.Fatal("expected no cached discovery before any write")
+	}
+
+	want := &camtypes.Discovery{BlobRoot: "/bs/"}
+	c.writeDiscoveryCache(want, "some-etag")
+
+	got, ok := c.cachedDiscovery()
+	if !ok {
+		t.Fatal("expected cached discovery after write")
+	}
+	if got.BlobRoot != want.BlobRoot {
+		t.Errorf("BlobRoot = %q; want %q", got.BlobRoot, want.BlobRoot)
+	}
+}
+
+func TestDiscoveryCacheExpires(t *testing.T) {
+	c := newTestClientForDiscoCache(t)
+	OptionDiscoveryCacheTTL(time.Millisecond).modifyClient(c)
+	c.writeDiscoveryCache(&camtypes.Discovery{BlobRoot: "/bs/"}, "some-etag")
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.cachedDiscovery(); ok {
+		t.Fatal("expected cached discovery to have expired")
+	}
+}
+
+func TestDiscoveryCacheDisabled(t *testing.T) {
+	c := newTestClientForDiscoCache(t)
+	OptionDiscoveryCacheTTL(0).modifyClient(c)
+	c.writeDiscoveryCache(&camtypes.Discovery{BlobRoot: "/bs/"}, "some-etag")
+	if _, ok := c.cachedDiscovery(); ok {
+		t.Fatal("expected discovery caching to be disabled")
+	}
+}
+
+func TestStaleDiscoveryCacheAndTouch(t *testing.T) {
+	c := newTestClientForDiscoCache(t)
+	OptionDiscoveryCacheTTL(time.Millisecond).modifyClient(c)
+	c.writeDiscoveryCache(&camtypes.Discovery{BlobRoot: "/bs/"}, "some-etag")
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.cachedDiscovery(); ok {
+		t.Fatal("expected cached discovery to have expired")
+	}
+	stale, ok := c.staleDiscoveryCache()
+	if !ok {
+		t.Fatal("expected a stale cache entry with its ETag intact")
+	}
+	if stale.ETag != "some-etag" {
+		t.Errorf("ETag = %q; want %q", stale.ETag, "some-etag")
+	}
+
+	c.touchDiscoveryCache(stale)
+	if _, ok := c.cachedDiscovery(); !ok {
+		t.Fatal("expected touchDiscoveryCache to refresh FetchedAt so the entry is fresh again")
+	}
+}
+
+func TestRefreshDiscoveryClearsCache(t *testing.T) {
+	c := newTestClientForDiscoCache(t)
+	c.writeDiscoveryCache(&camtypes.Discovery{BlobRoot: "/bs/"}, "some-etag")
+	c.RefreshDiscovery()
+	if _, ok := c.cachedDiscovery(); ok {
+		t.Fatal("expected RefreshDiscovery to clear the on-disk cache")
+	}
+}
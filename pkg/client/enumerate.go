@@ -27,6 +27,81 @@ import (
 	"perkeep.org/pkg/blob"
 )
 
+// BlobIterator iterates over the blobs of a Client's storage, in
+// order, without accumulating them in memory: it fetches them from
+// the server in bounded-size pages behind the scenes, exposing them
+// one at a time.
+//
+// An iterator must be closed after use, but it is not necessary to
+// read an iterator until exhaustion.
+type BlobIterator struct {
+	ch     chan blob.SizedRef
+	cancel context.CancelFunc
+	errc   chan error
+	err    error
+	cur    blob.SizedRef
+	done   bool
+}
+
+// NewBlobIterator returns an iterator over c's blobs greater than after
+// (in string sort order), or over all blobs if after is empty.
+func (c *Client) NewBlobIterator(ctx context.Context, after string) *BlobIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &BlobIterator{
+		ch:     make(chan blob.SizedRef, enumerateBatchSize),
+		cancel: cancel,
+		errc:   make(chan error, 1),
+	}
+	go func() {
+		if c.sto != nil {
+			it.errc <- c.sto.EnumerateBlobs(ctx, it.ch, after, math.MaxInt32)
+			return
+		}
+		it.errc <- c.EnumerateBlobsOpts(ctx, it.ch, EnumerateOpts{After: after})
+	}()
+	return it
+}
+
+// Next advances the iterator. It returns false when the iterator is
+// exhausted or an error occurred; see Err.
+func (it *BlobIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	sb, ok := <-it.ch
+	if !ok {
+		it.done = true
+		it.err = <-it.errc
+		return false
+	}
+	it.cur = sb
+	return true
+}
+
+// SizedRef returns the blob at the iterator's current position.
+// Only valid after a call to Next returns true.
+func (it *BlobIterator) SizedRef() blob.SizedRef { return it.cur }
+
+// Err returns the first error, if any, encountered during iteration.
+func (it *BlobIterator) Err() error { return it.err }
+
+// Close releases resources associated with the iterator. It is safe
+// to call Close before the iterator is exhausted, and to call Close
+// more than once.
+func (it *BlobIterator) Close() error {
+	if it.done {
+		return it.err
+	}
+	it.cancel()
+	for range it.ch {
+		// drain so the EnumerateBlobsOpts goroutine's send doesn't
+		// block forever on a cancellation race.
+	}
+	it.done = true
+	it.err = <-it.errc
+	return it.err
+}
+
 // EnumerateOpts are the options to Client.EnumerateBlobsOpts.
 type EnumerateOpts struct {
 	After   string        // last blobref seen; start with ones greater than this
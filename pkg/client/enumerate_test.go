@@ -0,0 +1,95 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/blobserver/memory"
+)
+
+func TestBlobIterator(t *testing.T) {
+	ctx := context.Background()
+	sto := &memory.Storage{}
+	var want []blob.Ref
+	for _, data := range []string{"foo", "bar", "baz", "quux"} {
+		sb, err := blobserver.ReceiveString(ctx, sto, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, sb.Ref)
+	}
+	sort.Sort(blob.ByRef(want))
+
+	c, err := New(OptionUseStorageClient(sto))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := c.NewBlobIterator(ctx, "")
+	var got []blob.Ref
+	for it.Next() {
+		got = append(got, it.SizedRef().Ref)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d blobs; want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("blob[%d] = %v; want %v", i, got[i], want[i])
+		}
+		if i > 0 && !got[i-1].Less(got[i]) {
+			t.Errorf("blobs not in strictly increasing order at index %d", i)
+		}
+	}
+}
+
+func TestBlobIteratorCloseEarly(t *testing.T) {
+	ctx := context.Background()
+	sto := &memory.Storage{}
+	for _, data := range []string{"foo", "bar", "baz"} {
+		if _, err := blobserver.ReceiveString(ctx, sto, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := New(OptionUseStorageClient(sto))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := c.NewBlobIterator(ctx, "")
+	if !it.Next() {
+		t.Fatal("expected at least one blob")
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+// MirrorRepairOpts configures VerifyAndRepairMirror.
+type MirrorRepairOpts struct {
+	// SampleRate is the fraction, in [0, 1], of blobs already present
+	// locally (right size, so not obviously missing or truncated) that
+	// are additionally re-read and re-hashed to catch silent
+	// corruption (bit rot). Zero, the default, skips this and only
+	// catches blobs that are missing or the wrong size, which is far
+	// cheaper for a large mirror than re-hashing everything on every
+	// run.
+	SampleRate float64
+
+	// Logf, if non-nil, is called with a message for each blob that's
+	// found missing or corrupt and for each repair attempt.
+	Logf func(format string, args ...interface{})
+}
+
+// MirrorRepairStats summarizes what VerifyAndRepairMirror found and did.
+type MirrorRepairStats struct {
+	Checked  int // blobs the remote server has, that were considered
+	Missing  int // of Checked, not present in local at all
+	Corrupt  int // of Checked, present locally but wrong size or failing a digest re-check
+	Repaired int // of Missing+Corrupt, successfully re-fetched from the remote and restored
+	Failed   int // of Checked, could not be checked locally, or was Missing or Corrupt and could not be repaired
+}
+
+// VerifyAndRepairMirror brings local, a previously-synced local mirror of
+// c's server, back to health. It enumerates every blob c's server has,
+// checks that each one exists in local with the right size (and,
+// per opts.SampleRate, that its content still matches its digest), and
+// re-fetches from c whatever is missing or corrupt.
+//
+// It only ever adds blobs to local; it never removes anything, so blobs
+// local has that have since been deleted from c's server are left alone.
+// That asymmetry is intentional: this is a repair tool for bit rot and
+// interrupted syncs, not a general reconciliation of the two ends.
+func (c *Client) VerifyAndRepairMirror(ctx context.Context, local blobserver.Storage, opts MirrorRepairOpts) (*MirrorRepairStats, error) {
+	logf := opts.Logf
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+	stats := new(MirrorRepairStats)
+
+	remote := make(chan blob.SizedRef, buffered)
+	enumErrc := make(chan error, 1)
+	go func() {
+		enumErrc <- blobserver.EnumerateAll(ctx, c, func(sb blob.SizedRef) error {
+			select {
+			case remote <- sb:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(remote)
+	}()
+
+	for sb := range remote {
+		stats.Checked++
+		status, err := verifyLocalBlob(ctx, local, sb, opts.SampleRate)
+		if err != nil {
+			logf("verify-mirror: error checking %v: %v", sb.Ref, err)
+			stats.Failed++
+			continue
+		}
+		switch status {
+		case blobOK:
+			continue
+		case blobMissing:
+			stats.Missing++
+			logf("verify-mirror: %v missing locally", sb.Ref)
+		case blobCorrupt:
+			stats.Corrupt++
+			logf("verify-mirror: %v corrupt locally", sb.Ref)
+		}
+		if err := c.repairBlob(ctx, local, sb.Ref); err != nil {
+			logf("verify-mirror: failed to repair %v: %v", sb.Ref, err)
+			stats.Failed++
+			continue
+		}
+		stats.Repaired++
+	}
+	if err := <-enumErrc; err != nil {
+		return stats, fmt.Errorf("enumerating remote blobs: %v", err)
+	}
+	return stats, nil
+}
+
+const buffered = 32 // arbitrary, same as pkg/gc's channel buffering
+
+type localBlobStatus int
+
+const (
+	blobOK localBlobStatus = iota
+	blobMissing
+	blobCorrupt
+)
+
+// verifyLocalBlob reports whether sb is present and intact in local.
+// A blob is sampled for a full digest re-check with probability
+// sampleRate; otherwise only its presence and size are checked.
+func verifyLocalBlob(ctx context.Context, local blobserver.Storage, sb blob.SizedRef, sampleRate float64) (localBlobStatus, error) {
+	var found blob.SizedRef
+	if err := local.StatBlobs(ctx, []blob.Ref{sb.Ref}, func(got blob.SizedRef) error {
+		found = got
+		return nil
+	}); err != nil {
+		return blobOK, err
+	}
+	if !found.Valid() {
+		return blobMissing, nil
+	}
+	if found.Size != sb.Size {
+		return blobCorrupt, nil
+	}
+	if sampleRate <= 0 || rand.Float64() >= sampleRate {
+		return blobOK, nil
+	}
+	h := sb.Ref.Hash()
+	if h == nil {
+		// Unknown/unsupported hash scheme; nothing to re-verify.
+		return blobOK, nil
+	}
+	rc, _, err := local.Fetch(ctx, sb.Ref)
+	if err != nil {
+		return blobOK, err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(h, rc); err != nil {
+		return blobOK, err
+	}
+	if !sb.Ref.HashMatches(h) {
+		return blobCorrupt, nil
+	}
+	return blobOK, nil
+}
+
+// repairBlob re-fetches br from c and writes it into local.
+func (c *Client) repairBlob(ctx context.Context, local blobserver.Storage, br blob.Ref) error {
+	rc, _, err := c.Fetch(ctx, br)
+	if err != nil {
+		return fmt.Errorf("fetching from remote: %v", err)
+	}
+	defer rc.Close()
+	if _, err := blobserver.Receive(ctx, local, br, rc); err != nil {
+		return fmt.Errorf("writing to local mirror: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An OpLog is a durable, ordered, on-disk queue of blobs (uploads and
+// signed claims) that a Client couldn't send to a server, so offline-first
+// tools can keep working while the server is unreachable and flush the
+// backlog once it comes back.
+//
+// Entries are files named by a monotonically increasing sequence number,
+// so the on-disk directory listing is itself the queue order: replaying
+// it in name order reproduces the original call order, which is all the
+// ordering guarantee dependent writes need (e.g. a permanode's
+// camliContent claim enqueued after the file blob it points to, since
+// Perkeep's blobs are content-addressed and the claim can't be built
+// until the file's blobref is known). It's also why there's no separate
+// conflict-resolution logic here: two queued claims about the same
+// attribute are just two blobs replayed in the order they were queued,
+// and the server (like it already does for any two claims that arrive
+// out of order) resolves the final value by each claim's own claim date.
+//
+// Each entry is written to a temporary file and atomically renamed into
+// place, so a crash mid-write leaves at most a stray temp file and never
+// a corrupt or partially-visible queue entry: on the next NewOpLog, the
+// existing (complete) entries are simply picked up where they were left.
+type OpLog struct {
+	dir string
+
+	mu      sync.Mutex
+	nextSeq int64
+}
+
+// NewOpLog opens (creating if necessary) a durable operation log rooted
+// at dir. If dir already contains entries from a previous, interrupted
+// run, they're recovered and will be included in the next Pending or
+// Flush call.
+func NewOpLog(dir string) (*OpLog, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("oplog: %v", err)
+	}
+	q := &OpLog{dir: dir}
+	seqs, err := q.pendingSeqs()
+	if err != nil {
+		return nil, err
+	}
+	if len(seqs) > 0 {
+		q.nextSeq = seqs[len(seqs)-1] + 1
+	}
+	return q, nil
+}
+
+func (q *OpLog) entryPath(seq int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d.blob", seq))
+}
+
+// pendingSeqs returns the sequence numbers of all entries currently in
+// the log, in replay order.
+func (q *OpLog) pendingSeqs() ([]int64, error) {
+	ents, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("oplog: %v", err)
+	}
+	var seqs []int64
+	for _, ent := range ents {
+		name := ent.Name()
+		if ent.IsDir() || !strings.HasSuffix(name, ".blob") {
+			continue
+		}
+		seq, err := strconv.ParseInt(strings.TrimSuffix(name, ".blob"), 10, 64)
+		if err != nil {
+			continue // not one of ours; ignore
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// Enqueue durably appends blob to the log and returns its sequence
+// number. It's safe to call from multiple goroutines.
+func (q *OpLog) Enqueue(blob []byte) (seq int64, err error) {
+	q.mu.Lock()
+	seq = q.nextSeq
+	q.nextSeq++
+	q.mu.Unlock()
+
+	path := q.entryPath(seq)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, blob, 0600); err != nil {
+		return 0, fmt.Errorf("oplog: writing entry %d: %v", seq, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, fmt.Errorf("oplog: committing entry %d: %v", seq, err)
+	}
+	return seq, nil
+}
+
+// Pending reports how many entries are currently queued.
+func (q *OpLog) Pending() (int, error) {
+	seqs, err := q.pendingSeqs()
+	if err != nil {
+		return 0, err
+	}
+	return len(seqs), nil
+}
+
+// Flush replays queued entries in order, calling send for each one's
+// blob bytes. An entry is removed from the log only after send returns
+// successfully for it. Flush stops at the first error, leaving that
+// entry and everything after it queued for the next call, so a server
+// that's still unreachable (or unreachable again partway through) never
+// loses or reorders an entry.
+func (q *OpLog) Flush(ctx context.Context, send func(ctx context.Context, blob []byte) error) error {
+	seqs, err := q.pendingSeqs()
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		path := q.entryPath(seq)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("oplog: reading entry %d: %v", seq, err)
+		}
+		if err := send(ctx, data); err != nil {
+			return fmt.Errorf("oplog: entry %d: %w", seq, err)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("oplog: removing flushed entry %d: %v", seq, err)
+		}
+	}
+	return nil
+}
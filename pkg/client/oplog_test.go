@@ -0,0 +1,130 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOpLogEnqueueFlushOrder(t *testing.T) {
+	q, err := NewOpLog(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"one", "two", "three"} {
+		if _, err := q.Enqueue([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := q.Pending(); err != nil || n != 3 {
+		t.Fatalf("Pending = %d, %v; want 3, nil", n, err)
+	}
+
+	var got []string
+	if err := q.Flush(context.Background(), func(ctx context.Context, blob []byte) error {
+		got = append(got, string(blob))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"one", "two", "three"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+	if n, err := q.Pending(); err != nil || n != 0 {
+		t.Fatalf("Pending after flush = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestOpLogFlushStopsAtFirstError(t *testing.T) {
+	q, err := NewOpLog(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errBoom := errors.New("boom")
+	var sent []string
+	err = q.Flush(context.Background(), func(ctx context.Context, blob []byte) error {
+		sent = append(sent, string(blob))
+		if string(blob) == "b" {
+			return errBoom
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Flush returned nil error; want one wrapping errBoom")
+	}
+	if !errors.Is(err, errBoom) {
+		t.Errorf("Flush error = %v; want it to wrap %v", err, errBoom)
+	}
+	if want := []string{"a", "b"}; len(sent) != len(want) || sent[0] != want[0] || sent[1] != want[1] {
+		t.Errorf("sent = %v; want %v (stop at first error)", sent, want)
+	}
+
+	// "b" and "c" should still be queued for a future Flush.
+	if n, err := q.Pending(); err != nil || n != 2 {
+		t.Fatalf("Pending after failed flush = %d, %v; want 2, nil", n, err)
+	}
+}
+
+func TestOpLogRecoversAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	q1, err := NewOpLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q1.Enqueue([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a process restart: a fresh OpLog over the same directory
+	// should pick up where the last one left off, both in terms of
+	// finding the pending entry and not reusing its sequence number.
+	q2, err := NewOpLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := q2.Pending(); err != nil || n != 1 {
+		t.Fatalf("Pending after reopen = %d, %v; want 1, nil", n, err)
+	}
+	if _, err := q2.Enqueue([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if err := q2.Flush(context.Background(), func(ctx context.Context, blob []byte) error {
+		got = append(got, string(blob))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "second"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
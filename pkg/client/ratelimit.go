@@ -0,0 +1,61 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// SetUploadRateLimiter sets the rate limiter used to cap the aggregate
+// throughput of blob bodies uploaded by Upload. It applies across all
+// blobs uploaded concurrently through c, not per upload.
+//
+// A nil limiter (the default) means unlimited, the previous behavior.
+func (c *Client) SetUploadRateLimiter(lim *rate.Limiter) {
+	c.uploadLimiter = lim
+}
+
+// rateLimitReader wraps r so that reads from it are paced by lim, shared
+// with any other concurrent uploads also using lim.
+func rateLimitReader(ctx context.Context, r io.Reader, lim *rate.Limiter) io.Reader {
+	if lim == nil {
+		return r
+	}
+	return &rateLimitedReader{ctx: ctx, r: r, lim: lim}
+}
+
+type rateLimitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	lim *rate.Limiter
+}
+
+func (rl *rateLimitedReader) Read(p []byte) (int, error) {
+	if burst := rl.lim.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := rl.r.Read(p)
+	if n > 0 {
+		if werr := rl.lim.WaitN(rl.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitReaderNilLimiterIsNoop(t *testing.T) {
+	src := bytes.NewReader([]byte("hello"))
+	r := rateLimitReader(context.Background(), src, nil)
+	if r != src {
+		t.Error("rateLimitReader with a nil limiter should return the input reader unchanged")
+	}
+}
+
+func TestRateLimitReaderPassesDataThrough(t *testing.T) {
+	want := bytes.Repeat([]byte("x"), 10<<10) // bigger than the limiter's burst, to exercise chunking
+	lim := rate.NewLimiter(rate.Inf, 1<<10)   // unlimited rate, small burst
+	r := rateLimitReader(context.Background(), bytes.NewReader(want), lim)
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("read %d bytes; want %d bytes matching input", len(got), len(want))
+	}
+}
+
+func TestRateLimitReaderRespectsContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lim := rate.NewLimiter(1, 1) // 1 byte/sec, so any real wait would block
+	r := rateLimitReader(ctx, bytes.NewReader([]byte("ab")), lim)
+	buf := make([]byte, 2)
+	if _, err := r.Read(buf); err == nil {
+		t.Error("Read with an already-canceled context: got nil error; want an error")
+	}
+}
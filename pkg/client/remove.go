@@ -59,6 +59,12 @@ func (c *Client) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
 	req = req.WithContext(ctx)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	c.authMode.AddAuthHeader(req)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.requestID != "" {
+		req.Header.Set(requestIDHeader, c.requestID)
+	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		resp.Body.Close()
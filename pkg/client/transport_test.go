@@ -35,37 +35,41 @@ var transportTests = []struct {
 	trustedCerts []string
 	insecureTLS  bool
 	// ouptput
-	dialFunc    bool // whether the transport's Dial is not nil
-	dialTLSFunc bool // whether the transport's DialTLS is not nil
-	http2       bool // whether we're on http2
+	dialFunc        bool // whether the transport's Dial is not nil
+	dialContextFunc bool // whether the transport's DialContext is not nil
+	dialTLSFunc     bool // whether the transport's DialTLS is not nil
+	http2           bool // whether we're on http2
 }{
 	// All http, not android.
 	{
-		server:       "http://example.com",
-		onAndroid:    false,
-		trustedCerts: nil,
-		insecureTLS:  false,
-		dialFunc:     false,
-		dialTLSFunc:  false,
-		http2:        false,
+		server:          "http://example.com",
+		onAndroid:       false,
+		trustedCerts:    nil,
+		insecureTLS:     false,
+		dialFunc:        false,
+		dialContextFunc: true,
+		dialTLSFunc:     false,
+		http2:           false,
 	},
 	{
-		server:       "http://example.com",
-		onAndroid:    false,
-		trustedCerts: nil,
-		insecureTLS:  true,
-		dialFunc:     false,
-		dialTLSFunc:  false,
-		http2:        false,
+		server:          "http://example.com",
+		onAndroid:       false,
+		trustedCerts:    nil,
+		insecureTLS:     true,
+		dialFunc:        false,
+		dialContextFunc: true,
+		dialTLSFunc:     false,
+		http2:           false,
 	},
 	{
-		server:       "http://example.com",
-		onAndroid:    false,
-		trustedCerts: []string{"whatever"},
-		insecureTLS:  false,
-		dialFunc:     false,
-		dialTLSFunc:  false,
-		http2:        false,
+		server:          "http://example.com",
+		onAndroid:       false,
+		trustedCerts:    []string{"whatever"},
+		insecureTLS:     false,
+		dialFunc:        false,
+		dialContextFunc: true,
+		dialTLSFunc:     false,
+		http2:           false,
 	},
 
 	// All http, on android.
@@ -204,5 +208,8 @@ func TestTransportSetup(t *testing.T) {
 		if tt.dialFunc != (tr.Dial != nil) {
 			t.Errorf("test %d for %#v: dialFunc should be %v", tti, tt, sayNil(!tt.dialFunc))
 		}
+		if tt.dialContextFunc != (tr.DialContext != nil) {
+			t.Errorf("test %d for %#v: dialContextFunc should be %v", tti, tt, sayNil(!tt.dialContextFunc))
+		}
 	}
 }
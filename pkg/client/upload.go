@@ -72,6 +72,7 @@ type PutResult struct {
 	BlobRef blob.Ref
 	Size    uint32
 	Skipped bool // already present on blobserver
+	Queued  bool // durably queued by UploadOrQueue instead of actually sent; see Client.SetOpLog
 }
 
 func (pr *PutResult) SizedBlobRef() blob.SizedRef {
@@ -247,6 +248,71 @@ func (h *UploadHandle) readerAndSize() (io.Reader, int64, error) {
 	return &b, n, nil
 }
 
+// resumableUploadThreshold is the minimum blob size, in bytes, above
+// which Upload tries the resumable single-blob PUT extension (see
+// doc/protocol/blob-upload-protocol.txt) before falling back to the
+// regular multipart POST. Below it, the extra status round-trip isn't
+// worth it.
+const resumableUploadThreshold = 4 << 20 // 4MB
+
+// uploadResumable uploads bodyReader (of length bodySize) to br using
+// the resumable single-blob PUT extension: it first asks the server
+// how much of the blob, if any, it already has staged from a
+// previous, interrupted attempt at the same blobref, then sends only
+// the remaining bytes.
+//
+// ok is false, with bodyReader left untouched, if the server doesn't
+// appear to support the resumable extension (e.g. because it predates
+// it), in which case the caller should fall back to a regular upload.
+func (c *Client) uploadResumable(ctx context.Context, pfx string, br blob.Ref, bodyReader io.Reader, bodySize int64) (pr *PutResult, ok bool, err error) {
+	blobrefStr := br.String()
+	uploadURL := fmt.Sprintf("%s/camli/%s", pfx, blobrefStr)
+
+	statusReq := c.newRequest(ctx, "PUT", uploadURL)
+	statusReq.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", bodySize))
+	statusReq.ContentLength = 0
+	statusResp, err := c.doReqGated(statusReq)
+	if err != nil {
+		return nil, false, fmt.Errorf("resumable upload status http error: %w", err)
+	}
+	statusResp.Body.Close()
+	if statusResp.StatusCode != http.StatusPermanentRedirect {
+		// Doesn't understand (or rejected) Content-Range; let the
+		// caller fall back to a normal upload.
+		return nil, false, nil
+	}
+
+	var offset int64
+	if rangeHdr := statusResp.Header.Get("Range"); rangeHdr != "" {
+		var end int64
+		if _, err := fmt.Sscanf(rangeHdr, "bytes=0-%d", &end); err == nil && end >= 0 && end < bodySize {
+			offset = end + 1
+		}
+	}
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, bodyReader, offset); err != nil {
+			return nil, true, fmt.Errorf("resumable upload: skipping already-uploaded %d bytes: %w", offset, err)
+		}
+		if env.DebugUploads() {
+			log.Printf("Resuming upload of %s at offset %d/%d", blobrefStr, offset, bodySize)
+		}
+	}
+
+	chunkReq := c.newRequest(ctx, "PUT", uploadURL, io.NopCloser(bodyReader))
+	chunkReq.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, bodySize-1, bodySize))
+	chunkReq.ContentLength = bodySize - offset
+	chunkResp, err := c.doReqGated(chunkReq)
+	if err != nil {
+		return nil, true, fmt.Errorf("resumable upload http error: %w", err)
+	}
+	defer chunkResp.Body.Close()
+	if chunkResp.StatusCode != http.StatusNoContent {
+		return nil, true, fmt.Errorf("resumable upload: unexpected http status %d", chunkResp.StatusCode)
+	}
+
+	return &PutResult{BlobRef: br, Size: uint32(bodySize)}, true, nil
+}
+
 // Upload uploads a blob, as described by the provided UploadHandle parameters.
 func (c *Client) Upload(ctx context.Context, h *UploadHandle) (*PutResult, error) {
 	errorf := func(msg string, arg ...interface{}) (*PutResult, error) {
@@ -262,6 +328,7 @@ func (c *Client) Upload(ctx context.Context, h *UploadHandle) (*PutResult, error
 	if bodySize > constants.MaxBlobSize {
 		return nil, errors.New("client: body is bigger then max blob size")
 	}
+	bodyReader = rateLimitReader(ctx, bodyReader, c.uploadLimiter)
 
 	c.statsMutex.Lock()
 	c.stats.UploadRequests.Blobs++
@@ -339,6 +406,23 @@ func (c *Client) Upload(ctx context.Context, h *UploadHandle) (*PutResult, error
 		log.Printf("Uploading: %s (%d bytes)", blobrefStr, bodySize)
 	}
 
+	if !h.Vivify && bodySize >= resumableUploadThreshold {
+		if pr, ok, err := c.uploadResumable(ctx, pfx, h.BlobRef, bodyReader, bodySize); ok {
+			if err != nil {
+				return nil, err
+			}
+			c.statsMutex.Lock()
+			c.stats.Uploads.Blobs++
+			c.stats.Uploads.Bytes += bodySize
+			c.statsMutex.Unlock()
+			c.haveCache.NoteBlobExists(pr.BlobRef, pr.Size)
+			return pr, nil
+		}
+		// Server doesn't support (or rejected) the resumable
+		// extension; bodyReader is untouched, so fall back to the
+		// regular multipart upload below.
+	}
+
 	pipeReader, pipeWriter := io.Pipe()
 	multipartWriter := multipart.NewWriter(pipeWriter)
 
@@ -482,6 +566,62 @@ func (c *Client) UploadFile(ctx context.Context, filename string, contents io.Re
 	return schema.WriteFileMap(ctx, c, fileMap, contents)
 }
 
+// PermanodeOptions is optionally provided to UploadFileAsPermanode.
+type PermanodeOptions struct {
+	// Title optionally sets the permanode's title attribute.
+	Title string
+	// Tags optionally sets the permanode's tag attribute, one claim per tag.
+	Tags []string
+}
+
+// UploadFileAsPermanode uploads the contents of contents as a file named
+// filename, creates a new permanode, and sets the permanode's camliContent
+// to the uploaded file, plus any title/tags from opts. It returns the new
+// permanode's ref.
+//
+// This composes UploadFile, UploadNewPermanode, and the usual
+// camliContent/title/tag claims, saving callers that just want "a permanode
+// for this file" from repeating that boilerplate themselves.
+//
+// If UploadFile or UploadNewPermanode fail, no partial state is left behind
+// worth cleaning up: an uploaded file blob with no permanode pointing to it
+// is harmless and content-addressed, so it's left in place. If a later
+// claim fails (setting camliContent, title, or a tag), the permanode has
+// already been created and returned to the caller with some of its
+// attributes unset; callers that care should retry the claim themselves
+// using the returned permanode ref.
+func (c *Client) UploadFileAsPermanode(ctx context.Context, filename string, contents io.Reader, opts *PermanodeOptions) (blob.Ref, error) {
+	fileRef, err := c.UploadFile(ctx, filename, contents, nil)
+	if err != nil {
+		return blob.Ref{}, fmt.Errorf("UploadFileAsPermanode: uploading file: %v", err)
+	}
+
+	pr, err := c.UploadNewPermanode(ctx)
+	if err != nil {
+		return blob.Ref{}, fmt.Errorf("UploadFileAsPermanode: creating permanode: %v", err)
+	}
+	permaNode := pr.BlobRef
+
+	if _, err := c.UploadAndSignBlob(ctx, schema.NewSetAttributeClaim(permaNode, "camliContent", fileRef.String())); err != nil {
+		return permaNode, fmt.Errorf("UploadFileAsPermanode: setting camliContent: %v", err)
+	}
+
+	if opts != nil {
+		if opts.Title != "" {
+			if _, err := c.UploadAndSignBlob(ctx, schema.NewSetAttributeClaim(permaNode, "title", opts.Title)); err != nil {
+				return permaNode, fmt.Errorf("UploadFileAsPermanode: setting title: %v", err)
+			}
+		}
+		for _, tag := range opts.Tags {
+			if _, err := c.UploadAndSignBlob(ctx, schema.NewAddAttributeClaim(permaNode, "tag", tag)); err != nil {
+				return permaNode, fmt.Errorf("UploadFileAsPermanode: adding tag %q: %v", tag, err)
+			}
+		}
+	}
+
+	return permaNode, nil
+}
+
 // TODO(mpl): replace up.wholeFileDigest in pk-put with c.wholeRef maybe.
 
 // wholeRef returns the blob ref(s) of the regular file's contents
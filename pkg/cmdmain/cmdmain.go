@@ -89,6 +89,7 @@ var (
 )
 
 func realExit(code int) {
+	stopProfiling()
 	os.Exit(code)
 }
 
@@ -264,6 +265,8 @@ func Main() {
 	flag.Parse()
 	flag.CommandLine.SetOutput(Stderr)
 	PostFlag()
+	startProfiling()
+	defer stopProfiling()
 
 	args := flag.Args()
 	if *FlagVersion {
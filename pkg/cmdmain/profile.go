@@ -0,0 +1,106 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmdmain
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+var (
+	// FlagProfileCPU, if non-empty, is the file to write a pprof CPU
+	// profile to, covering the whole run of the command.
+	FlagProfileCPU = flag.String("profile-cpu", "", "if non-empty, write a CPU profile to this file")
+	// FlagProfileMem, if non-empty, is the file to write a pprof heap
+	// profile to, taken just before the command exits.
+	FlagProfileMem = flag.String("profile-mem", "", "if non-empty, write a memory profile to this file")
+)
+
+var (
+	profileStopOnce sync.Once
+	profileStopFn   = func() {}
+)
+
+// startProfiling starts any profiling requested by FlagProfileCPU and
+// FlagProfileMem. It should be called once flags are parsed, and its
+// effects undone by calling stopProfiling exactly once before the
+// process exits.
+func startProfiling() {
+	if *FlagProfileCPU != "" {
+		f, err := os.Create(*FlagProfileCPU)
+		if err != nil {
+			log.Fatalf("profile-cpu: %v", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatalf("profile-cpu: %v", err)
+		}
+		addProfileStop(func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if *FlagProfileMem != "" {
+		addProfileStop(func() {
+			f, err := os.Create(*FlagProfileMem)
+			if err != nil {
+				log.Printf("profile-mem: %v", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC() // get up-to-date statistics
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				log.Printf("profile-mem: %v", err)
+			}
+		})
+	}
+	if *FlagProfileCPU == "" && *FlagProfileMem == "" {
+		return
+	}
+	// Make sure an interrupt (e.g. ctrl-C during a slow upload) still
+	// flushes the profile to disk instead of losing it.
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	go func() {
+		<-sigc
+		stopProfiling()
+		os.Exit(1)
+	}()
+}
+
+// addProfileStop appends fn to the set of functions that stopProfiling
+// runs.
+func addProfileStop(fn func()) {
+	prev := profileStopFn
+	profileStopFn = func() {
+		prev()
+		fn()
+	}
+}
+
+// stopProfiling stops and flushes any profiling started by
+// startProfiling. It is safe to call more than once; only the first
+// call has an effect.
+func stopProfiling() {
+	profileStopOnce.Do(func() {
+		profileStopFn()
+	})
+}
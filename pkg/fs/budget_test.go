@@ -0,0 +1,102 @@
+//go:build linux
+
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fs
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+)
+
+func TestAcquireOpenFileUnlimited(t *testing.T) {
+	var fs CamliFileSystem
+	// MaxOpenFiles unset (zero value) must never block.
+	for i := 0; i < 100; i++ {
+		fs.acquireOpenFile()
+	}
+}
+
+func TestAcquireOpenFileBlocks(t *testing.T) {
+	fs := &CamliFileSystem{MaxOpenFiles: 1}
+	fs.acquireOpenFile()
+
+	acquired := make(chan bool, 1)
+	go func() {
+		fs.acquireOpenFile()
+		acquired <- true
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquireOpenFile returned before a slot was released")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fs.releaseOpenFile()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("acquireOpenFile never returned after releaseOpenFile")
+	}
+}
+
+func TestAcquireWriteMemoryUnlimited(t *testing.T) {
+	var fs CamliFileSystem
+	if err := fs.acquireWriteMemory(1 << 30); err != nil {
+		t.Fatalf("unexpected error with no MaxWriteMemory set: %v", err)
+	}
+}
+
+func TestAcquireWriteMemoryTooLarge(t *testing.T) {
+	fs := &CamliFileSystem{MaxWriteMemory: 1024}
+	err := fs.acquireWriteMemory(2048)
+	if want := fuse.Errno(syscall.ENOMEM); err != want {
+		t.Errorf("acquireWriteMemory(2048) with budget 1024 = %v; want %v", err, want)
+	}
+}
+
+func TestAcquireWriteMemoryBlocks(t *testing.T) {
+	fs := &CamliFileSystem{MaxWriteMemory: 100}
+	if err := fs.acquireWriteMemory(100); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- fs.acquireWriteMemory(1)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquireWriteMemory returned before budget was released")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fs.releaseWriteMemory(100)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquireWriteMemory never returned after releaseWriteMemory")
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"syscall"
 	"time"
 
 	"perkeep.org/internal/lru"
@@ -36,6 +37,7 @@ import (
 
 	"bazil.org/fuse"
 	fusefs "bazil.org/fuse/fs"
+	"go4.org/syncutil"
 )
 
 var (
@@ -55,11 +57,79 @@ type CamliFileSystem struct {
 	// permissions to 0600/0700.
 	IgnoreOwners bool
 
+	// MaxOpenFiles, if positive, caps the number of mutable file
+	// handles (those opened for writing, e.g. by Open or Create) that
+	// may be open at once, blocking further opens until one is
+	// released. Zero, the default, means unlimited.
+	MaxOpenFiles int
+
+	// MaxWriteMemory, if positive, caps the total number of bytes of
+	// FUSE write-request payloads that may be in flight (being copied
+	// into a handle's local temp file) at once, across all open
+	// handles; further writes block until enough memory is released.
+	// A single write larger than MaxWriteMemory can never fit and
+	// fails immediately with ENOMEM rather than blocking forever.
+	// Zero, the default, means unlimited.
+	MaxWriteMemory int64
+
+	openFilesOnce sync.Once
+	openFilesSem  *syncutil.Sem
+
+	writeMemOnce sync.Once
+	writeMemSem  *syncutil.Sem
+
 	blobToSchema *lru.Cache // ~map[blobstring]*schema.Blob
 	nameToBlob   *lru.Cache // ~map[string]blob.Ref
 	nameToAttr   *lru.Cache // ~map[string]*fuse.Attr
 }
 
+// acquireOpenFile blocks, if MaxOpenFiles is set, until a file-handle
+// slot is available. Every successful call must be paired with a
+// releaseOpenFile once the handle is released.
+func (fs *CamliFileSystem) acquireOpenFile() {
+	if fs.MaxOpenFiles <= 0 {
+		return
+	}
+	fs.openFilesOnce.Do(func() {
+		fs.openFilesSem = syncutil.NewSem(int64(fs.MaxOpenFiles))
+	})
+	fs.openFilesSem.Acquire(1)
+}
+
+// releaseOpenFile releases a slot acquired by acquireOpenFile.
+func (fs *CamliFileSystem) releaseOpenFile() {
+	if fs.openFilesSem != nil {
+		fs.openFilesSem.Release(1)
+	}
+}
+
+// acquireWriteMemory blocks, if MaxWriteMemory is set, until n bytes
+// of write-buffer budget are available, and returns nil once acquired.
+// It returns fuse.Errno(syscall.ENOMEM) immediately, without blocking,
+// if n alone exceeds the entire budget. Every successful call must be
+// paired with a releaseWriteMemory for the same n.
+func (fs *CamliFileSystem) acquireWriteMemory(n int64) error {
+	if fs.MaxWriteMemory <= 0 || n <= 0 {
+		return nil
+	}
+	if n > fs.MaxWriteMemory {
+		return fuse.Errno(syscall.ENOMEM)
+	}
+	fs.writeMemOnce.Do(func() {
+		fs.writeMemSem = syncutil.NewSem(fs.MaxWriteMemory)
+	})
+	fs.writeMemSem.Acquire(n)
+	return nil
+}
+
+// releaseWriteMemory releases n bytes acquired by a successful
+// acquireWriteMemory.
+func (fs *CamliFileSystem) releaseWriteMemory(n int64) {
+	if fs.writeMemSem != nil && n > 0 {
+		fs.writeMemSem.Release(n)
+	}
+}
+
 var _ fusefs.FS = (*CamliFileSystem)(nil)
 
 func newCamliFileSystem(fetcher blob.Fetcher) *CamliFileSystem {
@@ -100,6 +170,21 @@ func NewRootedCamliFileSystem(cli *client.Client, fetcher blob.Fetcher, root blo
 	return fs, nil
 }
 
+// NewQueryCamliFileSystem returns a CamliFileSystem whose root is a flat
+// directory of the permanodes matching expr, for use by `pk-mount
+// --query`. It's a quick way to expose an arbitrary search, such as
+// "all PDFs tagged invoice", as a browsable folder, without needing a
+// root permanode to organize the results under.
+func NewQueryCamliFileSystem(cli *client.Client, fetcher blob.Fetcher, expr string) *CamliFileSystem {
+	if cli == nil || fetcher == nil {
+		panic("nil argument")
+	}
+	fs := newCamliFileSystem(fetcher)
+	fs.client = cli
+	fs.root = &queryDir{fs: fs, expr: expr}
+	return fs
+}
+
 // node implements fuse.Node with a read-only Camli "file" or
 // "directory" blob.
 type node struct {
@@ -115,6 +200,7 @@ type node struct {
 	attr    fuse.Attr
 	meta    *schema.Blob
 	lookMap map[string]blob.Ref
+	pin     *pinStatus // most recent pin request on this node, if any
 }
 
 var _ fusefs.Node = (*node)(nil)
@@ -143,6 +229,9 @@ func (n *node) Lookup(ctx context.Context, name string) (fusefs.Node, error) {
 		// TODO: only in dev mode
 		log.Fatalf("Shutting down due to .quitquitquit lookup.")
 	}
+	if name == pinControlName {
+		return pinFile{n: n}, nil
+	}
 
 	// If we haven't done Readdir yet (dirents isn't set), then force a Readdir
 	// call to populate lookMap.
@@ -267,7 +356,7 @@ func (n *node) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 		Logger.Printf("camli.ReadDirAll error on %v: %v", n.blobref, err)
 		return nil, handleEIOorEINTR(err)
 	}
-	n.dirents = make([]fuse.Dirent, 0)
+	n.dirents = []fuse.Dirent{{Name: pinControlName}}
 	for _, sent := range schemaEnts {
 		if name := sent.FileName(); name != "" {
 			n.addLookupEntry(name, sent.BlobRef())
@@ -302,6 +391,18 @@ func (n *node) populateAttr() error {
 		n.attr.Mtime = n.pnodeModTime
 	}
 
+	// bazil.org/fuse's Attr has no birth-time (Crtime) field, so there's no
+	// way to surface a true creation time through this FUSE library. Ctime
+	// (time of last inode change) is the closest available approximation:
+	// populate it from the file's captured unixCtime when the uploader
+	// recorded one, falling back to Mtime for files without a clear
+	// creation-time claim.
+	if ct := meta.CTime(); !ct.IsZero() {
+		n.attr.Ctime = ct
+	} else {
+		n.attr.Ctime = n.attr.Mtime
+	}
+
 	switch meta.Type() {
 	case schema.TypeFile:
 		n.attr.Size = uint64(meta.PartsSize())
@@ -461,6 +461,50 @@ func TestRename(t *testing.T) {
 	})
 }
 
+func TestLink(t *testing.T) {
+	condSkip(t)
+	inEmptyMutDir(t, func(env *mountEnv, rootDir string) {
+		name1 := filepath.Join(rootDir, "1")
+		subdir := filepath.Join(rootDir, "dir")
+		name2 := filepath.Join(subdir, "2")
+
+		contents := []byte("Some file contents")
+		if err := os.WriteFile(name1, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Mkdir(subdir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		// Link across directories, exercising the "or detect same-source
+		// copies" case of cross-directory clones.
+		if err := os.Link(name1, name2); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(name2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, contents) {
+			t.Errorf("name2 contents = %q; want %q", got, contents)
+		}
+
+		// Unlike a real hard link, the clone is an independent permanode:
+		// writing to one must not affect the other.
+		if err := os.WriteFile(name1, []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err = os.ReadFile(name2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, contents) {
+			t.Errorf("after editing name1, name2 contents = %q; want unchanged %q", got, contents)
+		}
+	})
+}
+
 func TestMoveAt(t *testing.T) {
 	condSkip(t)
 	var beforeTime, afterTime time.Time
@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"perkeep.org/pkg/blob"
@@ -45,6 +46,13 @@ const populateInterval = 30 * time.Second
 // regardless of its presence in the indexing server.
 const deletionRefreshWindow = time.Minute
 
+// How long a failed Lookup is remembered, so that repeated stats of a
+// nonexistent name (as done by build tools and shells) don't each
+// trigger a populate of the directory. This is intentionally short: a
+// file created on the server (rather than through this mount) within
+// the TTL won't become visible until it expires.
+const negativeLookupTTL = 5 * time.Second
+
 type nodeType int
 
 const (
@@ -63,11 +71,12 @@ type mutDir struct {
 
 	localCreateTime time.Time // time this node was created locally (iff it was)
 
-	mu       sync.Mutex
-	lastPop  time.Time
-	children map[string]mutFileOrDir
-	xattrs   map[string][]byte
-	deleted  bool
+	mu        sync.Mutex
+	lastPop   time.Time
+	children  map[string]mutFileOrDir
+	negLookup map[string]time.Time // name -> time of last failed Lookup
+	xattrs    map[string][]byte
+	deleted   bool
 }
 
 var _ fs.Node = (*mutDir)(nil)
@@ -82,6 +91,7 @@ var _ fs.NodeMkdirer = (*mutDir)(nil)
 var _ fs.NodeSymlinker = (*mutDir)(nil)
 var _ fs.NodeRemover = (*mutDir)(nil)
 var _ fs.NodeRenamer = (*mutDir)(nil)
+var _ fs.NodeLinker = (*mutDir)(nil)
 
 func (n *mutDir) String() string {
 	return fmt.Sprintf("&mutDir{%p name=%q perm:%v}", n, n.fullPath(), n.permanode)
@@ -231,6 +241,7 @@ func (n *mutDir) maybeAddChild(name string, permanode *search.DescribedPermanode
 
 		child.xattr().load(permanode)
 		n.children[name] = child
+		delete(n.negLookup, name)
 	}
 }
 
@@ -283,6 +294,10 @@ func (n *mutDir) Lookup(ctx context.Context, name string) (ret fs.Node, err erro
 	defer func() {
 		Logger.Printf("mutDir(%q).Lookup(%q) = %v, %v", n.fullPath(), name, ret, err)
 	}()
+	if n.negativelyCached(name) {
+		return nil, fuse.ENOENT
+	}
+
 	if err := n.populate(ctx); err != nil {
 		Logger.Println("populate:", err)
 		return nil, handleEIOorEINTR(err)
@@ -290,8 +305,13 @@ func (n *mutDir) Lookup(ctx context.Context, name string) (ret fs.Node, err erro
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	if n2 := n.children[name]; n2 != nil {
+		delete(n.negLookup, name)
 		return n2, nil
 	}
+	if n.negLookup == nil {
+		n.negLookup = make(map[string]time.Time)
+	}
+	n.negLookup[name] = time.Now()
 	return nil, fuse.ENOENT
 }
 
@@ -351,6 +371,71 @@ func (n *mutDir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node
 	return node, nil
 }
 
+// Link creates req.NewName in n as a clone of old: a new, independent
+// permanode whose camliContent points at old's existing file schema blob,
+// so the content is never re-read or re-uploaded.
+//
+// This is not a POSIX hard link (old and the new node do not share a
+// permanode, and edits to one never affect the other) because the
+// underlying bazil.org/fuse version this package is built against doesn't
+// dispatch the kernel's copy_file_range request, so there's no way to hook
+// "cp a b" itself; Link is the nearest FUSE operation that hands us both
+// the source node and a destination name/directory, so "ln a b" gets the
+// zero-copy behavior instead.
+func (n *mutDir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
+	oldFile, ok := old.(*mutFile)
+	if !ok {
+		return nil, fuse.EPERM
+	}
+	oldFile.mu.Lock()
+	symLink := oldFile.symLink
+	content := oldFile.content
+	size := oldFile.size
+	oldFile.mu.Unlock()
+	if symLink || !content.Valid() {
+		return nil, fuse.EPERM
+	}
+
+	pr, err := n.fs.client.UploadNewPermanode(ctx)
+	if err != nil {
+		return nil, handleEIOorEINTR(err)
+	}
+
+	var grp syncutil.Group
+	grp.Go(func() (err error) {
+		claim := schema.NewSetAttributeClaim(n.permanode, "camliPath:"+req.NewName, pr.BlobRef.String())
+		_, err = n.fs.client.UploadAndSignBlob(ctx, claim)
+		return
+	})
+	grp.Go(func() (err error) {
+		claim := schema.NewSetAttributeClaim(pr.BlobRef, "camliContent", content.String())
+		_, err = n.fs.client.UploadAndSignBlob(ctx, claim)
+		return
+	})
+	if err := grp.Err(); err != nil {
+		return nil, handleEIOorEINTR(err)
+	}
+
+	child := &mutFile{
+		fs:              n.fs,
+		permanode:       pr.BlobRef,
+		parent:          n,
+		name:            req.NewName,
+		xattrs:          make(map[string][]byte),
+		localCreateTime: time.Now(),
+		content:         content,
+		size:            size,
+	}
+	n.mu.Lock()
+	n.children[req.NewName] = child
+	delete(n.negLookup, req.NewName)
+	n.mu.Unlock()
+
+	Logger.Printf("Cloned %v to %v in %p", old, child, n)
+
+	return child, nil
+}
+
 func (n *mutDir) creat(ctx context.Context, name string, typ nodeType) (fs.Node, error) {
 	// Create a Permanode for the file/directory.
 	pr, err := n.fs.client.UploadNewPermanode(ctx)
@@ -421,6 +506,7 @@ func (n *mutDir) creat(ctx context.Context, name string, typ nodeType) (fs.Node,
 	}
 	n.mu.Lock()
 	n.children[name] = child
+	delete(n.negLookup, name)
 	n.mu.Unlock()
 
 	Logger.Printf("Created %v in %p", child, n)
@@ -518,6 +604,7 @@ func (n *mutDir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.
 	n.mu.Unlock()
 	n2.mu.Lock()
 	n2.children[req.NewName] = target
+	delete(n2.negLookup, req.NewName)
 	n2.mu.Unlock()
 
 	return nil
@@ -540,6 +627,12 @@ type mutFile struct {
 	mtime, atime time.Time // if zero, use serverStart
 	xattrs       map[string][]byte
 	deleted      bool
+
+	// activeHandle is the currently open read-write *mutFileHandle for
+	// this file, if any, so an fsync on the node can flush its buffered
+	// writes to the server without waiting for a Release. Nil when the
+	// file isn't open for writing.
+	activeHandle *mutFileHandle
 }
 
 var (
@@ -693,10 +786,16 @@ func (n *mutFile) Open(ctx context.Context, req *fuse.OpenRequest, res *fuse.Ope
 }
 
 func (n *mutFile) Fsync(ctx context.Context, r *fuse.FsyncRequest) error {
-	// TODO(adg): in the fuse package, plumb through fsync to mutFileHandle
-	// in the same way we did Truncate.
-	Logger.Printf("mutFile.Fsync: TODO")
-	return nil
+	n.mu.Lock()
+	h := n.activeHandle
+	n.mu.Unlock()
+	if h == nil {
+		// Nothing open for writing on this file; nothing buffered to
+		// publish.
+		return nil
+	}
+	Logger.Printf("mutFile.Fsync: %v: publishing buffered writes to server", n.permanode)
+	return h.flush(ctx)
 }
 
 func (n *mutFile) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
@@ -730,6 +829,8 @@ func (n *mutFile) Setattr(ctx context.Context, req *fuse.SetattrRequest, res *fu
 }
 
 func (n *mutFile) newHandle(body io.Reader) (fs.Handle, error) {
+	n.fs.acquireOpenFile()
+
 	tmp, err := os.CreateTemp("", "camli-")
 	if err == nil && body != nil {
 		_, err = io.Copy(tmp, body)
@@ -740,9 +841,17 @@ func (n *mutFile) newHandle(body io.Reader) (fs.Handle, error) {
 			tmp.Close()
 			os.Remove(tmp.Name())
 		}
+		n.fs.releaseOpenFile()
+		if errors.Is(err, syscall.ENOSPC) {
+			return nil, fuse.Errno(syscall.ENOSPC)
+		}
 		return nil, fuse.EIO
 	}
-	return &mutFileHandle{f: n, tmp: tmp}, nil
+	h := &mutFileHandle{f: n, tmp: tmp}
+	n.mu.Lock()
+	n.activeHandle = h
+	n.mu.Unlock()
+	return h, nil
 }
 
 // mutFileHandle represents an open mutable file.
@@ -794,6 +903,15 @@ func (h *mutFileHandle) Read(ctx context.Context, req *fuse.ReadRequest, res *fu
 }
 
 func (h *mutFileHandle) Write(ctx context.Context, req *fuse.WriteRequest, res *fuse.WriteResponse) error {
+	// Bound the memory used by in-flight write payloads before taking
+	// h.f.mu, so a write blocked on budget doesn't also hold up other
+	// operations on this file.
+	dataLen := int64(len(req.Data))
+	if err := h.f.fs.acquireWriteMemory(dataLen); err != nil {
+		return err
+	}
+	defer h.f.fs.releaseWriteMemory(dataLen)
+
 	h.f.mu.Lock()
 	defer h.f.mu.Unlock()
 
@@ -807,6 +925,13 @@ func (h *mutFileHandle) Write(ctx context.Context, req *fuse.WriteRequest, res *
 		h.f.fullPath(), len(req.Data), req.Offset, req.Flags, n, err)
 	if err != nil {
 		Logger.Println("mutFileHandle.Write:", err)
+		if errors.Is(err, syscall.ENOSPC) {
+			// The local spill file couldn't grow; surface that as
+			// what it is rather than a generic I/O error, so
+			// callers (and users) can tell "disk full" apart from
+			// "something's broken".
+			return fuse.Errno(syscall.ENOSPC)
+		}
 		return fuse.EIO
 	}
 
@@ -833,6 +958,16 @@ func (h *mutFileHandle) Write(ctx context.Context, req *fuse.WriteRequest, res *
 // Note that this is distinct from Fsync -- which is a user-requested
 // flush (fsync, etc...)
 func (h *mutFileHandle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	return h.flush(ctx)
+}
+
+// flush chunks the handle's buffered spill file, uploads the resulting
+// blobs, and publishes them as the file permanode's camliContent, so
+// the write becomes visible to everyone else talking to the server.
+// It's called from Flush, on close, and from mutFile.Fsync, on an
+// explicit fsync; either way the handle stays open afterwards and may
+// still be written to and flushed again.
+func (h *mutFileHandle) flush(ctx context.Context) error {
 	h.f.mu.Lock()
 	defer h.f.mu.Unlock()
 
@@ -869,6 +1004,10 @@ func (h *mutFileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) e
 	h.tmp.Close()
 	os.Remove(h.tmp.Name())
 	h.tmp = nil
+	if h.f.activeHandle == h {
+		h.f.activeHandle = nil
+	}
+	h.f.fs.releaseOpenFile()
 
 	return nil
 }
@@ -909,3 +1048,12 @@ func (n *mutFile) eligibleToDelete() bool {
 func (n *mutDir) eligibleToDelete() bool {
 	return n.localCreateTime.Before(time.Now().Add(-deletionRefreshWindow))
 }
+
+// negativelyCached reports whether name was recently looked up in n and
+// found not to exist, and that result hasn't expired yet.
+func (n *mutDir) negativelyCached(name string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	failedAt, ok := n.negLookup[name]
+	return ok && time.Since(failedAt) < negativeLookupTTL
+}
@@ -47,3 +47,24 @@ func TestDeleteEligibility(t *testing.T) {
 		}
 	}
 }
+
+func TestNegativelyCached(t *testing.T) {
+	d := &mutDir{}
+	if d.negativelyCached("foo") {
+		t.Error("empty negLookup: got cached, want not")
+	}
+
+	d.negLookup = map[string]time.Time{
+		"foo": time.Now(),
+		"bar": time.Now().Add(-2 * negativeLookupTTL),
+	}
+	if !d.negativelyCached("foo") {
+		t.Error("recent failed lookup: got not cached, want cached")
+	}
+	if d.negativelyCached("bar") {
+		t.Error("expired failed lookup: got cached, want not")
+	}
+	if d.negativelyCached("baz") {
+		t.Error("never looked up: got cached, want not")
+	}
+}
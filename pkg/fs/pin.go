@@ -0,0 +1,269 @@
+//go:build linux
+
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+	"go4.org/syncutil"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/schema"
+)
+
+// pinControlName is the name of the magic control file that appears in
+// every camli directory. Writing anything to it starts recursively
+// fetching the directory's subtree into the local cache; reading it
+// back reports the status of the most recent such request.
+const pinControlName = ".camli_pin"
+
+// pinConcurrency bounds how many blobs are fetched at once while
+// pinning a subtree into the local cache.
+const pinConcurrency = 10
+
+// pinnable is implemented by cache fetchers (such as *cacher.DiskCache)
+// that can protect specific blobs from future eviction.
+type pinnable interface {
+	MarkPinned(refs ...blob.Ref)
+}
+
+// pinStatus tracks the progress of one pinSubtree call, so a control
+// file's Read can report on a Write that already returned.
+type pinStatus struct {
+	mu       sync.Mutex
+	pending  int // blobs discovered so far, including done and in-flight
+	done     int
+	err      error
+	finished bool
+}
+
+func (s *pinStatus) addPending(n int) {
+	s.mu.Lock()
+	s.pending += n
+	s.mu.Unlock()
+}
+
+func (s *pinStatus) incDone() {
+	s.mu.Lock()
+	s.done++
+	s.mu.Unlock()
+}
+
+func (s *pinStatus) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *pinStatus) markFinished() {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+}
+
+func (s *pinStatus) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case !s.finished:
+		return fmt.Sprintf("pinning: %d/%d blobs cached so far\n", s.done, s.pending)
+	case s.err != nil:
+		return fmt.Sprintf("pin failed after %d/%d blobs: %v\n", s.done, s.pending, s.err)
+	default:
+		return fmt.Sprintf("pin complete: %d blobs cached\n", s.done)
+	}
+}
+
+// pinSubtree recursively fetches every blob (schema blobs and file
+// content) reachable from root into fs's fetcher, with bounded
+// concurrency. It returns immediately with a *pinStatus that's updated
+// as the (asynchronous) walk progresses.
+//
+// If fs's fetcher supports it (see pinnable), every blob the walk
+// fetches itself (directory and file schema blobs, and file content
+// chunks) is also marked pinned, protecting it from future cache
+// eviction. Blobs that the directory and file readers fetch along the
+// way, such as a directory's static-set blob, are not marked.
+func (fs *CamliFileSystem) pinSubtree(root blob.Ref) *pinStatus {
+	ctx := context.Background()
+	st := &pinStatus{}
+	pinner, _ := fs.fetcher.(pinnable)
+	gate := syncutil.NewGate(pinConcurrency)
+	var wg sync.WaitGroup
+
+	fetchBlob := func(br blob.Ref) {
+		defer wg.Done()
+		gate.Start()
+		defer gate.Done()
+		rc, _, err := fs.fetcher.Fetch(ctx, br)
+		if err != nil {
+			st.fail(fmt.Errorf("pin: fetching %v: %v", br, err))
+			return
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			st.fail(fmt.Errorf("pin: reading %v: %v", br, err))
+			return
+		}
+		if pinner != nil {
+			pinner.MarkPinned(br)
+		}
+		st.incDone()
+	}
+
+	var walk func(br blob.Ref)
+	walk = func(br blob.Ref) {
+		defer wg.Done()
+		meta, err := fs.fetchSchemaMeta(ctx, br)
+		if err != nil {
+			st.fail(fmt.Errorf("pin: fetching %v: %v", br, err))
+			return
+		}
+		if pinner != nil {
+			pinner.MarkPinned(br)
+		}
+		st.incDone()
+
+		switch meta.Type() {
+		case schema.TypeDirectory:
+			dr, err := schema.NewDirReader(ctx, fs.fetcher, br)
+			if err != nil {
+				st.fail(fmt.Errorf("pin: reading directory %v: %v", br, err))
+				return
+			}
+			ents, err := dr.Readdir(ctx, -1)
+			if err != nil {
+				st.fail(fmt.Errorf("pin: reading directory %v: %v", br, err))
+				return
+			}
+			for _, ent := range ents {
+				if ent.CamliType() != schema.TypeDirectory && ent.CamliType() != schema.TypeFile {
+					// Symlinks, FIFOs, etc. have no content beyond
+					// their own schema blob, already fetched by
+					// dr.Readdir above.
+					continue
+				}
+				st.addPending(1)
+				wg.Add(1)
+				go walk(ent.BlobRef())
+			}
+		case schema.TypeFile:
+			fr, err := meta.NewFileReader(fs.fetcher)
+			if err != nil {
+				st.fail(fmt.Errorf("pin: opening file %v: %v", br, err))
+				return
+			}
+			defer fr.Close()
+			err = fr.ForeachChunk(ctx, func(_ []blob.Ref, p schema.BytesPart) error {
+				if !p.BlobRef.Valid() {
+					return nil
+				}
+				st.addPending(1)
+				wg.Add(1)
+				go fetchBlob(p.BlobRef)
+				return nil
+			})
+			if err != nil {
+				st.fail(fmt.Errorf("pin: enumerating chunks of %v: %v", br, err))
+			}
+		}
+	}
+
+	st.addPending(1)
+	wg.Add(1)
+	go walk(root)
+	go func() {
+		wg.Wait()
+		st.markFinished()
+	}()
+	return st
+}
+
+// startPin starts pinning n's subtree into the local cache, unless a
+// previous pin of n is still running.
+func (n *node) startPin() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pin != nil {
+		n.pin.mu.Lock()
+		running := !n.pin.finished
+		n.pin.mu.Unlock()
+		if running {
+			return
+		}
+	}
+	n.pin = n.fs.pinSubtree(n.blobref)
+}
+
+// pinStatusString reports the status of the most recent pin request on
+// n, or a usage hint if none was made.
+func (n *node) pinStatusString() string {
+	n.mu.Lock()
+	st := n.pin
+	n.mu.Unlock()
+	if st == nil {
+		return "no pin requested; write to this file to pin this directory's contents into the local cache\n"
+	}
+	return st.String()
+}
+
+// pinFile is the fuse node for the pinControlName control file.
+type pinFile struct {
+	n *node
+}
+
+var (
+	_ fusefs.Node         = pinFile{}
+	_ fusefs.HandleWriter = pinFile{}
+	_ fusefs.HandleReader = pinFile{}
+)
+
+func (pinFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = 0600
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+	return nil
+}
+
+func (f pinFile) Write(ctx context.Context, req *fuse.WriteRequest, res *fuse.WriteResponse) error {
+	f.n.startPin()
+	res.Size = len(req.Data)
+	return nil
+}
+
+func (f pinFile) Read(ctx context.Context, req *fuse.ReadRequest, res *fuse.ReadResponse) error {
+	msg := f.n.pinStatusString()
+	if req.Offset >= int64(len(msg)) {
+		return nil
+	}
+	end := req.Offset + int64(req.Size)
+	if end > int64(len(msg)) {
+		end = int64(len(msg))
+	}
+	res.Data = []byte(msg)[req.Offset:end]
+	return nil
+}
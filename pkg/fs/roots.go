@@ -133,30 +133,12 @@ func (n *rootsDir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir f
 		return fuse.EIO
 	}
 
-	// Don't allow renames if the root contains content.  Rename
-	// is mostly implemented to make GUIs that create directories
-	// before asking for the directory name.
-	res, err := n.fs.client.Describe(ctx, &search.DescribeRequest{BlobRef: target})
-	if err != nil {
-		Logger.Println("rootsDir.Rename:", err)
-		return handleEIOorEINTR(err)
-	}
-	db := res.Meta[target.String()]
-	if db == nil {
-		Logger.Printf("Failed to pull meta for target: %v", target)
-		return fuse.EIO
-	}
-
-	for k := range db.Permanode.Attr {
-		const p = "camliPath:"
-		if strings.HasPrefix(k, p) {
-			Logger.Printf("Found file in %q: %q, disallowing rename", req.OldName, k[len(p):])
-			return fuse.EIO
-		}
-	}
-
+	// Renaming a root only changes the camliRoot attribute on its
+	// permanode; the root's content (its camliPath:* entries) stays
+	// attached to that same permanode, so it's unaffected by the name
+	// change and doesn't need to be touched here.
 	claim := schema.NewSetAttributeClaim(target, "camliRoot", req.NewName)
-	_, err = n.fs.client.UploadAndSignBlob(ctx, claim)
+	_, err := n.fs.client.UploadAndSignBlob(ctx, claim)
 	if err != nil {
 		Logger.Printf("Upload rename link error: %v", err)
 		return handleEIOorEINTR(err)
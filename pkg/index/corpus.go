@@ -39,6 +39,7 @@ import (
 
 	"go4.org/strutil"
 	"go4.org/syncutil"
+	"go4.org/types"
 )
 
 // Corpus is an in-memory summary of all of a user's blobs' metadata.
@@ -107,6 +108,8 @@ type Corpus struct {
 
 	mediaTags map[blob.Ref]map[string]string // wholeref -> "album" -> "foo"
 
+	fileTexts map[blob.Ref]string // wholeref -> extracted document text (e.g. from a PDF)
+
 	permanodesByTime    *lazySortedPermanodes // cache of permanodes sorted by creation time.
 	permanodesByModtime *lazySortedPermanodes // cache of permanodes sorted by modtime.
 
@@ -115,6 +118,18 @@ type Corpus struct {
 	// value. The bool is always true.
 	permanodesSetByNodeType map[string]map[blob.Ref]bool
 
+	// permanodesByNodeTypeTime caches, per camliNodeType value, that
+	// type's permanodes sorted by creation time. It's the composite
+	// (nodeType, reverse-time) index used by queries that combine a
+	// camliNodeType constraint with a creation-date range, so they can
+	// enumerate candidates of the right type in the right order instead
+	// of scanning every permanode and filtering. Entries are built
+	// lazily, on top of permanodesSetByNodeType, the first time a given
+	// type is queried, and use the same generation-based invalidation
+	// as permanodesByTime.
+	permanodesByNodeTypeTimeMu sync.Mutex
+	permanodesByNodeTypeTime   map[string]*lazySortedPermanodes
+
 	// scratch string slice
 	ss []string
 }
@@ -310,23 +325,25 @@ func (pm *PermanodeMeta) valuesAtSigner(at time.Time,
 
 func newCorpus() *Corpus {
 	c := &Corpus{
-		blobs:                   make(map[blob.Ref]*camtypes.BlobMeta),
-		camBlobs:                make(map[schema.CamliType]map[blob.Ref]*camtypes.BlobMeta),
-		files:                   make(map[blob.Ref]camtypes.FileInfo),
-		permanodes:              make(map[blob.Ref]*PermanodeMeta),
-		imageInfo:               make(map[blob.Ref]camtypes.ImageInfo),
-		deletedBy:               make(map[blob.Ref]blob.Ref),
-		keyId:                   make(map[blob.Ref]string),
-		signerRefs:              make(map[string]SignerRefSet),
-		brOfStr:                 make(map[string]blob.Ref),
-		fileWholeRef:            make(map[blob.Ref]blob.Ref),
-		gps:                     make(map[blob.Ref]latLong),
-		mediaTags:               make(map[blob.Ref]map[string]string),
-		deletes:                 make(map[blob.Ref][]deletion),
-		claimBack:               make(map[blob.Ref][]*camtypes.Claim),
-		permanodesSetByNodeType: make(map[string]map[blob.Ref]bool),
-		dirChildren:             make(map[blob.Ref]map[blob.Ref]struct{}),
-		fileParents:             make(map[blob.Ref]map[blob.Ref]struct{}),
+		blobs:                    make(map[blob.Ref]*camtypes.BlobMeta),
+		camBlobs:                 make(map[schema.CamliType]map[blob.Ref]*camtypes.BlobMeta),
+		files:                    make(map[blob.Ref]camtypes.FileInfo),
+		permanodes:               make(map[blob.Ref]*PermanodeMeta),
+		imageInfo:                make(map[blob.Ref]camtypes.ImageInfo),
+		deletedBy:                make(map[blob.Ref]blob.Ref),
+		keyId:                    make(map[blob.Ref]string),
+		signerRefs:               make(map[string]SignerRefSet),
+		brOfStr:                  make(map[string]blob.Ref),
+		fileWholeRef:             make(map[blob.Ref]blob.Ref),
+		gps:                      make(map[blob.Ref]latLong),
+		mediaTags:                make(map[blob.Ref]map[string]string),
+		fileTexts:                make(map[blob.Ref]string),
+		deletes:                  make(map[blob.Ref][]deletion),
+		claimBack:                make(map[blob.Ref][]*camtypes.Claim),
+		permanodesSetByNodeType:  make(map[string]map[blob.Ref]bool),
+		permanodesByNodeTypeTime: make(map[string]*lazySortedPermanodes),
+		dirChildren:              make(map[blob.Ref]map[blob.Ref]struct{}),
+		fileParents:              make(map[blob.Ref]map[blob.Ref]struct{}),
 	}
 	c.permanodesByModtime = &lazySortedPermanodes{
 		c:      c,
@@ -381,12 +398,14 @@ var corpusMergeFunc = map[string]func(c *Corpus, k, v []byte) error{
 	"claim":                (*Corpus).mergeClaimRow,
 	"fileinfo":             (*Corpus).mergeFileInfoRow,
 	keyFileTimes.name:      (*Corpus).mergeFileTimesRow,
+	keyEXIFTakenTime.name:  (*Corpus).mergeEXIFTakenTimeRow,
 	"imagesize":            (*Corpus).mergeImageSizeRow,
 	"wholetofile":          (*Corpus).mergeWholeToFileRow,
 	"exifgps":              (*Corpus).mergeEXIFGPSRow,
 	"exiftag":              nil, // not using any for now
 	"signerattrvalue":      nil, // ignoring for now
 	"mediatag":             (*Corpus).mergeMediaTag,
+	keyFileText.name:       (*Corpus).mergeFileText,
 	keyStaticDirChild.name: (*Corpus).mergeStaticDirChildRow,
 }
 
@@ -408,10 +427,12 @@ var slurpPrefixes = []string{
 	"claim|",
 	"fileinfo|",
 	keyFileTimes.name + "|",
+	keyEXIFTakenTime.name + "|",
 	"imagesize|",
 	"wholetofile|",
 	"exifgps|",
 	"mediatag|",
+	keyFileText.name + "|",
 	keyStaticDirChild.name + "|",
 }
 
@@ -815,6 +836,30 @@ func (c *Corpus) mergeFileTimesRow(k, v []byte) error {
 	return nil
 }
 
+func (c *Corpus) mergeEXIFTakenTimeRow(k, v []byte) error {
+	if len(v) == 0 {
+		return nil
+	}
+	// "exiftaken|sha1-579f7f246bd420d486ddeb0dadbb256cfaf8bf6b" "1970-01-01T00%3A02%3A03Z"
+	pipe := bytes.IndexByte(k, '|')
+	if pipe < 0 {
+		return fmt.Errorf("unexpected exiftaken key %q", k)
+	}
+	br, ok := blob.ParseBytes(k[pipe+1:])
+	if !ok {
+		return fmt.Errorf("unexpected exiftaken blobref in key %q", k)
+	}
+	t, err := time.Parse(time.RFC3339, urld(string(v)))
+	if err != nil {
+		return fmt.Errorf("unexpected exiftaken time %q: %v", v, err)
+	}
+	taken := types.Time3339(t)
+	c.mutateFileInfo(br, func(fi *camtypes.FileInfo) {
+		fi.Taken = &taken
+	})
+	return nil
+}
+
 func (c *Corpus) mutateFileInfo(br blob.Ref, fn func(*camtypes.FileInfo)) {
 	br = c.br(br)
 	fi := c.files[br] // use zero value if not present
@@ -875,6 +920,16 @@ func (c *Corpus) mergeMediaTag(k, v []byte) error {
 	return nil
 }
 
+// "filetext|sha1-2b219be9d9691b4f8090e7ee2690098097f59566" = "some+extracted+text"
+func (c *Corpus) mergeFileText(k, v []byte) error {
+	wholeRef, ok := blob.Parse(strings.TrimPrefix(string(k), keyFileText.name+"|"))
+	if !ok {
+		return fmt.Errorf("failed to parse wholeref from key %q", k)
+	}
+	c.fileTexts[wholeRef] = urld(string(v))
+	return nil
+}
+
 // "exifgps|sha1-17b53c7c3e664d3613dfdce50ef1f2a09e8f04b5" -> "-122.39897155555556|37.61952208333334"
 func (c *Corpus) mergeEXIFGPSRow(k, v []byte) error {
 	wholeRef, ok := blob.ParseBytes(k[len("exifgps|"):])
@@ -997,6 +1052,10 @@ type lazySortedPermanodes struct {
 	c      *Corpus
 	pnTime func(blob.Ref) (time.Time, bool) // returns permanode's time (if any) to sort on
 
+	// nodeType, if non-empty, restricts sorting to the permanodes in
+	// c.permanodesSetByNodeType[nodeType] instead of all of c.permanodes.
+	nodeType string
+
 	mu                  sync.Mutex  // guards sortedCache and ofGen
 	sortedCache         []pnAndTime // nil if invalidated
 	sortedCacheReversed []pnAndTime // nil if invalidated
@@ -1041,13 +1100,27 @@ func (lsp *lazySortedPermanodes) sorted(reverse bool) []pnAndTime {
 	// invalidate the caches
 	lsp.sortedCache = nil
 	lsp.sortedCacheReversed = nil
-	pns := make([]pnAndTime, 0, len(lsp.c.permanodes))
-	for pn := range lsp.c.permanodes {
-		if lsp.c.IsDeleted(pn) {
-			continue
+	var pns []pnAndTime
+	if lsp.nodeType != "" {
+		set := lsp.c.permanodesSetByNodeType[lsp.nodeType]
+		pns = make([]pnAndTime, 0, len(set))
+		for pn := range set {
+			if lsp.c.IsDeleted(pn) {
+				continue
+			}
+			if pt, ok := lsp.pnTime(pn); ok {
+				pns = append(pns, pnAndTime{pn, pt})
+			}
 		}
-		if pt, ok := lsp.pnTime(pn); ok {
-			pns = append(pns, pnAndTime{pn, pt})
+	} else {
+		pns = make([]pnAndTime, 0, len(lsp.c.permanodes))
+		for pn := range lsp.c.permanodes {
+			if lsp.c.IsDeleted(pn) {
+				continue
+			}
+			if pt, ok := lsp.pnTime(pn); ok {
+				pns = append(pns, pnAndTime{pn, pt})
+			}
 		}
 	}
 	// and rebuild one of them
@@ -1080,6 +1153,16 @@ func (c *Corpus) EnumeratePermanodesLastModified(fn func(camtypes.BlobMeta) bool
 	c.enumeratePermanodes(fn, c.permanodesByModtime.sorted(true))
 }
 
+// EnumeratePermanodesLastModifiedAsc calls fn for all permanodes, sorted by
+// least recently modified first (oldest change first). It's the ascending
+// counterpart to EnumeratePermanodesLastModified, used to scroll forward
+// through changes since a previously-seen point, such as for a sync client
+// polling with a Continue token.
+// Iteration ends prematurely if fn returns false.
+func (c *Corpus) EnumeratePermanodesLastModifiedAsc(fn func(camtypes.BlobMeta) bool) {
+	c.enumeratePermanodes(fn, c.permanodesByModtime.sorted(false))
+}
+
 // EnumeratePermanodesCreated calls fn for all permanodes.
 // They are sorted using the contents creation date if any, the permanode modtime
 // otherwise, and in the order specified by newestFirst.
@@ -1095,6 +1178,35 @@ func (c *Corpus) EnumerateSingleBlob(fn func(camtypes.BlobMeta) bool, br blob.Re
 	}
 }
 
+// permanodesByNodeType returns the lazily-built, generation-cached
+// creation-time sort of nodeType's permanodes, creating it on first use.
+func (c *Corpus) permanodesByNodeType(nodeType string) *lazySortedPermanodes {
+	c.permanodesByNodeTypeTimeMu.Lock()
+	defer c.permanodesByNodeTypeTimeMu.Unlock()
+	lsp, ok := c.permanodesByNodeTypeTime[nodeType]
+	if !ok {
+		lsp = &lazySortedPermanodes{
+			c:        c,
+			pnTime:   c.PermanodeAnyTime,
+			nodeType: nodeType,
+		}
+		c.permanodesByNodeTypeTime[nodeType] = lsp
+	}
+	return lsp
+}
+
+// EnumeratePermanodesByNodeTypeCreated calls fn for all permanodes that
+// might have the given camliNodeType attribute value, sorted by creation
+// time as EnumeratePermanodesCreated does. Unlike EnumeratePermanodesCreated
+// followed by a camliNodeType filter, this never looks at permanodes of a
+// different type, so a query combining a camliNodeType constraint with a
+// creation-date range (or just a Limit) can stop as soon as it has enough
+// matches instead of scanning the whole corpus.
+// Iteration ends prematurely if fn returns false.
+func (c *Corpus) EnumeratePermanodesByNodeTypeCreated(fn func(camtypes.BlobMeta) bool, nodeType string, newestFirst bool) {
+	c.enumeratePermanodes(fn, c.permanodesByNodeType(nodeType).sorted(newestFirst))
+}
+
 // EnumeratePermanodesByNodeTypes enumerates over all permanodes that might
 // have one of the provided camliNodeType values, calling fn for each. If fn returns false,
 // enumeration ends.
@@ -1192,6 +1304,22 @@ func (c *Corpus) PermanodeAnyTime(pn blob.Ref) (t time.Time, ok bool) {
 	return c.PermanodeModtime(pn)
 }
 
+// PermanodeTakenTime returns the EXIF "date taken" of the image file
+// referenced by pn's camliContent attribute, if any. It reports
+// ok=false if pn has no camliContent, or that content has no EXIF date
+// taken.
+func (c *Corpus) PermanodeTakenTime(pn blob.Ref) (t time.Time, ok bool) {
+	ccRef, _, ok := c.pnCamliContent(pn)
+	if !ok {
+		return time.Time{}, false
+	}
+	fi := c.files[ccRef]
+	if fi.Taken == nil {
+		return time.Time{}, false
+	}
+	return fi.Taken.Time(), true
+}
+
 func (c *Corpus) pnCamliContent(pn blob.Ref) (cc blob.Ref, t time.Time, ok bool) {
 	// TODO(bradfitz): keep this property cached
 	pm, ok := c.permanodes[pn]
@@ -1455,6 +1583,21 @@ func (c *Corpus) GetMediaTags(ctx context.Context, fileRef blob.Ref) (map[string
 	return tags, nil
 }
 
+// GetFileText returns the text previously extracted from the contents of
+// fileRef (e.g. from a PDF), if any. It returns os.ErrNotExist if fileRef
+// is unknown or no text was extracted for it.
+func (c *Corpus) GetFileText(ctx context.Context, fileRef blob.Ref) (string, error) {
+	wholeRef, ok := c.fileWholeRef[fileRef]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	text, ok := c.fileTexts[wholeRef]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return text, nil
+}
+
 func (c *Corpus) GetWholeRef(ctx context.Context, fileRef blob.Ref) (wholeRef blob.Ref, ok bool) {
 	wholeRef, ok = c.fileWholeRef[fileRef]
 	return
@@ -1472,6 +1615,19 @@ func (c *Corpus) FileLatLong(fileRef blob.Ref) (lat, long float64, ok bool) {
 	return ll.lat, ll.long, true
 }
 
+// ClaimCount returns the number of claims ever made about permaNode,
+// including claims that no longer affect its current attributes (such as
+// a delete claim, or a set-attribute claim later overwritten by another
+// one). It's a measure of the permanode's edit activity, not of its
+// current state.
+func (c *Corpus) ClaimCount(permaNode blob.Ref) int {
+	pm, ok := c.permanodes[permaNode]
+	if !ok {
+		return 0
+	}
+	return len(pm.Claims)
+}
+
 // ForeachClaim calls fn for each claim of permaNode.
 // If at is zero, all claims are yielded.
 // If at is non-zero, claims after that point are skipped.
@@ -1508,6 +1664,64 @@ func (c *Corpus) ForeachClaimBack(value blob.Ref, at time.Time, fn func(*camtype
 	}
 }
 
+// ForeachAttrName calls fn once for each distinct permanode attribute name
+// in use, with the number of (non-deleted) permanodes that currently have
+// at least one value set for that attribute. Iteration is in an undefined
+// order, and stops early if fn returns false.
+func (c *Corpus) ForeachAttrName(fn func(name string, numPermanodes int) bool) {
+	counts := make(map[string]int)
+	for pn, pm := range c.permanodes {
+		if c.IsDeleted(pn) {
+			continue
+		}
+		for name, vals := range pm.attr {
+			if len(vals) > 0 {
+				counts[name]++
+			}
+		}
+	}
+	for name, n := range counts {
+		if !fn(name, n) {
+			return
+		}
+	}
+}
+
+// ForeachDuplicateFileName calls fn once for each base filename (e.g.
+// "report.pdf", never a path like "docs/report.pdf": camtypes.FileInfo
+// only records the base name) shared by more than one file or directory
+// blob, with the blobs sharing it. It's for surfacing accidental
+// re-imports or naming collisions, as a complement to content-based
+// (wholeRef) dedup, which this ignores: the blobs in a group may have
+// completely different contents.
+//
+// If foldCase is true, names that differ only by case (e.g. "IMG_1.JPG"
+// and "img_1.jpg") are grouped together; otherwise matching is exact.
+//
+// Iteration is in an undefined order, and stops early if fn returns
+// false.
+func (c *Corpus) ForeachDuplicateFileName(fn func(filename string, files []blob.Ref) bool, foldCase bool) {
+	groups := make(map[string][]blob.Ref)
+	for br, fi := range c.files {
+		if fi.FileName == "" {
+			continue
+		}
+		key := fi.FileName
+		if foldCase {
+			key = strings.ToLower(key)
+		}
+		groups[key] = append(groups[key], br)
+	}
+	for name, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+		if !fn(name, files) {
+			return
+		}
+	}
+}
+
 // PermanodeHasAttrValue reports whether the permanode pn at
 // time at (zero means now) has the given attribute with the given
 // value. If the attribute is multi-valued, any may match.
@@ -265,6 +265,43 @@ func TestCorpusPermanodeHasAttrValue(t *testing.T) {
 	}
 }
 
+func TestCorpusForeachAttrName(t *testing.T) {
+	c, _, _, _ := newTestCorpusWithPermanode(t)
+
+	got := make(map[string]int)
+	c.ForeachAttrName(func(name string, numPermanodes int) bool {
+		got[name] = numPermanodes
+		return true
+	})
+
+	// DelAll isn't included: its only permanode had every value deleted.
+	want := map[string]int{
+		"foo":         1,
+		"tag":         1,
+		"DelOne":      1,
+		"SetAfterAdd": 1,
+		"CacheTest":   1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ForeachAttrName = %v; want %v", got, want)
+	}
+}
+
+func TestCorpusClaimCount(t *testing.T) {
+	c, pn, _, _ := newTestCorpusWithPermanode(t)
+
+	// This counts every claim ever made about pn, including ones later
+	// superseded or deleted, so it doesn't match the number of attributes
+	// or values PermanodeHasAttrValue/AppendPermanodeAttrValues report.
+	if got, want := c.ClaimCount(pn), 26; got != want {
+		t.Errorf("ClaimCount(pn) = %d; want %d", got, want)
+	}
+
+	if got, want := c.ClaimCount(blob.MustParse("nonexistent-123")), 0; got != want {
+		t.Errorf("ClaimCount(unknown) = %d; want %d", got, want)
+	}
+}
+
 func TestKVClaimAllocs(t *testing.T) {
 	n := testing.AllocsPerRun(20, func() {
 		index.ExpKvClaim("claim|sha224-d159f351eca8c09dcf649aae43bbf92d99293242d520a9c0b41070ca|2931A67C26F5ABDA|2011-11-28T01:32:37.000123456Z|sha224-39020aa3cc25f74ea6e75793203ef31143dfe3604fb80af3d3816c5c",
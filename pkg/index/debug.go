@@ -0,0 +1,65 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package index
+
+import "strings"
+
+// DebugRow is one raw key/value entry from the index's underlying
+// key/value storage, as returned by Index.ForeachRow. See keys.go for
+// the documented key formats (e.g. "recpn", "claim", "signerattrvalue").
+type DebugRow struct {
+	Key   string
+	Value string
+}
+
+// maxDebugRows caps how many rows a single ForeachRow call yields, so a
+// broad prefix (or no prefix at all) can't dump the whole index in one
+// call.
+const maxDebugRows = 1000
+
+// ForeachRow calls fn, in key order, for raw key/value entries whose key
+// starts with prefix (or every entry, if prefix is empty) and, if substr
+// is non-empty, whose key or value also contains substr. Passing a
+// blobref as substr finds every raw entry that references it, regardless
+// of where it appears in the key or value.
+//
+// ForeachRow stops early if fn returns false, or once maxDebugRows rows
+// have matched, in which case it returns truncated=true.
+//
+// It's meant for interactively debugging the index's raw contents (e.g.
+// via a search/debug HTTP endpoint), not for programmatic use: a broad
+// prefix, or a substr search with no prefix, scans the whole index.
+func (x *Index) ForeachRow(prefix, substr string, fn func(DebugRow) bool) (truncated bool, err error) {
+	it := x.queryPrefixString(prefix)
+	defer closeIterator(it, &err)
+	n := 0
+	for it.Next() {
+		key, val := it.Key(), it.Value()
+		if substr != "" && !strings.Contains(key, substr) && !strings.Contains(val, substr) {
+			continue
+		}
+		if n >= maxDebugRows {
+			truncated = true
+			break
+		}
+		n++
+		if !fn(DebugRow{Key: key, Value: val}) {
+			break
+		}
+	}
+	return truncated, err
+}
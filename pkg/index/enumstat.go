@@ -84,6 +84,22 @@ func (ix *Index) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.S
 	return nil
 }
 
+// DeleteBlobMeta removes the index rows that record br's own existence
+// (its "have:" and "meta:" rows), for use by a repair tool that has
+// confirmed br no longer exists in the blob storage backing the index.
+//
+// It does not attempt to remove or fix up anything that referenced br
+// (e.g. a directory's static set, or a permanode's camliContent claim);
+// a full reindex is the way to fully clean those up. This only stops
+// br itself from being reported as present by EnumerateBlobs, StatBlobs,
+// and GetBlobMeta.
+func (ix *Index) DeleteBlobMeta(ctx context.Context, br blob.Ref) error {
+	bm := ix.s.BeginBatch()
+	bm.Delete("have:" + br.String())
+	bm.Delete("meta:" + br.String())
+	return ix.s.CommitBatch(bm)
+}
+
 // parseHaveVal takes the value part of an "have" index row and returns
 // the blob size found in that value. Examples:
 // parseHaveVal("324|indexed") == 324
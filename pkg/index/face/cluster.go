@@ -0,0 +1,90 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package face
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultThreshold is the maximum Euclidean distance between a face's
+// descriptor and a cluster's centroid for the face to be considered the
+// same person, for callers that don't have a better value tuned to
+// their Detector.
+const DefaultThreshold = 0.6
+
+// Cluster is a group of faces believed to belong to the same person,
+// identified by a stable ID. Once a user names a cluster, the name is
+// stored as a permanode attribute rather than in Cluster itself; see
+// the "person:" search predicate.
+type Cluster struct {
+	ID       string
+	Centroid []float32 // running average descriptor of the cluster's faces
+	NumFaces int
+}
+
+// Assign matches face against the existing clusters and returns the ID
+// of the cluster it's assigned to, updating that cluster's centroid to
+// account for face. If no existing cluster's centroid is within
+// threshold of face's descriptor, a new cluster is appended to
+// clusters and its ID is returned.
+//
+// Assign implements simple incremental, greedy nearest-centroid
+// clustering: each face is assigned once, in whatever order it's
+// presented, and clusters are never split or merged after the fact.
+// It's meant as a reasonable default for a freshly registered Detector,
+// not as a state-of-the-art face clustering algorithm.
+func Assign(clusters *[]Cluster, face Face, threshold float64) string {
+	best := -1
+	bestDist := math.Inf(1)
+	for i, c := range *clusters {
+		if d := distance(c.Centroid, face.Descriptor); d < bestDist {
+			bestDist = d
+			best = i
+		}
+	}
+	if best >= 0 && bestDist <= threshold {
+		c := &(*clusters)[best]
+		c.NumFaces++
+		for i, v := range face.Descriptor {
+			c.Centroid[i] += (v - c.Centroid[i]) / float32(c.NumFaces)
+		}
+		return c.ID
+	}
+	c := Cluster{
+		ID:       fmt.Sprintf("p%d", len(*clusters)+1),
+		Centroid: append([]float32(nil), face.Descriptor...),
+		NumFaces: 1,
+	}
+	*clusters = append(*clusters, c)
+	return c.ID
+}
+
+// distance returns the Euclidean distance between a and b, or +Inf if
+// they have different lengths (e.g. two Detectors with incompatible
+// descriptors were mixed together).
+func distance(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return math.Inf(1)
+	}
+	var sum float64
+	for i, av := range a {
+		d := float64(av) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package face
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestAssignGroupsNearbyFaces(t *testing.T) {
+	var clusters []Cluster
+
+	id1 := Assign(&clusters, Face{Descriptor: []float32{0, 0}}, DefaultThreshold)
+	id2 := Assign(&clusters, Face{Descriptor: []float32{0.01, 0}}, DefaultThreshold)
+	if id1 != id2 {
+		t.Errorf("nearby faces got different cluster IDs: %q, %q", id1, id2)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("len(clusters) = %d; want 1", len(clusters))
+	}
+	if clusters[0].NumFaces != 2 {
+		t.Errorf("clusters[0].NumFaces = %d; want 2", clusters[0].NumFaces)
+	}
+}
+
+func TestAssignSplitsDistantFaces(t *testing.T) {
+	var clusters []Cluster
+
+	id1 := Assign(&clusters, Face{Descriptor: []float32{0, 0}}, DefaultThreshold)
+	id2 := Assign(&clusters, Face{Descriptor: []float32{10, 10}}, DefaultThreshold)
+	if id1 == id2 {
+		t.Errorf("distant faces got the same cluster ID: %q", id1)
+	}
+	if len(clusters) != 2 {
+		t.Fatalf("len(clusters) = %d; want 2", len(clusters))
+	}
+}
+
+func TestRegisterDetectorTwicePanics(t *testing.T) {
+	defer func() { detector = nil }()
+	RegisterDetector(fakeDetector{})
+	defer func() {
+		if recover() == nil {
+			t.Error("second RegisterDetector call did not panic")
+		}
+	}()
+	RegisterDetector(fakeDetector{})
+}
+
+type fakeDetector struct{}
+
+func (fakeDetector) Detect(context.Context, io.Reader) ([]Face, error) {
+	return nil, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package face defines a pluggable interface for detecting faces in
+// images, and a simple incremental clustering of detected faces into
+// person clusters, for the "person:" search predicate.
+//
+// Face detection is CPU- and memory-heavy and depends on a model that
+// isn't vendored with Perkeep, so this package ships no Detector of its
+// own; nothing runs unless one is registered with RegisterDetector.
+//
+// The indexer's image pipeline ((*index.Index).populateFile, via
+// detectFaces) calls a registered Detector on every new photo and
+// clusters the results with Assign, but only logs the resulting
+// cluster IDs; it doesn't turn them into "person" attribute claims,
+// since minting a claim requires an owner-signed identity the indexer
+// doesn't have. Until something with that identity does (e.g. a
+// standalone tool walking the corpus, detecting faces, and issuing
+// schema.NewSetAttributeClaim itself, the way pk-put issues claims
+// today), "person:<clusterID>" (see pkg/search) only matches clusters
+// a user has named by hand.
+package face // import "perkeep.org/pkg/index/face"
+
+import (
+	"context"
+	"io"
+)
+
+// Face is a single face detected within an image.
+type Face struct {
+	// X, Y, Width, and Height describe the face's bounding box, in
+	// pixels, within the image passed to Detect.
+	X, Y, Width, Height int
+
+	// Descriptor is an embedding vector describing the face, suitable
+	// for nearest-neighbor comparison against the Descriptor of another
+	// Face of the same person. Its length is Detector-specific; all
+	// Faces returned by a given Detector must use the same length.
+	Descriptor []float32
+}
+
+// A Detector finds faces in an image read from r.
+type Detector interface {
+	Detect(ctx context.Context, r io.Reader) ([]Face, error)
+}
+
+var detector Detector
+
+// RegisterDetector installs d as the Detector the indexer uses to find
+// faces in images. It's meant to be called from the init function of a
+// package (typically behind a build tag, since detectors tend to carry
+// heavy dependencies) that a server binary wanting face detection
+// support blank-imports. Only one Detector may be registered; calling
+// RegisterDetector twice panics.
+func RegisterDetector(d Detector) {
+	if detector != nil {
+		panic("face: RegisterDetector called twice")
+	}
+	detector = d
+}
+
+// RegisteredDetector returns the Detector installed with
+// RegisterDetector, or nil if none has been registered, in which case
+// the indexer should skip face detection entirely.
+func RegisteredDetector() Detector {
+	return detector
+}
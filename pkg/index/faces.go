@@ -0,0 +1,64 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package index
+
+import (
+	"bytes"
+	"context"
+	"log"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/index/face"
+)
+
+// detectFaces runs the registered face.Detector, if any, against an
+// image's already-buffered leading bytes (the same prefix populateFile
+// uses for EXIF and dimensions), incrementally clustering any detected
+// faces into person clusters via face.Assign.
+//
+// It's a no-op unless a Detector has been registered with
+// face.RegisterDetector, in which case this is the only place it's
+// ever called from. Cluster assignments are logged (like this
+// function's EXIF-parsing neighbors in populateFile) but not yet
+// persisted as permanode attributes: unlike the rest of populateFile,
+// which only writes to ix's local key/value index, turning a cluster
+// ID into a queryable "person" attribute (see the "person:" search
+// predicate) requires an owner-signed claim, and the indexer has no
+// signing identity to mint one. That remains a follow-up, likely done
+// by a client-side tool that walks the corpus and issues the claims
+// itself, the way pk-put issues claims today.
+func (ix *Index) detectFaces(ctx context.Context, wholeRef blob.Ref, imagePrefix []byte) {
+	d := face.RegisteredDetector()
+	if d == nil {
+		return
+	}
+	faces, err := d.Detect(ctx, bytes.NewReader(imagePrefix))
+	if err != nil {
+		if debugEnv {
+			log.Printf("index: WARNING: face detection on %v: %v", wholeRef, err)
+		}
+		return
+	}
+	ix.faceMu.Lock()
+	defer ix.faceMu.Unlock()
+	for _, f := range faces {
+		id := face.Assign(&ix.faceClusters, f, face.DefaultThreshold)
+		if debugEnv {
+			log.Printf("index: %v: detected face assigned to person cluster %s", wholeRef, id)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package index
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/index/face"
+)
+
+type fakeDetector struct {
+	faces []face.Face
+}
+
+func (d fakeDetector) Detect(ctx context.Context, r io.Reader) ([]face.Face, error) {
+	return d.faces, nil
+}
+
+// TestDetectFaces covers both halves of detectFaces: it's a no-op with
+// no Detector registered, and clusters faces across calls once one is.
+// The two are one test, rather than two, because face.RegisterDetector
+// may only ever be called once per process.
+func TestDetectFaces(t *testing.T) {
+	ix := new(Index)
+	ref1 := blob.RefFromString("photo1")
+	ref2 := blob.RefFromString("photo2")
+
+	ix.detectFaces(context.Background(), ref1, []byte("not really an image"))
+	if len(ix.faceClusters) != 0 {
+		t.Fatalf("faceClusters = %v; want none with no Detector registered", ix.faceClusters)
+	}
+
+	face.RegisterDetector(fakeDetector{
+		faces: []face.Face{{Descriptor: []float32{1, 0, 0}}},
+	})
+	ix.detectFaces(context.Background(), ref1, nil)
+	ix.detectFaces(context.Background(), ref2, nil)
+
+	if len(ix.faceClusters) != 1 {
+		t.Fatalf("faceClusters = %v; want exactly 1 cluster after two near-identical faces", ix.faceClusters)
+	}
+	if got := ix.faceClusters[0].NumFaces; got != 2 {
+		t.Errorf("cluster NumFaces = %d; want 2", got)
+	}
+}
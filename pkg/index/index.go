@@ -35,6 +35,7 @@ import (
 	"perkeep.org/pkg/blob"
 	"perkeep.org/pkg/blobserver"
 	"perkeep.org/pkg/env"
+	"perkeep.org/pkg/index/face"
 	"perkeep.org/pkg/schema"
 	"perkeep.org/pkg/sorted"
 	"perkeep.org/pkg/types/camtypes"
@@ -85,6 +86,53 @@ type Index struct {
 	blobSource blobserver.FetcherEnumerator
 
 	hasWiped bool // whether Wipe has been called on s. So we don't redo it in Reindex() for nothing.
+
+	// batchMaxMutations and batchMaxDelay optionally enable write-batching
+	// of index mutations across multiple ReceiveBlob calls, for faster
+	// bulk ingest on backends where committing many small batches is
+	// slow. batchMaxMutations <= 0 (the default) disables batching:
+	// every ReceiveBlob commits its own mutations in its own batch, as
+	// before. See SetBatching.
+	batchMaxMutations int
+	batchMaxDelay     time.Duration
+
+	// batchMu guards the following. It's separate from mu because the
+	// delayed flush below fires from its own timer goroutine and
+	// shouldn't have to contend with mu, which guards unrelated state.
+	batchMu sync.Mutex
+	// batch is the pending batch mutation, or nil if nothing is
+	// buffered. A sorted.KeyValue batch is committed atomically, so
+	// whatever's accumulated in batch either fully applies at the next
+	// flush or, if perkeepd crashes first, not at all; either way,
+	// nothing in it is left half-applied. Blobs whose mutations didn't
+	// make it into a flushed batch simply don't have their "indexed"
+	// marker set, so a subsequent ReceiveBlob of the same (content-addressed)
+	// blob reindexes them from scratch.
+	batch        sorted.BatchMutation
+	batchN       int            // number of key/value pairs currently in batch
+	batchDeletes []schema.Claim // deletion claims accumulated in batch, applied to the deletes cache once batch is flushed
+	batchTimer   *time.Timer    // fires flushBatchOnTimer after batchMaxDelay of the oldest unflushed mutation; nil if batch is nil
+
+	// faceMu guards faceClusters, the in-memory, process-lifetime state
+	// of face.Assign's incremental clustering. It's separate from mu
+	// for the same reason batchMu is: populateFile shouldn't have to
+	// contend with mu for this. Nothing here is persisted to s; a
+	// restart starts clustering over from scratch. See detectFaces.
+	faceMu       sync.Mutex
+	faceClusters []face.Cluster
+}
+
+// SetBatching enables (or, with maxMutations <= 0, disables) write-batching
+// of index mutations: mutations from up to maxMutations ReceiveBlob calls,
+// or those accumulated over maxDelay since the first of them, whichever
+// comes first, are committed together in a single sorted.KeyValue batch
+// instead of one batch per blob. This can substantially speed up bulk
+// imports and reindexing on KeyValue implementations where committing a
+// batch has a fixed per-call cost. It must be called before the index
+// starts receiving blobs.
+func (x *Index) SetBatching(maxMutations int, maxDelay time.Duration) {
+	x.batchMaxMutations = maxMutations
+	x.batchMaxDelay = maxDelay
 }
 
 func (x *Index) Lock()    { x.mu.Lock() }
@@ -231,18 +279,20 @@ func New(s sorted.KeyValue) (*Index, error) {
 }
 
 func is4To5SchemaBump(schemaVersion int) bool {
-	return schemaVersion == 4 && requiredSchemaVersion == 5
+	return schemaVersion == 4
 }
 
 var errMissingWholeRef = errors.New("missing wholeRef field in fileInfo rows")
 
-// fixMissingWholeRef appends the wholeRef to all the keyFileInfo rows values. It should
-// only be called to upgrade a version 4 index schema to version 5.
+// fixMissingWholeRef appends the wholeRef to all the keyFileInfo rows values,
+// bringing a version 4 index schema up to the current schema version. It
+// should only be called when upgrading from a version 4 index; no other
+// schema version has ever lacked the wholeRef field.
 func (x *Index) fixMissingWholeRef(fetcher blob.Fetcher) (err error) {
 	// We did that check from the caller, but double-check again to prevent from misuse
 	// of that function.
-	if x.schemaVersion() != 4 || requiredSchemaVersion != 5 {
-		panic("fixMissingWholeRef should only be used when upgrading from v4 to v5 of the index schema")
+	if x.schemaVersion() != 4 {
+		panic("fixMissingWholeRef should only be used when upgrading from v4 of the index schema")
 	}
 	x.logf("fixing the missing wholeRef in the fileInfo rows...")
 	defer func() {
@@ -331,7 +381,7 @@ func (x *Index) fixMissingWholeRef(fetcher blob.Fetcher) (err error) {
 	for k, v := range mutations {
 		bm.Set(k, v)
 	}
-	bm.Set(keySchemaVersion.name, "5")
+	bm.Set(keySchemaVersion.name, fmt.Sprint(requiredSchemaVersion))
 	if err := x.s.CommitBatch(bm); err != nil {
 		return err
 	}
@@ -346,6 +396,12 @@ func newFromConfig(ld blobserver.Loader, config jsonconfig.Obj) (blobserver.Stor
 	kvConfig := config.RequiredObject("storage")
 	reindex := config.OptionalBool("reindex", false)
 	keepGoing := config.OptionalBool("keepGoing", false)
+	// batchMaxMutations and batchMaxDelayMS configure optional write
+	// batching of index mutations across blobs; see Index.SetBatching.
+	// Batching is off by default, matching the historical one-batch-per-blob
+	// behavior.
+	batchMaxMutations := config.OptionalInt("batchMaxMutations", 0)
+	batchMaxDelayMS := config.OptionalInt("batchMaxDelayMS", 1000)
 
 	if err := config.Validate(); err != nil {
 		return nil, err
@@ -395,6 +451,7 @@ func newFromConfig(ld blobserver.Loader, config jsonconfig.Obj) (blobserver.Stor
 	if reindex {
 		ix.hasWiped = true
 	}
+	ix.SetBatching(batchMaxMutations, time.Duration(batchMaxDelayMS)*time.Millisecond)
 	if err != nil {
 		return nil, err
 	}
@@ -413,6 +470,13 @@ func (x *Index) String() string {
 	return fmt.Sprintf("Perkeep index, using key/value implementation %T", x.s)
 }
 
+// KeyValue returns the sorted.KeyValue backing the index, so callers
+// (such as an index-backup handler) can read its raw contents without
+// going through the index's higher-level, typed accessors.
+func (x *Index) KeyValue() sorted.KeyValue {
+	return x.s
+}
+
 func (x *Index) isEmpty() bool {
 	iter := x.s.Find("", "")
 	hasRows := iter.Next()
@@ -1433,22 +1497,29 @@ func updateFileInfoTimes(fi *camtypes.FileInfo, times []string) {
 	}
 }
 
-// v is "width|height"
+// v is "width|height|colors", where colors is a url-query-escaped,
+// comma-separated list of "RRGGBB" hex triplets. Older rows lack the
+// trailing "|colors" part entirely.
 func kvImageInfo(v []byte) (ii camtypes.ImageInfo, ok bool) {
-	pipei := bytes.IndexByte(v, '|')
-	if pipei < 0 {
+	parts := bytes.SplitN(v, []byte("|"), 3)
+	if len(parts) < 2 {
 		return
 	}
-	w, err := strutil.ParseUintBytes(v[:pipei], 10, 16)
+	w, err := strutil.ParseUintBytes(parts[0], 10, 16)
 	if err != nil {
 		return
 	}
-	h, err := strutil.ParseUintBytes(v[pipei+1:], 10, 16)
+	h, err := strutil.ParseUintBytes(parts[1], 10, 16)
 	if err != nil {
 		return
 	}
 	ii.Width = uint16(w)
 	ii.Height = uint16(h)
+	if len(parts) == 3 {
+		if colors := urld(string(parts[2])); colors != "" {
+			ii.DominantColors = strings.Split(colors, ",")
+		}
+	}
 	return ii, true
 }
 
@@ -1559,6 +1630,9 @@ func (x *Index) EdgesTo(ref blob.Ref, opts *camtypes.EdgesToOpts) (edges []*camt
 	for _, e := range permanodeParents {
 		edges = append(edges, e)
 	}
+	if opts != nil && opts.Max > 0 && len(edges) > opts.Max {
+		edges = edges[:opts.Max]
+	}
 	return edges, nil
 }
 
@@ -1737,10 +1811,14 @@ func (x *Index) EnumerateBlobMeta(ctx context.Context, fn func(camtypes.BlobMeta
 // Storage returns the index's underlying Storage implementation.
 func (x *Index) Storage() sorted.KeyValue { return x.s }
 
-// Close closes the underlying sorted.KeyValue, if the storage has a Close method.
+// Close flushes any batched mutations (see SetBatching) and closes the
+// underlying sorted.KeyValue, if the storage has a Close method.
 // The return value is the return value of the underlying Close, or
 // nil otherwise.
 func (x *Index) Close() error {
+	if err := x.FlushBatch(); err != nil {
+		return fmt.Errorf("error flushing batched index mutations: %v", err)
+	}
 	if cl, ok := x.s.(io.Closer); ok {
 		return cl.Close()
 	}
@@ -1818,7 +1896,7 @@ func IsIndexedAttribute(attr string) bool {
 // relationships.
 func IsBlobReferenceAttribute(attr string) bool {
 	switch attr {
-	case "camliMember":
+	case "camliMember", "camliContent", "camliContentImage":
 		return true
 	}
 	return false
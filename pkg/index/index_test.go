@@ -27,6 +27,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"perkeep.org/pkg/blob"
 	"perkeep.org/pkg/blobserver"
@@ -117,8 +118,10 @@ var (
 		"corpus_bench_test.go",
 		"corpus_test.go",
 		"export_test.go",
+		"faces_test.go",
 		"index_test.go",
 		"keys_test.go",
+		"pdftext_test.go",
 		"util_test.go",
 	}
 	// A map is used in hasAllRequiredTests to note which required
@@ -341,6 +344,159 @@ func TestInitNeededMaps(t *testing.T) {
 	dumpSorted(t, s)
 }
 
+// TestBatching verifies that with SetBatching enabled, mutations from
+// several blobs accumulate in memory rather than hitting storage one
+// blob at a time, and are flushed together once the batch fills up or
+// is explicitly flushed.
+func TestBatching(t *testing.T) {
+	s := sorted.NewMemoryKeyValue()
+	ix, err := index.New(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A high enough threshold that indexing one permanode (which sets
+	// several key/value pairs at once) doesn't reach it by itself, so a
+	// delayed flush must be triggered explicitly, via FlushBatch, for
+	// this test.
+	ix.SetBatching(1000, time.Hour)
+
+	id := indextest.NewIndexDeps(ix)
+	id.Fataler = t
+
+	countRows := func() int {
+		n := 0
+		foreachSorted(t, s, func(k, v string) { n++ })
+		return n
+	}
+	base := countRows() // e.g. the schemaversion row, written outside of batching
+
+	pn := id.NewPermanode()
+	if n := countRows(); n != base {
+		t.Fatalf("after indexing a permanode, storage has %d rows; want unchanged at %d (still buffered)", n, base)
+	}
+	if _, err := s.Get("have:" + pn.String()); err == nil {
+		t.Fatal("permanode appears indexed before the batch was flushed")
+	}
+
+	if err := ix.FlushBatch(); err != nil {
+		t.Fatalf("FlushBatch: %v", err)
+	}
+	if n := countRows(); n <= base {
+		t.Fatalf("after FlushBatch, storage has %d rows; want more than %d", n, base)
+	}
+	if _, err := s.Get("have:" + pn.String()); err != nil {
+		t.Errorf("permanode %v not indexed after flush: %v", pn, err)
+	}
+}
+
+func TestForeachRow(t *testing.T) {
+	s := sorted.NewMemoryKeyValue()
+	ix, err := index.New(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Set("claim|pn1|deadbeef", "set-attr|title|hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("claim|pn2|deadbeef", "set-attr|title|world"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("fileinfo|pn1", "12|foo.txt|text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(prefix, substr string) (rows []index.DebugRow, truncated bool) {
+		truncated, err := ix.ForeachRow(prefix, substr, func(row index.DebugRow) bool {
+			rows = append(rows, row)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("ForeachRow(%q, %q): %v", prefix, substr, err)
+		}
+		return rows, truncated
+	}
+
+	if rows, _ := collect("claim", ""); len(rows) != 2 {
+		t.Errorf(`ForeachRow("claim", "") = %d rows; want 2`, len(rows))
+	}
+	if rows, _ := collect("claim", "pn1"); len(rows) != 1 || rows[0].Key != "claim|pn1|deadbeef" {
+		t.Errorf(`ForeachRow("claim", "pn1") = %+v; want the single pn1 row`, rows)
+	}
+	if rows, _ := collect("", "pn1"); len(rows) != 2 {
+		t.Errorf(`ForeachRow("", "pn1") = %d rows; want 2 (claim and fileinfo)`, len(rows))
+	}
+	if rows, _ := collect("nonexistent", ""); len(rows) != 0 {
+		t.Errorf(`ForeachRow("nonexistent", "") = %d rows; want 0`, len(rows))
+	}
+
+	stopped := 0
+	if _, err := ix.ForeachRow("claim", "", func(index.DebugRow) bool {
+		stopped++
+		return false
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if stopped != 1 {
+		t.Errorf("fn returning false: called %d times; want 1", stopped)
+	}
+}
+
+func TestBatchingFlushesOnTimer(t *testing.T) {
+	s := sorted.NewMemoryKeyValue()
+	ix, err := index.New(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// High mutation threshold so only the timer triggers a flush.
+	ix.SetBatching(1000, 20*time.Millisecond)
+
+	id := indextest.NewIndexDeps(ix)
+	id.Fataler = t
+	id.NewPermanode()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		n := 0
+		foreachSorted(t, s, func(k, v string) { n++ })
+		if n > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timeout waiting for timer-triggered flush")
+}
+
+func TestBatchingFlushedByClose(t *testing.T) {
+	s := sorted.NewMemoryKeyValue()
+	ix, err := index.New(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix.SetBatching(1000, time.Hour)
+
+	countRows := func() int {
+		n := 0
+		foreachSorted(t, s, func(k, v string) { n++ })
+		return n
+	}
+	base := countRows()
+
+	id := indextest.NewIndexDeps(ix)
+	id.Fataler = t
+	id.NewPermanode()
+
+	if n := countRows(); n != base {
+		t.Fatalf("before Close, storage has %d rows; want unchanged at %d (still buffered)", n, base)
+	}
+	if err := ix.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n := countRows(); n == base {
+		t.Fatal("after Close, storage is unchanged; want the pending batch to have been flushed")
+	}
+}
+
 func dumpSorted(t *testing.T, s sorted.KeyValue) {
 	foreachSorted(t, s, func(k, v string) {
 		t.Logf("index %q = %q", k, v)
@@ -385,7 +385,7 @@ func Index(t *testing.T, initIdx func() *index.Index) {
 	}
 
 	key = "imagesize|" + jpegFileRef.String()
-	if g, e := id.Get(key), "50|100"; g != e {
+	if g, e := id.Get(key), "50|100|e0e0e0%2C000000%2C808080%2C202020%2Cc0c0c0"; g != e {
 		t.Errorf("JPEG dude.jpg key %q = %q; want %q", key, g, e)
 	}
 
@@ -1046,6 +1046,65 @@ func EdgesTo(t *testing.T, initIdx func() *index.Index) {
 			t.Errorf("Wrong edge.\n GOT: %v\nWANT: %v", got, want)
 		}
 	}
+
+	// pn3 ---camliContent---> pn4
+	pn3 := id.NewPermanode()
+	pn4 := id.NewPermanode()
+	id.SetAttribute(pn3, "camliContent", pn4.String())
+	t.Logf("edge %s --camliContent--> %s", pn3, pn4)
+	{
+		edges, err := idx.EdgesTo(pn4, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edges) != 1 {
+			t.Fatalf("num edges = %d; want 1", len(edges))
+		}
+		wantEdge := &camtypes.Edge{
+			From:     pn3,
+			To:       pn4,
+			FromType: "permanode",
+		}
+		if got, want := edges[0].String(), wantEdge.String(); got != want {
+			t.Errorf("Wrong edge.\n GOT: %v\nWANT: %v", got, want)
+		}
+	}
+
+	// A share claim targeting pn4 is also a reverse edge to pn4.
+	share := id.uploadAndSign(schema.NewShareRef(schema.ShareHaveRef, false).SetShareTarget(pn4))
+	t.Logf("share %s --> %s", share, pn4)
+	{
+		edges, err := idx.EdgesTo(pn4, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edges) != 2 {
+			t.Fatalf("num edges = %d; want 2", len(edges))
+		}
+		var sawShare bool
+		for _, e := range edges {
+			if e.From == share {
+				sawShare = true
+				if e.FromType != schema.TypeClaim {
+					t.Errorf("share edge FromType = %v; want %v", e.FromType, schema.TypeClaim)
+				}
+			}
+		}
+		if !sawShare {
+			t.Errorf("did not find share %v among edges to %v: %v", share, pn4, edges)
+		}
+	}
+
+	// EdgesToOpts.Max caps the number of edges returned.
+	{
+		edges, err := idx.EdgesTo(pn4, &camtypes.EdgesToOpts{Max: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edges) != 1 {
+			t.Fatalf("num edges with Max=1 = %d; want 1", len(edges))
+		}
+	}
 }
 
 func Delete(t *testing.T, initIdx func() *index.Index) {
@@ -28,7 +28,9 @@ import (
 // an index key type is added, changed, or removed.
 // Version 4: EXIF tags + GPS
 // Version 5: wholeRef added to keyFileInfo
-const requiredSchemaVersion = 5
+// Version 6: keyFileText added
+// Version 7: dominant colors added to keyImageSize; keyEXIFTakenTime added
+const requiredSchemaVersion = 7
 
 // type of key returns the identifier in k before the first ":" or "|".
 // (Originally we packed keys by hand and there are a mix of styles)
@@ -280,6 +282,20 @@ var (
 		},
 	}
 
+	// The EXIF "date taken" of an image file, distinct from
+	// keyFileTimes (which mixes in modtimes and can't be trusted to
+	// reflect when a photo was actually shot). Only set when the
+	// file actually has a usable EXIF date.
+	keyEXIFTakenTime = &keyType{
+		"exiftaken",
+		[]part{
+			{"fileref", typeBlobRef},
+		},
+		[]part{
+			{"time3339", typeStr},
+		},
+	}
+
 	keySignerAttrValue = &keyType{
 		"signerattrvalue",
 		[]part{
@@ -326,7 +342,8 @@ var (
 		},
 	}
 
-	// Width and height after any EXIF rotation.
+	// Width and height after any EXIF rotation, plus the image's
+	// dominant colors.
 	keyImageSize = &keyType{
 		"imagesize",
 		[]part{
@@ -335,6 +352,9 @@ var (
 		[]part{
 			{"width", typeStr},
 			{"height", typeStr},
+			// comma-separated "RRGGBB" hex triplets, most common
+			// first; empty if the image's colors weren't computed.
+			{"colors", typeStr},
 		},
 	}
 
@@ -363,6 +383,19 @@ var (
 		},
 	}
 
+	// Text extracted from a document's content (currently just PDF),
+	// for the search package's "content:" predicate. The value is
+	// truncated to maxIndexedTextSize bytes.
+	keyFileText = &keyType{
+		"filetext",
+		[]part{
+			{"wholeRef", typeBlobRef}, // wholeRef of the document
+		},
+		[]part{
+			{"text", typeStr},
+		},
+	}
+
 	// EXIF tags
 	keyEXIFTag = &keyType{
 		"exiftag",
@@ -0,0 +1,103 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"go4.org/readerutil"
+	"perkeep.org/pkg/blob"
+
+	"rsc.io/pdf"
+)
+
+const (
+	// maxTextExtractSize is the largest file size that populateFile will
+	// attempt to extract full-text content from. Larger files are left
+	// unindexed for "content:" purposes, to bound indexing time.
+	maxTextExtractSize = 64 << 20 // 64MB
+
+	// maxIndexedTextSize is the most extracted text kept per file; text
+	// beyond this is truncated before being stored in the index.
+	maxIndexedTextSize = 1 << 20 // 1MB
+)
+
+// indexDocumentText extracts any full-text content from fr (a file of the
+// given mimeType and size) and, if any was found, stores it under wholeRef
+// for the search package's "content:" predicate.
+//
+// Only PDF is currently supported; common office formats (docx, odt, etc.)
+// are not extracted yet. Extraction is best-effort: encrypted PDFs,
+// corrupt files, and any other failure are ignored (and logged if
+// CAMLI_DEBUG is set),
+// since a missing snippet of full-text should never prevent a file from
+// being indexed.
+func indexDocumentText(mimeType string, wholeRef blob.Ref, size int64, fr readerutil.SizeReaderAt, mm *mutationMap) {
+	if size == 0 || size > maxTextExtractSize {
+		return
+	}
+	if mimeType != "application/pdf" {
+		return
+	}
+	text, err := extractPDFText(fr)
+	if err != nil {
+		if debugEnv {
+			log.Printf("index: WARNING: extracting text from PDF %v: %v", wholeRef, err)
+		}
+		return
+	}
+	if text == "" {
+		return
+	}
+	mm.Set(keyFileText.Key(wholeRef), keyFileText.Val(text))
+}
+
+// extractPDFText returns the concatenated text of every page of the PDF
+// read from r, truncated to maxIndexedTextSize. Malformed PDFs can cause
+// the underlying parser to panic; extractPDFText recovers from that and
+// returns it as an error instead.
+func extractPDFText(r readerutil.SizeReaderAt) (text string, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			text, err = "", fmt.Errorf("panic parsing PDF: %v", e)
+		}
+	}()
+	doc, err := pdf.NewReader(r, r.Size())
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for pn := 1; pn <= doc.NumPage(); pn++ {
+		page := doc.Page(pn)
+		if page.V.IsNull() {
+			continue
+		}
+		for _, t := range page.Content().Text {
+			buf.WriteString(t.S)
+		}
+		buf.WriteByte('\n')
+		if buf.Len() >= maxIndexedTextSize {
+			break
+		}
+	}
+	if buf.Len() > maxIndexedTextSize {
+		return buf.String()[:maxIndexedTextSize], nil
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package index
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+)
+
+func TestExtractPDFText(t *testing.T) {
+	data, err := os.ReadFile("../../internal/magic/testdata/magic.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := extractPDFText(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("extractPDFText: %v", err)
+	}
+	if len(text) == 0 {
+		t.Error("extractPDFText returned no text for a valid PDF")
+	}
+}
+
+func TestExtractPDFTextNotAPDF(t *testing.T) {
+	if _, err := extractPDFText(bytes.NewReader([]byte("this is not a PDF"))); err == nil {
+		t.Error("extractPDFText succeeded on non-PDF data; want error")
+	}
+}
+
+func TestIndexDocumentTextUnsupportedMIME(t *testing.T) {
+	data, err := os.ReadFile("../../internal/magic/testdata/magic.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mm := new(mutationMap)
+	indexDocumentText("application/msword", blob.RefFromString("whatever"), int64(len(data)), bytes.NewReader(data), mm)
+	if len(mm.kv) != 0 {
+		t.Errorf("indexDocumentText set %d keys for an unsupported MIME type; want 0", len(mm.kv))
+	}
+}
+
+func TestIndexDocumentTextPDF(t *testing.T) {
+	data, err := os.ReadFile("../../internal/magic/testdata/magic.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wholeRef := blob.RefFromString("whatever")
+	mm := new(mutationMap)
+	indexDocumentText("application/pdf", wholeRef, int64(len(data)), bytes.NewReader(data), mm)
+	if _, ok := mm.kv[keyFileText.Key(wholeRef)]; !ok {
+		t.Error("indexDocumentText did not set keyFileText for a valid PDF")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -288,10 +289,38 @@ func (ix *Index) ReceiveBlob(ctx context.Context, blobRef blob.Ref, source io.Re
 	return blob.SizedRef{Ref: blobRef, Size: uint32(written)}, nil
 }
 
-// commit writes the contents of the mutationMap on a batch
-// mutation and commits that batch. It also updates the deletes
-// cache.
+// commit writes the contents of the mutationMap to the index. If batching
+// is enabled (see Index.SetBatching), mm's mutations are added to the
+// pending batch, which is flushed once it reaches batchMaxMutations
+// key/value pairs or batchMaxDelay has elapsed, whichever comes first.
+// Otherwise, as when batching is disabled, mm is committed in its own
+// batch mutation immediately.
 func (ix *Index) commit(mm *mutationMap) error {
+	if ix.batchMaxMutations <= 0 {
+		return ix.commitNow(mm)
+	}
+
+	ix.batchMu.Lock()
+	defer ix.batchMu.Unlock()
+	if ix.batch == nil {
+		ix.batch = ix.s.BeginBatch()
+		ix.batchTimer = time.AfterFunc(ix.batchMaxDelay, ix.flushBatchOnTimer)
+	}
+	for k, v := range mm.kv {
+		ix.batch.Set(k, v)
+		ix.batchN++
+	}
+	ix.batchDeletes = append(ix.batchDeletes, mm.deletes...)
+	if ix.batchN >= ix.batchMaxMutations {
+		return ix.flushBatchLocked()
+	}
+	return nil
+}
+
+// commitNow writes the contents of the mutationMap on a batch
+// mutation and commits that batch immediately. It also updates the
+// deletes cache.
+func (ix *Index) commitNow(mm *mutationMap) error {
 	// We want the update of the deletes cache to be atomic
 	// with the transaction commit, so we lock here instead
 	// of within updateDeletesCache.
@@ -313,6 +342,53 @@ func (ix *Index) commit(mm *mutationMap) error {
 	return nil
 }
 
+// flushBatchLocked commits the pending batch, if any, and applies its
+// accumulated deletion claims to the deletes cache. ix.batchMu must be
+// held by the caller.
+func (ix *Index) flushBatchLocked() error {
+	if ix.batch == nil {
+		return nil
+	}
+	if ix.batchTimer != nil {
+		ix.batchTimer.Stop()
+	}
+	b, deletes := ix.batch, ix.batchDeletes
+	ix.batch, ix.batchN, ix.batchDeletes, ix.batchTimer = nil, 0, nil, nil
+
+	ix.deletes.Lock()
+	defer ix.deletes.Unlock()
+	if err := ix.s.CommitBatch(b); err != nil {
+		return err
+	}
+	for _, cl := range deletes {
+		if err := ix.updateDeletesCache(cl); err != nil {
+			return fmt.Errorf("Could not update the deletes cache after deletion from %v: %v", cl, err)
+		}
+	}
+	return nil
+}
+
+// flushBatchOnTimer is called by ix.batchTimer once batchMaxDelay has
+// elapsed since the oldest mutation in the pending batch, so a lull in
+// incoming blobs doesn't leave mutations unflushed indefinitely.
+func (ix *Index) flushBatchOnTimer() {
+	ix.batchMu.Lock()
+	defer ix.batchMu.Unlock()
+	if err := ix.flushBatchLocked(); err != nil {
+		log.Printf("index: error flushing batched mutations: %v", err)
+	}
+}
+
+// FlushBatch commits any mutations currently buffered by batching (see
+// Index.SetBatching) to the underlying storage. It's a no-op if batching
+// is disabled or there's nothing pending. Close calls this before
+// closing the underlying storage.
+func (ix *Index) FlushBatch() error {
+	ix.batchMu.Lock()
+	defer ix.batchMu.Unlock()
+	return ix.flushBatchLocked()
+}
+
 func (ix *Index) verifySignature(ctx context.Context, fetcher *missTrackFetcher, schemaBlob *schema.Blob) (*jsonsign.VerifyRequest, error) {
 	tf := &trackErrorsFetcher{f: fetcher}
 	vr := jsonsign.NewVerificationRequest(schemaBlob.JSON(), blob.NewSerialFetcher(ix.KeyFetcher, tf))
@@ -478,6 +554,76 @@ func readPrefixOrFile(prefix []byte, fetcher blob.Fetcher, b *schema.Blob, fn fu
 	return err
 }
 
+// numDominantColors is how many of an image's most common colors are
+// indexed, for the search package's "color:" predicate.
+const numDominantColors = 5
+
+// dominantColorsOfPrefix decodes the image described by b (trying
+// prefix first, then falling back to the whole file), downscaling it
+// for speed, and returns up to numDominantColors of its most common
+// colors as lowercase "RRGGBB" hex triplets, most common first. It
+// returns nil if the image couldn't be decoded.
+func dominantColorsOfPrefix(prefix []byte, fetcher blob.Fetcher, b *schema.Blob) []string {
+	var im image.Image
+	decode := func(r filePrefixReader) error {
+		var err error
+		im, _, err = images.Decode(r, &images.DecodeOpts{MaxWidth: 40, MaxHeight: 40})
+		return err
+	}
+	if err := readPrefixOrFile(prefix, fetcher, b, decode); err != nil {
+		if debugEnv {
+			log.Printf("index: WARNING: image dominant colors: %v", err)
+		}
+		return nil
+	}
+	return quantizeDominantColors(im, numDominantColors)
+}
+
+// quantizeDominantColors buckets im's pixels by reducing each 8-bit
+// RGB channel to its top 3 bits (a 512-color palette), and returns
+// the n most common non-transparent buckets, most common first, as
+// "RRGGBB" hex triplets naming each bucket's lower corner color.
+func quantizeDominantColors(im image.Image, n int) []string {
+	const dropBits = 5 // 8 - 3: how many low bits of each channel to discard
+	counts := make(map[uint32]int)
+	bounds := im.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, bl, a := im.At(x, y).RGBA()
+			if a>>8 < 0x80 {
+				continue // skip mostly-transparent pixels
+			}
+			key := uint32(r>>8)>>dropBits<<16 | uint32(g>>8)>>dropBits<<8 | uint32(bl>>8)>>dropBits
+			counts[key]++
+		}
+	}
+	type bucket struct {
+		key   uint32
+		count int
+	}
+	buckets := make([]bucket, 0, len(counts))
+	for k, c := range counts {
+		buckets = append(buckets, bucket{k, c})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].count != buckets[j].count {
+			return buckets[i].count > buckets[j].count
+		}
+		return buckets[i].key < buckets[j].key // stable, deterministic tie-break
+	})
+	if len(buckets) > n {
+		buckets = buckets[:n]
+	}
+	colors := make([]string, 0, len(buckets))
+	for _, buck := range buckets {
+		r := buck.key >> 16 & 0xff << dropBits
+		g := buck.key >> 8 & 0xff << dropBits
+		bl := buck.key & 0xff << dropBits
+		colors = append(colors, fmt.Sprintf("%02x%02x%02x", r, g, bl))
+	}
+	return colors
+}
+
 const msdosEpoch = "1980-01-01T00:00:00Z"
 
 var (
@@ -527,7 +673,8 @@ func (ix *Index) populateFile(ctx context.Context, fetcher blob.Fetcher, b *sche
 			return err
 		}
 		if err := readPrefixOrFile(imageBuf.Bytes, fetcher, b, decodeConfig); err == nil {
-			mm.Set(keyImageSize.Key(blobRef), keyImageSize.Val(fmt.Sprint(conf.Width), fmt.Sprint(conf.Height)))
+			colors := strings.Join(dominantColorsOfPrefix(imageBuf.Bytes, fetcher, b), ",")
+			mm.Set(keyImageSize.Key(blobRef), keyImageSize.Val(fmt.Sprint(conf.Width), fmt.Sprint(conf.Height), colors))
 		} else if debugEnv {
 			log.Printf("index: WARNING: image decodeConfig: %v", err)
 		}
@@ -552,6 +699,20 @@ func (ix *Index) populateFile(ctx context.Context, fetcher blob.Fetcher, b *sche
 			log.Printf("filename %q exif = %v, %v", b.FileName(), ft, err)
 		}
 
+		var takenTime time.Time
+		var haveTaken bool
+		exifTakenTime := func(r filePrefixReader) error {
+			var err error
+			takenTime, haveTaken, err = schema.EXIFTime(r)
+			return err
+		}
+		if err := readPrefixOrFile(exifData, fetcher, b, exifTakenTime); err != nil && debugEnv {
+			log.Printf("index: WARNING: image EXIFTime: %v", err)
+		}
+		if haveTaken {
+			mm.Set(keyEXIFTakenTime.Key(blobRef), keyEXIFTakenTime.Val(types.Time3339(takenTime).String()))
+		}
+
 		// TODO(mpl): find (generate?) more broken EXIF images to experiment with.
 		indexEXIFData := func(r filePrefixReader) error {
 			return indexEXIF(wholeRef, r, mm)
@@ -561,6 +722,8 @@ func (ix *Index) populateFile(ctx context.Context, fetcher blob.Fetcher, b *sche
 				log.Printf("error parsing EXIF: %v", err)
 			}
 		}
+
+		ix.detectFaces(ctx, wholeRef, imageBuf.Bytes)
 	}
 
 	var sortTimes []time.Time
@@ -594,6 +757,8 @@ func (ix *Index) populateFile(ctx context.Context, fetcher blob.Fetcher, b *sche
 		indexMusic(io.NewSectionReader(fr, 0, fr.Size()), wholeRef, mm)
 	}
 
+	indexDocumentText(mimeType, wholeRef, size, io.NewSectionReader(fr, 0, fr.Size()), mm)
+
 	return nil
 }
 
@@ -889,6 +1054,12 @@ func (ix *Index) populateClaim(ctx context.Context, fetcher *missTrackFetcher, b
 
 	pnbr := claim.ModifiedPermanode()
 	if !pnbr.Valid() {
+		if claim.ClaimType() == string(schema.ShareClaim) {
+			if target := claim.Target(); target.Valid() {
+				key := keyEdgeBackward.Key(target, br, br)
+				mm.Set(key, keyEdgeBackward.Val(string(schema.TypeClaim), ""))
+			}
+		}
 		// A different type of claim; not modifying a permanode.
 		return nil
 	}
@@ -203,6 +203,9 @@ func (b *Blob) ShareTarget() blob.Ref {
 // ModTime returns the "unixMtime" field, or the zero time.
 func (b *Blob) ModTime() time.Time { return b.ss.ModTime() }
 
+// CTime returns the "unixCtime" field, or the zero time. See superset.CTime.
+func (b *Blob) CTime() time.Time { return b.ss.CTime() }
+
 // A Claim is a Blob that is signed.
 type Claim struct {
 	b *Blob
@@ -525,6 +528,18 @@ func (bb *Builder) SetSymlinkTarget(target string) *Builder {
 	return bb
 }
 
+// UnsetUnixMetadata removes the unixPermission, unixOwnerId, unixOwner,
+// unixGroupId, and unixGroup fields populated by NewCommonFileMap. It's
+// for callers that don't want to expose a file's local permission and
+// ownership bits in the blob it produces. It leaves symlinkTarget and
+// unixMtime untouched.
+func (bb *Builder) UnsetUnixMetadata() *Builder {
+	for _, k := range []string{"unixPermission", "unixOwnerId", "unixOwner", "unixGroupId", "unixGroup"} {
+		delete(bb.m, k)
+	}
+	return bb
+}
+
 // IsClaimType returns whether this blob builder is for a type
 // which should be signed. (a "claim" or "permanode")
 func (bb *Builder) IsClaimType() bool {
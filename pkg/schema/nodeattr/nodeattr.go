@@ -117,6 +117,25 @@ const (
 	// visibility of the concerned permanode in the web UI.
 	DefaultVisibility = "camliDefVis"
 
+	// Hidden is "camliHidden". When set to "true" on a permanode, it
+	// marks that permanode (and, transitively, any permanode reachable
+	// only through it via camliMember or camliPath:* attributes) as
+	// hidden from normal browsing: search.PermanodeConstraint.SkipHidden
+	// excludes it, the same as the legacy camliDefVis=hide marker.
+	// Apps use it to keep internal/system objects out of a user's
+	// regular search and browse results.
+	Hidden = "camliHidden"
+
+	// Trashed is "camliTrashed". When set to an RFC 3339 timestamp on
+	// a permanode, it marks that permanode as moved to trash: like
+	// Hidden, search.PermanodeConstraint.SkipHidden excludes it (and,
+	// transitively, anything reachable only through it) from normal
+	// browsing. Unlike Hidden, the value records when the item was
+	// trashed, so a purge job can delete it once it's been trashed
+	// longer than a retention period. Removing the attribute restores
+	// the permanode.
+	Trashed = "camliTrashed"
+
 	// Version is http://schema.org/version
 	Version = "version"
 
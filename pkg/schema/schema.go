@@ -462,7 +462,16 @@ func (ss *superset) FileMode() os.FileMode {
 	if hasPerm {
 		m64, err := strconv.ParseUint(ss.UnixPermission, 8, 64)
 		if err == nil {
-			mode = mode | os.FileMode(m64)
+			mode |= os.FileMode(m64) & os.ModePerm
+			if m64&04000 != 0 {
+				mode |= os.ModeSetuid
+			}
+			if m64&02000 != 0 {
+				mode |= os.ModeSetgid
+			}
+			if m64&01000 != 0 {
+				mode |= os.ModeSticky
+			}
 		}
 	}
 
@@ -500,6 +509,14 @@ func (b *Blob) MapUid() int { return b.ss.MapUid() }
 // followed by just mapping the number through directly.
 func (b *Blob) MapGid() int { return b.ss.MapGid() }
 
+// HasUnixOwner reports whether the blob's schema recorded a Unix owner,
+// by name or numeric id.
+func (b *Blob) HasUnixOwner() bool { return b.ss.UnixOwner != "" || b.ss.UnixOwnerId != 0 }
+
+// HasUnixGroup reports whether the blob's schema recorded a Unix group,
+// by name or numeric id.
+func (b *Blob) HasUnixGroup() bool { return b.ss.UnixGroup != "" || b.ss.UnixGroupId != 0 }
+
 func (ss *superset) MapUid() int {
 	if ss.UnixOwner != "" {
 		uid, ok := getUidFromName(ss.UnixOwner)
@@ -531,6 +548,21 @@ func (ss *superset) ModTime() time.Time {
 	return t
 }
 
+// CTime returns the "unixCtime" field (the inode change time recorded at
+// upload time), or the zero time if it wasn't set. Not all uploaders record
+// it (see populateSchemaCtime), and it's only present when it differs from
+// the file's modtime.
+func (ss *superset) CTime() time.Time {
+	if ss.UnixCtime == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, ss.UnixCtime)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
 var DefaultStatHasher = &defaultStatHasher{}
 
 type defaultStatHasher struct{}
@@ -714,7 +746,7 @@ func NewCommonFileMap(fileName string, fi os.FileInfo) *Builder {
 	bb := newCommonFilenameMap(fileName)
 	// Common elements (from file-common.txt)
 	if fi.Mode()&os.ModeSymlink == 0 {
-		bb.m["unixPermission"] = fmt.Sprintf("0%o", fi.Mode().Perm())
+		bb.m["unixPermission"] = fmt.Sprintf("0%o", unixPermissionBits(fi.Mode()))
 	}
 
 	// OS-specific population; defined in schema_posix.go, etc. (not on App Engine)
@@ -728,6 +760,24 @@ func NewCommonFileMap(fileName string, fi os.FileInfo) *Builder {
 	return bb
 }
 
+// unixPermissionBits returns the traditional POSIX permission bits for
+// mode, as a uint32 suitable for octal formatting: the low 9 bits from
+// mode.Perm(), plus the setuid, setgid, and sticky bits, which os.FileMode
+// stores outside of Perm.
+func unixPermissionBits(mode os.FileMode) uint32 {
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= 01000
+	}
+	return perm
+}
+
 // PopulateParts sets the "parts" field of the blob with the provided
 // parts.  The sum of the sizes of parts must match the provided size
 // or an error is returned.  Also, each BytesPart may only contain either
@@ -987,20 +1037,30 @@ func findSize(v interface{}) (size int64, ok bool) {
 // It there was a valid EXIF but an error while trying to get a date from it,
 // it logs the error and tries the other methods.
 func FileTime(f io.ReaderAt) (time.Time, error) {
-	var ct time.Time
-	defaultTime := func() (time.Time, error) {
-		if osf, ok := f.(*os.File); ok {
-			fi, err := osf.Stat()
-			if err != nil {
-				return ct, fmt.Errorf("Failed to find a modtime: stat: %w", err)
-			}
-			return fi.ModTime(), nil
+	if ct, ok, err := EXIFTime(f); ok {
+		return ct, nil
+	} else if err == io.ErrUnexpectedEOF {
+		return time.Time{}, err
+	}
+	if osf, ok := f.(*os.File); ok {
+		fi, err := osf.Stat()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Failed to find a modtime: stat: %w", err)
 		}
-		return ct, errors.New("all methods failed to find a creation time or modtime")
+		return fi.ModTime(), nil
 	}
+	return time.Time{}, errors.New("all methods failed to find a creation time or modtime")
+}
 
-	size, ok := findSize(f)
-	if !ok {
+// EXIFTime returns the "date taken" of an image, as recorded in its EXIF
+// metadata (DateTimeOriginal, falling back to DateTime), without falling
+// back to the filesystem's modification time the way FileTime does.
+// ok is false if f has no image with a usable EXIF date. err is non-nil
+// only for io.ErrUnexpectedEOF, which callers should treat the way they
+// treat it from FileTime: as a signal to retry with a larger read.
+func EXIFTime(f io.ReaderAt) (ct time.Time, ok bool, err error) {
+	size, sok := findSize(f)
+	if !sok {
 		size = 256 << 10 // enough to get the EXIF
 	}
 	r := io.NewSectionReader(f, 0, size)
@@ -1009,34 +1069,34 @@ func FileTime(f io.ReaderAt) (time.Time, error) {
 	if err != nil {
 		tiffErr = err
 		if exif.IsShortReadTagValueError(err) {
-			return ct, io.ErrUnexpectedEOF
+			return ct, false, io.ErrUnexpectedEOF
 		}
 		if exif.IsCriticalError(err) || exif.IsExifError(err) {
-			return defaultTime()
+			return ct, false, nil
 		}
 	}
 	ct, err = ex.DateTime()
 	if err != nil {
-		return defaultTime()
+		return time.Time{}, false, nil
 	}
 	// If the EXIF file only had local timezone, but it did have
 	// GPS, then lookup the timezone and correct the time.
 	if ct.Location() == time.Local {
 		if exif.IsGPSError(tiffErr) {
 			log.Printf("Invalid EXIF GPS data: %v", tiffErr)
-			return ct, nil
+			return ct, true, nil
 		}
 		if lat, long, err := ex.LatLong(); err == nil {
 			if loc := lookupLocation(latlong.LookupZoneName(lat, long)); loc != nil {
 				if t, err := exifDateTimeInLocation(ex, loc); err == nil {
-					return t, nil
+					return t, true, nil
 				}
 			}
 		} else if !exif.IsTagNotPresentError(err) {
 			log.Printf("Invalid EXIF GPS data: %v", err)
 		}
 	}
-	return ct, nil
+	return ct, true, nil
 }
 
 // This is basically a copy of the exif.Exif.DateTime() method, except:
@@ -96,6 +96,59 @@ func TestSymlink(t *testing.T) {
 	}
 }
 
+func TestFileModeSetuidStickyRoundTrip(t *testing.T) {
+	tests := []os.FileMode{
+		0644,
+		0755,
+		0755 | os.ModeSetuid,
+		0755 | os.ModeSetgid,
+		01777 &^ os.ModeSticky | os.ModeSticky, // 1777, sticky world-writable dir
+		0700 | os.ModeSetuid | os.ModeSetgid | os.ModeSticky,
+	}
+	for _, mode := range tests {
+		bb := NewBuilder()
+		bb.m["camliType"] = string(TypeFile)
+		bb.m["unixPermission"] = fmt.Sprintf("0%o", unixPermissionBits(mode))
+		blob, err := BlobFromReader(blob.RefFromString("x"), strings.NewReader(mustJSON(t, bb)))
+		if err != nil {
+			t.Fatalf("mode %v: BlobFromReader: %v", mode, err)
+		}
+		got := blob.FileMode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		want := mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if got != want {
+			t.Errorf("mode %v: FileMode() = %v; want %v", mode, got, want)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, bb *Builder) string {
+	t.Helper()
+	s, err := bb.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestUnsetUnixMetadata(t *testing.T) {
+	fileName := "schema_test.go"
+	fi, err := os.Lstat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := NewCommonFileMap(fileName, fi)
+	bb.UnsetUnixMetadata()
+	json, err := bb.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"unixPermission", "unixOwnerId", "unixOwner", "unixGroupId", "unixGroup"} {
+		if strings.Contains(json, k) {
+			t.Errorf("JSON unexpectedly contains %s after UnsetUnixMetadata: [%s]", k, json)
+		}
+	}
+}
+
 func TestUtf8StrLen(t *testing.T) {
 	tests := []struct {
 		in   string
@@ -234,6 +287,26 @@ func TestBlobFromReader(t *testing.T) {
 	}
 }
 
+func TestCTime(t *testing.T) {
+	br := blob.MustParse("sha1-f1d2d2f924e986ac86fdf7b36c94bcdf32beec15")
+	blob, err := BlobFromReader(br, strings.NewReader(`{"camliVersion": 1, "camliType": "file", "unixCtime": "2011-06-23T04:34:00Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2011, 6, 23, 4, 34, 0, 0, time.UTC)
+	if got := blob.CTime(); !got.Equal(want) {
+		t.Errorf("CTime = %v; want %v", got, want)
+	}
+
+	blob, err = BlobFromReader(br, strings.NewReader(`{"camliVersion": 1, "camliType": "file"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := blob.CTime(); !got.IsZero() {
+		t.Errorf("CTime = %v; want zero time for a blob without unixCtime", got)
+	}
+}
+
 func TestAttribute(t *testing.T) {
 	tm := time.Unix(123, 456)
 	br := blob.MustParse("xxx-1234")
@@ -56,6 +56,11 @@ func (s *Signer) Entity() *openpgp.Entity {
 	return s.privEntity
 }
 
+// PublicKeyBlobRef returns the blobref of the signer's armored public key.
+func (s *Signer) PublicKeyBlobRef() blob.Ref {
+	return s.pubref
+}
+
 // NewSigner returns an Signer given an armored public key's blobref,
 // its armored content, and its associated private key entity.
 // The privateKeySource must be either an *openpgp.Entity or a string filename to a secret key.
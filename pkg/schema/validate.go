@@ -0,0 +1,116 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Validate parses data as a schema blob and reports whether it has the
+// fields required of its declared camliType, as documented in doc/schema.
+// It does not verify a signature (see VerifySignature for signed blobs) or
+// that any referenced blobs exist.
+//
+// Validate is intended for tools that construct schema blobs by hand (e.g.
+// without using Builder) and want to catch mistakes, such as a missing
+// required field or an unrecognized camliType, before publishing the blob.
+func Validate(data []byte) error {
+	ss, err := parseSuperset(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("schema: %v", err)
+	}
+	if ss.Version == 0 {
+		return ErrNoCamliVersion
+	}
+	switch ss.Type {
+	case TypePermanode:
+		// No required fields beyond camliVersion and camliType; see
+		// doc/schema/permanode.md.
+	case TypeClaim:
+		return validateClaim(ss)
+	case TypeFile, TypeDirectory, TypeSymlink, TypeFIFO, TypeSocket:
+		return validateFileCommon(ss)
+	case TypeStaticSet:
+		// Members and MergeSets are both optional (an empty static-set is
+		// valid); see doc/schema/static-set.md.
+	case TypeBytes:
+		// Parts may be empty (an empty "bytes" blob is valid); see
+		// doc/schema/bytes.md.
+	case TypeInode, TypeKeep:
+		// No additional required fields.
+	case "":
+		return fmt.Errorf("schema: missing required camliType")
+	default:
+		return fmt.Errorf("schema: unrecognized camliType %q", ss.Type)
+	}
+	return nil
+}
+
+// validateClaim checks the fields required of a "claim" blob. See
+// doc/schema/claims/ and, for the "share" claimType, doc/schema/share.md.
+func validateClaim(ss *superset) error {
+	if ss.ClaimType == "" {
+		return fmt.Errorf("schema: claim blob missing required claimType")
+	}
+	if time.Time(ss.ClaimDate).IsZero() {
+		return fmt.Errorf("schema: claim blob missing required claimDate")
+	}
+	switch ClaimType(ss.ClaimType) {
+	case SetAttributeClaim, AddAttributeClaim, DelAttributeClaim:
+		if !ss.Permanode.Valid() {
+			return fmt.Errorf("schema: %s claim missing required permaNode", ss.ClaimType)
+		}
+		if ss.Attribute == "" {
+			return fmt.Errorf("schema: %s claim missing required attribute", ss.ClaimType)
+		}
+	case DeleteClaim:
+		if !ss.Target.Valid() {
+			return fmt.Errorf("schema: delete claim missing required target")
+		}
+	case ShareClaim:
+		if ss.AuthType == "" {
+			return fmt.Errorf("schema: share claim missing required authType")
+		}
+		if !ss.Target.Valid() && ss.Search == nil {
+			return fmt.Errorf("schema: share claim missing required target or search")
+		}
+	}
+	return nil
+}
+
+// validateFileCommon checks the fields required of the file-like camliTypes
+// (file, directory, symlink, fifo, socket), which all share the common
+// fileName/permissions fields documented in doc/schema/file.md and
+// doc/schema/common.md.
+func validateFileCommon(ss *superset) error {
+	if ss.FileNameString() == "" {
+		return fmt.Errorf("schema: %s blob missing required fileName", ss.Type)
+	}
+	switch ss.Type {
+	case TypeDirectory:
+		if !ss.Entries.Valid() {
+			return fmt.Errorf("schema: directory blob missing required entries")
+		}
+	case TypeSymlink:
+		if ss.SymlinkTargetString() == "" {
+			return fmt.Errorf("schema: symlink blob missing required symlinkTarget")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"testing"
+
+	"perkeep.org/pkg/blob"
+)
+
+func TestValidateValid(t *testing.T) {
+	pn := blob.MustParse("sha224-0000000000000000000000000000000000000000000000000000000a")
+	tests := []struct {
+		name string
+		bb   *Builder
+	}{
+		{"permanode", NewUnsignedPermanode()},
+		{"set-attribute claim", NewSetAttributeClaim(pn, "tag", "foo")},
+		{"delete claim", NewDeleteClaim(pn)},
+		{"share", NewShareRef("haveref", true).SetShareTarget(pn)},
+		{"static-set", NewStaticSet()},
+		{"bytes", newBytes()},
+		{"file", NewFileMap("foo.txt")},
+		{"directory", NewDirMap("dir").PopulateDirectoryMap(pn)},
+	}
+	for _, tt := range tests {
+		j, err := tt.bb.JSON()
+		if err != nil {
+			t.Fatalf("%s: JSON: %v", tt.name, err)
+		}
+		if err := Validate([]byte(j)); err != nil {
+			t.Errorf("%s: Validate(%s) = %v; want nil", tt.name, j, err)
+		}
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"no camliVersion", `{"camliType": "permanode"}`},
+		{"no camliType", `{"camliVersion": 1}`},
+		{"unknown camliType", `{"camliVersion": 1, "camliType": "bogus"}`},
+		{"claim missing claimType", `{"camliVersion": 1, "camliType": "claim", "claimDate": "2011-01-01T00:00:00Z"}`},
+		{"claim missing claimDate", `{"camliVersion": 1, "camliType": "claim", "claimType": "set-attribute", "permaNode": "sha224-0000000000000000000000000000000000000000000000000000000a", "attribute": "tag", "value": "foo"}`},
+		{"set-attribute missing permaNode", `{"camliVersion": 1, "camliType": "claim", "claimType": "set-attribute", "claimDate": "2011-01-01T00:00:00Z", "attribute": "tag", "value": "foo"}`},
+		{"set-attribute missing attribute", `{"camliVersion": 1, "camliType": "claim", "claimType": "set-attribute", "claimDate": "2011-01-01T00:00:00Z", "permaNode": "sha224-0000000000000000000000000000000000000000000000000000000a", "value": "foo"}`},
+		{"delete claim missing target", `{"camliVersion": 1, "camliType": "claim", "claimType": "delete", "claimDate": "2011-01-01T00:00:00Z"}`},
+		{"share missing target and search", `{"camliVersion": 1, "camliType": "claim", "claimType": "share", "claimDate": "2011-01-01T00:00:00Z", "authType": "haveref"}`},
+		{"share missing authType", `{"camliVersion": 1, "camliType": "claim", "claimType": "share", "claimDate": "2011-01-01T00:00:00Z", "target": "sha224-0000000000000000000000000000000000000000000000000000000a"}`},
+		{"file missing fileName", `{"camliVersion": 1, "camliType": "file"}`},
+		{"directory missing entries", `{"camliVersion": 1, "camliType": "directory", "fileName": "dir"}`},
+		{"symlink missing symlinkTarget", `{"camliVersion": 1, "camliType": "symlink", "fileName": "link"}`},
+	}
+	for _, tt := range tests {
+		if err := Validate([]byte(tt.json)); err == nil {
+			t.Errorf("%s: Validate(%s) = nil; want an error", tt.name, tt.json)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"perkeep.org/internal/httputil"
+)
+
+// AttrNamesRequest is a request to get an AttrNamesResponse: the distinct
+// permanode attribute names currently in use, for populating things like
+// search-by-attribute UI.
+type AttrNamesRequest struct{}
+
+// FromHTTP parses req's JSON body into r. It's currently always empty, but
+// is a struct (rather than no argument at all) for consistency with the
+// rest of the package's request types, and to leave room to grow.
+func (r *AttrNamesRequest) FromHTTP(req *http.Request) error {
+	dec := json.NewDecoder(io.LimitReader(req.Body, 1<<20))
+	return dec.Decode(r)
+}
+
+// AttrNameCount is one attribute name and how many permanodes currently
+// have a value set for it, in an AttrNamesResponse.
+type AttrNameCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// AttrNamesResponse is the result of an AttrNames request. Names is sorted
+// alphabetically by Name.
+type AttrNamesResponse struct {
+	Names []AttrNameCount `json:"names"`
+}
+
+// AttrNames returns the distinct permanode attribute names currently in
+// use, and how many permanodes have each one set, so a client can build a
+// list of attributes to search or filter by without knowing them in
+// advance.
+//
+// AttrNames requires an in-memory index corpus; it returns an error if none
+// is available.
+func (h *Handler) AttrNames(ctx context.Context, req *AttrNamesRequest) (*AttrNamesResponse, error) {
+	corpus := h.corpus
+	if corpus == nil {
+		return nil, errors.New("search: AttrNames requires an in-memory index corpus")
+	}
+
+	res := &AttrNamesResponse{}
+	h.index.RLock()
+	defer h.index.RUnlock()
+	corpus.ForeachAttrName(func(name string, numPermanodes int) bool {
+		res.Names = append(res.Names, AttrNameCount{Name: name, Count: numPermanodes})
+		return true
+	})
+	sort.Slice(res.Names, func(i, j int) bool { return res.Names[i].Name < res.Names[j].Name })
+	return res, nil
+}
+
+// serveAttrNames is the HTTP handler for the "attrnames" endpoint.
+func (h *Handler) serveAttrNames(rw http.ResponseWriter, req *http.Request) {
+	defer httputil.RecoverJSON(rw, req)
+
+	var ar AttrNamesRequest
+	if err := ar.FromHTTP(req); err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	res, err := h.AttrNames(req.Context(), &ar)
+	if err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	httputil.ReturnJSON(rw, res)
+}
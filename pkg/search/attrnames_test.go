@@ -0,0 +1,60 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search_test
+
+import (
+	"testing"
+
+	. "perkeep.org/pkg/search"
+)
+
+func TestAttrNames(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+
+		p1 := id.NewPlannedPermanode("1")
+		id.SetAttribute(p1, "tag", "a")
+		p2 := id.NewPlannedPermanode("2")
+		id.SetAttribute(p2, "tag", "b")
+		id.SetAttribute(p2, "title", "hello")
+
+		res, err := qt.Handler().AttrNames(ctxbg, &AttrNamesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []AttrNameCount{
+			{Name: "tag", Count: 2},
+			{Name: "title", Count: 1},
+		}
+		if len(res.Names) != len(want) {
+			t.Fatalf("got %+v; want %+v", res.Names, want)
+		}
+		for i, nc := range res.Names {
+			if nc != want[i] {
+				t.Errorf("Names[%d] = %+v; want %+v", i, nc, want[i])
+			}
+		}
+	})
+}
+
+func TestAttrNamesRequiresCorpus(t *testing.T) {
+	testQueryTypes(t, []indexType{indexClassic}, func(qt *queryTest) {
+		if _, err := qt.Handler().AttrNames(ctxbg, &AttrNamesRequest{}); err == nil {
+			t.Error("AttrNames without a corpus: got nil error; want an error")
+		}
+	})
+}
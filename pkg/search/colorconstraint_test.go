@@ -0,0 +1,52 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import "testing"
+
+func TestColorConstraintPaletteMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       *ColorConstraint
+		palette []string
+		want    bool
+	}{
+		{"exact match", &ColorConstraint{Hex: "3366cc"}, []string{"3366cc"}, true},
+		{"within default tolerance", &ColorConstraint{Hex: "3366cc"}, []string{"3366d0"}, true},
+		{"outside default tolerance", &ColorConstraint{Hex: "000000"}, []string{"ffffff"}, false},
+		{"custom tolerance widens match", &ColorConstraint{Hex: "000000", MaxDistance: 500}, []string{"ffffff"}, true},
+		{"hash prefix on query color", &ColorConstraint{Hex: "#3366cc"}, []string{"3366cc"}, true},
+		{"no match in empty palette", &ColorConstraint{Hex: "3366cc"}, nil, false},
+		{"bogus palette entries are skipped", &ColorConstraint{Hex: "3366cc"}, []string{"not-a-color", "3366cc"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.paletteMatches(tt.palette); got != tt.want {
+				t.Errorf("paletteMatches(%v) = %v; want %v", tt.palette, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	if rgb, ok := parseHexColor("#ff8000"); !ok || rgb != [3]int{0xff, 0x80, 0x00} {
+		t.Errorf("parseHexColor(#ff8000) = %v, %v", rgb, ok)
+	}
+	if _, ok := parseHexColor("nope"); ok {
+		t.Error("parseHexColor(\"nope\") should fail")
+	}
+}
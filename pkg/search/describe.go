@@ -125,6 +125,16 @@ type DescribeRequest struct {
 	// longer match or internal limits are hit.
 	Rules []*DescribeRule `json:"rules,omitempty"`
 
+	// MemberDepth optionally limits how many levels of collection
+	// membership (the "camliMember" and "camliPath:*" attributes)
+	// are followed from the described blobs, independently of
+	// Depth. For example, MemberDepth 1 describes an album's
+	// direct photos but not those photos' own members, if any.
+	// It's a convenience over writing the equivalent Rules by
+	// hand, capped at maxMemberDepth. If Rules is also set,
+	// MemberDepth is ignored.
+	MemberDepth int `json:"memberDepth,omitempty"`
+
 	// Internal details, used while loading.
 	// Initialized by sh.initDescribeRequest.
 	sh            *Handler
@@ -530,6 +540,41 @@ func (h *Handler) initDescribeRequest(req *DescribeRequest) {
 	req.m = make(MetaMap)
 	req.errs = make(map[string]error)
 	req.wg = new(sync.WaitGroup)
+	if len(req.Rules) == 0 && req.MemberDepth > 0 {
+		req.Rules = []*DescribeRule{memberExpansionRule(req.MemberDepth)}
+	}
+}
+
+// maxMemberDepth caps DescribeRequest.MemberDepth, so a client can't
+// force an unbounded (or merely huge) chain of member-expansion rules.
+const maxMemberDepth = 10
+
+// memberAttrs are the attributes that make a permanode a container of
+// other permanodes: unnamed ("camliMember") and named
+// ("camliPath:"-prefixed) members. See DescribedBlob.Members.
+var memberAttrs = []string{"camliMember", "camliPath:*"}
+
+// memberExpansionRule returns a DescribeRule chain, depth levels deep,
+// that expands camliMember and camliPath:* attributes starting only
+// from the blobs in the original request (IfResultRoot), and no
+// further. That root restriction is what bounds the expansion to
+// depth levels: without it, a rule matches every permanode discovered
+// so far, including ones added by its own earlier matches, and the
+// fixed-point loop in expandRules would keep following membership
+// indefinitely (cycle-safe, since StartDescribe dedupes by blobref, but
+// not depth-limited).
+func memberExpansionRule(depth int) *DescribeRule {
+	if depth > maxMemberDepth {
+		depth = maxMemberDepth
+	}
+	root := &DescribeRule{IfResultRoot: true, Attrs: memberAttrs}
+	cur := root
+	for i := 1; i < depth; i++ {
+		child := &DescribeRule{Attrs: memberAttrs}
+		cur.Rules = []*DescribeRule{child}
+		cur = child
+	}
+	return root
 }
 
 type DescribeError map[string]error
@@ -0,0 +1,123 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"perkeep.org/internal/httputil"
+	"perkeep.org/pkg/blob"
+)
+
+// describeStreamBatchSize is how many blobs QueryDescribeStream describes
+// (and writes out) at a time, so a large result set is never fully
+// buffered in memory.
+const describeStreamBatchSize = 50
+
+// QueryDescribeStream runs sq and writes the full described metadata for
+// every matching blob to w, as a stream of newline-delimited JSON
+// DescribedBlob objects (one per line), in the query's result order.
+//
+// Unlike SearchQuery.Describe, which requires the entire result set's
+// descriptions to be built up in memory before the response can be
+// returned, QueryDescribeStream describes and writes results in small
+// batches, making it suitable for exporting large collections. sq.Describe,
+// if set, still controls the describe rules (Depth, Rules, MemberDepth,
+// etc.) applied to each result; its BlobRef and BlobRefs are ignored and
+// overwritten per batch.
+//
+// If w implements http.Flusher, it's flushed after each batch.
+func (h *Handler) QueryDescribeStream(ctx context.Context, sq *SearchQuery, w io.Writer) error {
+	dr := sq.Describe
+	if dr == nil {
+		dr = &DescribeRequest{}
+	}
+
+	// Run the query itself without its usual single-shot describe pass;
+	// we do our own, batched, below.
+	q := *sq
+	q.Describe = nil
+	res, err := h.Query(ctx, &q)
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	blobs := res.Blobs
+	for len(blobs) > 0 {
+		n := describeStreamBatchSize
+		if n > len(blobs) {
+			n = len(blobs)
+		}
+		batch := blobs[:n]
+		blobs = blobs[n:]
+
+		refs := make([]blob.Ref, len(batch))
+		for i, srb := range batch {
+			refs[i] = srb.Blob
+		}
+		dres, err := h.Describe(ctx, &DescribeRequest{
+			BlobRefs:       refs,
+			Depth:          dr.Depth,
+			MaxDirChildren: dr.MaxDirChildren,
+			At:             dr.At,
+			Rules:          dr.Rules,
+			MemberDepth:    dr.MemberDepth,
+		})
+		if err != nil {
+			return fmt.Errorf("describing batch: %v", err)
+		}
+		for _, ref := range refs {
+			db, ok := dres.Meta[ref.String()]
+			if !ok {
+				continue
+			}
+			if err := enc.Encode(db); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
+// serveDescribeByQuery is the HTTP handler for the "describebyquery"
+// endpoint: it runs the posted SearchQuery and streams the described
+// results, rather than returning them as one buffered JSON response like
+// "query" does. See QueryDescribeStream.
+func (h *Handler) serveDescribeByQuery(rw http.ResponseWriter, req *http.Request) {
+	defer httputil.RecoverJSON(rw, req)
+
+	var sq SearchQuery
+	if err := sq.FromHTTP(req); err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	if err := h.QueryDescribeStream(req.Context(), &sq, rw); err != nil {
+		log.Printf("describebyquery: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	. "perkeep.org/pkg/search"
+)
+
+func (qt *queryTest) wantDescribeStream(req *SearchQuery, wanted ...blob.Ref) {
+	if qt.itype == indexClassic {
+		req.Sort = Unsorted
+	}
+	var buf bytes.Buffer
+	if err := qt.Handler().QueryDescribeStream(ctxbg, req, &buf); err != nil {
+		qt.t.Fatal(err)
+	}
+
+	need := make(map[blob.Ref]bool)
+	for _, br := range wanted {
+		need[br] = true
+	}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var db DescribedBlob
+		if err := dec.Decode(&db); err != nil {
+			qt.t.Fatal(err)
+		}
+		if !need[db.BlobRef] {
+			qt.t.Errorf("unexpected described blob: %v", db.BlobRef)
+		} else {
+			delete(need, db.BlobRef)
+		}
+	}
+	for br := range need {
+		qt.t.Errorf("missing from described stream: %v", br)
+	}
+}
+
+func TestQueryDescribeStream(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		fileRef, wholeRef := qt.id.UploadFile("file.txt", "the content", time.Unix(1382073153, 0))
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Anything: true,
+			},
+			Describe: &DescribeRequest{},
+		}
+		qt.wantDescribeStream(sq, fileRef, wholeRef)
+	})
+}
@@ -356,6 +356,24 @@ var searchDescribeTests = []handlerTest{
 		}),
 		wantDescribed: []string{dbRefStr("set-0"), dbRefStr("venuepic-1"), dbRefStr("venuepic-2"), dbRefStr("somevenuepic-0"), dbRefStr("somevenuepic-2")},
 	},
+
+	{
+		name: "member depth 1 stops at direct members",
+		postBody: marshalJSON(&search.DescribeRequest{
+			BlobRef:     describedBlobs["set-0"],
+			MemberDepth: 1,
+		}),
+		wantDescribed: []string{dbRefStr("set-0"), dbRefStr("venuepic-1"), dbRefStr("venuepic-2")},
+	},
+
+	{
+		name: "member depth 2 follows one more level",
+		postBody: marshalJSON(&search.DescribeRequest{
+			BlobRef:     describedBlobs["fourvenue-123"],
+			MemberDepth: 2,
+		}),
+		wantDescribed: []string{dbRefStr("fourvenue-123"), dbRefStr("venuepicset-123"), dbRefStr("venuepic-1")},
+	},
 }
 
 func init() {
@@ -0,0 +1,104 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"sync"
+	"time"
+
+	"perkeep.org/internal/lru"
+	"perkeep.org/pkg/blob"
+)
+
+// DescribedCache is a size- and TTL-bounded LRU cache of DescribedBlob
+// values, keyed by blobref. It's meant to be shared by web-facing
+// handlers (such as the publisher) that repeatedly describe the same
+// blobs (e.g. each item of a gallery being rendered) and would
+// otherwise pay a describe round-trip for every one of them.
+//
+// A DescribedCache is safe for concurrent use.
+type DescribedCache struct {
+	ttl time.Duration // <= 0 means entries never expire on their own
+
+	mu    sync.Mutex
+	inner *lru.Cache // key -> *describedCacheEntry
+}
+
+type describedCacheEntry struct {
+	des     *DescribedBlob
+	expires time.Time // zero if ttl <= 0
+}
+
+// NewDescribedCache returns a DescribedCache holding at most maxEntries
+// entries (0 means unlimited, bounded only by ttl), each valid for at
+// most ttl after being added or refreshed (<= 0 means entries don't
+// expire on their own and are only evicted by LRU size pressure).
+func NewDescribedCache(maxEntries int, ttl time.Duration) *DescribedCache {
+	return &DescribedCache{
+		ttl:   ttl,
+		inner: lru.New(maxEntries),
+	}
+}
+
+// Get returns the cached description of br, if present and not
+// expired.
+func (c *DescribedCache) Get(br blob.Ref) (*DescribedBlob, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.inner.Get(br.String())
+	if !ok {
+		return nil, false
+	}
+	ent := v.(*describedCacheEntry)
+	if c.ttl > 0 && time.Now().After(ent.expires) {
+		return nil, false
+	}
+	return ent.des, true
+}
+
+// Add adds or refreshes the cached description of des.BlobRef,
+// resetting its TTL.
+func (c *DescribedCache) Add(des *DescribedBlob) {
+	if des == nil || !des.BlobRef.Valid() {
+		return
+	}
+	ent := &describedCacheEntry{des: des}
+	if c.ttl > 0 {
+		ent.expires = time.Now().Add(c.ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inner.Add(des.BlobRef.String(), ent)
+}
+
+// Invalidate removes br's cached description, if any. Callers should
+// use this whenever they know a blob's description may have changed
+// out from under the cache (for example, after applying a claim to a
+// permanode) instead of waiting out the TTL.
+func (c *DescribedCache) Invalidate(br blob.Ref) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inner.RemoveKey(br.String())
+}
+
+// Len returns the number of entries currently in the cache, including
+// any that have expired but not yet been evicted.
+func (c *DescribedCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inner.Len()
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"testing"
+	"time"
+
+	"perkeep.org/pkg/blob"
+)
+
+func TestDescribedCache(t *testing.T) {
+	br := blob.RefFromString("foo")
+	des := &DescribedBlob{BlobRef: br}
+
+	c := NewDescribedCache(0, 0)
+	if _, ok := c.Get(br); ok {
+		t.Fatal("expected miss before Add")
+	}
+	c.Add(des)
+	got, ok := c.Get(br)
+	if !ok || got != des {
+		t.Fatalf("Get = %v, %v; want %v, true", got, ok, des)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Len = %d; want 1", c.Len())
+	}
+
+	c.Invalidate(br)
+	if _, ok := c.Get(br); ok {
+		t.Fatal("expected miss after Invalidate")
+	}
+}
+
+func TestDescribedCacheTTL(t *testing.T) {
+	br := blob.RefFromString("foo")
+	des := &DescribedBlob{BlobRef: br}
+
+	c := NewDescribedCache(0, time.Nanosecond)
+	c.Add(des)
+	time.Sleep(time.Millisecond)
+	if _, ok := c.Get(br); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+}
+
+func TestDescribedCacheMaxEntries(t *testing.T) {
+	c := NewDescribedCache(1, 0)
+	br1 := blob.RefFromString("one")
+	br2 := blob.RefFromString("two")
+	c.Add(&DescribedBlob{BlobRef: br1})
+	c.Add(&DescribedBlob{BlobRef: br2})
+	if _, ok := c.Get(br1); ok {
+		t.Fatal("expected br1 to have been evicted")
+	}
+	if _, ok := c.Get(br2); !ok {
+		t.Fatal("expected br2 to still be cached")
+	}
+}
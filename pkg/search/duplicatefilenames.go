@@ -0,0 +1,106 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"perkeep.org/internal/httputil"
+	"perkeep.org/pkg/blob"
+)
+
+// DuplicateFileNamesRequest is a request to get a
+// DuplicateFileNamesResponse.
+type DuplicateFileNamesRequest struct {
+	// FoldCase, if true, groups filenames that differ only by case
+	// (e.g. "IMG_1.JPG" and "img_1.jpg") instead of requiring an exact
+	// match.
+	FoldCase bool `json:"foldCase,omitempty"`
+}
+
+// FromHTTP parses req's JSON body into r.
+func (r *DuplicateFileNamesRequest) FromHTTP(req *http.Request) error {
+	dec := json.NewDecoder(io.LimitReader(req.Body, 1<<20))
+	return dec.Decode(r)
+}
+
+// DuplicateFileNameGroup is a base filename and the file or directory
+// blobs that share it, in a DuplicateFileNamesResponse.
+type DuplicateFileNameGroup struct {
+	FileName string     `json:"fileName"`
+	Files    []blob.Ref `json:"files"`
+}
+
+// DuplicateFileNamesResponse is the result of a DuplicateFileNames
+// request. Groups is sorted alphabetically by FileName.
+type DuplicateFileNamesResponse struct {
+	Groups []DuplicateFileNameGroup `json:"groups"`
+}
+
+// DuplicateFileNames reports the base filenames shared by more than one
+// file or directory blob, so a user can spot accidental re-imports or
+// naming conflicts that content-based (wholeRef) dedup wouldn't catch,
+// since the files in a group may have entirely different contents.
+//
+// Only base filenames are considered (e.g. "report.pdf", never a
+// path like "docs/report.pdf"), since that's all Perkeep's file schema
+// records; two files with the same base name in different logical
+// directories are still reported as a group.
+//
+// DuplicateFileNames requires an in-memory index corpus; it returns an
+// error if none is available.
+func (h *Handler) DuplicateFileNames(ctx context.Context, req *DuplicateFileNamesRequest) (*DuplicateFileNamesResponse, error) {
+	corpus := h.corpus
+	if corpus == nil {
+		return nil, errors.New("search: DuplicateFileNames requires an in-memory index corpus")
+	}
+
+	res := &DuplicateFileNamesResponse{}
+	h.index.RLock()
+	defer h.index.RUnlock()
+	corpus.ForeachDuplicateFileName(func(filename string, files []blob.Ref) bool {
+		res.Groups = append(res.Groups, DuplicateFileNameGroup{FileName: filename, Files: files})
+		return true
+	}, req.FoldCase)
+	sort.Slice(res.Groups, func(i, j int) bool { return res.Groups[i].FileName < res.Groups[j].FileName })
+	return res, nil
+}
+
+// serveDuplicateFileNames is the HTTP handler for the
+// "duplicatefilenames" endpoint.
+func (h *Handler) serveDuplicateFileNames(rw http.ResponseWriter, req *http.Request) {
+	defer httputil.RecoverJSON(rw, req)
+
+	var dr DuplicateFileNamesRequest
+	if err := dr.FromHTTP(req); err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	res, err := h.DuplicateFileNames(req.Context(), &dr)
+	if err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	httputil.ReturnJSON(rw, res)
+}
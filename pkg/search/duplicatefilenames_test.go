@@ -0,0 +1,91 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search_test
+
+import (
+	"testing"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	. "perkeep.org/pkg/search"
+)
+
+func TestDuplicateFileNames(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+		now := time.Unix(1382073153, 0)
+
+		f1, _ := id.UploadFile("report.pdf", "one", now)
+		f2, _ := id.UploadFile("report.pdf", "two, different content", now)
+		id.UploadFile("unique.txt", "only one of these", now)
+
+		res, err := qt.Handler().DuplicateFileNames(ctxbg, &DuplicateFileNamesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Groups) != 1 {
+			t.Fatalf("got %d groups; want 1: %+v", len(res.Groups), res.Groups)
+		}
+		g := res.Groups[0]
+		if g.FileName != "report.pdf" {
+			t.Errorf("group name = %q; want %q", g.FileName, "report.pdf")
+		}
+		want := map[blob.Ref]bool{f1: true, f2: true}
+		if len(g.Files) != 2 {
+			t.Fatalf("group files = %v; want 2 entries", g.Files)
+		}
+		for _, f := range g.Files {
+			if !want[f] {
+				t.Errorf("unexpected file %v in group", f)
+			}
+		}
+	})
+}
+
+func TestDuplicateFileNamesFoldCase(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+		now := time.Unix(1382073153, 0)
+
+		id.UploadFile("IMG_1.JPG", "one", now)
+		id.UploadFile("img_1.jpg", "two", now)
+
+		res, err := qt.Handler().DuplicateFileNames(ctxbg, &DuplicateFileNamesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Groups) != 0 {
+			t.Fatalf("without FoldCase, got %d groups; want 0: %+v", len(res.Groups), res.Groups)
+		}
+
+		res, err = qt.Handler().DuplicateFileNames(ctxbg, &DuplicateFileNamesRequest{FoldCase: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Groups) != 1 {
+			t.Fatalf("with FoldCase, got %d groups; want 1: %+v", len(res.Groups), res.Groups)
+		}
+	})
+}
+
+func TestDuplicateFileNamesRequiresCorpus(t *testing.T) {
+	testQueryTypes(t, []indexType{indexClassic}, func(qt *queryTest) {
+		if _, err := qt.Handler().DuplicateFileNames(ctxbg, &DuplicateFileNamesRequest{}); err == nil {
+			t.Error("DuplicateFileNames without a corpus: got nil error; want an error")
+		}
+	})
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"perkeep.org/internal/httputil"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/types/camtypes"
+)
+
+// eventClusterBatchSize is how many matched blobs EventCluster describes
+// at a time, so clustering a large result set doesn't require holding
+// every match's description in memory at once.
+const eventClusterBatchSize = 500
+
+// eventClusterDescribeDepth is the DescribeRequest depth used to fetch
+// each match along with its camliContent, so a permanode's underlying
+// file (and its EXIF time and location) is described in the same batch.
+const eventClusterDescribeDepth = 2
+
+// EventClusterRequest requests a temporal clustering of a result set into
+// "events" (e.g. a party or a trip), by grouping items whose times are
+// close together.
+type EventClusterRequest struct {
+	// Constraint selects which permanodes to consider. Required.
+	Constraint *Constraint `json:"constraint"`
+
+	// GapMinutes is the maximum gap, in minutes, between two
+	// consecutive items (sorted by time) for them to belong to the same
+	// event. A new event starts whenever the gap since the previous
+	// item exceeds this. Must be positive.
+	GapMinutes int `json:"gapMinutes"`
+
+	// MaxDistanceMeters, if positive, also starts a new event whenever
+	// two consecutive items are both location-tagged and more than this
+	// many meters apart, even if within GapMinutes of each other. Items
+	// with no known location never split an event on distance grounds.
+	MaxDistanceMeters float64 `json:"maxDistanceMeters,omitempty"`
+}
+
+// FromHTTP parses req's JSON body into r.
+func (r *EventClusterRequest) FromHTTP(req *http.Request) error {
+	dec := json.NewDecoder(io.LimitReader(req.Body, 1<<20))
+	return dec.Decode(r)
+}
+
+// Event is one cluster of temporally (and optionally spatially) nearby
+// matches.
+type Event struct {
+	// Blobs are the matches in this event, in ascending time order.
+	Blobs []blob.Ref `json:"blobs"`
+
+	// Start and End are the times of the first and last blob in the event.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Center is the average location of the event's blobs that have a
+	// known location, or nil if none do.
+	Center *camtypes.Location `json:"center,omitempty"`
+}
+
+// EventClusterResponse is the result of an EventCluster query.
+type EventClusterResponse struct {
+	// Events are the discovered events, in ascending time order.
+	Events []*Event `json:"events"`
+
+	// Undated holds the matches for which no time could be determined,
+	// and which therefore couldn't be placed into an event.
+	Undated []blob.Ref `json:"undated,omitempty"`
+}
+
+// EventCluster groups the permanodes matching req.Constraint into
+// "events": runs of items sorted by time where consecutive items are no
+// more than req.GapMinutes apart (and, if req.MaxDistanceMeters is set,
+// no further apart than that in space). Items without a known time are
+// reported separately in EventClusterResponse.Undated rather than being
+// dropped or arbitrarily assigned to an event.
+func (h *Handler) EventCluster(ctx context.Context, req *EventClusterRequest) (*EventClusterResponse, error) {
+	if req.Constraint == nil {
+		return nil, errors.New("search: EventClusterRequest.Constraint is required")
+	}
+	if req.GapMinutes <= 0 {
+		return nil, errors.New("search: EventClusterRequest.GapMinutes must be positive")
+	}
+	gap := time.Duration(req.GapMinutes) * time.Minute
+
+	// Order doesn't matter here: results are explicitly sorted by time
+	// below, and Unsorted avoids requiring a corpus, which not all index
+	// implementations have.
+	res, err := h.Query(ctx, &SearchQuery{Constraint: req.Constraint, Limit: -1, Sort: Unsorted})
+	if err != nil {
+		return nil, err
+	}
+
+	type item struct {
+		ref blob.Ref
+		t   time.Time
+		loc *camtypes.Location
+	}
+	var items []item
+	resp := &EventClusterResponse{}
+
+	blobs := res.Blobs
+	for len(blobs) > 0 {
+		n := eventClusterBatchSize
+		if n > len(blobs) {
+			n = len(blobs)
+		}
+		batch := blobs[:n]
+		blobs = blobs[n:]
+
+		refs := make([]blob.Ref, len(batch))
+		for i, srb := range batch {
+			refs[i] = srb.Blob
+		}
+		dres, err := h.Describe(ctx, &DescribeRequest{BlobRefs: refs, Depth: eventClusterDescribeDepth})
+		if err != nil {
+			return nil, fmt.Errorf("describing batch: %v", err)
+		}
+		for _, ref := range refs {
+			db, ok := dres.Meta[ref.String()]
+			if !ok {
+				continue
+			}
+			t := describedBlobTime(db)
+			if t.IsZero() {
+				resp.Undated = append(resp.Undated, ref)
+				continue
+			}
+			items = append(items, item{ref: ref, t: t, loc: describedBlobLocation(db)})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].t.Before(items[j].t) })
+	sort.Slice(resp.Undated, func(i, j int) bool { return resp.Undated[i].String() < resp.Undated[j].String() })
+
+	var cur *Event
+	var sumLat, sumLong float64
+	var nLoc int
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if nLoc > 0 {
+			cur.Center = &camtypes.Location{
+				Latitude:  sumLat / float64(nLoc),
+				Longitude: camtypes.Longitude(sumLong / float64(nLoc)).WrapTo180(),
+			}
+		}
+		resp.Events = append(resp.Events, cur)
+	}
+	var prev *item
+	for i := range items {
+		it := &items[i]
+		startNew := cur == nil
+		if !startNew {
+			if it.t.Sub(prev.t) > gap {
+				startNew = true
+			} else if req.MaxDistanceMeters > 0 && it.loc != nil && prev.loc != nil &&
+				locationDistanceMeters(*prev.loc, *it.loc) > req.MaxDistanceMeters {
+				startNew = true
+			}
+		}
+		if startNew {
+			flush()
+			cur = &Event{Start: it.t}
+			sumLat, sumLong, nLoc = 0, 0, 0
+		}
+		cur.Blobs = append(cur.Blobs, it.ref)
+		cur.End = it.t
+		if it.loc != nil {
+			sumLat += it.loc.Latitude
+			sumLong += float64(it.loc.Longitude)
+			nLoc++
+		}
+		prev = it
+	}
+	flush()
+
+	return resp, nil
+}
+
+// describedBlobTime returns the best-known time for db: a file's own EXIF
+// or modification time, or (for a permanode) its camliContent file's
+// time, falling back to the permanode's last modification time. It
+// returns the zero Time if none of those are known.
+func describedBlobTime(db *DescribedBlob) time.Time {
+	if db == nil {
+		return time.Time{}
+	}
+	if db.File != nil && db.File.Time != nil {
+		return db.File.Time.Time()
+	}
+	if cref, ok := db.ContentRef(); ok {
+		if cdb := db.PeerBlob(cref); cdb.File != nil && cdb.File.Time != nil {
+			return cdb.File.Time.Time()
+		}
+	}
+	if db.Permanode != nil && !db.Permanode.ModTime.IsZero() {
+		return db.Permanode.ModTime
+	}
+	return time.Time{}
+}
+
+// describedBlobLocation returns db's own Location if known, or else its
+// camliContent peer's Location, or nil if neither is known.
+func describedBlobLocation(db *DescribedBlob) *camtypes.Location {
+	if db == nil {
+		return nil
+	}
+	if db.Location != nil {
+		return db.Location
+	}
+	if cref, ok := db.ContentRef(); ok {
+		if cdb := db.PeerBlob(cref); cdb.Location != nil {
+			return cdb.Location
+		}
+	}
+	return nil
+}
+
+// earthRadiusMeters is the mean radius of the Earth, used by
+// locationDistanceMeters.
+const earthRadiusMeters = 6371000.0
+
+// locationDistanceMeters returns the great-circle distance between a and
+// b, using the haversine formula.
+func locationDistanceMeters(a, b camtypes.Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLong := (float64(b.Longitude) - float64(a.Longitude)) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// serveEventCluster is the HTTP handler for the "eventcluster" endpoint.
+func (h *Handler) serveEventCluster(rw http.ResponseWriter, req *http.Request) {
+	defer httputil.RecoverJSON(rw, req)
+
+	var er EventClusterRequest
+	if err := er.FromHTTP(req); err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	res, err := h.EventCluster(req.Context(), &er)
+	if err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	httputil.ReturnJSON(rw, res)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search_test
+
+import (
+	"testing"
+	"time"
+
+	. "perkeep.org/pkg/search"
+)
+
+func TestEventCluster(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+
+		mk := func(name string, t time.Time) {
+			fileRef, _ := id.UploadFile(name, "contents of "+name, t)
+			pn := id.NewPlannedPermanode(name)
+			id.SetAttribute(pn, "camliContent", fileRef.String())
+		}
+
+		base := time.Unix(1000000, 0)
+		// Two photos a minute apart: one event.
+		mk("a.jpg", base)
+		mk("b.jpg", base.Add(time.Minute))
+		// A third photo an hour later: a new event.
+		mk("c.jpg", base.Add(time.Hour))
+		// A permanode with no time at all: reported as undated.
+		id.NewPlannedPermanode("no-time")
+
+		res, err := qt.Handler().EventCluster(ctxbg, &EventClusterRequest{
+			Constraint: &Constraint{Permanode: &PermanodeConstraint{}},
+			GapMinutes: 10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Events) != 2 {
+			t.Fatalf("got %d events; want 2: %+v", len(res.Events), res.Events)
+		}
+		if len(res.Events[0].Blobs) != 2 {
+			t.Errorf("first event has %d blobs; want 2", len(res.Events[0].Blobs))
+		}
+		if len(res.Events[1].Blobs) != 1 {
+			t.Errorf("second event has %d blobs; want 1", len(res.Events[1].Blobs))
+		}
+		if len(res.Undated) != 1 {
+			t.Errorf("got %d undated blobs; want 1: %+v", len(res.Undated), res.Undated)
+		}
+	})
+}
+
+func TestEventClusterRequiresConstraint(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		if _, err := qt.Handler().EventCluster(ctxbg, &EventClusterRequest{GapMinutes: 10}); err == nil {
+			t.Error("EventCluster with nil Constraint: got nil error; want an error")
+		}
+	})
+}
+
+func TestEventClusterRequiresPositiveGap(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		req := &EventClusterRequest{Constraint: &Constraint{Permanode: &PermanodeConstraint{}}}
+		if _, err := qt.Handler().EventCluster(ctxbg, req); err == nil {
+			t.Error("EventCluster with zero GapMinutes: got nil error; want an error")
+		}
+	})
+}
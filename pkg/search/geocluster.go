@@ -0,0 +1,261 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"perkeep.org/internal/httputil"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/types/camtypes"
+)
+
+// geoClusterMaxZoom bounds GeoClusterRequest.Zoom, so a client-supplied
+// zoom level can't make GeoCluster build an enormous in-memory grid.
+const geoClusterMaxZoom = 12
+
+// geoClusterBatchSize is how many matched blobs GeoCluster describes at
+// a time, so clustering a viewport with many results doesn't require
+// holding every match's description in memory at once.
+const geoClusterBatchSize = 500
+
+// GeoClusterRequest requests a spatial clustering of GPS-tagged
+// permanodes (e.g. photos) within a map viewport, so a map UI can
+// render cluster markers without fetching every individual point.
+type GeoClusterRequest struct {
+	// Constraint, if non-nil, further restricts which permanodes are
+	// considered (e.g. by tag or owner). It is ANDed with a location
+	// constraint built from Bounds, so a Permanode.Location of its own,
+	// if set, applies as well.
+	Constraint *Constraint `json:"constraint,omitempty"`
+
+	// Bounds is the map viewport to cluster over. As with
+	// LocationConstraint, West may be greater than East to indicate a
+	// viewport spanning the antimeridian.
+	Bounds camtypes.LocationBounds `json:"bounds"`
+
+	// Zoom is the map's zoom level, used to size the clustering grid:
+	// Bounds is divided into a (2^Zoom)x(2^Zoom) grid of cells, similar
+	// to standard web map tile schemes. Zoom is clamped to
+	// [0, geoClusterMaxZoom].
+	Zoom int `json:"zoom"`
+}
+
+// FromHTTP parses req's JSON body into r.
+func (r *GeoClusterRequest) FromHTTP(req *http.Request) error {
+	dec := json.NewDecoder(io.LimitReader(req.Body, 1<<20))
+	return dec.Decode(r)
+}
+
+// GeoCluster is one non-empty cell of a GeoClusterResponse's grid.
+type GeoCluster struct {
+	// Bounds is the cell's area, a subdivision of the request's Bounds.
+	Bounds camtypes.LocationBounds `json:"bounds"`
+
+	// Center is the average location of the matched permanodes in this
+	// cell, suitable for placing a cluster marker.
+	Center camtypes.Location `json:"center"`
+
+	// Count is the number of matched permanodes in this cell.
+	Count int `json:"count"`
+}
+
+// GeoClusterResponse is the result of a GeoCluster query. Cells of the
+// grid with no matches are omitted, so Clusters may be empty (with a
+// nil error) if no permanode in Bounds has a known location.
+type GeoClusterResponse struct {
+	Clusters []*GeoCluster `json:"clusters"`
+}
+
+// GeoCluster buckets the locations of permanodes matching req into a
+// grid of cells covering req.Bounds, at a resolution determined by
+// req.Zoom, for rendering map cluster markers without fetching every
+// individual point.
+func (h *Handler) GeoCluster(ctx context.Context, req *GeoClusterRequest) (*GeoClusterResponse, error) {
+	if req.Bounds == (camtypes.LocationBounds{}) {
+		return nil, errors.New("search: GeoClusterRequest.Bounds is required")
+	}
+	zoom := req.Zoom
+	if zoom < 0 {
+		zoom = 0
+	} else if zoom > geoClusterMaxZoom {
+		zoom = geoClusterMaxZoom
+	}
+	grid := newGeoGrid(req.Bounds, 1<<uint(zoom))
+
+	locConstraint := &Constraint{
+		Permanode: &PermanodeConstraint{
+			Location: &LocationConstraint{
+				North: req.Bounds.North,
+				South: req.Bounds.South,
+				East:  req.Bounds.East,
+				West:  req.Bounds.West,
+			},
+		},
+	}
+	constraint := locConstraint
+	if req.Constraint != nil {
+		constraint = &Constraint{
+			Logical: &LogicalConstraint{Op: "and", A: req.Constraint, B: locConstraint},
+		}
+	}
+
+	// Order doesn't matter for clustering, and Unsorted avoids requiring
+	// a corpus, which not all index implementations have.
+	res, err := h.Query(ctx, &SearchQuery{Constraint: constraint, Limit: -1, Sort: Unsorted})
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make(map[int]*geoClusterAccum)
+	blobs := res.Blobs
+	for len(blobs) > 0 {
+		n := geoClusterBatchSize
+		if n > len(blobs) {
+			n = len(blobs)
+		}
+		batch := blobs[:n]
+		blobs = blobs[n:]
+
+		refs := make([]blob.Ref, len(batch))
+		for i, srb := range batch {
+			refs[i] = srb.Blob
+		}
+		dres, err := h.Describe(ctx, &DescribeRequest{BlobRefs: refs})
+		if err != nil {
+			return nil, fmt.Errorf("describing batch: %v", err)
+		}
+		for _, ref := range refs {
+			db, ok := dres.Meta[ref.String()]
+			if !ok || db.Location == nil {
+				continue
+			}
+			cell, ok := grid.cellOf(*db.Location)
+			if !ok {
+				continue
+			}
+			acc := cells[cell]
+			if acc == nil {
+				acc = &geoClusterAccum{bounds: grid.cellBounds(cell)}
+				cells[cell] = acc
+			}
+			acc.count++
+			acc.sumLat += db.Location.Latitude
+			acc.sumLong += db.Location.Longitude
+		}
+	}
+
+	resp := &GeoClusterResponse{}
+	for _, acc := range cells {
+		resp.Clusters = append(resp.Clusters, &GeoCluster{
+			Bounds: acc.bounds,
+			Center: camtypes.Location{
+				Latitude:  acc.sumLat / float64(acc.count),
+				Longitude: camtypes.Longitude(acc.sumLong / float64(acc.count)).WrapTo180(),
+			},
+			Count: acc.count,
+		})
+	}
+	return resp, nil
+}
+
+// serveGeoCluster is the HTTP handler for the "geocluster" endpoint.
+func (h *Handler) serveGeoCluster(rw http.ResponseWriter, req *http.Request) {
+	defer httputil.RecoverJSON(rw, req)
+
+	var gr GeoClusterRequest
+	if err := gr.FromHTTP(req); err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	res, err := h.GeoCluster(req.Context(), &gr)
+	if err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+
+	httputil.ReturnJSON(rw, res)
+}
+
+// geoClusterAccum accumulates the matches falling into one grid cell.
+type geoClusterAccum struct {
+	bounds          camtypes.LocationBounds
+	count           int
+	sumLat, sumLong float64
+}
+
+// geoGrid divides a LocationBounds area into dim*dim cells, handling
+// areas that span the antimeridian (bounds.West > bounds.East) by
+// treating longitude as increasing eastward from West, wrapping at
+// 180°.
+type geoGrid struct {
+	bounds     camtypes.LocationBounds
+	dim        int
+	cellWidth  float64 // degrees of (possibly wrapped) longitude
+	cellHeight float64 // degrees of latitude
+}
+
+func newGeoGrid(bounds camtypes.LocationBounds, dim int) *geoGrid {
+	return &geoGrid{
+		bounds:     bounds,
+		dim:        dim,
+		cellWidth:  bounds.Width() / float64(dim),
+		cellHeight: (bounds.North - bounds.South) / float64(dim),
+	}
+}
+
+// cellOf returns the index of the cell containing loc, and whether loc
+// is within the grid's bounds at all.
+func (g *geoGrid) cellOf(loc camtypes.Location) (cell int, ok bool) {
+	if !g.bounds.Contains(loc) || loc.Latitude > g.bounds.North || loc.Latitude < g.bounds.South {
+		return 0, false
+	}
+	dlong := loc.Longitude - g.bounds.West
+	if dlong < 0 {
+		dlong += 360
+	}
+	x := int(dlong / g.cellWidth)
+	y := int((g.bounds.North - loc.Latitude) / g.cellHeight)
+	if x >= g.dim {
+		x = g.dim - 1
+	}
+	if y >= g.dim {
+		y = g.dim - 1
+	}
+	return y*g.dim + x, true
+}
+
+// cellBounds returns the geographic bounds of the given cell index, as
+// returned by a prior call to cellOf.
+func (g *geoGrid) cellBounds(cell int) camtypes.LocationBounds {
+	x := cell % g.dim
+	y := cell / g.dim
+	west := camtypes.Longitude(g.bounds.West + float64(x)*g.cellWidth).WrapTo180()
+	east := camtypes.Longitude(g.bounds.West + float64(x+1)*g.cellWidth).WrapTo180()
+	return camtypes.LocationBounds{
+		North: g.bounds.North - float64(y)*g.cellHeight,
+		South: g.bounds.North - float64(y+1)*g.cellHeight,
+		West:  west,
+		East:  east,
+	}
+}
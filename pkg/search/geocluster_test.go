@@ -0,0 +1,97 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search_test
+
+import (
+	"testing"
+
+	. "perkeep.org/pkg/search"
+	"perkeep.org/pkg/types/camtypes"
+)
+
+func TestGeoCluster(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+
+		p1 := id.NewPlannedPermanode("1")
+		id.SetAttribute(p1, "latitude", "10")
+		id.SetAttribute(p1, "longitude", "10")
+		p2 := id.NewPlannedPermanode("2")
+		id.SetAttribute(p2, "latitude", "10")
+		id.SetAttribute(p2, "longitude", "10")
+		p3 := id.NewPlannedPermanode("3")
+		id.SetAttribute(p3, "latitude", "-10")
+		id.SetAttribute(p3, "longitude", "-10")
+
+		// A permanode with no location shouldn't affect the clustering.
+		id.NewPlannedPermanode("no-location")
+
+		req := &GeoClusterRequest{
+			Bounds: camtypes.LocationBounds{North: 20, South: -20, West: -20, East: 20},
+			Zoom:   1,
+		}
+		res, err := qt.Handler().GeoCluster(ctxbg, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Clusters) != 2 {
+			t.Fatalf("got %d clusters; want 2: %+v", len(res.Clusters), res.Clusters)
+		}
+		byCount := make(map[int]*GeoCluster)
+		for _, c := range res.Clusters {
+			byCount[c.Count] = c
+		}
+		big, ok := byCount[2]
+		if !ok {
+			t.Fatalf("no cluster with count 2: %+v", res.Clusters)
+		}
+		if big.Center.Latitude != 10 || big.Center.Longitude != 10 {
+			t.Errorf("2-count cluster center = %+v; want (10, 10)", big.Center)
+		}
+		small, ok := byCount[1]
+		if !ok {
+			t.Fatalf("no cluster with count 1: %+v", res.Clusters)
+		}
+		if small.Center.Latitude != -10 || small.Center.Longitude != -10 {
+			t.Errorf("1-count cluster center = %+v; want (-10, -10)", small.Center)
+		}
+	})
+}
+
+func TestGeoClusterEmptyRegion(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		req := &GeoClusterRequest{
+			Bounds: camtypes.LocationBounds{North: 89, South: 88, West: 1, East: 2},
+			Zoom:   3,
+		}
+		res, err := qt.Handler().GeoCluster(ctxbg, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Clusters) != 0 {
+			t.Errorf("got %d clusters in an empty region; want 0", len(res.Clusters))
+		}
+	})
+}
+
+func TestGeoClusterRequiresBounds(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		if _, err := qt.Handler().GeoCluster(ctxbg, &GeoClusterRequest{}); err == nil {
+			t.Error("GeoCluster with zero Bounds: got nil error; want an error")
+		}
+	})
+}
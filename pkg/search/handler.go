@@ -26,10 +26,12 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go4.org/jsonconfig"
@@ -58,6 +60,84 @@ func init() {
 	blobserver.RegisterHandlerConstructor("search", newHandlerFromConfig)
 }
 
+// queryQueueWait bounds how long a query waits for a free queryLimiter
+// slot before its request is rejected with a 503.
+const queryQueueWait = 5 * time.Second
+
+// queryLimiter bounds the number of concurrently in-flight top-level HTTP
+// search queries, with a separate (typically larger) limit for queries the
+// in-memory corpus can answer cheaply. It's only applied at serveQuery's
+// HTTP entry point, never inside Query itself: constraints such as
+// ValueInSet recursively call Query to run a sub-query, and gating there
+// too would make a sub-query queue behind (and potentially deadlock
+// waiting on) a slot its own parent query is holding.
+type queryLimiter struct {
+	general querySemaphore
+	cheap   querySemaphore
+}
+
+func newQueryLimiter(maxGeneral, maxCheap int) *queryLimiter {
+	if maxGeneral <= 0 && maxCheap <= 0 {
+		return nil
+	}
+	return &queryLimiter{
+		general: newQuerySemaphore(maxGeneral),
+		cheap:   newQuerySemaphore(maxCheap),
+	}
+}
+
+// acquire waits for a free slot in the appropriate semaphore, giving up
+// after queryQueueWait or if ctx is done first. On success it returns a
+// release func the caller must call; on failure it returns ok == false and
+// a nil func.
+func (l *queryLimiter) acquire(ctx context.Context, cheap bool) (release func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	sem := l.general
+	if cheap {
+		sem = l.cheap
+	}
+	return sem.acquire(ctx, queryQueueWait)
+}
+
+// querySemaphore is a channel-based counting semaphore, sized at
+// construction. Unlike go4.org/syncutil.Gate, it supports bounded waiting
+// via acquire, and a zero size means "unlimited" rather than "always full".
+type querySemaphore chan struct{}
+
+func newQuerySemaphore(size int) querySemaphore {
+	if size <= 0 {
+		return nil // unlimited
+	}
+	return make(querySemaphore, size)
+}
+
+// acquire blocks until a slot is available, maxWait elapses, or ctx is
+// done, whichever comes first. Queued acquires are served in roughly the
+// order the runtime wakes their goroutines, which is fair enough for
+// shedding load without needing a dedicated fair-queueing structure.
+func (s querySemaphore) acquire(ctx context.Context, maxWait time.Duration) (release func(), ok bool) {
+	if s == nil {
+		return func() {}, true
+	}
+	select {
+	case s <- struct{}{}:
+		return func() { <-s }, true
+	default:
+	}
+	t := time.NewTimer(maxWait)
+	defer t.Stop()
+	select {
+	case s <- struct{}{}:
+		return func() { <-s }, true
+	case <-t.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
 var (
 	_ QueryDescriber = (*Handler)(nil)
 )
@@ -77,8 +157,30 @@ type Handler struct {
 
 	lh *index.LocationHelper
 
+	// storages holds the blob storage handlers discovered at
+	// InitHandler time, keyed by their configured prefix (with the
+	// leading and trailing slashes trimmed), for use by the storage:
+	// search predicate.
+	storages map[string]blobserver.BlobStatter
+
+	// thumbChecker, if set with SetThumbnailChecker, backs the
+	// "hasthumbnail" search predicate.
+	thumbChecker ThumbnailChecker
+
+	// queryLimiter bounds how many top-level HTTP search queries may
+	// run concurrently, so a burst of expensive queries can't overwhelm
+	// the server. It's nil unless configured, in which case queries
+	// beyond the limit queue briefly and then are rejected with a 503.
+	queryLimiter *queryLimiter
+
 	// WebSocket hub
 	wsHub *wsHub
+
+	// savedSearchMu guards savedSearches, backing SetSavedSearch and
+	// GetSavedSearch. It's a plain in-memory map: saved searches don't
+	// survive a restart.
+	savedSearchMu sync.RWMutex
+	savedSearches map[string]*SearchQuery
 }
 
 // GetRecentPermanoder is the interface containing the GetRecentPermanodes method.
@@ -104,15 +206,75 @@ func NewHandler(ix index.Interface, owner *index.Owner) *Handler {
 }
 
 func (h *Handler) InitHandler(lh blobserver.FindHandlerByTyper) error {
-	_, handler, err := lh.FindHandlerByType("storage-filesystem")
-	if err != nil || handler == nil {
-		return nil
+	if _, handler, err := lh.FindHandlerByType("storage-filesystem"); err == nil && handler != nil {
+		h.fetcher = handler.(blob.Fetcher)
+		registerKeyword(newNamedSearch(h))
+	}
+
+	types, handlers := lh.AllHandlers()
+	for prefix, htype := range types {
+		if !strings.HasPrefix(htype, "storage-") {
+			continue
+		}
+		statter, ok := handlers[prefix].(blobserver.BlobStatter)
+		if !ok {
+			continue
+		}
+		if h.storages == nil {
+			h.storages = make(map[string]blobserver.BlobStatter)
+		}
+		h.storages[strings.Trim(prefix, "/")] = statter
+	}
+	if len(h.storages) > 0 {
+		registerKeyword(newStorageSearch(h))
 	}
-	h.fetcher = handler.(blob.Fetcher)
-	registerKeyword(newNamedSearch(h))
 	return nil
 }
 
+// ThumbnailChecker reports whether a file blob already has a cached
+// thumbnail. It's the interface behind the "hasthumbnail" search
+// predicate; pkg/server's ThumbMeta implements it, but pkg/search can't
+// import pkg/server directly (pkg/server already imports pkg/search),
+// so it's wired up with SetThumbnailChecker instead.
+type ThumbnailChecker interface {
+	HasThumbnail(ctx context.Context, fileRef blob.Ref) (bool, error)
+}
+
+// SetThumbnailChecker configures the ThumbnailChecker backing the
+// "hasthumbnail" search predicate. Without one configured, the
+// predicate matches nothing.
+func (h *Handler) SetThumbnailChecker(c ThumbnailChecker) {
+	h.thumbChecker = c
+}
+
+// hasThumbnail reports whether br already has a cached thumbnail, per
+// the configured ThumbnailChecker.
+func (h *Handler) hasThumbnail(ctx context.Context, br blob.Ref) (bool, error) {
+	if h.thumbChecker == nil {
+		return false, nil
+	}
+	return h.thumbChecker.HasThumbnail(ctx, br)
+}
+
+// blobInStorage reports whether br is present in the named blob storage
+// handler, as discovered by InitHandler. name is the storage handler's
+// configured prefix with the leading and trailing slashes trimmed.
+func (h *Handler) blobInStorage(ctx context.Context, br blob.Ref, name string) (bool, error) {
+	statter, ok := h.storages[name]
+	if !ok {
+		return false, fmt.Errorf("search: no known storage handler named %q", name)
+	}
+	found := false
+	err := statter.StatBlobs(ctx, []blob.Ref{br}, func(blob.SizedRef) error {
+		found = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
 func (h *Handler) subscribeToNewBlobs() {
 	ch := make(chan blob.Ref, buffered)
 	blobserver.GetHub(h.index).RegisterListener(ch)
@@ -147,6 +309,11 @@ func newHandlerFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (http.Handl
 
 	devBlockStartupPrefix := conf.OptionalString("devBlockStartupOn", "")
 	slurpToMemory := conf.OptionalBool("slurpToMemory", false)
+	// maxConcurrentQueries and maxConcurrentCheapQueries default to 0,
+	// meaning unlimited, preserving prior behavior for configs that
+	// don't set them.
+	maxConcurrentQueries := conf.OptionalInt("maxConcurrentQueries", 0)
+	maxConcurrentCheapQueries := conf.OptionalInt("maxConcurrentCheapQueries", 0)
 	if err := conf.Validate(); err != nil {
 		return nil, err
 	}
@@ -175,6 +342,7 @@ func newHandlerFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (http.Handl
 		return nil, fmt.Errorf("could not create Owner %v", err)
 	}
 	h := NewHandler(indexer, owner)
+	h.queryLimiter = newQueryLimiter(maxConcurrentQueries, maxConcurrentCheapQueries)
 
 	if slurpToMemory {
 		ii := indexer.(*index.Index)
@@ -242,11 +410,18 @@ var getHandler = map[string]func(*Handler, http.ResponseWriter, *http.Request){
 	"signerattrvalue": (*Handler).serveSignerAttrValue,
 	"signerpaths":     (*Handler).serveSignerPaths,
 	"edgesto":         (*Handler).serveEdgesTo,
+	"pathtarget":      (*Handler).servePathTarget,
 }
 
 var postHandler = map[string]func(*Handler, http.ResponseWriter, *http.Request){
-	"describe": (*Handler).serveDescribe,
-	"query":    (*Handler).serveQuery,
+	"describe":           (*Handler).serveDescribe,
+	"query":              (*Handler).serveQuery,
+	"describebyquery":    (*Handler).serveDescribeByQuery,
+	"timeline":           (*Handler).serveTimeline,
+	"geocluster":         (*Handler).serveGeoCluster,
+	"eventcluster":       (*Handler).serveEventCluster,
+	"attrnames":          (*Handler).serveAttrNames,
+	"duplicatefilenames": (*Handler).serveDuplicateFileNames,
 }
 
 func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -408,11 +583,17 @@ func (r *SignerPathsRequest) fromHTTP(req *http.Request) {
 type EdgesRequest struct {
 	// The blob we want to find as a reference.
 	ToRef blob.Ref
+
+	// Max, if positive, caps the number of edges returned. Large
+	// fan-in (e.g. a widely-shared blob) can otherwise return an
+	// unbounded number of results.
+	Max int
 }
 
 // fromHTTP panics with an httputil value on failure
 func (r *EdgesRequest) fromHTTP(req *http.Request) {
 	r.ToRef = httputil.MustGetBlobRef(req, "blobref")
+	r.Max, _ = strconv.Atoi(req.FormValue("maxedges"))
 }
 
 // TODO(mpl): it looks like we never populate RecentResponse.Error*, shouldn't we remove them?
@@ -501,6 +682,9 @@ type SignerPathsItem struct {
 type EdgesResponse struct {
 	ToRef   blob.Ref    `json:"toRef"`
 	EdgesTo []*EdgeItem `json:"edgesTo"`
+	// Truncated is true if EdgesTo was capped by req.Max and more
+	// edges exist.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // An EdgeItem is an item returned from $searchRoot/camli/search/edgesto.
@@ -569,6 +753,153 @@ func (h *Handler) serveRecentPermanodes(rw http.ResponseWriter, req *http.Reques
 	httputil.ReturnJSON(rw, res)
 }
 
+// TimelineRequest is a request to get a TimelineResponse: counts of
+// permanodes last modified per calendar day, over a date range.
+type TimelineRequest struct {
+	// Start and End bound the range of ModTimes to aggregate; Start is
+	// inclusive and End exclusive.
+	// A zero Start means no lower bound; a zero End means now.
+	Start, End time.Time
+
+	// Constraint optionally restricts which permanodes are counted. If
+	// nil, all of the owner's permanodes in range are counted.
+	Constraint *Constraint
+
+	// Loc sets the time zone used to bucket a ModTime into a calendar
+	// day. If nil, time.Local is used.
+	Loc *time.Location
+
+	// Sparse, if true, omits days with a zero count from Days instead of
+	// including them.
+	Sparse bool
+}
+
+func (r *TimelineRequest) URLSuffix() string { return "camli/search/timeline" }
+
+func (r *TimelineRequest) FromHTTP(req *http.Request) error {
+	dec := json.NewDecoder(io.LimitReader(req.Body, 1<<20))
+	return dec.Decode(r)
+}
+
+// TimelineResponse is the JSON response from $searchRoot/camli/search/timeline.
+type TimelineResponse struct {
+	Days []TimelineDay `json:"days"`
+}
+
+// A TimelineDay is one bucket of a TimelineResponse, for a single calendar
+// day (in the request's Loc).
+type TimelineDay struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int    `json:"count"`
+}
+
+// GetTimeline returns, for each day in req's range, the number of
+// permanodes last modified that day, for a GitHub-style activity graph.
+func (h *Handler) GetTimeline(ctx context.Context, req *TimelineRequest) (*TimelineResponse, error) {
+	loc := req.Loc
+	if loc == nil {
+		loc = time.Local
+	}
+
+	rangeConstraint := &Constraint{Permanode: &PermanodeConstraint{}}
+	if !req.Start.IsZero() || !req.End.IsZero() {
+		tc := &TimeConstraint{}
+		if !req.Start.IsZero() {
+			tc.After = types.Time3339(req.Start)
+		}
+		if !req.End.IsZero() {
+			tc.Before = types.Time3339(req.End)
+		}
+		rangeConstraint = &Constraint{Permanode: &PermanodeConstraint{ModTime: tc}}
+	}
+	constraint := rangeConstraint
+	if req.Constraint != nil {
+		constraint = &Constraint{
+			Logical: &LogicalConstraint{
+				Op: "and",
+				A:  req.Constraint,
+				B:  rangeConstraint,
+			},
+		}
+	}
+
+	// Sort order doesn't matter: every matching blob's ModTime gets
+	// bucketed by day below, regardless of the order they arrive in.
+	res, err := h.Query(ctx, &SearchQuery{
+		Constraint: constraint,
+		Limit:      -1,
+		Sort:       Unsorted,
+		Describe:   &DescribeRequest{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, b := range res.Blobs {
+		meta := res.Describe.Meta[b.Blob.String()]
+		if meta == nil || meta.Permanode == nil || meta.Permanode.ModTime.IsZero() {
+			continue
+		}
+		counts[meta.Permanode.ModTime.In(loc).Format("2006-01-02")]++
+	}
+
+	if req.Sparse {
+		days := make([]TimelineDay, 0, len(counts))
+		for date, n := range counts {
+			days = append(days, TimelineDay{Date: date, Count: n})
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+		return &TimelineResponse{Days: days}, nil
+	}
+
+	end := req.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	end = dayStart(end, loc)
+	start := req.Start
+	if start.IsZero() {
+		// No lower bound was given: fall back to the earliest day that
+		// actually has activity, so an unbounded request doesn't return
+		// decades of zero-count days.
+		start = end
+		for date := range counts {
+			if t, err := time.ParseInLocation("2006-01-02", date, loc); err == nil && t.Before(start) {
+				start = t
+			}
+		}
+	}
+	start = dayStart(start, loc)
+
+	var days []TimelineDay
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		days = append(days, TimelineDay{Date: date, Count: counts[date]})
+	}
+	return &TimelineResponse{Days: days}, nil
+}
+
+func dayStart(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+func (h *Handler) serveTimeline(rw http.ResponseWriter, req *http.Request) {
+	defer httputil.RecoverJSON(rw, req)
+	var tr TimelineRequest
+	if err := tr.FromHTTP(req); err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+	res, err := h.GetTimeline(req.Context(), &tr)
+	if err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+	httputil.ReturnJSON(rw, res)
+}
+
 // GetPermanodesWithAttr returns permanodes with attribute req.Attr
 // having the req.Value as a value.
 // See WithAttrRequest for more details about the query.
@@ -777,10 +1108,19 @@ func (h *Handler) EdgesTo(req *EdgesRequest) (*EdgesResponse, error) {
 	toRefStr := toRef.String()
 	var edgeItems []*EdgeItem
 
-	edges, err := h.index.EdgesTo(toRef, nil)
+	// Ask for one edge more than req.Max, to tell whether more exist.
+	opts := &camtypes.EdgesToOpts{}
+	if req.Max > 0 {
+		opts.Max = req.Max + 1
+	}
+	edges, err := h.index.EdgesTo(toRef, opts)
 	if err != nil {
 		panic(err)
 	}
+	truncated := req.Max > 0 && len(edges) > req.Max
+	if truncated {
+		edges = edges[:req.Max]
+	}
 
 	type edgeOrError struct {
 		edge *EdgeItem // or nil
@@ -838,8 +1178,9 @@ func (h *Handler) EdgesTo(req *EdgesRequest) (*EdgesResponse, error) {
 	}
 
 	return &EdgesResponse{
-		ToRef:   toRef,
-		EdgesTo: edgeItems,
+		ToRef:     toRef,
+		EdgesTo:   edgeItems,
+		Truncated: truncated,
 	}, nil
 }
 
@@ -866,6 +1207,19 @@ func (h *Handler) serveQuery(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Queries the in-memory corpus can answer without touching the
+	// index are cheap enough to allow more of them concurrently.
+	release, ok := h.queryLimiter.acquire(req.Context(), h.corpus != nil)
+	if !ok {
+		rw.Header().Set("Retry-After", "1")
+		httputil.ReturnJSONCode(rw, http.StatusServiceUnavailable, map[string]interface{}{
+			"error":     "too many concurrent search queries; try again shortly",
+			"errorType": http.StatusText(http.StatusServiceUnavailable),
+		})
+		return
+	}
+	defer release()
+
 	sr, err := h.Query(req.Context(), &sq)
 	if err != nil {
 		httputil.ServeJSONError(rw, err)
@@ -929,6 +1283,94 @@ func (h *Handler) serveSignerPaths(rw http.ResponseWriter, req *http.Request) {
 	httputil.ReturnJSON(rw, res)
 }
 
+// PathTargetRequest is a request to resolve a camliPath:suffix relation
+// directly against the index's maintained path index, rather than via a
+// general search query.
+type PathTargetRequest struct {
+	// Base is the root permanode that Suffix's camliPath claims are
+	// attached to.
+	Base blob.Ref
+
+	// Suffix is the path suffix to resolve, i.e. the "foo" of a
+	// "camliPath:foo" claim on Base.
+	Suffix string
+
+	// Signer optionally restricts the lookup to path claims signed by
+	// Signer. If zero, the search handler's owner is used.
+	Signer blob.Ref
+
+	// At, if non-zero, resolves the path as it stood at this time,
+	// rather than using the most recent claim.
+	At time.Time
+}
+
+func (r *PathTargetRequest) URLSuffix() string {
+	s := fmt.Sprintf("camli/search/pathtarget?base=%v&suffix=%s&signer=%v",
+		r.Base, url.QueryEscape(r.Suffix), r.Signer)
+	if !r.At.IsZero() {
+		s += fmt.Sprintf("&at=%s", types.Time3339(r.At))
+	}
+	return s
+}
+
+// fromHTTP panics with an httputil value on failure
+func (r *PathTargetRequest) fromHTTP(req *http.Request) {
+	r.Base = httputil.MustGetBlobRef(req, "base")
+	r.Suffix = req.FormValue("suffix")
+	r.Signer = blob.ParseOrZero(req.FormValue("signer"))
+	if at := req.FormValue("at"); at != "" {
+		r.At = time.Time(types.ParseTime3339OrZero(at))
+	}
+}
+
+// PathTargetResponse is the JSON response from
+// $searchRoot/camli/search/pathtarget.
+type PathTargetResponse struct {
+	Target blob.Ref `json:"target"`
+}
+
+// GetPathTarget resolves req's camliPath:suffix relation to its current
+// target permanode, using the index's maintained path index (latest
+// claim wins) instead of a general search query. It returns
+// os.ErrNotExist if no matching path claim exists.
+func (h *Handler) GetPathTarget(ctx context.Context, req *PathTargetRequest) (*PathTargetResponse, error) {
+	if !req.Base.Valid() {
+		return nil, errors.New("error getting path target: nil base")
+	}
+	signer := req.Signer
+	if !signer.Valid() {
+		signer = h.owner.BlobRef()
+	}
+	h.index.RLock()
+	defer h.index.RUnlock()
+
+	path, err := h.index.PathLookup(ctx, signer, req.Base, req.Suffix, req.At)
+	if err != nil {
+		return nil, err
+	}
+	return &PathTargetResponse{Target: path.Target}, nil
+}
+
+func (h *Handler) servePathTarget(rw http.ResponseWriter, req *http.Request) {
+	defer httputil.RecoverJSON(rw, req)
+	var pr PathTargetRequest
+	pr.fromHTTP(req)
+
+	res, err := h.GetPathTarget(req.Context(), &pr)
+	if err == os.ErrNotExist {
+		httputil.ReturnJSONCode(rw, http.StatusNotFound, map[string]interface{}{
+			"error":     "no such path",
+			"errorType": http.StatusText(http.StatusNotFound),
+		})
+		return
+	}
+	if err != nil {
+		httputil.ServeJSONError(rw, err)
+		return
+	}
+	httputil.ReturnJSON(rw, res)
+}
+
 // EvalSearchInput checks if its input is JSON. If so it returns a Constraint constructed from that JSON. Otherwise
 // it assumes the input to be a search expression. It parses the expression and returns the parsed Constraint.
 func evalSearchInput(in string) (*Constraint, error) {
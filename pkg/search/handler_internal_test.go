@@ -0,0 +1,106 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+)
+
+// fakeFindHandler is a minimal blobserver.FindHandlerByTyper for testing
+// Handler.InitHandler's storage discovery.
+type fakeFindHandler struct {
+	types    map[string]string
+	handlers map[string]interface{}
+}
+
+func (f fakeFindHandler) FindHandlerByType(htype string) (prefix string, handler interface{}, err error) {
+	for pfx, t := range f.types {
+		if t == htype {
+			return pfx, f.handlers[pfx], nil
+		}
+	}
+	return "", nil, blobserver.ErrHandlerTypeNotFound
+}
+
+func (f fakeFindHandler) AllHandlers() (map[string]string, map[string]interface{}) {
+	return f.types, f.handlers
+}
+
+// fakeStatter is a blobserver.BlobStatter with a fixed set of present blobs.
+type fakeStatter struct {
+	has map[blob.Ref]bool
+}
+
+func (f fakeStatter) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.SizedRef) error) error {
+	for _, br := range blobs {
+		if f.has[br] {
+			if err := fn(blob.SizedRef{Ref: br, Size: 1}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestInitHandlerStorageDiscovery(t *testing.T) {
+	br := blob.RefFromString("hello")
+	cloud := fakeStatter{has: map[blob.Ref]bool{br: true}}
+	local := fakeStatter{has: map[blob.Ref]bool{}}
+
+	lh := fakeFindHandler{
+		types: map[string]string{
+			"/sto-cloud/": "storage-s3",
+			"/bs/":        "storage-diskpacked",
+			"/ui/":        "ui",
+		},
+		handlers: map[string]interface{}{
+			"/sto-cloud/": cloud,
+			"/bs/":        local,
+			"/ui/":        nil,
+		},
+	}
+
+	h := &Handler{}
+	if err := h.InitHandler(lh); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ok, err := h.blobInStorage(ctx, br, "sto-cloud")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("blobInStorage(sto-cloud) = false; want true")
+	}
+
+	ok, err = h.blobInStorage(ctx, br, "bs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("blobInStorage(bs) = true; want false")
+	}
+
+	if _, err := h.blobInStorage(ctx, br, "nonexistent"); err == nil {
+		t.Error("blobInStorage(nonexistent) = nil error; want error")
+	}
+}
@@ -580,6 +580,64 @@ func initTests() []handlerTest {
 			}`),
 		},
 
+		// test that pathtarget resolves a camliPath:foo claim directly
+		{
+			name: "pathtarget-found",
+			setup: func(t *testing.T) indexAndOwner {
+				idx := index.NewMemoryIndex()
+				tf := new(test.Fetcher)
+				idx.InitBlobSource(tf)
+				idx.KeyFetcher = tf
+				fi := &fetcherIndex{
+					tf:  tf,
+					idx: idx,
+				}
+
+				checkErr(t, fi.addBlob(ownerRef))
+				perma123 := testBlobs["perma-123"]
+				checkErr(t, fi.addBlob(perma123))
+				target := testBlobs["fakeref-123"]
+				checkErr(t, fi.addBlob(target))
+				checkErr(t, fi.addClaim(schema.NewSetAttributeClaim(perma123.BlobRef(), "camliPath:foo", target.BlobRef().String())))
+				return indexAndOwner{
+					index: idx,
+					owner: owner.BlobRef(),
+				}
+			},
+			query: "pathtarget?base=" + tbRefStr("perma-123") + "&suffix=foo",
+			want: parseJSON(`{
+				"target": "` + tbRefStr("fakeref-123") + `"
+			}`),
+		},
+
+		// test that pathtarget reports a missing camliPath claim as not found
+		{
+			name: "pathtarget-not-found",
+			setup: func(t *testing.T) indexAndOwner {
+				idx := index.NewMemoryIndex()
+				tf := new(test.Fetcher)
+				idx.InitBlobSource(tf)
+				idx.KeyFetcher = tf
+				fi := &fetcherIndex{
+					tf:  tf,
+					idx: idx,
+				}
+
+				checkErr(t, fi.addBlob(ownerRef))
+				perma123 := testBlobs["perma-123"]
+				checkErr(t, fi.addBlob(perma123))
+				return indexAndOwner{
+					index: idx,
+					owner: owner.BlobRef(),
+				}
+			},
+			query: "pathtarget?base=" + tbRefStr("perma-123") + "&suffix=nonexistent",
+			want: parseJSON(`{
+				"error": "no such path",
+				"errorType": "Not Found"
+			}`),
+		},
+
 		// Test recent permanodes
 		{
 			name: "recent-1",
@@ -676,7 +734,8 @@ func initTests() []handlerTest {
 					  },
 					  "image": {
 						"width": 50,
-						"height": 100
+						"height": 100,
+						"dominantColors": ["e0e0e0", "000000", "808080", "202020", "c0c0c0"]
 					  }
 					}
 						 }
@@ -769,7 +828,8 @@ func initTests() []handlerTest {
 					  },
 					  "image": {
 						"width": 50,
-						"height": 100
+						"height": 100,
+						"dominantColors": ["e0e0e0", "000000", "808080", "202020", "c0c0c0"]
 					  }
 					}
 				  }
@@ -119,24 +119,34 @@ func init() {
 	registerKeyword(newIsLandscape())
 	registerKeyword(newIsPano())
 	registerKeyword(newIsPortait())
+	registerKeyword(newOrientation())
+	registerKeyword(newRatio())
 	registerKeyword(newWidth())
+	registerKeyword(newTaken())
+	registerKeyword(newColor())
 
 	// File predicates
 	registerKeyword(newFilename())
+	registerKeyword(newExt())
+	registerKeyword(newContent())
 
 	// Custom predicates
 	registerKeyword(newIsPost())
 	registerKeyword(newIsLike())
 	registerKeyword(newIsCheckin())
 	registerKeyword(newIsUntagged())
+	registerKeyword(newAttrExists())
+	registerKeyword(newClaimCount())
 
 	// Location predicates
 	registerKeyword(newHasLocation())
+	registerKeyword(newHasThumbnail())
 	registerKeyword(newNamedLocation())
 	registerKeyword(newLocation())
 
 	// People predicates
 	registerKeyword(newWith())
+	registerKeyword(newPerson())
 }
 
 // Helper implementation for mixing into keyword implementations
@@ -245,16 +255,33 @@ func newAttribute() keyword {
 func (a attribute) Description() string {
 	return "match on attribute. Use attr:foo:bar to match nodes having their foo\n" +
 		"attribute set to bar or attr:foo:~bar to do a substring\n" +
-		"case-insensitive search for 'bar' in attribute foo"
+		"case-insensitive search for 'bar' in attribute foo. Use\n" +
+		"attr:foo:100..200, attr:foo:>100, or attr:foo:<200 to match nodes\n" +
+		"whose foo attribute, interpreted as a number, falls in that range.\n" +
+		"Either end of a range may be omitted, e.g. attr:foo:100.. Nodes\n" +
+		"whose attribute value isn't numeric never match a range."
 }
 
 func (a attribute) Predicate(ctx context.Context, args []string) (*Constraint, error) {
-	c := permWithAttr(args[0], args[1])
-	if strings.HasPrefix(args[1], "~") {
+	attr, val := args[0], args[1]
+	if ic, fc, ok, err := parseNumericRange(val); err != nil {
+		return nil, fmt.Errorf("attr:%s:%s: %v", attr, val, err)
+	} else if ok {
+		return &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr:              attr,
+				SkipHidden:        true,
+				ValueMatchesInt:   ic,
+				ValueMatchesFloat: fc,
+			},
+		}, nil
+	}
+	c := permWithAttr(attr, val)
+	if strings.HasPrefix(val, "~") {
 		// Substring. Hack. Figure out better way to do this.
 		c.Permanode.Value = ""
 		c.Permanode.ValueMatches = &StringConstraint{
-			Contains:        args[1][1:],
+			Contains:        val[1:],
 			CaseInsensitive: true,
 		}
 	}
@@ -399,6 +426,22 @@ func (w with) Predicate(ctx context.Context, args []string) (*Constraint, error)
 	return c, nil
 }
 
+type person struct {
+	matchPrefix
+}
+
+func newPerson() keyword {
+	return person{newMatchPrefix("person")}
+}
+
+func (p person) Description() string {
+	return "match photos with a detected face belonging to the given person cluster ID, or name once a cluster has been named (see the indexer's optional face detection)"
+}
+
+func (p person) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	return permWithAttr("person", args[0]), nil
+}
+
 type title struct {
 	matchPrefix
 }
@@ -519,6 +562,62 @@ func (k isPortait) Predicate(ctx context.Context, args []string) (*Constraint, e
 	return whRatio(&FloatConstraint{Max: 1.0}), nil
 }
 
+// squareTolerance is how far from an exact 1:1 width/height ratio an image
+// can be and still count as "square" for orientation:square, since very few
+// real images have a perfectly equal width and height.
+const squareTolerance = 0.05
+
+type orientation struct {
+	matchPrefix
+}
+
+func newOrientation() keyword {
+	return orientation{newMatchPrefix("orientation")}
+}
+
+func (o orientation) Description() string {
+	return "use orientation:landscape, orientation:portrait, or orientation:square\n" +
+		"to match images by their overall shape. square matches images whose\n" +
+		"width/height ratio is within 5% of 1; landscape matches images with a\n" +
+		"ratio of at least 1.05 and portrait those with a ratio of at most 0.95."
+}
+
+func (o orientation) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	switch args[0] {
+	case "landscape":
+		return whRatio(&FloatConstraint{Min: 1 + squareTolerance}), nil
+	case "portrait":
+		return whRatio(&FloatConstraint{Max: 1 - squareTolerance}), nil
+	case "square":
+		return whRatio(&FloatConstraint{Min: 1 - squareTolerance, Max: 1 + squareTolerance}), nil
+	}
+	return nil, fmt.Errorf("unknown orientation %q; want landscape, portrait, or square", args[0])
+}
+
+type ratio struct {
+	matchPrefix
+}
+
+func newRatio() keyword {
+	return ratio{newMatchPrefix("ratio")}
+}
+
+func (r ratio) Description() string {
+	return "use ratio:min-max to match images with a width/height aspect ratio of\n" +
+		"at least min and at most max, e.g. ratio:1.5-2. Also accepts a single\n" +
+		"value (ratio:1.5) or a comparison (ratio:>1, ratio:>=1, ratio:<1,\n" +
+		"ratio:<=1). A square image has a ratio of 1; a wide image has a ratio\n" +
+		"greater than 1."
+}
+
+func (r ratio) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	fc, err := parseFloatComparisonExpression(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return whRatio(fc), nil
+}
+
 type width struct {
 	matchPrefix
 }
@@ -573,6 +672,111 @@ func (h height) Predicate(ctx context.Context, args []string) (*Constraint, erro
 	return c, nil
 }
 
+type taken struct {
+	matchPrefix
+}
+
+func newTaken() keyword {
+	return taken{newMatchPrefix("taken")}
+}
+
+func (t taken) Description() string {
+	return "use taken:start..end to match images with an EXIF date taken in\n" +
+		"that range. Either end may be omitted, e.g. taken:2020.. or\n" +
+		"taken:..2021; the end is exclusive. A single date (taken:2020) matches\n" +
+		"that date and anything later, as with after:.\n" +
+		"Date format is RFC3339, but can be shortened as required, as with\n" +
+		"before/after. Images without an EXIF date taken never match."
+}
+
+func (t taken) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	tc := &TimeConstraint{}
+	arg := args[0]
+	if start, end, ok := strings.Cut(arg, ".."); ok {
+		if start != "" {
+			t, err := parseTimePrefix(start)
+			if err != nil {
+				return nil, err
+			}
+			tc.After = types.Time3339(t)
+		}
+		if end != "" {
+			t, err := parseTimePrefix(end)
+			if err != nil {
+				return nil, err
+			}
+			tc.Before = types.Time3339(t)
+		}
+	} else {
+		t, err := parseTimePrefix(arg)
+		if err != nil {
+			return nil, err
+		}
+		tc.After = types.Time3339(t)
+	}
+	c := permOfFile(&FileConstraint{
+		IsImage: true,
+		EXIF:    &EXIFConstraint{DateTaken: tc},
+	})
+	return c, nil
+}
+
+// namedColors maps common color names to a representative hex value,
+// for use with the color: predicate.
+var namedColors = map[string]string{
+	"black":  "000000",
+	"white":  "ffffff",
+	"gray":   "808080",
+	"grey":   "808080",
+	"red":    "ff0000",
+	"orange": "ffa500",
+	"yellow": "ffff00",
+	"green":  "008000",
+	"blue":   "0000ff",
+	"purple": "800080",
+	"pink":   "ffc0cb",
+	"brown":  "a52a2a",
+}
+
+type color struct {
+	matchPrefix
+}
+
+func newColor() keyword {
+	return color{newMatchPrefix("color")}
+}
+
+func (c color) Description() string {
+	return "use color:name (e.g. color:blue) or color:#RRGGBB to match images\n" +
+		"whose dominant colors include one near the given color. Append\n" +
+		"±tolerance to widen or narrow the match, e.g. color:#3366cc±40; the\n" +
+		"tolerance is a distance in RGB space and defaults to 30."
+}
+
+func (c color) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	arg := args[0]
+	tolerance := 0
+	if hex, tol, ok := strings.Cut(arg, "±"); ok {
+		arg = hex
+		n, err := strconv.Atoi(tol)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color tolerance %q: %v", tol, err)
+		}
+		tolerance = n
+	}
+	hex := strings.TrimPrefix(arg, "#")
+	if named, ok := namedColors[strings.ToLower(hex)]; ok {
+		hex = named
+	}
+	if _, ok := parseHexColor(hex); !ok {
+		return nil, fmt.Errorf("unknown color %q; want a color name or #RRGGBB", arg)
+	}
+	return permOfFile(&FileConstraint{
+		IsImage: true,
+		Color:   &ColorConstraint{Hex: hex, MaxDistance: tolerance},
+	}), nil
+}
+
 // Location predicates
 
 // namedLocation matches e.g. `loc:Paris` or `loc:"New York, New York"` queries.
@@ -694,6 +898,34 @@ func (h hasLocation) Predicate(ctx context.Context, args []string) (*Constraint,
 	}, nil
 }
 
+// hasThumbnail implements the "hasthumbnail:true"/"hasthumbnail:false"
+// predicate, matching image files that do (or don't) already have a cached
+// thumbnail, per whatever thumbnail cache the search Handler was
+// configured with via SetThumbnailChecker. If none was configured,
+// the predicate has nothing to check against and matches nothing.
+type hasThumbnail struct {
+	matchPrefix
+}
+
+func newHasThumbnail() keyword {
+	return hasThumbnail{newMatchPrefix("hasthumbnail")}
+}
+
+func (h hasThumbnail) Description() string {
+	return "hasthumbnail:true or hasthumbnail:false. Matches image files that " +
+		"already have (or don't have) a cached thumbnail, regardless of size. " +
+		"Useful for a pre-generation job to target only images that still need " +
+		"one, or for a UI deciding whether to request an on-demand resize."
+}
+
+func (h hasThumbnail) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	want, err := strconv.ParseBool(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid hasthumbnail value %q: %v", args[0], err)
+	}
+	return permOfFile(&FileConstraint{IsImage: true, HasThumbnail: &want}), nil
+}
+
 // NamedSearch lets you use the search aliases you defined with SetNamed from the search handler.
 type namedSearch struct {
 	matchPrefix
@@ -721,6 +953,27 @@ func (n namedSearch) namedConstraint(name string) (*Constraint, error) {
 	return evalSearchInput(subst)
 }
 
+// storageSearch implements the "storage:<name>" predicate, matching files
+// whose blob is present on the named blob storage handler. It's registered
+// dynamically by Handler.InitHandler, once the set of available storage
+// handlers is known, rather than in this file's init().
+type storageSearch struct {
+	matchPrefix
+	sh *Handler
+}
+
+func newStorageSearch(sh *Handler) keyword {
+	return storageSearch{newMatchPrefix("storage"), sh}
+}
+
+func (s storageSearch) Description() string {
+	return "Match files whose content blob is present on the named blob storage handler, e.g. \"storage:sto-cloud\" for a handler configured at \"/sto-cloud/\". A blob present on more than one backend matches every one of them."
+}
+
+func (s storageSearch) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	return permOfFile(&FileConstraint{StorageName: args[0]}), nil
+}
+
 // Helpers
 
 func permWithAttr(attr, val string) *Constraint {
@@ -773,6 +1026,112 @@ func parseWHExpression(expr string) (min, max string, err error) {
 	return "", "", fmt.Errorf("Unable to parse %q as range, wanted something like 480-1024, 480-, -1024 or 1024", expr)
 }
 
+// parseNumericRange reports whether s is a numeric range expression, one of
+// "min..max", ">min", ">=min", "<max" or "<=max" (either bound may be a
+// float). If it is, exactly one of ic or fc is returned, holding the
+// corresponding bound(s); ok is false (with a nil err) if s isn't a range
+// expression at all, so callers can fall back to treating it as a literal
+// value.
+func parseNumericRange(s string) (ic *IntConstraint, fc *FloatConstraint, ok bool, err error) {
+	var minS, maxS string
+	var strictMin, strictMax bool
+	switch {
+	case strings.HasPrefix(s, ">="):
+		minS = s[2:]
+	case strings.HasPrefix(s, "<="):
+		maxS = s[2:]
+	case strings.HasPrefix(s, ">"):
+		minS, strictMin = s[1:], true
+	case strings.HasPrefix(s, "<"):
+		maxS, strictMax = s[1:], true
+	default:
+		var isRange bool
+		minS, maxS, isRange = strings.Cut(s, "..")
+		if !isRange {
+			return nil, nil, false, nil
+		}
+	}
+	if minS == "" && maxS == "" {
+		return nil, nil, false, nil
+	}
+	if containsFloat(minS) || containsFloat(maxS) {
+		fc, err = floatRangeConstraint(minS, maxS)
+		if err == nil && strictMin {
+			fc.Min, fc.ZeroMin = fc.Min+ratioEpsilon, false
+		}
+		if err == nil && strictMax {
+			fc.Max, fc.ZeroMax = fc.Max-ratioEpsilon, false
+		}
+		return nil, fc, true, err
+	}
+	ic, err = intRangeConstraint(minS, maxS)
+	if err == nil && strictMin {
+		ic.Min, ic.ZeroMin = ic.Min+1, ic.Min+1 == 0
+	}
+	if err == nil && strictMax {
+		ic.Max, ic.ZeroMax = ic.Max-1, ic.Max-1 == 0
+	}
+	return ic, nil, true, err
+}
+
+func containsFloat(s string) bool {
+	return strings.Contains(s, ".")
+}
+
+func intRangeConstraint(minS, maxS string) (*IntConstraint, error) {
+	ic := &IntConstraint{}
+	if minS != "" {
+		n, err := strconv.ParseInt(minS, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", minS)
+		}
+		if n == 0 {
+			ic.ZeroMin = true
+		} else {
+			ic.Min = n
+		}
+	}
+	if maxS != "" {
+		n, err := strconv.ParseInt(maxS, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", maxS)
+		}
+		if n == 0 {
+			ic.ZeroMax = true
+		} else {
+			ic.Max = n
+		}
+	}
+	return ic, nil
+}
+
+func floatRangeConstraint(minS, maxS string) (*FloatConstraint, error) {
+	fc := &FloatConstraint{}
+	if minS != "" {
+		f, err := strconv.ParseFloat(minS, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", minS)
+		}
+		if f == 0 {
+			fc.ZeroMin = true
+		} else {
+			fc.Min = f
+		}
+	}
+	if maxS != "" {
+		f, err := strconv.ParseFloat(maxS, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", maxS)
+		}
+		if f == 0 {
+			fc.ZeroMax = true
+		} else {
+			fc.Max = f
+		}
+	}
+	return fc, nil
+}
+
 func parseTimePrefix(when string) (time.Time, error) {
 	if len(when) < len(base) {
 		when += base[len(when):]
@@ -896,26 +1255,239 @@ func (k isUntagged) Description() string {
 }
 
 func (k isUntagged) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	return notConst(attrHasValue("tag")), nil
+}
+
+// attrHasValue returns a constraint matching permanodes that currently have
+// at least one non-empty value set for attr. Negating it (via the "-"
+// expression prefix or a LogicalConstraint "not") therefore matches
+// permanodes where attr is absent or was cleared, as opposed to permanodes
+// that never had attr set versus ones where it was set then deleted; both
+// look the same here, since deleting an attribute's last value removes it.
+func attrHasValue(attr string) *Constraint {
 	return &Constraint{
-		// Note: we can't just match the Empty string constraint for the tag attribute,
-		// because we actually want to match the absence of any tag attribute, hence below.
-		Logical: &LogicalConstraint{
-			Op: "not",
-			A: &Constraint{
-				Permanode: &PermanodeConstraint{
-					Attr:       "tag",
-					SkipHidden: true,
-					ValueMatches: &StringConstraint{
-						ByteLength: &IntConstraint{
-							Min: 1,
-						},
-					},
+		Permanode: &PermanodeConstraint{
+			Attr:       attr,
+			SkipHidden: true,
+			ValueMatches: &StringConstraint{
+				ByteLength: &IntConstraint{
+					Min: 1,
 				},
 			},
 		},
+	}
+}
+
+type attrExists struct {
+	matchPrefix
+}
+
+func newAttrExists() keyword {
+	return attrExists{newMatchPrefix("attrexists")}
+}
+
+func (a attrExists) Description() string {
+	return "matches permanodes that have a non-empty value set for the\n" +
+		"given attribute, e.g. attrexists:title. Negate with\n" +
+		"-attrexists:title to find permanodes missing (or emptied of) it."
+}
+
+func (a attrExists) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	return attrHasValue(args[0]), nil
+}
+
+type claimCount struct {
+	matchPrefix
+}
+
+func newClaimCount() keyword {
+	return claimCount{newMatchPrefix("claimcount")}
+}
+
+func (c claimCount) Description() string {
+	return "matches permanodes by how many claims have ever been made about\n" +
+		"them (their edit activity), e.g. claimcount:10 for exactly 10,\n" +
+		"claimcount:>10 or claimcount:<10 for more or fewer, and\n" +
+		"claimcount:5-10 for a range. Requires an in-memory index corpus."
+}
+
+func (c claimCount) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	ic, err := parseIntComparisonExpression(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Constraint{
+		Permanode: &PermanodeConstraint{NumClaims: ic},
 	}, nil
 }
 
+// parseIntComparisonExpression parses expr as one of:
+//
+//	"N"      exactly N
+//	">N"     more than N
+//	">=N"    at least N
+//	"<N"     fewer than N
+//	"<=N"    at most N
+//	"min-max" as with parseWHExpression
+func parseIntComparisonExpression(expr string) (*IntConstraint, error) {
+	cmp, rest := "", expr
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(expr, op) {
+			cmp, rest = op, expr[len(op):]
+			break
+		}
+	}
+	n, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		mins, maxs, err := parseWHExpression(expr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %q as a number, comparison, or range", expr)
+		}
+		return whIntConstraint(mins, maxs), nil
+	}
+	ic := &IntConstraint{}
+	switch cmp {
+	case "":
+		ic.Min, ic.Max = n, n
+	case ">":
+		ic.Min = n + 1
+	case ">=":
+		ic.Min = n
+	case "<":
+		ic.Max = n - 1
+	case "<=":
+		ic.Max = n
+	}
+	// A zero Min or Max alone means "no bound", so a bound of zero needs
+	// ZeroMin or ZeroMax.
+	ic.ZeroMin = ic.Min == 0 && (cmp == "" || cmp == ">" || cmp == ">=")
+	ic.ZeroMax = ic.Max == 0 && (cmp == "" || cmp == "<" || cmp == "<=")
+	return ic, nil
+}
+
+// ratioEpsilon nudges a strict > or < comparison of a float (an aspect
+// ratio, or a numeric attribute value) into the inclusive Min/Max bounds
+// that FloatConstraint supports.
+const ratioEpsilon = 1e-9
+
+// parseFloatComparisonExpression parses expr as one of:
+//
+//	"N"      exactly N
+//	">N"     more than N
+//	">=N"    at least N
+//	"<N"     fewer than N
+//	"<=N"    at most N
+//	"min-max" an inclusive range; either end may be omitted
+func parseFloatComparisonExpression(expr string) (*FloatConstraint, error) {
+	cmp, rest := "", expr
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(expr, op) {
+			cmp, rest = op, expr[len(op):]
+			break
+		}
+	}
+	if n, err := strconv.ParseFloat(rest, 64); err == nil {
+		switch cmp {
+		case "":
+			return &FloatConstraint{Min: n, Max: n}, nil
+		case ">":
+			return &FloatConstraint{Min: n + ratioEpsilon}, nil
+		case ">=":
+			return &FloatConstraint{Min: n}, nil
+		case "<":
+			return &FloatConstraint{Max: n - ratioEpsilon}, nil
+		case "<=":
+			return &FloatConstraint{Max: n}, nil
+		}
+	}
+	mins, maxs, ok := strings.Cut(expr, "-")
+	if !ok {
+		return nil, fmt.Errorf("unable to parse %q as a ratio, comparison, or range", expr)
+	}
+	fc := &FloatConstraint{}
+	if mins != "" {
+		min, err := strconv.ParseFloat(mins, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %q as a ratio, comparison, or range", expr)
+		}
+		fc.Min = min
+	}
+	if maxs != "" {
+		max, err := strconv.ParseFloat(maxs, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %q as a ratio, comparison, or range", expr)
+		}
+		fc.Max = max
+	}
+	if mins == "" && maxs == "" {
+		return nil, fmt.Errorf("unable to parse %q as a ratio, comparison, or range", expr)
+	}
+	return fc, nil
+}
+
+type ext struct {
+	matchPrefix
+}
+
+func newExt() keyword {
+	return ext{newMatchPrefix("ext")}
+}
+
+func (e ext) Description() string {
+	return "Match files by filename extension, case insensitively. Takes a comma-separated " +
+		"set of extensions, e.g. ext:jpg,png. Files with no extension never match. For " +
+		"multi-dot names such as archive.tar.gz, spell out the compound extension " +
+		"(ext:tar.gz) to match it exactly; ext:gz also matches on the final component alone."
+}
+
+func (e ext) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	var c *Constraint
+	for _, e := range strings.Split(args[0], ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		fc := permOfFile(&FileConstraint{
+			FileName: &StringConstraint{
+				HasSuffix:       "." + e,
+				CaseInsensitive: true,
+			},
+		})
+		if c == nil {
+			c = fc
+		} else {
+			c = orConst(c, fc)
+		}
+	}
+	if c == nil {
+		return nil, errors.New("ext: predicate requires at least one extension")
+	}
+	return c, nil
+}
+
+type content struct {
+	matchPrefix
+}
+
+func newContent() keyword {
+	return content{newMatchPrefix("content")}
+}
+
+func (c content) Description() string {
+	return "Match files whose extracted text content contains the given substring, case " +
+		"insensitively. Currently only PDF files have their text extracted; other formats " +
+		"never match. Requires an in-memory index corpus."
+}
+
+func (c content) Predicate(ctx context.Context, args []string) (*Constraint, error) {
+	return permOfFile(&FileConstraint{
+		Content: &StringConstraint{
+			Contains:        args[0],
+			CaseInsensitive: true,
+		},
+	}), nil
+}
+
 type filename struct {
 	matchPrefix
 }
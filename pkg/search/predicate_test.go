@@ -217,6 +217,36 @@ var keywordTests = []keywordTestcase{
 		},
 	},
 
+	{
+		object: newAttribute(),
+		args:   []string{"pages", ">10"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr:            "pages",
+				SkipHidden:      true,
+				ValueMatchesInt: &IntConstraint{Min: 11},
+			},
+		},
+	},
+
+	{
+		object: newAttribute(),
+		args:   []string{"price", "100..500.5"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr:              "price",
+				SkipHidden:        true,
+				ValueMatchesFloat: &FloatConstraint{Min: 100, Max: 500.5},
+			},
+		},
+	},
+
+	{
+		object:      newAttribute(),
+		args:        []string{"pages", ">nope"},
+		errContains: "invalid integer",
+	},
+
 	{
 		object: newChildrenOf(),
 		args:   []string{"foo"},
@@ -300,6 +330,105 @@ var keywordTests = []keywordTestcase{
 		},
 	},
 
+	{
+		object: newAttrExists(),
+		args:   []string{"title"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr:       "title",
+				SkipHidden: true,
+				ValueMatches: &StringConstraint{
+					ByteLength: &IntConstraint{
+						Min: 1,
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newClaimCount(),
+		args:   []string{"10"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				NumClaims: &IntConstraint{Min: 10, Max: 10},
+			},
+		},
+	},
+
+	{
+		object: newClaimCount(),
+		args:   []string{">10"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				NumClaims: &IntConstraint{Min: 11},
+			},
+		},
+	},
+
+	{
+		object: newClaimCount(),
+		args:   []string{"5-10"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				NumClaims: &IntConstraint{Min: 5, Max: 10},
+			},
+		},
+	},
+
+	{
+		object:      newClaimCount(),
+		args:        []string{"nope"},
+		errContains: "unable to parse",
+	},
+
+	{
+		object: newContent(),
+		args:   []string{"invoice"},
+		want: permOfFile(&FileConstraint{
+			Content: &StringConstraint{
+				Contains:        "invoice",
+				CaseInsensitive: true,
+			},
+		}),
+	},
+
+	{
+		object: newExt(),
+		args:   []string{"jpg"},
+		want: permOfFile(&FileConstraint{
+			FileName: &StringConstraint{
+				HasSuffix:       ".jpg",
+				CaseInsensitive: true,
+			},
+		}),
+	},
+
+	{
+		object: newExt(),
+		args:   []string{"jpg,png"},
+		want: orConst(
+			permOfFile(&FileConstraint{
+				FileName: &StringConstraint{
+					HasSuffix:       ".jpg",
+					CaseInsensitive: true,
+				},
+			}),
+			permOfFile(&FileConstraint{
+				FileName: &StringConstraint{
+					HasSuffix:       ".png",
+					CaseInsensitive: true,
+				},
+			}),
+		),
+	},
+
+	{
+		object:      newExt(),
+		args:        []string{""},
+		errContains: "requires at least one extension",
+	},
+
 	{
 		object: newTitle(),
 		args:   []string{""},
@@ -336,6 +465,21 @@ var keywordTests = []keywordTestcase{
 		},
 	},
 
+	{
+		object: newStorageSearch(nil),
+		args:   []string{"sto-cloud"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						StorageName: "sto-cloud",
+					},
+				},
+			},
+		},
+	},
+
 	{
 		object: newWith(),
 		args:   []string{"fitz"},
@@ -433,6 +577,110 @@ var keywordTests = []keywordTestcase{
 		},
 	},
 
+	{
+		object: newOrientation(),
+		args:   []string{"landscape"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						WHRatio: &FloatConstraint{
+							Min: 1.05,
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newOrientation(),
+		args:   []string{"portrait"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						WHRatio: &FloatConstraint{
+							Max: 0.95,
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newOrientation(),
+		args:   []string{"square"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						WHRatio: &FloatConstraint{
+							Min: 0.95,
+							Max: 1.05,
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object:      newOrientation(),
+		args:        []string{"diagonal"},
+		errContains: `unknown orientation "diagonal"`,
+	},
+
+	{
+		object: newRatio(),
+		args:   []string{"1.5-2"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						WHRatio: &FloatConstraint{
+							Min: 1.5,
+							Max: 2,
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newRatio(),
+		args:   []string{">=1"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						WHRatio: &FloatConstraint{
+							Min: 1,
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object:      newRatio(),
+		args:        []string{"nonsense"},
+		errContains: "unable to parse",
+	},
+
 	{
 		object:      newWidth(),
 		args:        []string{""},
@@ -593,6 +841,111 @@ var keywordTests = []keywordTestcase{
 		},
 	},
 
+	{
+		object:      newTaken(),
+		args:        []string{"faulty"},
+		errContains: "faulty",
+	},
+
+	{
+		object: newTaken(),
+		args:   []string{"2013-02-03"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						EXIF: &EXIFConstraint{
+							DateTaken: &TimeConstraint{
+								After: types.Time3339(testtime),
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newTaken(),
+		args:   []string{"2013-02-03..2013-03-01"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						EXIF: &EXIFConstraint{
+							DateTaken: &TimeConstraint{
+								After:  types.Time3339(testtime),
+								Before: types.Time3339(time.Date(2013, time.March, 1, 0, 0, 0, 0, time.UTC)),
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newTaken(),
+		args:   []string{"..2013-02-03"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						EXIF: &EXIFConstraint{
+							DateTaken: &TimeConstraint{
+								Before: types.Time3339(testtime),
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newColor(),
+		args:   []string{"blue"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						Color:   &ColorConstraint{Hex: "0000ff"},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object: newColor(),
+		args:   []string{"#3366cc±40"},
+		want: &Constraint{
+			Permanode: &PermanodeConstraint{
+				Attr: "camliContent",
+				ValueInSet: &Constraint{
+					File: &FileConstraint{
+						IsImage: true,
+						Color:   &ColorConstraint{Hex: "3366cc", MaxDistance: 40},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		object:      newColor(),
+		args:        []string{"chartreuse"},
+		errContains: `unknown color "chartreuse"`,
+	},
+
 	// Location predicates
 	{
 		object: newNamedLocation(),
@@ -752,3 +1105,30 @@ func TestLocationConstraint(t *testing.T) {
 		}
 	}
 }
+
+func TestExtStringMatches(t *testing.T) {
+	// A StringConstraint{HasSuffix: "."+ext} is what the ext predicate
+	// compiles down to; verify it does the right thing on the edge cases
+	// called out in the predicate's own doc comment: no extension, and
+	// multi-dot names, where a plain suffix match still requires the
+	// caller to spell out the full compound extension (e.g. "tar.gz").
+	tests := []struct {
+		fileName string
+		ext      string
+		want     bool
+	}{
+		{"photo.jpg", "jpg", true},
+		{"photo.JPG", "jpg", true},
+		{"photo.png", "jpg", false},
+		{"README", "jpg", false},
+		{"archive.tar.gz", "gz", true},
+		{"archive.tar.gz", "tar.gz", true},
+		{"archive.tar.gz", "tar", false},
+	}
+	for _, tt := range tests {
+		sc := &StringConstraint{HasSuffix: "." + tt.ext, CaseInsensitive: true}
+		if got := sc.stringMatches(tt.fileName); got != tt.want {
+			t.Errorf("stringMatches(%q) with ext %q = %v; want %v", tt.fileName, tt.ext, got, tt.want)
+		}
+	}
+}
@@ -26,7 +26,9 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"path"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,12 +38,16 @@ import (
 	"perkeep.org/pkg/blob"
 	"perkeep.org/pkg/index"
 	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/schema/nodeattr"
 	"perkeep.org/pkg/types/camtypes"
 
 	"context"
 
 	"go4.org/strutil"
 	"go4.org/types"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
 )
 
 type SortType int
@@ -50,16 +56,49 @@ const (
 	UnspecifiedSort SortType = iota
 	Unsorted
 	LastModifiedDesc
+	// LastModifiedAsc sorts permanodes oldest-modified first. Combined
+	// with Continue, it doubles as a delta/sync query: a client polls
+	// with the Continue token from its previous SearchResult (empty on
+	// the first poll) and gets back only the permanodes modified since
+	// then, oldest first, with a fresh Continue token to poll with next
+	// time. Unlike the *Desc sorts, whose Continue token is only
+	// returned for a full page, LastModifiedAsc always returns one when
+	// there are any results, since a sync client needs to advance past
+	// what it already saw regardless of page size.
+	//
+	// This reuses the existing modtime index and continue-token
+	// machinery rather than a separate changelog; there's no token
+	// expiry or compaction to worry about, since the token is just an
+	// opaque (modtime, blobref) position, not a reference into a
+	// bounded log. The one gap: permanode deletion isn't represented as
+	// a distinct event. A permanode that's since been deleted (see
+	// index.Corpus.IsDeleted) simply stops appearing in results,
+	// including here, rather than showing up once as a tombstone; a
+	// client that needs to notice deletions still has to reconcile its
+	// own view against a fresh non-incremental query occasionally.
 	LastModifiedAsc
 	CreatedDesc
 	CreatedAsc
 	BlobRefAsc
+	TakenAsc
+	TakenDesc
 	// MapSort requests that any limited search results are optimized
 	// for rendering on a map. If there are fewer matches than the
 	// requested limit, no results are pruned. When limiting results,
 	// MapSort prefers results spread around the map before clustering
 	// items too tightly.
 	MapSort
+	// ClaimCountDesc and ClaimCountAsc sort permanodes by how many
+	// claims have been made about them (see
+	// PermanodeConstraint.NumClaims), busiest (or quietest) first.
+	ClaimCountDesc
+	ClaimCountAsc
+	// TitleAsc and TitleDesc sort permanodes by their "title" attribute,
+	// using locale-aware collation (see SearchQuery.Locale) rather than
+	// raw byte order, so accented and non-ASCII titles sort the way a
+	// human reading that locale would expect.
+	TitleAsc
+	TitleDesc
 	maxSortType
 )
 
@@ -70,7 +109,13 @@ var sortName = map[SortType][]byte{
 	CreatedDesc:      []byte(`"-created"`),
 	CreatedAsc:       []byte(`"created"`),
 	BlobRefAsc:       []byte(`"blobref"`),
+	TakenAsc:         []byte(`"taken"`),
+	TakenDesc:        []byte(`"-taken"`),
 	MapSort:          []byte(`"map"`),
+	ClaimCountDesc:   []byte(`"-claimcount"`),
+	ClaimCountAsc:    []byte(`"claimcount"`),
+	TitleAsc:         []byte(`"title"`),
+	TitleDesc:        []byte(`"-title"`),
 }
 
 func (t SortType) MarshalJSON() ([]byte, error) {
@@ -109,6 +154,13 @@ type SearchQuery struct {
 	// query is about permanodes only.
 	Sort SortType `json:"sort,omitempty"`
 
+	// Locale optionally specifies a BCP 47 language tag (e.g. "fr" or
+	// "de-CH") whose collation rules are used to order results for
+	// TitleAsc or TitleDesc. It's ignored for all other Sort values.
+	// If empty, the default (locale-independent) Unicode collation
+	// order is used.
+	Locale string `json:"locale,omitempty"`
+
 	// Around specifies that the results, after sorting, should be centered around
 	// this result. If Around is not found the returned results will be empty.
 	// If both Continue and Around are set, an error is returned.
@@ -127,6 +179,20 @@ type SearchQuery struct {
 	// If Describe is specified, the matched blobs are also described,
 	// as if the Describe.BlobRefs field was populated.
 	Describe *DescribeRequest `json:"describe,omitempty"`
+
+	// If Tree is specified, SearchResult.Tree is also populated,
+	// organizing the matched blobs as a hierarchical tree instead of
+	// (in addition to) the flat Blobs list. Describe must also be
+	// specified, since tree construction walks the description of
+	// each result blob.
+	Tree *TreeOptions `json:"tree,omitempty"`
+
+	// WantMatchCount specifies that SearchResult.MatchCount should be
+	// populated with the total number of matches and blobs scanned,
+	// even when Limit causes fewer results to be returned. Computing
+	// it requires an extra counting pass over the candidates beyond
+	// what Limit would otherwise require, so it defaults to off.
+	WantMatchCount bool `json:"wantMatchCount,omitempty"`
 }
 
 func (q *SearchQuery) URLSuffix() string { return "camli/search/query" }
@@ -203,10 +269,10 @@ func (q *SearchQuery) addContinueConstraint() error {
 		if !ok {
 			return errors.New("Unexpected continue token")
 		}
-		if q.Sort == LastModifiedDesc || q.Sort == CreatedDesc {
+		if q.Sort == LastModifiedDesc || q.Sort == CreatedDesc || q.Sort == LastModifiedAsc {
 			var lastMod, lastCreated time.Time
 			switch q.Sort {
-			case LastModifiedDesc:
+			case LastModifiedDesc, LastModifiedAsc:
 				lastMod = tokent
 			case CreatedDesc:
 				lastCreated = tokent
@@ -221,6 +287,7 @@ func (q *SearchQuery) addContinueConstraint() error {
 								LastCreated: lastCreated,
 								LastMod:     lastMod,
 								Last:        lastbr,
+								Forward:     q.Sort == LastModifiedAsc,
 							},
 						},
 					},
@@ -246,6 +313,9 @@ func (q *SearchQuery) checkValid(ctx context.Context) (sq *SearchQuery, err erro
 	if q.Constraint != nil && q.Expression != "" {
 		return nil, errors.New("Constraint and Expression are mutually exclusive in a search query")
 	}
+	if q.Tree != nil && q.Describe == nil {
+		return nil, errors.New("Tree requires Describe to also be set")
+	}
 	if q.Constraint != nil {
 		return sq, q.Constraint.checkValid()
 	}
@@ -274,6 +344,142 @@ type SearchResult struct {
 	// continue fetching results in this result set, if interrupted
 	// by a Limit.
 	Continue string `json:"continue,omitempty"`
+
+	// Tree is non-nil if SearchQuery.Tree was set: one root TreeNode
+	// per entry in Blobs, in the same order, with descendants found
+	// by following the described blobs' camliMember/camliPath:*
+	// permanode attributes and directory children.
+	Tree []*TreeNode `json:"tree,omitempty"`
+
+	// MatchCount is non-nil if SearchQuery.WantMatchCount was set.
+	MatchCount *MatchCount `json:"matchCount,omitempty"`
+}
+
+// MatchCount reports how many candidate blobs were scanned and how
+// many of those matched a query's constraint, independently of any
+// Limit truncation of SearchResult.Blobs.
+type MatchCount struct {
+	// Matched is the total number of blobs that matched the query,
+	// even if Limit caused SearchResult.Blobs to contain fewer.
+	Matched int `json:"matched"`
+
+	// Scanned is the number of candidate blobs considered while
+	// computing Matched.
+	Scanned int `json:"scanned"`
+
+	// Estimated is true if Matched and Scanned are a lower bound
+	// rather than an exact count, because the query's other options
+	// (currently, Around) prevented a full scan of the candidates.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// TreeOptions controls how SearchQuery.Tree is built.
+type TreeOptions struct {
+	// MaxDepth optionally bounds how many levels below each root are
+	// expanded into TreeNode.Children, independently of any
+	// expansion depth requested of Describe. If zero, a default (8)
+	// is used. It also bounds cycles: a node revisited along its own
+	// ancestor chain is reported as Truncated rather than expanded
+	// again.
+	MaxDepth int `json:"maxDepth,omitempty"`
+}
+
+// TreeNode is one node of a SearchResult.Tree.
+type TreeNode struct {
+	Blob blob.Ref `json:"blob"`
+
+	// Children are Blob's members, in the order found in its
+	// description: for a permanode, its camliMember values followed
+	// by its camliPath:* targets; for a directory, its DirChildren.
+	// A permanode or directory referenced as a member of more than
+	// one parent appears, with its own children, under each parent.
+	Children []*TreeNode `json:"children,omitempty"`
+
+	// Truncated is true if Blob has members that aren't reflected in
+	// Children, because TreeOptions.MaxDepth was reached or because
+	// Blob is its own ancestor in this branch of the tree.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+const defaultTreeMaxDepth = 8
+
+// buildTree turns res.Blobs into a forest of TreeNodes rooted at
+// those blobs, using dres to look up each blob's members. It requires
+// dres to be the DescribeResponse for a request that at least covers
+// res.Blobs and (transitively) their members; blobs missing from dres
+// are returned as childless leaves.
+func buildTree(blobs []*SearchResultBlob, dres *DescribeResponse, opts *TreeOptions) []*TreeNode {
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultTreeMaxDepth
+	}
+	tree := make([]*TreeNode, 0, len(blobs))
+	ancestors := make(map[blob.Ref]bool)
+	for _, srb := range blobs {
+		tree = append(tree, buildTreeNode(srb.Blob, dres.Meta, maxDepth, ancestors))
+	}
+	return tree
+}
+
+func buildTreeNode(br blob.Ref, meta MetaMap, depthLeft int, ancestors map[blob.Ref]bool) *TreeNode {
+	node := &TreeNode{Blob: br}
+	if ancestors[br] {
+		node.Truncated = true
+		return node
+	}
+	children := describedMembers(meta.Get(br))
+	if len(children) == 0 {
+		return node
+	}
+	if depthLeft <= 0 {
+		node.Truncated = true
+		return node
+	}
+	ancestors[br] = true
+	for _, c := range children {
+		node.Children = append(node.Children, buildTreeNode(c, meta, depthLeft-1, ancestors))
+	}
+	delete(ancestors, br)
+	return node
+}
+
+// describedMembers returns the blobs that db's description says are
+// db's members: for a permanode, its camliMember values and its
+// camliPath:* targets; for a directory, its children.
+func describedMembers(db *DescribedBlob) []blob.Ref {
+	if db == nil {
+		return nil
+	}
+	if db.CamliType == schema.TypeDirectory {
+		return db.DirChildren
+	}
+	if db.Permanode == nil {
+		return nil
+	}
+	var members []blob.Ref
+	for _, v := range db.Permanode.Attr["camliMember"] {
+		if br, ok := blob.Parse(v); ok {
+			members = append(members, br)
+		}
+	}
+	var pathAttrs []string
+	for k := range db.Permanode.Attr {
+		if strings.HasPrefix(k, "camliPath:") {
+			pathAttrs = append(pathAttrs, k)
+		}
+	}
+	sort.Strings(pathAttrs)
+	for _, k := range pathAttrs {
+		vals := db.Permanode.Attr[k]
+		if len(vals) == 0 {
+			continue
+		}
+		// The last value of a multi-valued attribute is its current one.
+		if br, ok := blob.Parse(vals[len(vals)-1]); ok {
+			members = append(members, br)
+		}
+	}
+	return members
 }
 
 type SearchResultBlob struct {
@@ -307,6 +513,10 @@ type Constraint struct {
 
 	Permanode *PermanodeConstraint `json:"permanode,omitempty"`
 
+	// SavedSearch, if non-nil, matches whatever a previously saved
+	// search would match. See SavedSearchConstraint.
+	SavedSearch *SavedSearchConstraint `json:"savedSearch,omitempty"`
+
 	matcherOnce sync.Once
 	matcherFn   matchFn
 }
@@ -324,6 +534,7 @@ func (c *Constraint) checkValid() error {
 		c.Dir,
 		c.BlobSize,
 		c.Permanode,
+		c.SavedSearch,
 	} {
 		if err := cv.checkValid(); err != nil {
 			return err
@@ -438,9 +649,28 @@ type FileConstraint struct {
 	Height   *IntConstraint      `json:"height,omitempty"`
 	WHRatio  *FloatConstraint    `json:"widthHeightRation,omitempty"`
 	Location *LocationConstraint `json:"location,omitempty"`
+	Color    *ColorConstraint    `json:"color,omitempty"`
 
 	// MediaTag is for ID3 (and similar) embedded metadata in files.
 	MediaTag *MediaTagConstraint `json:"mediaTag,omitempty"`
+
+	// Content, if non-nil, matches files against their extracted
+	// full-text content (currently only extracted from PDFs; see the
+	// "content:" search predicate). Requires an in-memory index corpus.
+	Content *StringConstraint `json:"content,omitempty"`
+
+	// StorageName, if non-empty, only matches if the file's blob is
+	// present on the named blob storage handler (the handler's
+	// configured prefix, with slashes trimmed, e.g. "sto-cloud" for a
+	// handler configured at "/sto-cloud/"). See the "storage" search
+	// predicate.
+	StorageName string `json:"storage,omitempty"`
+
+	// HasThumbnail, if non-nil, only matches files that do (*HasThumbnail
+	// true) or don't (false) already have a thumbnail cached, per the
+	// search Handler's configured ThumbnailChecker. See the
+	// "hasthumbnail" search predicate.
+	HasThumbnail *bool `json:"hasThumbnail,omitempty"`
 }
 
 type MediaTagConstraint struct {
@@ -562,8 +792,12 @@ func (c *FloatConstraint) floatMatches(v float64) bool {
 }
 
 type EXIFConstraint struct {
-	// TODO.  need to put this in the index probably.
-	// Maybe: GPS *LocationConstraint
+	// DateTaken, if non-nil, matches the image's EXIF "date taken",
+	// as opposed to any filesystem or upload time. Images with no
+	// EXIF date never match.
+	DateTaken *TimeConstraint `json:"dateTaken,omitempty"`
+
+	// TODO: GPS *LocationConstraint
 	// ISO, Aperature, Camera Make/Model, etc.
 }
 
@@ -593,6 +827,69 @@ func (c *LocationConstraint) matchesLatLong(lat, long float64) bool {
 	return c.West <= long || long <= c.East
 }
 
+// defaultColorMaxDistance is used by ColorConstraint when MaxDistance
+// is zero, so a bare color match still tolerates minor
+// compression/quantization noise instead of requiring an exact hit.
+const defaultColorMaxDistance = 30
+
+// ColorConstraint matches images with a dominant color near Hex.
+type ColorConstraint struct {
+	// Hex is a color as a "RRGGBB" or "#RRGGBB" hex triplet.
+	Hex string `json:"hex"`
+
+	// MaxDistance is how far (in RGB Euclidean distance, each channel
+	// 0-255) one of the image's dominant colors may be from Hex and
+	// still match. If zero, defaultColorMaxDistance is used.
+	MaxDistance int `json:"maxDistance,omitempty"`
+}
+
+// paletteMatches reports whether any color in palette (as "RRGGBB"
+// hex triplets, e.g. from ImageInfo.DominantColors) is within c's
+// tolerance of c.Hex.
+func (c *ColorConstraint) paletteMatches(palette []string) bool {
+	want, ok := parseHexColor(c.Hex)
+	if !ok {
+		return false
+	}
+	maxDist := c.MaxDistance
+	if maxDist <= 0 {
+		maxDist = defaultColorMaxDistance
+	}
+	for _, hex := range palette {
+		got, ok := parseHexColor(hex)
+		if !ok {
+			continue
+		}
+		if colorDistance(want, got) <= float64(maxDist) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHexColor parses a "RRGGBB" or "#RRGGBB" string into 0-255 RGB
+// components.
+func parseHexColor(s string) (rgb [3]int, ok bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return rgb, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return rgb, false
+	}
+	return [3]int{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}, true
+}
+
+// colorDistance is the Euclidean distance between two RGB colors,
+// each channel in 0-255.
+func colorDistance(a, b [3]int) float64 {
+	dr := float64(a[0] - b[0])
+	dg := float64(a[1] - b[1])
+	db := float64(a[2] - b[2])
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
 // A StringConstraint specifies constraints on a string.
 // All non-zero must match.
 type StringConstraint struct {
@@ -604,9 +901,59 @@ type StringConstraint struct {
 	ByteLength      *IntConstraint `json:"byteLength,omitempty"` // length in bytes (not chars)
 	CaseInsensitive bool           `json:"caseInsensitive,omitempty"`
 
+	// Glob optionally matches using shell file name matching, as in
+	// path.Match: "*" matches any sequence of non-Separator
+	// characters, "?" matches any single non-Separator character,
+	// and "[...]" matches a character class. E.g. "project-*" or
+	// "Invoice-????".
+	Glob string `json:"glob,omitempty"`
+
+	// Regexp optionally matches the value against an RE2 regular
+	// expression, as in Go's regexp package. It's implicitly
+	// anchored at neither end, so use "^" and "$" for a full-value
+	// match. Its length is capped at maxStringConstraintRegexpLen to
+	// bound compilation and matching cost.
+	Regexp string `json:"regexp,omitempty"`
+
+	re     *regexp.Regexp
+	reOnce sync.Once
+	reErr  error
+
 	// TODO: CharLength (assume UTF-8)
 }
 
+// maxStringConstraintRegexpLen bounds the length of StringConstraint.Regexp.
+// Go's regexp package (RE2) guarantees linear-time matching, so there's no
+// catastrophic-backtracking risk, but an unbounded pattern is still an easy
+// way to make each match arbitrarily expensive to compile.
+const maxStringConstraintRegexpLen = 512
+
+// checkValid validates the Regexp field, if set, so a bad pattern is
+// reported once at query time instead of on every blob evaluated.
+func (c *StringConstraint) checkValid() error {
+	if c == nil || c.Regexp == "" {
+		return nil
+	}
+	if len(c.Regexp) > maxStringConstraintRegexpLen {
+		return fmt.Errorf("StringConstraint.Regexp is %d bytes; max is %d", len(c.Regexp), maxStringConstraintRegexpLen)
+	}
+	_, err := c.compiledRegexp()
+	return err
+}
+
+// compiledRegexp lazily compiles and caches c.Regexp, honoring
+// CaseInsensitive.
+func (c *StringConstraint) compiledRegexp() (*regexp.Regexp, error) {
+	c.reOnce.Do(func() {
+		pat := c.Regexp
+		if c.CaseInsensitive {
+			pat = "(?i)" + pat
+		}
+		c.re, c.reErr = regexp.Compile(pat)
+	})
+	return c.re, c.reErr
+}
+
 // stringCompareFunc contains a function to get a value from a StringConstraint and a second function to compare it
 // against the string s that's being matched.
 type stringConstraintFunc struct {
@@ -647,6 +994,21 @@ func (c *StringConstraint) stringMatches(s string) bool {
 			return false
 		}
 	}
+	if c.Glob != "" {
+		pat, val := c.Glob, s
+		if c.CaseInsensitive {
+			pat, val = strings.ToLower(pat), strings.ToLower(val)
+		}
+		if matched, _ := path.Match(pat, val); !matched {
+			return false
+		}
+	}
+	if c.Regexp != "" {
+		re, err := c.compiledRegexp()
+		if err != nil || !re.MatchString(s) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -675,6 +1037,57 @@ type LogicalConstraint struct {
 	B  *Constraint `json:"b"` // only valid if Op != "not"
 }
 
+// SavedSearchConstraint matches whatever a previously saved search
+// named Name would match. Wrapping two SavedSearchConstraints (or one
+// SavedSearchConstraint and any other Constraint) in a LogicalConstraint
+// computes their intersection ("and"), union ("or"), symmetric
+// difference ("xor"), or complement ("not"); e.g. "items in A but not
+// in B" is {Op: "and", A: {SavedSearch: {Name: "A"}}, B: {Op: "not",
+// A: {SavedSearch: {Name: "B"}}}}.
+//
+// Name is resolved against the search Handler's saved-search store
+// (see Handler.SetSavedSearch) when the constraint is matched against
+// a blob. Handler.SetSavedSearch itself refuses to save a search that
+// would, directly or transitively, reference its own name; blobMatches
+// additionally guards against a cycle slipping through anyway (e.g. a
+// registered SearchQuery's Constraint being modified afterwards, since
+// SetSavedSearch keeps the caller's pointer).
+type SavedSearchConstraint struct {
+	Name string `json:"name"`
+}
+
+func (c *SavedSearchConstraint) checkValid() error {
+	if c == nil {
+		return nil
+	}
+	if c.Name == "" {
+		return errors.New("SavedSearchConstraint requires Name")
+	}
+	return nil
+}
+
+// blobMatches expands c.Name against s.h's saved-search store and
+// matches br against the resulting constraint.
+func (c *SavedSearchConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref, bm camtypes.BlobMeta) (bool, error) {
+	for _, name := range s.expandingSavedSearches {
+		if name == c.Name {
+			return false, fmt.Errorf("saved search %q is self-referential", c.Name)
+		}
+	}
+	sq, ok := s.h.GetSavedSearch(c.Name)
+	if !ok {
+		return false, fmt.Errorf("no saved search named %q", c.Name)
+	}
+	if sq.Constraint == nil {
+		return false, fmt.Errorf("saved search %q has no constraint to expand", c.Name)
+	}
+	s.expandingSavedSearches = append(s.expandingSavedSearches, c.Name)
+	defer func() {
+		s.expandingSavedSearches = s.expandingSavedSearches[:len(s.expandingSavedSearches)-1]
+	}()
+	return sq.Constraint.matcher()(ctx, s, br, bm)
+}
+
 // PermanodeConstraint matches permanodes.
 type PermanodeConstraint struct {
 	// At specifies the time at which to pretend we're resolving attributes.
@@ -741,11 +1154,17 @@ type PermanodeConstraint struct {
 	// type to have an lat/long location.
 	Location *LocationConstraint `json:"location,omitempty"`
 
+	// NumClaims optionally tests the number of claims that have
+	// been made about this permanode (its "activity"), including
+	// claims that no longer affect its current attributes, such as
+	// a delete claim or a since-overwritten set-attribute claim.
+	// Requires an in-memory corpus.
+	NumClaims *IntConstraint `json:"numClaims,omitempty"`
+
 	// Continue is for internal use.
 	Continue *PermanodeContinueConstraint `json:"-"`
 
 	// TODO:
-	// NumClaims *IntConstraint  // by owner
 	// Owner  blob.Ref // search for permanodes by an owner
 
 	// Note: When adding a field, update hasValueConstraint.
@@ -767,6 +1186,16 @@ type PermanodeContinueConstraint struct {
 	// If the time is past this in the scroll position, then this
 	// field is ignored.
 	Last blob.Ref
+
+	// Forward reverses the sense of LastMod and Last above: it's set when
+	// scrolling forwards through an ascending (oldest/least-recent-first)
+	// sort, such as LastModifiedAsc, instead of backwards through a
+	// descending one. Permanodes with a LastMod time before LastMod, or
+	// equal to it with a Last blobref that's not after Last, are excluded.
+	// This is what lets a sync client ask for only what changed after a
+	// previously-seen point, rather than scrolling a fixed page further
+	// back into history.
+	Forward bool
 }
 
 func (pcc *PermanodeContinueConstraint) checkValid() error {
@@ -921,6 +1350,12 @@ type search struct {
 	// the corpus instead, then we wouldn't need this. And then
 	// searches would be faster anyway. This is a hack.
 	loc map[blob.Ref]camtypes.Location
+
+	// expandingSavedSearches tracks the names of saved searches
+	// currently being expanded by SavedSearchConstraint.blobMatches, so
+	// a saved search that (directly or transitively) references itself
+	// is rejected instead of recursing forever.
+	expandingSavedSearches []string
 }
 
 func (s *search) blobMeta(ctx context.Context, br blob.Ref) (camtypes.BlobMeta, error) {
@@ -1024,6 +1459,18 @@ func (h *Handler) Query(ctx context.Context, rawq *SearchQuery) (ret_ *SearchRes
 	}
 	blobMatches := q.Constraint.matcher()
 
+	var mc *MatchCount
+	if q.WantMatchCount {
+		mc = &MatchCount{}
+		res.MatchCount = mc
+		if wantAround {
+			// Around's windowing logic bails out as soon as it has
+			// enough results around the target blob, so it can't
+			// also drive an exhaustive counting pass.
+			mc.Estimated = true
+		}
+	}
+
 	var enumErr error
 	cands.send(ctx, s, func(meta camtypes.BlobMeta) bool {
 		match, err := blobMatches(ctx, s, meta.Ref, meta)
@@ -1031,26 +1478,51 @@ func (h *Handler) Query(ctx context.Context, rawq *SearchQuery) (ret_ *SearchRes
 			enumErr = err
 			return false
 		}
+		if mc != nil {
+			mc.Scanned++
+		}
 		if match {
-			res.Blobs = append(res.Blobs, &SearchResultBlob{
-				Blob: meta.Ref,
-			})
+			if mc != nil {
+				mc.Matched++
+			}
 			if q.Sort == MapSort {
 				// We need all the matching blobs to apply the MapSort selection afterwards, so
 				// we temporarily ignore the limit.
 				// TODO(mpl): the above means that we also ignore Continue and Around here. I
 				// don't think we need them for the map aspect for now though.
+				res.Blobs = append(res.Blobs, &SearchResultBlob{
+					Blob: meta.Ref,
+				})
 				return true
 			}
 			if q.Limit <= 0 || !cands.sorted {
+				res.Blobs = append(res.Blobs, &SearchResultBlob{
+					Blob: meta.Ref,
+				})
 				if wantAround && !foundAround && q.Around == meta.Ref {
 					foundAround = true
 				}
 				return true
 			}
 			if !wantAround || foundAround {
+				// Keep scanning past Limit when an exact MatchCount
+				// was requested, without materializing more than
+				// Limit results, so Matched ends up exact instead of
+				// just "however many we happened to see before
+				// stopping".
+				if len(res.Blobs) < q.Limit {
+					res.Blobs = append(res.Blobs, &SearchResultBlob{
+						Blob: meta.Ref,
+					})
+				}
+				if mc != nil {
+					return true
+				}
 				return len(res.Blobs) != q.Limit
 			}
+			res.Blobs = append(res.Blobs, &SearchResultBlob{
+				Blob: meta.Ref,
+			})
 			if q.Around == meta.Ref {
 				foundAround = true
 				if len(res.Blobs)*2 > q.Limit {
@@ -1125,6 +1597,76 @@ func (h *Handler) Query(ctx context.Context, rawq *SearchQuery) (ret_ *SearchRes
 			if err != nil {
 				return nil, err
 			}
+		case TakenAsc, TakenDesc:
+			if corpus == nil {
+				return nil, errors.New("TODO: Sorting without a corpus unsupported")
+			}
+			if !q.Constraint.onlyMatchesPermanode() {
+				return nil, errors.New("can only sort by taken time when all results are permanodes")
+			}
+			var err error
+			sort.Sort(sortSearchResultBlobs{res.Blobs, func(a, b *SearchResultBlob) bool {
+				if err != nil {
+					return false
+				}
+				ta, ok := corpus.PermanodeTakenTime(a.Blob)
+				if !ok {
+					err = fmt.Errorf("no EXIF date taken found for %v", a.Blob)
+					return false
+				}
+				tb, ok := corpus.PermanodeTakenTime(b.Blob)
+				if !ok {
+					err = fmt.Errorf("no EXIF date taken found for %v", b.Blob)
+					return false
+				}
+				if q.Sort == TakenAsc {
+					return ta.Before(tb)
+				}
+				return tb.Before(ta)
+			}})
+			if err != nil {
+				return nil, err
+			}
+		case ClaimCountDesc, ClaimCountAsc:
+			if corpus == nil {
+				return nil, errors.New("TODO: Sorting without a corpus unsupported")
+			}
+			if !q.Constraint.onlyMatchesPermanode() {
+				return nil, errors.New("can only sort by claim count when all results are permanodes")
+			}
+			sort.Sort(sortSearchResultBlobs{res.Blobs, func(a, b *SearchResultBlob) bool {
+				ca := corpus.ClaimCount(a.Blob)
+				cb := corpus.ClaimCount(b.Blob)
+				if q.Sort == ClaimCountAsc {
+					return ca < cb
+				}
+				return ca > cb
+			}})
+		case TitleAsc, TitleDesc:
+			if corpus == nil {
+				return nil, errors.New("TODO: Sorting without a corpus unsupported")
+			}
+			if !q.Constraint.onlyMatchesPermanode() {
+				return nil, errors.New("can only sort by title when all results are permanodes")
+			}
+			tag, err := language.Parse(q.Locale)
+			if err != nil {
+				tag = language.Und
+			}
+			col := collate.New(tag)
+			var buf collate.Buffer
+			keys := make(map[blob.Ref][]byte, len(res.Blobs))
+			for _, sb := range res.Blobs {
+				title := corpus.PermanodeAttrValue(sb.Blob, nodeattr.Title, time.Time{}, h.owner.KeyID())
+				keys[sb.Blob] = append([]byte(nil), col.KeyFromString(&buf, title)...)
+			}
+			sort.Sort(sortSearchResultBlobs{res.Blobs, func(a, b *SearchResultBlob) bool {
+				cmp := bytes.Compare(keys[a.Blob], keys[b.Blob])
+				if q.Sort == TitleDesc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}})
 		// TODO(mpl): LastModifiedDesc, LastModifiedAsc
 		default:
 			return nil, errors.New("TODO: unsupported sort+query combination.")
@@ -1197,6 +1739,10 @@ func (h *Handler) Query(ctx context.Context, rawq *SearchQuery) (ret_ *SearchRes
 			return nil, err
 		}
 		s.res.Describe = res
+
+		if q.Tree != nil {
+			s.res.Tree = buildTree(s.res.Blobs, res, q.Tree)
+		}
 	}
 
 	return s.res, nil
@@ -1360,7 +1906,7 @@ func (q *SearchQuery) setResultContinue(corpus *index.Corpus, res *SearchResult)
 	}
 	var pnTimeFunc func(blob.Ref) (t time.Time, ok bool)
 	switch q.Sort {
-	case LastModifiedDesc:
+	case LastModifiedDesc, LastModifiedAsc:
 		pnTimeFunc = corpus.PermanodeModtime
 	case CreatedDesc:
 		pnTimeFunc = corpus.PermanodeAnyTime
@@ -1368,7 +1914,14 @@ func (q *SearchQuery) setResultContinue(corpus *index.Corpus, res *SearchResult)
 		return
 	}
 
-	if q.Limit <= 0 || len(res.Blobs) != q.Limit {
+	if q.Sort != LastModifiedAsc && (q.Limit <= 0 || len(res.Blobs) != q.Limit) {
+		return
+	}
+	// For LastModifiedAsc, always advance the token to the last result
+	// seen, even short of Limit: a sync client polling for what changed
+	// needs a token good for its next poll regardless of how many
+	// changes there happened to be, not just when a page was full.
+	if len(res.Blobs) == 0 {
 		return
 	}
 	lastpn := res.Blobs[len(res.Blobs)-1].Blob
@@ -1422,7 +1975,28 @@ func (q *SearchQuery) pickCandidateSource(s *search) (src candidateSource) {
 					return nil
 				}
 				return
+			case LastModifiedAsc:
+				src.name = "corpus_permanode_lastmod_asc"
+				src.send = func(ctx context.Context, s *search, fn func(camtypes.BlobMeta) bool) error {
+					corpus.EnumeratePermanodesLastModifiedAsc(fn)
+					return nil
+				}
+				return
 			case CreatedDesc:
+				if typs := c.matchesPermanodeTypes(); len(typs) == 1 {
+					// A single required camliNodeType value: use the
+					// (nodeType, reverse-time) composite index so a
+					// "type X created in range Y" query only walks
+					// permanodes of that type, instead of every
+					// permanode in creation order.
+					t := typs[0]
+					src.name = "corpus_permanode_nodetype_created"
+					src.send = func(ctx context.Context, s *search, fn func(camtypes.BlobMeta) bool) error {
+						corpus.EnumeratePermanodesByNodeTypeCreated(fn, t, true)
+						return nil
+					}
+					return
+				}
 				src.name = "corpus_permanode_created"
 				src.send = func(ctx context.Context, s *search, fn func(camtypes.BlobMeta) bool) error {
 					corpus.EnumeratePermanodesCreated(fn, true)
@@ -1528,6 +2102,9 @@ func (c *Constraint) genMatcher() matchFn {
 		addCond(c.Permanode.blobMatches)
 	}
 	// TODO: ClaimConstraint
+	if c.SavedSearch != nil {
+		addCond(c.SavedSearch.blobMatches)
+	}
 	if c.File != nil {
 		addCond(c.File.blobMatches)
 	}
@@ -1631,6 +2208,9 @@ func (c *PermanodeConstraint) checkValid() error {
 	if c == nil {
 		return nil
 	}
+	if err := c.ValueMatches.checkValid(); err != nil {
+		return err
+	}
 	if c.Attr != "" {
 		if c.NumValue == nil && !c.hasValueConstraint() {
 			return errors.New("PermanodeConstraint with Attr requires also setting NumValue or a value-matching constraint")
@@ -1652,6 +2232,11 @@ func (c *PermanodeConstraint) checkValid() error {
 			return err
 		}
 	}
+	if nc := c.NumClaims; nc != nil {
+		if err := nc.checkValid(); err != nil {
+			return err
+		}
+	}
 	if pcc := c.Continue; pcc != nil {
 		if err := pcc.checkValid(); err != nil {
 			return err
@@ -1665,7 +2250,7 @@ var numPermanodeFields = reflect.TypeOf(PermanodeConstraint{}).NumField()
 // hasValueConstraint returns true if one or more constraints that check an attribute's value are set.
 func (c *PermanodeConstraint) hasValueConstraint() bool {
 	// If a field has been added or removed, update this after adding the new field to the return statement if necessary.
-	const expectedFields = 15
+	const expectedFields = 16
 	if numPermanodeFields != expectedFields {
 		panic(fmt.Sprintf("PermanodeConstraint field count changed (now %v rather than %v)", numPermanodeFields, expectedFields))
 	}
@@ -1676,6 +2261,62 @@ func (c *PermanodeConstraint) hasValueConstraint() bool {
 		c.ValueInSet != nil
 }
 
+// maxHiddenAncestorDepth bounds how many camliMember/camliPath ancestor
+// hops permanodeOrAncestorHidden will walk looking for a hidden
+// container, so that a pathological or (in theory impossible, but
+// let's be safe) cyclic containment graph can't hang a query.
+const maxHiddenAncestorDepth = 16
+
+// permanodeHidden reports whether br is itself marked hidden, via
+// nodeattr.Hidden, the older camliDefVis=hide marker, or nodeattr.Trashed
+// (moved to trash).
+func permanodeHidden(corpus *index.Corpus, br blob.Ref, at time.Time, signerFilter string) bool {
+	if corpus.PermanodeAttrValue(br, nodeattr.Hidden, at, signerFilter) == "true" {
+		return true
+	}
+	if corpus.PermanodeAttrValue(br, "camliDefVis", at, signerFilter) == "hide" {
+		return true
+	}
+	return corpus.PermanodeAttrValue(br, nodeattr.Trashed, at, signerFilter) != ""
+}
+
+// permanodeOrAncestorHidden reports whether br is hidden, or is
+// currently a camliMember or camliPath:* member of a container that is
+// itself hidden (transitively). This lets hiding a container hide
+// everything reachable only through it, without having to mark each
+// member individually.
+func permanodeOrAncestorHidden(corpus *index.Corpus, br blob.Ref, at time.Time, signerFilter string, depth int, seen map[blob.Ref]bool) bool {
+	if permanodeHidden(corpus, br, at, signerFilter) {
+		return true
+	}
+	if depth >= maxHiddenAncestorDepth {
+		return false
+	}
+	if seen == nil {
+		seen = make(map[blob.Ref]bool)
+	}
+	if seen[br] {
+		return false
+	}
+	seen[br] = true
+
+	hidden := false
+	corpus.ForeachClaimBack(br, at, func(cl *camtypes.Claim) bool {
+		if cl.Attr != "camliMember" && !strings.HasPrefix(cl.Attr, "camliPath:") {
+			return true // not a containment claim; keep looking
+		}
+		if !corpus.PermanodeHasAttrValue(cl.Permanode, at, cl.Attr, cl.Value) {
+			return true // claim once matched, but no longer live
+		}
+		if permanodeOrAncestorHidden(corpus, cl.Permanode, at, signerFilter, depth+1, seen) {
+			hidden = true
+			return false // done
+		}
+		return true
+	})
+	return hidden
+}
+
 func (c *PermanodeConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref, bm camtypes.BlobMeta) (ok bool, err error) {
 	if bm.CamliType != schema.TypePermanode {
 		return false, nil
@@ -1714,8 +2355,7 @@ func (c *PermanodeConstraint) blobMatches(ctx context.Context, s *search, br blo
 	}
 
 	if c.SkipHidden && corpus != nil {
-		defVis := corpus.PermanodeAttrValue(br, "camliDefVis", c.At, s.h.owner.KeyID())
-		if defVis == "hide" {
+		if permanodeOrAncestorHidden(corpus, br, c.At, s.h.owner.KeyID(), 0, nil) {
 			return false, nil
 		}
 		nodeType := corpus.PermanodeAttrValue(br, "camliNodeType", c.At, s.h.owner.KeyID())
@@ -1757,6 +2397,15 @@ func (c *PermanodeConstraint) blobMatches(ctx context.Context, s *search, br blo
 		}
 	}
 
+	if nc := c.NumClaims; nc != nil {
+		if corpus == nil {
+			return false, errors.New("PermanodeConstraint.NumClaims requires an in-memory corpus")
+		}
+		if !nc.intMatches(int64(corpus.ClaimCount(br))) {
+			return false, nil
+		}
+	}
+
 	if c.Location != nil || s.q.Sort == MapSort {
 		l, err := s.h.lh.PermanodeLocation(ctx, br, c.At, s.h.owner)
 		if c.Location != nil {
@@ -1781,6 +2430,26 @@ func (c *PermanodeConstraint) blobMatches(ctx context.Context, s *search, br blo
 			// scroll. At least for now.
 			return false, nil
 		}
+		if cc.Forward {
+			// Scrolling forwards through an ascending sort (e.g.
+			// LastModifiedAsc): keep only permanodes strictly after
+			// the last one seen, so a sync client polling with the
+			// previous result's Continue token gets only what
+			// changed since then.
+			//     mod3, sha1-aa
+			//     mod3, sha1-bb <--- last seen item, continue = "pn:mod3:sha1-bb"
+			//     mod3, sha1-cc  <-- and we want this one next.
+			//     mod4, sha1-72
+			//     mod5, sha1-25
+			pnTime, ok := corpus.PermanodeModtime(br)
+			if !ok || pnTime.Before(cc.LastMod) {
+				return false, nil
+			}
+			if pnTime.Equal(cc.LastMod) && !cc.Last.Less(br) {
+				return false, nil
+			}
+			return true, nil
+		}
 		var pnTime time.Time
 		var ok bool
 		switch {
@@ -1877,6 +2546,18 @@ func (c *PermanodeConstraint) permanodeMatchesAttrVal(ctx context.Context, s *se
 }
 
 func (c *FileConstraint) checkValid() error {
+	if c == nil {
+		return nil
+	}
+	if err := c.FileName.checkValid(); err != nil {
+		return err
+	}
+	if err := c.MIMEType.checkValid(); err != nil {
+		return err
+	}
+	if err := c.Content.checkValid(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -1913,6 +2594,13 @@ func (c *FileConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref
 			return false, nil
 		}
 	}
+	if ec := c.EXIF; ec != nil {
+		if tc := ec.DateTaken; tc != nil {
+			if fi.Taken == nil || !tc.timeMatches(fi.Taken.Time()) {
+				return false, nil
+			}
+		}
+	}
 	if pc := c.ParentDir; pc != nil {
 		parents, err := s.parentDirs(ctx, br)
 		if err == os.ErrNotExist {
@@ -1943,6 +2631,24 @@ func (c *FileConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref
 			return false, nil
 		}
 	}
+	if c.StorageName != "" {
+		ok, err := s.h.blobInStorage(ctx, br, c.StorageName)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	if c.HasThumbnail != nil {
+		has, err := s.h.hasThumbnail(ctx, br)
+		if err != nil {
+			return false, err
+		}
+		if has != *c.HasThumbnail {
+			return false, nil
+		}
+	}
 	corpus := s.h.corpus
 	if c.WholeRef.Valid() {
 		if corpus == nil {
@@ -1954,7 +2660,8 @@ func (c *FileConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref
 		}
 	}
 	var width, height int64
-	if c.Width != nil || c.Height != nil || c.WHRatio != nil {
+	var colors []string
+	if c.Width != nil || c.Height != nil || c.WHRatio != nil || c.Color != nil {
 		if corpus == nil {
 			return false, nil
 		}
@@ -1967,6 +2674,7 @@ func (c *FileConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref
 		}
 		width = int64(imageInfo.Width)
 		height = int64(imageInfo.Height)
+		colors = imageInfo.DominantColors
 	}
 	if c.Width != nil && !c.Width.intMatches(width) {
 		return false, nil
@@ -1977,6 +2685,9 @@ func (c *FileConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref
 	if c.WHRatio != nil && !c.WHRatio.floatMatches(float64(width)/float64(height)) {
 		return false, nil
 	}
+	if c.Color != nil && !c.Color.paletteMatches(colors) {
+		return false, nil
+	}
 	if c.Location != nil {
 		if corpus == nil {
 			return false, nil
@@ -2024,6 +2735,18 @@ func (c *FileConstraint) blobMatches(ctx context.Context, s *search, br blob.Ref
 			return false, nil
 		}
 	}
+	if sc := c.Content; sc != nil {
+		if corpus == nil {
+			return false, nil
+		}
+		text, err := corpus.GetFileText(ctx, br)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if !sc.stringMatches(text) {
+			return false, nil
+		}
+	}
 	// TODO: EXIF timeconstraint
 	return true, nil
 }
@@ -2056,6 +2779,9 @@ func (c *DirConstraint) checkValid() error {
 	if c.Contains != nil && c.RecursiveContains != nil {
 		return errors.New("Contains and RecursiveContains in a DirConstraint are mutually exclusive")
 	}
+	if err := c.FileName.checkValid(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -206,6 +206,42 @@ func TestQueryCamliType(t *testing.T) {
 	})
 }
 
+func TestQueryWantMatchCount(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		f1, _ := qt.id.UploadFile("file1.txt", "foo", time.Unix(1382073153, 0))
+		f2, _ := qt.id.UploadFile("file2.txt", "bar", time.Unix(1382073154, 0))
+		f3, _ := qt.id.UploadFile("file3.txt", "baz", time.Unix(1382073155, 0))
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				CamliType: "file",
+			},
+			Sort:           BlobRefAsc,
+			Limit:          1,
+			WantMatchCount: true,
+		}
+		if qt.itype == indexClassic {
+			sq.Sort = Unsorted
+		}
+		res, err := qt.Handler().Query(ctxbg, sq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(res.Blobs), 1; got != want {
+			t.Errorf("len(res.Blobs) = %d; want %d (Limit should still truncate the returned results)", got, want)
+		}
+		if res.MatchCount == nil {
+			t.Fatal("MatchCount is nil; want non-nil")
+		}
+		if got, want := res.MatchCount.Matched, 3; got != want {
+			t.Errorf("MatchCount.Matched = %d; want %d (all of %v, %v, %v matched, even though Limit=1)", got, want, f1, f2, f3)
+		}
+		if res.MatchCount.Estimated {
+			t.Error("MatchCount.Estimated = true; want false")
+		}
+	})
+}
+
 func TestQueryAnyCamliType(t *testing.T) {
 	testQuery(t, func(qt *queryTest) {
 		fileRef, _ := qt.id.UploadFile("file.txt", "foo", time.Unix(1382073153, 0))
@@ -389,6 +425,55 @@ func TestQueryLogicalNot(t *testing.T) {
 	})
 }
 
+func TestQuerySavedSearch(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+
+		// foo is sha224-0808f64e60d58979fcb676c96ec938270dea42445aeefcd3a4e6f8db
+		_, foo := id.UploadFile("file.txt", "foo", time.Unix(1382073153, 0))
+		// bar is sha224-07daf010de7f7f0d8d76a76eb8d1eb40182c8d1e7a3877a6686c9bf0
+		_, bar := id.UploadFile("file.txt", "bar", time.Unix(1382073153, 0))
+
+		if err := qt.Handler().SetSavedSearch("foos", &SearchQuery{
+			Constraint: &Constraint{BlobRefPrefix: "sha224-08"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := qt.Handler().SetSavedSearch("bars", &SearchQuery{
+			Constraint: &Constraint{BlobRefPrefix: "sha224-07"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Logical: &LogicalConstraint{
+					Op: "or",
+					A:  &Constraint{SavedSearch: &SavedSearchConstraint{Name: "foos"}},
+					B:  &Constraint{SavedSearch: &SavedSearchConstraint{Name: "bars"}},
+				},
+			},
+		}
+		qt.wantRes(sq, foo, bar)
+	})
+}
+
+func TestQuerySavedSearchUnknown(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+		id.UploadFile("file.txt", "foo", time.Unix(1382073153, 0))
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				SavedSearch: &SavedSearchConstraint{Name: "nonexistent"},
+			},
+		}
+		if _, err := qt.Handler().Query(ctxbg, sq); err == nil {
+			t.Fatal("expected an error querying an unknown saved search, got nil")
+		}
+	})
+}
+
 func TestQueryPermanodeAttrExact(t *testing.T) {
 	testQuery(t, func(qt *queryTest) {
 		id := qt.id
@@ -410,6 +495,108 @@ func TestQueryPermanodeAttrExact(t *testing.T) {
 	})
 }
 
+func TestQueryPermanodeNumClaims(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+
+		p1 := id.NewPlannedPermanode("1")
+		id.SetAttribute(p1, "someAttr", "value1")
+
+		p2 := id.NewPlannedPermanode("2")
+		id.SetAttribute(p2, "someAttr", "value1")
+		id.SetAttribute(p2, "someAttr", "value2")
+		id.DelAttribute(p2, "someAttr", "")
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					NumClaims: &IntConstraint{Min: 3},
+				},
+			},
+		}
+		qt.wantRes(sq, p2)
+	})
+}
+
+func TestQuerySortClaimCount(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+
+		p1 := id.NewPlannedPermanode("1")
+		id.SetAttribute(p1, "someAttr", "value1")
+
+		p2 := id.NewPlannedPermanode("2")
+		id.SetAttribute(p2, "someAttr", "value1")
+		id.SetAttribute(p2, "someAttr", "value2")
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					NumClaims: &IntConstraint{ZeroMin: true},
+				},
+			},
+			Sort: ClaimCountDesc,
+		}
+		res, err := qt.Handler().Query(ctxbg, sq)
+		if err != nil {
+			qt.t.Fatal(err)
+		}
+		if len(res.Blobs) != 2 || res.Blobs[0].Blob != p2 || res.Blobs[1].Blob != p1 {
+			qt.t.Fatalf("got %v; want [%v %v]", res.Blobs, p2, p1)
+		}
+	})
+}
+
+func TestQuerySortTitle(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+
+		p1 := id.NewPlannedPermanode("1")
+		id.SetAttribute(p1, "title", "Zebra")
+
+		p2 := id.NewPlannedPermanode("2")
+		id.SetAttribute(p2, "title", "Étude")
+
+		p3 := id.NewPlannedPermanode("3")
+		id.SetAttribute(p3, "title", "apple")
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{},
+			},
+			Sort: TitleAsc,
+		}
+		res, err := qt.Handler().Query(ctxbg, sq)
+		if err != nil {
+			qt.t.Fatal(err)
+		}
+		want := []blob.Ref{p3, p2, p1} // apple, Étude, Zebra
+		if len(res.Blobs) != len(want) {
+			qt.t.Fatalf("got %v; want %v", res.Blobs, want)
+		}
+		for i, sb := range res.Blobs {
+			if sb.Blob != want[i] {
+				qt.t.Errorf("Blobs[%d] = %v; want %v", i, sb.Blob, want[i])
+			}
+		}
+
+		sq.Sort = TitleDesc
+		res, err = qt.Handler().Query(ctxbg, sq)
+		if err != nil {
+			qt.t.Fatal(err)
+		}
+		want = []blob.Ref{p1, p2, p3}
+		if len(res.Blobs) != len(want) {
+			qt.t.Fatalf("got %v; want %v", res.Blobs, want)
+		}
+		for i, sb := range res.Blobs {
+			if sb.Blob != want[i] {
+				qt.t.Errorf("Blobs[%d] = %v; want %v", i, sb.Blob, want[i])
+			}
+		}
+	})
+}
+
 func TestQueryPermanodeAttrMatches(t *testing.T) {
 	testQuery(t, func(qt *queryTest) {
 		id := qt.id
@@ -435,6 +622,74 @@ func TestQueryPermanodeAttrMatches(t *testing.T) {
 	})
 }
 
+func TestQueryPermanodeAttrGlob(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+
+		p1 := id.NewPlannedPermanode("1")
+		p2 := id.NewPlannedPermanode("2")
+		p3 := id.NewPlannedPermanode("3")
+		id.SetAttribute(p1, "tag", "project-foo")
+		id.SetAttribute(p2, "tag", "project-bar")
+		id.SetAttribute(p3, "tag", "other")
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					Attr: "tag",
+					ValueMatches: &StringConstraint{
+						Glob: "project-*",
+					},
+				},
+			},
+		}
+		qt.wantRes(sq, p1, p2)
+	})
+}
+
+func TestQueryPermanodeAttrRegexp(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+
+		p1 := id.NewPlannedPermanode("1")
+		p2 := id.NewPlannedPermanode("2")
+		p3 := id.NewPlannedPermanode("3")
+		id.SetAttribute(p1, "title", "Invoice-001")
+		id.SetAttribute(p2, "title", "Invoice-002")
+		id.SetAttribute(p3, "title", "Receipt-001")
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					Attr: "title",
+					ValueMatches: &StringConstraint{
+						Regexp: "^Invoice-\\d+$",
+					},
+				},
+			},
+		}
+		qt.wantRes(sq, p1, p2)
+	})
+}
+
+func TestQueryPermanodeAttrRegexpTooLong(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					Attr: "title",
+					ValueMatches: &StringConstraint{
+						Regexp: strings.Repeat("a", 1000),
+					},
+				},
+			},
+		}
+		if _, err := qt.Handler().Query(ctxbg, sq); err == nil {
+			t.Fatal("expected error for oversized Regexp, got nil")
+		}
+	})
+}
+
 func TestQueryPermanodeAttrNumValue(t *testing.T) {
 	testQuery(t, func(qt *queryTest) {
 		id := qt.id
@@ -1052,6 +1307,39 @@ func TestQueryFileConstraint_WholeRef(t *testing.T) {
 	})
 }
 
+func TestQueryFileConstraint_Content(t *testing.T) {
+	pdfData, err := os.ReadFile("../../internal/magic/testdata/magic.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+		fileRef, _ := id.UploadFile("doc.pdf", string(pdfData), time.Unix(123, 0))
+		p1 := id.NewPlannedPermanode("1")
+		id.SetAttribute(p1, "camliContent", fileRef.String())
+
+		fileRef2, _ := id.UploadFile("other.txt", "hello world", time.Unix(456, 0))
+		p2 := id.NewPlannedPermanode("2")
+		id.SetAttribute(p2, "camliContent", fileRef2.String())
+
+		// Only the PDF should have any extracted content text; the plain
+		// text file never gets a Content value at all.
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					Attr: "camliContent",
+					ValueInSet: &Constraint{
+						File: &FileConstraint{
+							Content: &StringConstraint{ByteLength: &IntConstraint{Min: 1}},
+						},
+					},
+				},
+			},
+		}
+		qt.wantRes(sq, p1)
+	})
+}
+
 func TestQueryPermanodeModtime(t *testing.T) {
 	testQuery(t, func(qt *queryTest) {
 		id := qt.id
@@ -1078,6 +1366,67 @@ func TestQueryPermanodeModtime(t *testing.T) {
 	})
 }
 
+func TestTimeline(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+
+		// indextest advances time one second per operation, all on the
+		// same UTC day: 2011-11-28.
+		p1 := id.NewPlannedPermanode("1")
+		p2 := id.NewPlannedPermanode("2")
+		p3 := id.NewPlannedPermanode("3")
+		id.SetAttribute(p1, "someAttr", "value1")
+		id.SetAttribute(p2, "someAttr", "value2")
+		id.SetAttribute(p3, "someAttr", "value3")
+
+		res, err := qt.Handler().GetTimeline(ctxbg, &TimelineRequest{
+			Start: time.Date(2011, 11, 27, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2011, 11, 29, 0, 0, 0, 0, time.UTC),
+			Loc:   time.UTC,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]int{
+			"2011-11-27": 0,
+			"2011-11-28": 3,
+			"2011-11-29": 0,
+		}
+		if len(res.Days) != len(want) {
+			t.Fatalf("got %d days, want %d: %+v", len(res.Days), len(want), res.Days)
+		}
+		for _, d := range res.Days {
+			if got, ok := want[d.Date]; !ok || got != d.Count {
+				t.Errorf("day %v: got count %d, want %d", d.Date, d.Count, want[d.Date])
+			}
+		}
+	})
+}
+
+func TestTimelineSparse(t *testing.T) {
+	testQuery(t, func(qt *queryTest) {
+		id := qt.id
+		p1 := id.NewPlannedPermanode("1")
+		id.SetAttribute(p1, "someAttr", "value1")
+
+		res, err := qt.Handler().GetTimeline(ctxbg, &TimelineRequest{
+			Start:  time.Date(2011, 11, 20, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2011, 11, 29, 0, 0, 0, 0, time.UTC),
+			Loc:    time.UTC,
+			Sparse: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Days) != 1 {
+			t.Fatalf("got %d days, want 1: %+v", len(res.Days), res.Days)
+		}
+		if res.Days[0].Date != "2011-11-28" || res.Days[0].Count != 1 {
+			t.Errorf("got %+v, want 2011-11-28 count 1", res.Days[0])
+		}
+	})
+}
+
 // This really belongs in pkg/index for the index-vs-corpus tests, but
 // it's easier here for now.
 // TODO: make all the indextest/tests.go
@@ -1363,6 +1712,84 @@ func testQueryRecentPermanodes_ContinueEndMidPage(t *testing.T, sortType SortTyp
 	})
 }
 
+// Tests that LastModifiedAsc, combined with Continue, can be used as a
+// delta/sync query: each poll returns only the permanodes modified since
+// the previous poll's Continue token, oldest first, and always advances
+// the token when it has any results, even short of Limit.
+func TestQueryRecentPermanodes_SyncLastModifiedAsc(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+
+		var blobs []blob.Ref
+		for i := 1; i <= 3; i++ {
+			pn := id.NewPlannedPermanode(fmt.Sprint(i))
+			blobs = append(blobs, pn)
+			id.SetAttribute_NoTimeMove(pn, "foo", "bar")
+		}
+		// All three permanodes share the same modtime, so ties are
+		// broken by blobref, ascending; sort our expectations to match.
+		sort.Sort(blob.ByRef(blobs))
+		p1, p2, p3 := blobs[0], blobs[1], blobs[2]
+
+		var usedSource string
+		ExportSetCandidateSourceHook(func(s string) {
+			usedSource = s
+		})
+
+		handler := qt.Handler()
+		poll := func(contToken string) *SearchResult {
+			req := &SearchQuery{
+				Constraint: &Constraint{
+					Permanode: &PermanodeConstraint{},
+				},
+				Limit:    2,
+				Sort:     LastModifiedAsc,
+				Continue: contToken,
+			}
+			res, err := handler.Query(ctxbg, req)
+			if err != nil {
+				qt.t.Fatal(err)
+			}
+			return res
+		}
+
+		res := poll("")
+		if usedSource != "corpus_permanode_lastmod_asc" {
+			t.Errorf("used candidate source strategy %q; want corpus_permanode_lastmod_asc", usedSource)
+		}
+		wantBlobs := []*SearchResultBlob{{Blob: p1}, {Blob: p2}}
+		if !reflect.DeepEqual(res.Blobs, wantBlobs) {
+			gotj, wantj := prettyJSON(res.Blobs), prettyJSON(wantBlobs)
+			t.Fatalf("first poll: got blobs:\n%s\nwant:\n%s\n", gotj, wantj)
+		}
+		if res.Continue == "" {
+			t.Fatal("first poll: expected a Continue token")
+		}
+
+		// A full page was returned, so there might be more; a second
+		// poll with the same Limit picks up the rest.
+		res = poll(res.Continue)
+		wantBlobs = []*SearchResultBlob{{Blob: p3}}
+		if !reflect.DeepEqual(res.Blobs, wantBlobs) {
+			gotj, wantj := prettyJSON(res.Blobs), prettyJSON(wantBlobs)
+			t.Fatalf("second poll: got blobs:\n%s\nwant:\n%s\n", gotj, wantj)
+		}
+		if res.Continue == "" {
+			t.Fatal("second poll: expected a Continue token even though the page wasn't full")
+		}
+
+		// Polling again with nothing new to report returns no results
+		// and no new token; the caller should keep using the same one.
+		res = poll(res.Continue)
+		if len(res.Blobs) != 0 {
+			t.Fatalf("third poll: got %d blobs; want 0", len(res.Blobs))
+		}
+		if res.Continue != "" {
+			t.Fatalf("third poll: got Continue token %q; want none", res.Continue)
+		}
+	})
+}
+
 // Tests PermanodeConstraint.ValueAll
 func TestQueryPermanodeValueAll(t *testing.T) {
 	testQuery(t, func(qt *queryTest) {
@@ -1469,6 +1896,45 @@ func TestQueryChildren(t *testing.T) {
 	})
 }
 
+func TestQuerySkipHidden(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+
+		visible := id.NewPlannedPermanode("visible")
+		id.AddAttribute(visible, "x", "x")
+
+		hidden := id.NewPlannedPermanode("hidden")
+		id.AddAttribute(hidden, "x", "x")
+		id.AddAttribute(hidden, "camliHidden", "true")
+
+		// A member of a hidden container is hidden too, even though it
+		// carries no camliHidden attribute of its own: hiding a container
+		// hides everything reachable only through it.
+		hiddenDir := id.NewPlannedPermanode("hidden_dir")
+		id.AddAttribute(hiddenDir, "camliHidden", "true")
+		hiddenMember := id.NewPlannedPermanode("hidden_member")
+		id.AddAttribute(hiddenMember, "x", "x")
+		id.AddAttribute(hiddenDir, "camliMember", hiddenMember.String())
+
+		// A trashed permanode (nodeattr.Trashed set) is hidden the
+		// same way, so it drops out of normal search results too.
+		trashed := id.NewPlannedPermanode("trashed")
+		id.AddAttribute(trashed, "x", "x")
+		id.AddAttribute(trashed, "camliTrashed", "2026-01-01T00:00:00Z")
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					SkipHidden: true,
+					Attr:       "x",
+					Value:      "x",
+				},
+			},
+		}
+		qt.wantRes(sq, visible)
+	})
+}
+
 func TestQueryParent(t *testing.T) {
 	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
 		id := qt.id
@@ -2149,6 +2615,29 @@ func TestIsCheckinQuerySource(t *testing.T) {
 	})
 }
 
+// permanode camliNodeType + creation-date candidate source
+func TestNodeTypeCreatedQuerySource(t *testing.T) {
+	testQueryTypes(t, memIndexTypes, func(qt *queryTest) {
+		id := qt.id
+		other := id.NewPlannedPermanode("other")
+		id.SetAttribute(other, "camliNodeType", "someapp.com:other")
+		pn := id.NewPlannedPermanode("doc")
+		id.SetAttribute(pn, "camliNodeType", "someapp.com:doc")
+
+		sq := &SearchQuery{
+			Constraint: &Constraint{
+				Permanode: &PermanodeConstraint{
+					Attr:  "camliNodeType",
+					Value: "someapp.com:doc",
+				},
+			},
+			Sort: CreatedDesc,
+		}
+		qt.candidateSource = "corpus_permanode_nodetype_created"
+		qt.wantRes(sq, pn)
+	})
+}
+
 // BenchmarkLocationPredicate aims at measuring the impact of
 // https://camlistore-review.googlesource.com/8049
 // ( + https://camlistore-review.googlesource.com/8649)
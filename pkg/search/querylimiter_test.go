@@ -0,0 +1,111 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQuerySemaphoreUnlimited(t *testing.T) {
+	s := newQuerySemaphore(0)
+	if s != nil {
+		t.Fatalf("newQuerySemaphore(0) = %v; want nil (unlimited)", s)
+	}
+	release, ok := s.acquire(context.Background(), time.Millisecond)
+	if !ok {
+		t.Fatal("acquire on unlimited semaphore should always succeed")
+	}
+	release()
+}
+
+func TestQuerySemaphoreRejectsWhenFull(t *testing.T) {
+	s := newQuerySemaphore(1)
+	release1, ok := s.acquire(context.Background(), time.Millisecond)
+	if !ok {
+		t.Fatal("first acquire should succeed")
+	}
+	defer release1()
+
+	if _, ok := s.acquire(context.Background(), 10*time.Millisecond); ok {
+		t.Fatal("second acquire on a full semaphore should time out and fail")
+	}
+}
+
+func TestQuerySemaphoreUnblocksOnRelease(t *testing.T) {
+	s := newQuerySemaphore(1)
+	release1, ok := s.acquire(context.Background(), time.Millisecond)
+	if !ok {
+		t.Fatal("first acquire should succeed")
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		release2, ok := s.acquire(context.Background(), time.Second)
+		done <- ok
+		if ok {
+			release2()
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	release1()
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("queued acquire should have succeeded once the slot freed up")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued acquire never returned after release")
+	}
+}
+
+func TestQueryLimiterNilWhenUnconfigured(t *testing.T) {
+	if l := newQueryLimiter(0, 0); l != nil {
+		t.Fatalf("newQueryLimiter(0, 0) = %v; want nil", l)
+	}
+	var l *queryLimiter
+	release, ok := l.acquire(context.Background(), false)
+	if !ok {
+		t.Fatal("acquire on a nil queryLimiter should always succeed")
+	}
+	release()
+}
+
+func TestQueryLimiterSeparateGates(t *testing.T) {
+	l := newQueryLimiter(1, 1)
+
+	releaseGeneral, ok := l.acquire(context.Background(), false)
+	if !ok {
+		t.Fatal("expected to acquire the general gate")
+	}
+	defer releaseGeneral()
+
+	// The cheap gate is independent, so it should still have room even
+	// though the general gate is full.
+	releaseCheap, ok := l.acquire(context.Background(), true)
+	if !ok {
+		t.Fatal("expected the cheap gate to be independent of the general gate")
+	}
+	releaseCheap()
+
+	if _, ok := l.general.acquire(context.Background(), 10*time.Millisecond); ok {
+		t.Fatal("expected the general gate to still be full")
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SetSavedSearch registers (or replaces) the named saved search, so a
+// SavedSearchConstraint with this Name can be used by future queries.
+// It's kept in memory only; nothing here persists it across restarts.
+//
+// It fails if query, expanded through any SavedSearchConstraint it
+// contains (transitively, via whatever's already registered), would
+// loop back to name, since that would otherwise make matching such a
+// constraint recurse forever.
+func (h *Handler) SetSavedSearch(name string, query *SearchQuery) error {
+	if name == "" {
+		return errors.New("search: saved search name must not be empty")
+	}
+	if query == nil || query.Constraint == nil {
+		return errors.New("search: saved search query must have a Constraint")
+	}
+	h.savedSearchMu.Lock()
+	defer h.savedSearchMu.Unlock()
+	if err := h.checkSavedSearchAcyclic(name, query.Constraint, map[string]bool{name: true}); err != nil {
+		return err
+	}
+	if h.savedSearches == nil {
+		h.savedSearches = make(map[string]*SearchQuery)
+	}
+	h.savedSearches[name] = query
+	return nil
+}
+
+// GetSavedSearch returns the named saved search, as registered with
+// SetSavedSearch.
+func (h *Handler) GetSavedSearch(name string) (*SearchQuery, bool) {
+	h.savedSearchMu.RLock()
+	defer h.savedSearchMu.RUnlock()
+	q, ok := h.savedSearches[name]
+	return q, ok
+}
+
+// checkSavedSearchAcyclic reports an error if c contains a
+// SavedSearchConstraint whose Name is already in seen, or whose
+// expansion (following whatever's already registered under
+// h.savedSearches) transitively reaches a name already in seen.
+// h.savedSearchMu must be held.
+func (h *Handler) checkSavedSearchAcyclic(root string, c *Constraint, seen map[string]bool) error {
+	if c == nil {
+		return nil
+	}
+	if c.SavedSearch != nil {
+		name := c.SavedSearch.Name
+		if seen[name] {
+			return fmt.Errorf("search: saved search %q would be self-referential via %q", root, name)
+		}
+		next, ok := h.savedSearches[name]
+		if ok {
+			seen = seen2WithName(seen, name)
+			if err := h.checkSavedSearchAcyclic(root, next.Constraint, seen); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Logical != nil {
+		if err := h.checkSavedSearchAcyclic(root, c.Logical.A, seen); err != nil {
+			return err
+		}
+		if c.Logical.Op != "not" {
+			if err := h.checkSavedSearchAcyclic(root, c.Logical.B, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// seen2WithName returns a copy of seen with name added, leaving seen
+// itself untouched so sibling branches of the same constraint tree
+// (e.g. LogicalConstraint's A and B) don't see each other's names.
+func seen2WithName(seen map[string]bool, name string) map[string]bool {
+	seen2 := make(map[string]bool, len(seen)+1)
+	for k := range seen {
+		seen2[k] = true
+	}
+	seen2[name] = true
+	return seen2
+}
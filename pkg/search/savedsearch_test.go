@@ -0,0 +1,77 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search_test
+
+import (
+	"testing"
+
+	"perkeep.org/pkg/index"
+	"perkeep.org/pkg/index/indextest"
+	. "perkeep.org/pkg/search"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	idx := index.NewMemoryIndex()
+	indextest.NewIndexDeps(idx).Fataler = t
+	return NewHandler(idx, owner)
+}
+
+func TestSetSavedSearchSelfReference(t *testing.T) {
+	h := newTestHandler(t)
+	err := h.SetSavedSearch("a", &SearchQuery{
+		Constraint: &Constraint{SavedSearch: &SavedSearchConstraint{Name: "a"}},
+	})
+	if err == nil {
+		t.Fatal("expected an error registering a self-referential saved search, got nil")
+	}
+}
+
+func TestSetSavedSearchCycle(t *testing.T) {
+	h := newTestHandler(t)
+	if err := h.SetSavedSearch("a", &SearchQuery{
+		Constraint: &Constraint{SavedSearch: &SavedSearchConstraint{Name: "b"}},
+	}); err != nil {
+		t.Fatalf("registering a: %v", err)
+	}
+	err := h.SetSavedSearch("b", &SearchQuery{
+		Constraint: &Constraint{SavedSearch: &SavedSearchConstraint{Name: "a"}},
+	})
+	if err == nil {
+		t.Fatal("expected an error registering b, which would close a cycle with a, got nil")
+	}
+	if _, ok := h.GetSavedSearch("b"); ok {
+		t.Error("cyclic saved search b was registered despite SetSavedSearch returning an error")
+	}
+}
+
+func TestSetSavedSearchGet(t *testing.T) {
+	h := newTestHandler(t)
+	want := &SearchQuery{Constraint: &Constraint{BlobRefPrefix: "sha224-"}}
+	if err := h.SetSavedSearch("all", want); err != nil {
+		t.Fatalf("SetSavedSearch: %v", err)
+	}
+	got, ok := h.GetSavedSearch("all")
+	if !ok {
+		t.Fatal("GetSavedSearch: not found")
+	}
+	if got != want {
+		t.Error("GetSavedSearch returned a different *SearchQuery than was registered")
+	}
+	if _, ok := h.GetSavedSearch("missing"); ok {
+		t.Error("GetSavedSearch unexpectedly found a search named \"missing\"")
+	}
+}
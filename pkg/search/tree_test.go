@@ -0,0 +1,167 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/schema"
+)
+
+func fakeRef(s string) blob.Ref {
+	return blob.RefFromString(s)
+}
+
+func permanodeMeta(members ...blob.Ref) *DescribedBlob {
+	var vals []string
+	for _, m := range members {
+		vals = append(vals, m.String())
+	}
+	return &DescribedBlob{
+		CamliType: schema.TypePermanode,
+		Permanode: &DescribedPermanode{Attr: url.Values{"camliMember": vals}},
+	}
+}
+
+func TestBuildTreeSimple(t *testing.T) {
+	root := fakeRef("root")
+	child := fakeRef("child")
+	meta := MetaMap{
+		root.String():  permanodeMeta(child),
+		child.String(): permanodeMeta(),
+	}
+	tree := buildTree(
+		[]*SearchResultBlob{{Blob: root}},
+		&DescribeResponse{Meta: meta},
+		&TreeOptions{},
+	)
+	if len(tree) != 1 || tree[0].Blob != root {
+		t.Fatalf("tree = %+v; want single root node", tree)
+	}
+	if got := tree[0].Children; len(got) != 1 || got[0].Blob != child {
+		t.Fatalf("root children = %+v; want [%v]", got, child)
+	}
+	if tree[0].Truncated || tree[0].Children[0].Truncated {
+		t.Errorf("unexpected Truncated in a non-cyclic, shallow tree")
+	}
+}
+
+func TestBuildTreeDiamond(t *testing.T) {
+	parent1 := fakeRef("parent1")
+	parent2 := fakeRef("parent2")
+	shared := fakeRef("shared")
+	meta := MetaMap{
+		parent1.String(): permanodeMeta(shared),
+		parent2.String(): permanodeMeta(shared),
+		shared.String():  permanodeMeta(),
+	}
+	tree := buildTree(
+		[]*SearchResultBlob{{Blob: parent1}, {Blob: parent2}},
+		&DescribeResponse{Meta: meta},
+		&TreeOptions{},
+	)
+	if len(tree) != 2 {
+		t.Fatalf("got %d roots; want 2", len(tree))
+	}
+	for _, root := range tree {
+		if len(root.Children) != 1 || root.Children[0].Blob != shared {
+			t.Errorf("root %v children = %+v; want shared node under each parent", root.Blob, root.Children)
+		}
+	}
+}
+
+func TestBuildTreeCycle(t *testing.T) {
+	a := fakeRef("a")
+	b := fakeRef("b")
+	meta := MetaMap{
+		a.String(): permanodeMeta(b),
+		b.String(): permanodeMeta(a), // cycle back to a
+	}
+	tree := buildTree(
+		[]*SearchResultBlob{{Blob: a}},
+		&DescribeResponse{Meta: meta},
+		&TreeOptions{},
+	)
+	if len(tree) != 1 {
+		t.Fatalf("got %d roots; want 1", len(tree))
+	}
+	node := tree[0]
+	if len(node.Children) != 1 || node.Children[0].Blob != b {
+		t.Fatalf("a's children = %+v; want [b]", node.Children)
+	}
+	bNode := node.Children[0]
+	if len(bNode.Children) != 1 || bNode.Children[0].Blob != a {
+		t.Fatalf("b's children = %+v; want [a]", bNode.Children)
+	}
+	aAgain := bNode.Children[0]
+	if !aAgain.Truncated {
+		t.Errorf("revisited node a = %+v; want Truncated true", aAgain)
+	}
+	if len(aAgain.Children) != 0 {
+		t.Errorf("truncated node has children %+v; want none", aAgain.Children)
+	}
+}
+
+func TestBuildTreeMaxDepth(t *testing.T) {
+	a := fakeRef("a")
+	b := fakeRef("b")
+	c := fakeRef("c")
+	meta := MetaMap{
+		a.String(): permanodeMeta(b),
+		b.String(): permanodeMeta(c),
+		c.String(): permanodeMeta(),
+	}
+	tree := buildTree(
+		[]*SearchResultBlob{{Blob: a}},
+		&DescribeResponse{Meta: meta},
+		&TreeOptions{MaxDepth: 1},
+	)
+	aNode := tree[0]
+	if len(aNode.Children) != 1 || aNode.Children[0].Blob != b {
+		t.Fatalf("a's children = %+v; want [b]", aNode.Children)
+	}
+	bNode := aNode.Children[0]
+	if !bNode.Truncated {
+		t.Errorf("b node = %+v; want Truncated true at MaxDepth 1", bNode)
+	}
+	if len(bNode.Children) != 0 {
+		t.Errorf("truncated node has children %+v; want none", bNode.Children)
+	}
+}
+
+func TestBuildTreeDirectory(t *testing.T) {
+	dir := fakeRef("dir")
+	file := fakeRef("file")
+	meta := MetaMap{
+		dir.String(): {
+			CamliType:   schema.TypeDirectory,
+			DirChildren: []blob.Ref{file},
+		},
+	}
+	tree := buildTree(
+		[]*SearchResultBlob{{Blob: dir}},
+		&DescribeResponse{Meta: meta},
+		&TreeOptions{},
+	)
+	want := []*TreeNode{{Blob: dir, Children: []*TreeNode{{Blob: file}}}}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("tree = %+v; want %+v", tree, want)
+	}
+}
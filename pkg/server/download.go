@@ -28,6 +28,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -304,12 +305,6 @@ func (dh *DownloadHandler) ServeFile(w http.ResponseWriter, r *http.Request, fil
 		return
 	}
 
-	if r.Header.Get("If-Modified-Since") != "" {
-		// Immutable, so any copy's a good copy.
-		w.WriteHeader(http.StatusNotModified)
-		return
-	}
-
 	dh.r = r
 	fi, packed, err := dh.fileInfo(ctx, file)
 	if err != nil {
@@ -325,6 +320,10 @@ func (dh *DownloadHandler) ServeFile(w http.ResponseWriter, r *http.Request, fil
 	h := w.Header()
 	h.Set("Content-Length", fmt.Sprint(fi.size))
 	h.Set("Expires", time.Now().Add(oneYear).Format(http.TimeFormat))
+	// The file's blobref is a strong, content-addressed ETag: the content
+	// behind it can never change, so http.ServeContent's If-None-Match
+	// handling below is always safe to trust.
+	h.Set("Etag", strconv.Quote(file.String()))
 	if packed {
 		h.Set("X-Camlistore-Packed", "1")
 	}
@@ -374,7 +373,7 @@ func (dh *DownloadHandler) ServeFile(w http.ResponseWriter, r *http.Request, fil
 		return
 	}
 
-	http.ServeContent(w, r, "", time.Now(), fi.rs)
+	http.ServeContent(w, r, "", fi.modtime, fi.rs)
 }
 
 // isText reports whether the first MB read from rs is valid UTF-8 text.
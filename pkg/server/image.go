@@ -166,10 +166,17 @@ func (ih *ImageHandler) cached(ctx context.Context, br blob.Ref) (io.ReadCloser,
 	return fr, nil
 }
 
-// Key format: "scaled:" + bref + ":" + width "x" + height
-// where bref is the blobref of the unscaled image.
-func cacheKey(bref string, width int, height int) string {
-	return fmt.Sprintf("scaled:%v:%dx%d:tv%v", bref, width, height, images.ThumbnailVersion())
+// Key format: "scaled:" + bref + ":" + width "x" + height + ":tv" + thumbnail version [+ ":square"]
+// where bref is the blobref of the unscaled image. square is included
+// when set, since it changes the resulting pixels (a center crop) for
+// the same width and height, and the key doubles as this rendition's
+// ETag, so distinct renditions must never collide.
+func cacheKey(bref string, width, height int, square bool) string {
+	suffix := ""
+	if square {
+		suffix = ":square"
+	}
+	return fmt.Sprintf("scaled:%v:%dx%d:tv%v%s", bref, width, height, images.ThumbnailVersion(), suffix)
 }
 
 // ScaledCached reads the scaled version of the image in file,
@@ -178,7 +185,7 @@ func cacheKey(bref string, width int, height int) string {
 // On successful read and population of buf, the returned format is non-empty.
 // Almost all errors are not interesting. Real errors will be logged.
 func (ih *ImageHandler) scaledCached(ctx context.Context, buf *bytes.Buffer, file blob.Ref) (format string) {
-	key := cacheKey(file.String(), ih.MaxWidth, ih.MaxHeight)
+	key := cacheKey(file.String(), ih.MaxWidth, ih.MaxHeight, ih.Square)
 	br, err := ih.ThumbMeta.Get(key)
 	if err == errCacheMiss {
 		return
@@ -348,7 +355,7 @@ func (ih *ImageHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request, fil
 		return
 	}
 
-	key := cacheKey(file.String(), mw, mh)
+	key := cacheKey(file.String(), mw, mh, ih.Square)
 	etag := blob.RefFromString(key).String()[5:]
 	inm := req.Header.Get("If-None-Match")
 	if inm != "" {
@@ -0,0 +1,27 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import "testing"
+
+func TestCacheKeySquareDoesNotCollide(t *testing.T) {
+	plain := cacheKey("sha1-foo", 100, 100, false)
+	square := cacheKey("sha1-foo", 100, 100, true)
+	if plain == square {
+		t.Errorf("cacheKey collided for square vs non-square rendition: %q", plain)
+	}
+}
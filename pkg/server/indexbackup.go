@@ -0,0 +1,243 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/index"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/sorted"
+
+	"go4.org/jsonconfig"
+)
+
+const (
+	defaultIndexBackupInterval = 24 * time.Hour
+	defaultIndexBackupRetain   = 7
+)
+
+// IndexBackupHandler periodically snapshots an index's underlying
+// sorted.KeyValue store to blob storage, so that a corrupted or lost
+// index can be restored (with sorted.Restore) without a full reindex.
+//
+// Example low-level config:
+//
+//	"/index-backup/": {
+//	    "handler": "indexbackup",
+//	    "handlerArgs": {
+//	        "index": "/index/",
+//	        "to": "/backup-blobs/",
+//	        "interval": "24h",
+//	        "retain": 7
+//	    }
+//	}
+//
+// Retention only bounds the number of index snapshots kept: once more
+// than "retain" have been taken, the oldest snapshots' file schema
+// blobs are removed from "to". It does not walk and remove the chunk
+// blobs those file schemas reference, so "to" should have its own
+// lifecycle policy (or be dedicated to backups) if reclaiming that
+// space matters.
+type IndexBackupHandler struct {
+	ix       *index.Index
+	to       blobserver.Storage
+	interval time.Duration
+	retain   int
+	meta     sorted.KeyValue // when: blobref, keyed by RFC3339 backup time
+
+	mu      sync.Mutex
+	backups []indexBackupRecord // oldest first
+	lastErr error
+}
+
+type indexBackupRecord struct {
+	when time.Time
+	ref  blob.Ref
+}
+
+var (
+	_ http.Handler             = (*IndexBackupHandler)(nil)
+	_ blobserver.HandlerIniter = (*IndexBackupHandler)(nil)
+)
+
+func init() {
+	blobserver.RegisterHandlerConstructor("indexbackup", newIndexBackupFromConfig)
+}
+
+func newIndexBackupFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (http.Handler, error) {
+	indexPrefix := conf.RequiredString("index")
+	toPrefix := conf.RequiredString("to")
+	intervalStr := conf.OptionalString("interval", defaultIndexBackupInterval.String())
+	retain := conf.OptionalInt("retain", defaultIndexBackupRetain)
+	metaConf := conf.OptionalObject("meta")
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	if retain < 1 {
+		return nil, fmt.Errorf("indexbackup: retain must be at least 1, got %d", retain)
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil {
+		return nil, fmt.Errorf("indexbackup: invalid interval %q: %v", intervalStr, err)
+	}
+
+	ixStorage, err := ld.GetStorage(indexPrefix)
+	if err != nil {
+		return nil, err
+	}
+	ix, ok := ixStorage.(*index.Index)
+	if !ok {
+		return nil, fmt.Errorf("indexbackup: %q is a %T, not an index", indexPrefix, ixStorage)
+	}
+	to, err := ld.GetStorage(toPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta sorted.KeyValue
+	if len(metaConf) == 0 {
+		meta = sorted.NewMemoryKeyValue()
+	} else {
+		meta, err = sorted.NewKeyValueMaybeWipe(metaConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h := &IndexBackupHandler{
+		ix:       ix,
+		to:       to,
+		interval: interval,
+		retain:   retain,
+		meta:     meta,
+	}
+	if err := h.loadBackups(); err != nil {
+		return nil, fmt.Errorf("indexbackup: loading backup history: %v", err)
+	}
+	go h.loop()
+	return h, nil
+}
+
+func (h *IndexBackupHandler) loadBackups() error {
+	return sorted.Foreach(h.meta, func(key, value string) error {
+		when, err := time.Parse(time.RFC3339Nano, key)
+		if err != nil {
+			return fmt.Errorf("bad backup time key %q: %v", key, err)
+		}
+		br, ok := blob.Parse(value)
+		if !ok {
+			return fmt.Errorf("bad backup blobref %q for key %q", value, key)
+		}
+		h.backups = append(h.backups, indexBackupRecord{when: when, ref: br})
+		return nil
+	})
+}
+
+// loop runs one backup immediately, then one every h.interval, until
+// the process exits.
+func (h *IndexBackupHandler) loop() {
+	ctx := context.Background()
+	h.backupOnce(ctx)
+	t := time.NewTicker(h.interval)
+	defer t.Stop()
+	for range t.C {
+		h.backupOnce(ctx)
+	}
+}
+
+func (h *IndexBackupHandler) backupOnce(ctx context.Context) {
+	var buf bytes.Buffer
+	if err := sorted.Backup(h.ix.KeyValue(), &buf); err != nil {
+		h.recordErr(fmt.Errorf("snapshotting index: %v", err))
+		return
+	}
+	when := time.Now()
+	name := fmt.Sprintf("index-backup-%s.kv", when.UTC().Format("20060102-150405"))
+	ref, err := schema.WriteFileFromReader(ctx, h.to, name, &buf)
+	if err != nil {
+		h.recordErr(fmt.Errorf("uploading index backup: %v", err))
+		return
+	}
+	if err := h.meta.Set(when.Format(time.RFC3339Nano), ref.String()); err != nil {
+		h.recordErr(fmt.Errorf("recording index backup: %v", err))
+		return
+	}
+	log.Printf("indexbackup: wrote index snapshot %v as %v", ref, name)
+
+	h.mu.Lock()
+	h.backups = append(h.backups, indexBackupRecord{when: when, ref: ref})
+	stale := h.backups[:max(0, len(h.backups)-h.retain)]
+	h.backups = h.backups[len(stale):]
+	retained := make(map[blob.Ref]bool, len(h.backups))
+	for _, rec := range h.backups {
+		retained[rec.ref] = true
+	}
+	h.mu.Unlock()
+
+	for _, rec := range stale {
+		// If the index was unchanged between snapshots taken within
+		// the same second (the file name has one-second resolution),
+		// rec.ref is content-addressed and identical to a
+		// still-retained backup's; forget the redundant record
+		// without removing the blob a retained backup still needs.
+		h.prune(ctx, rec, retained[rec.ref])
+	}
+}
+
+func (h *IndexBackupHandler) prune(ctx context.Context, rec indexBackupRecord, keepBlob bool) {
+	if !keepBlob {
+		if err := h.to.RemoveBlobs(ctx, []blob.Ref{rec.ref}); err != nil {
+			h.recordErr(fmt.Errorf("removing stale index backup %v: %v", rec.ref, err))
+			return
+		}
+	}
+	if err := h.meta.Delete(rec.when.Format(time.RFC3339Nano)); err != nil {
+		h.recordErr(fmt.Errorf("forgetting stale index backup %v: %v", rec.ref, err))
+	}
+}
+
+func (h *IndexBackupHandler) recordErr(err error) {
+	log.Printf("indexbackup: %v", err)
+	h.mu.Lock()
+	h.lastErr = err
+	h.mu.Unlock()
+}
+
+func (h *IndexBackupHandler) InitHandler(hl blobserver.FindHandlerByTyper) error {
+	return nil
+}
+
+func (h *IndexBackupHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(rw, "Index backups (retaining %d, every %v):\n", h.retain, h.interval)
+	for _, rec := range h.backups {
+		fmt.Fprintf(rw, "  %v  %v\n", rec.when.Format(time.RFC3339), rec.ref)
+	}
+	if h.lastErr != nil {
+		fmt.Fprintf(rw, "Last error: %v\n", h.lastErr)
+	}
+}
@@ -0,0 +1,339 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"go4.org/jsonconfig"
+	"go4.org/syncutil"
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/jsonsign/signhandler"
+	"perkeep.org/pkg/schema"
+)
+
+// A ProcessHandler watches a source storage for newly received blobs and,
+// for each blob whose schema camliType matches a configured rule, runs an
+// external command to compute derived data (e.g. OCR text, a transcode),
+// storing the command's output as a new blob and recording it as an
+// attribute on a permanode wrapping the source blob.
+//
+// It generalizes what thumbnailing does ad hoc (see
+// perkeep.org/internal/video/thumbnail) into a pluggable, config-driven
+// framework that apps like scanning cabinet can register processors
+// against, instead of each app reinventing its own subprocess plumbing.
+type ProcessHandler struct {
+	fromName string
+	from     blobserver.Storage
+	rules    []processRule
+
+	target blobserver.StatReceiver // where derived blobs and claims are written; from the "root" handler
+	signer *schema.Signer          // from the "jsonsign" handler
+
+	gate *syncutil.Gate // bounds the number of concurrent subprocesses
+
+	// done tracks (source blob ref, rule index) pairs that have already
+	// been processed, so a reindex or a duplicate receive hook firing
+	// doesn't spawn the external command again for the same input.
+	mu   sync.Mutex
+	done map[doneKey]bool
+}
+
+type doneKey struct {
+	ref  blob.Ref
+	rule int
+}
+
+// A processRule describes one derived-data job: blobs whose schema
+// camliType matches camliType are piped to command, and the command's
+// stdout is stored as a new blob and set as the attr attribute on a
+// permanode wrapping the source blob.
+type processRule struct {
+	name      string // config key, for logging
+	camliType string
+	command   []string
+	attr      string
+	timeout   time.Duration
+}
+
+var (
+	_ blobserver.Storage = (*ProcessHandler)(nil)
+)
+
+func (ph *ProcessHandler) String() string {
+	return fmt.Sprintf("[ProcessHandler of %q]", ph.fromName)
+}
+
+func (ph *ProcessHandler) logf(format string, args ...interface{}) {
+	log.Printf("process: "+ph.fromName+": "+format, args...)
+}
+
+func init() {
+	blobserver.RegisterHandlerConstructor("process", newProcessFromConfig)
+}
+
+// newProcessFromConfig builds a ProcessHandler from configuration like:
+//
+//	{
+//	  "from": "/bs/",
+//	  "maxProcs": 2,
+//	  "rules": {
+//	    "ocr": {
+//	      "camliType": "file",
+//	      "command": ["/usr/local/bin/ocr", "-lang", "eng"],
+//	      "attr": "ocrText",
+//	      "timeoutSeconds": 30
+//	    }
+//	  }
+//	}
+func newProcessFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (http.Handler, error) {
+	from := conf.RequiredString("from")
+	maxProcs := conf.OptionalInt("maxProcs", 1)
+	rulesConf := conf.RequiredObject("rules")
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	fromBs, err := ld.GetStorage(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []processRule
+	for name, v := range rulesConf {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("process: rule %q is not a JSON object", name)
+		}
+		rconf := jsonconfig.Obj(m)
+		camliType := rconf.RequiredString("camliType")
+		attr := rconf.RequiredString("attr")
+		cmd := rconf.RequiredList("command")
+		timeoutSeconds := rconf.OptionalInt("timeoutSeconds", 30)
+		if err := rconf.Validate(); err != nil {
+			return nil, fmt.Errorf("process: rule %q: %v", name, err)
+		}
+		if len(cmd) == 0 {
+			return nil, fmt.Errorf("process: rule %q: \"command\" must not be empty", name)
+		}
+		rules = append(rules, processRule{
+			name:      name,
+			camliType: camliType,
+			command:   cmd,
+			attr:      attr,
+			timeout:   time.Duration(timeoutSeconds) * time.Second,
+		})
+	}
+	if len(rules) == 0 {
+		return nil, errors.New(`process: "rules" must define at least one rule`)
+	}
+
+	ph := &ProcessHandler{
+		fromName: from,
+		from:     fromBs,
+		rules:    rules,
+		gate:     syncutil.NewGate(maxProcs),
+		done:     make(map[doneKey]bool),
+	}
+	return ph, nil
+}
+
+func (ph *ProcessHandler) InitHandler(hl blobserver.FindHandlerByTyper) error {
+	_, handler, err := hl.FindHandlerByType("root")
+	if err != nil {
+		return errors.New("process: requires a 'root' handler")
+	}
+	rh := handler.(*RootHandler)
+	if rh.Storage == nil {
+		return errors.New("process: requires a 'root' handler with 'blobRoot' defined")
+	}
+	ph.target = rh.Storage
+
+	_, handler, _ = hl.FindHandlerByType("jsonsign")
+	if sigh, ok := handler.(*signhandler.Handler); ok {
+		ph.signer = sigh.Signer()
+	}
+	if ph.signer == nil {
+		return errors.New("process: requires a 'jsonsign' handler")
+	}
+
+	blobserver.GetHub(ph.from).AddReceiveHook(ph.enqueue)
+	return nil
+}
+
+// enqueue is the synchronous BlobHub receive hook: it decides whether sb
+// matches any configured rule and, if so, kicks off (asynchronous)
+// processing. It never returns an error itself, since a processing
+// failure shouldn't prevent the blob from having been received.
+func (ph *ProcessHandler) enqueue(sb blob.SizedRef) error {
+	for i, rule := range ph.rules {
+		i, rule := i, rule
+		go func() {
+			if err := ph.maybeProcess(context.Background(), sb.Ref, i, rule); err != nil {
+				ph.logf("error running rule %q on %v: %v", rule.name, sb.Ref, err)
+			}
+		}()
+	}
+	return nil
+}
+
+func (ph *ProcessHandler) alreadyDone(ref blob.Ref, rule int) bool {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+	return ph.done[doneKey{ref, rule}]
+}
+
+func (ph *ProcessHandler) markDone(ref blob.Ref, rule int) {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+	ph.done[doneKey{ref, rule}] = true
+}
+
+func (ph *ProcessHandler) maybeProcess(ctx context.Context, ref blob.Ref, ruleIdx int, rule processRule) error {
+	if ph.alreadyDone(ref, ruleIdx) {
+		return nil
+	}
+	rc, _, err := ph.from.Fetch(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("fetching %v: %v", ref, err)
+	}
+	blobBytes, err := io.ReadAll(io.LimitReader(rc, schema.MaxSchemaBlobSize))
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("reading %v: %v", ref, err)
+	}
+	sb, err := schema.BlobFromReader(ref, bytes.NewReader(blobBytes))
+	if err != nil {
+		// Not a (valid) schema blob, so it can't have a camliType. Not an error.
+		return nil
+	}
+	if string(sb.Type()) != rule.camliType {
+		return nil
+	}
+
+	ph.gate.Start()
+	defer ph.gate.Done()
+	if ph.alreadyDone(ref, ruleIdx) {
+		// Lost a race against another hook firing for the same blob.
+		return nil
+	}
+
+	derived, err := ph.run(ctx, rule, ref)
+	if err != nil {
+		return fmt.Errorf("running command for %v: %v", ref, err)
+	}
+
+	pn, err := ph.upload(ctx, schema.NewUnsignedPermanode())
+	if err != nil {
+		return fmt.Errorf("creating permanode for %v: %v", ref, err)
+	}
+	if _, err := ph.upload(ctx, schema.NewSetAttributeClaim(pn, "camliContent", ref.String())); err != nil {
+		return fmt.Errorf("setting camliContent on %v: %v", pn, err)
+	}
+	if _, err := ph.upload(ctx, schema.NewSetAttributeClaim(pn, rule.attr, derived.String())); err != nil {
+		return fmt.Errorf("setting %q on %v: %v", rule.attr, pn, err)
+	}
+
+	ph.markDone(ref, ruleIdx)
+	return nil
+}
+
+// run executes rule's external command, feeding it the schema blob's
+// referenced file contents on stdin, and stores the command's standard
+// output as a new blob. The command is run with a bounded timeout and a
+// minimal environment: it has no access to this process's environment
+// variables, network, or filesystem beyond what the command binary itself
+// opens.
+func (ph *ProcessHandler) run(ctx context.Context, rule processRule, ref blob.Ref) (blob.Ref, error) {
+	fr, err := schema.NewFileReader(ctx, ph.from, ref)
+	if err != nil {
+		return blob.Ref{}, err
+	}
+	defer fr.Close()
+
+	cctx, cancel := context.WithTimeout(ctx, rule.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cctx, rule.command[0], rule.command[1:]...)
+	cmd.Env = nil // don't leak this process's environment to the subprocess
+	cmd.Stdin = fr
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return blob.Ref{}, fmt.Errorf("%v: %v (stderr: %s)", rule.command, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	sb, err := blobserver.ReceiveString(ctx, ph.target, stdout.String())
+	if err != nil {
+		return blob.Ref{}, err
+	}
+	return sb.Ref, nil
+}
+
+func (ph *ProcessHandler) upload(ctx context.Context, bb *schema.Builder) (blob.Ref, error) {
+	signed, err := bb.Sign(ctx, ph.signer)
+	if err != nil {
+		return blob.Ref{}, err
+	}
+	sb, err := blobserver.ReceiveString(ctx, ph.target, signed)
+	if err != nil {
+		return blob.Ref{}, err
+	}
+	return sb.Ref, nil
+}
+
+func (ph *ProcessHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	ph.mu.Lock()
+	n := len(ph.done)
+	ph.mu.Unlock()
+	fmt.Fprintf(rw, "<h1>Process Status (source: %s)</h1>", html.EscapeString(ph.fromName))
+	fmt.Fprintf(rw, "<p>%d rule(s) configured; %d (blob, rule) pair(s) processed so far.</p>", len(ph.rules), n)
+}
+
+// blobserver.Storage passthrough: ProcessHandler doesn't itself store
+// blobs, it only observes what's received on ph.from via the blob hub.
+
+func (ph *ProcessHandler) Fetch(ctx context.Context, br blob.Ref) (io.ReadCloser, uint32, error) {
+	return ph.from.Fetch(ctx, br)
+}
+
+func (ph *ProcessHandler) ReceiveBlob(ctx context.Context, br blob.Ref, source io.Reader) (blob.SizedRef, error) {
+	return ph.from.ReceiveBlob(ctx, br, source)
+}
+
+func (ph *ProcessHandler) StatBlobs(ctx context.Context, blobs []blob.Ref, fn func(blob.SizedRef) error) error {
+	return ph.from.StatBlobs(ctx, blobs, fn)
+}
+
+func (ph *ProcessHandler) EnumerateBlobs(ctx context.Context, dest chan<- blob.SizedRef, after string, limit int) error {
+	return ph.from.EnumerateBlobs(ctx, dest, after, limit)
+}
+
+func (ph *ProcessHandler) RemoveBlobs(ctx context.Context, blobs []blob.Ref) error {
+	return ph.from.RemoveBlobs(ctx, blobs)
+}
@@ -23,12 +23,14 @@ import (
 	"log"
 	"net/http"
 	"sort"
+	"strings"
 	"sync"
 
 	"perkeep.org/internal/httputil"
 	"perkeep.org/internal/images"
 	"perkeep.org/internal/osutil"
 	"perkeep.org/pkg/auth"
+	"perkeep.org/pkg/blob"
 	"perkeep.org/pkg/blobserver"
 	"perkeep.org/pkg/buildinfo"
 	"perkeep.org/pkg/jsonsign/signhandler"
@@ -273,10 +275,31 @@ func (rh *RootHandler) serveDiscovery(rw http.ResponseWriter, req *http.Request)
 		d.SyncHandlers = syncHandlers
 	}
 	d.HasLegacySHA1Index = rh.hasLegacySHA1
+	d.Features = map[string]bool{
+		// batchUpload reports support for the multipart batch blob
+		// upload handler (as opposed to single-blob PUT only).
+		"batchUpload": true,
+	}
 	discoveryHelper(rw, req, d)
 }
 
 func discoveryHelper(rw http.ResponseWriter, req *http.Request, dr *camtypes.Discovery) {
+	bytes, err := json.MarshalIndent(dr, "", "  ")
+	if err != nil {
+		httputil.ServeJSONError(rw, httputil.ServerError("encoding discovery information: "+err.Error()))
+		return
+	}
+
+	// The ETag is derived from the discovery document's own contents, so
+	// it changes whenever anything discovery reports (config, storage
+	// generation, registered handlers, etc.) changes.
+	etag := blob.RefFromString(string(bytes)).String()[5:]
+	rw.Header().Set("ETag", `"`+etag+`"`)
+	if inm := req.Header.Get("If-None-Match"); inm != "" && strings.Trim(inm, `"`) == etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	rw.Header().Set("Content-Type", "text/javascript")
 	if cb := req.FormValue("cb"); identOrDotPattern.MatchString(cb) {
 		fmt.Fprintf(rw, "%s(", cb)
@@ -285,10 +308,5 @@ func discoveryHelper(rw http.ResponseWriter, req *http.Request, dr *camtypes.Dis
 		fmt.Fprintf(rw, "%s = ", v)
 		defer rw.Write([]byte(";\n"))
 	}
-	bytes, err := json.MarshalIndent(dr, "", "  ")
-	if err != nil {
-		httputil.ServeJSONError(rw, httputil.ServerError("encoding discovery information: "+err.Error()))
-		return
-	}
 	rw.Write(bytes)
 }
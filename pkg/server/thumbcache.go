@@ -17,6 +17,7 @@ limitations under the License.
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -82,3 +83,20 @@ func (m *ThumbMeta) Put(key string, br blob.Ref) error {
 	}
 	return nil
 }
+
+// HasThumbnail reports whether fileRef has any cached thumbnail, of any
+// size, backing the "hasthumbnail" search predicate (see
+// search.ThumbnailChecker). It relies on cacheKey's "scaled:<bref>:..."
+// key format to do a prefix scan rather than tracking a separate
+// reverse index. It always returns false, nil when m has no backing kv
+// store (memory-only mode), since there's nothing to scan.
+func (m *ThumbMeta) HasThumbnail(ctx context.Context, fileRef blob.Ref) (bool, error) {
+	if m.kv == nil {
+		return false, nil
+	}
+	prefix := "scaled:" + fileRef.String() + ":"
+	end := prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+	it := m.kv.Find(prefix, end)
+	found := it.Next()
+	return found, it.Close()
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/blobserver"
+	"perkeep.org/pkg/jsonsign/signhandler"
+	"perkeep.org/pkg/schema"
+	"perkeep.org/pkg/schema/nodeattr"
+	"perkeep.org/pkg/search"
+
+	"go4.org/jsonconfig"
+)
+
+const (
+	defaultTrashPurgeInterval  = 24 * time.Hour
+	defaultTrashPurgeRetention = 30 * 24 * time.Hour
+)
+
+// TrashPurgeHandler periodically finds permanodes that have been
+// trashed (see nodeattr.Trashed) for longer than a retention period,
+// and writes delete claims for them.
+//
+// A POST to the handler triggers a purge immediately, in addition to
+// the periodic run.
+//
+// Example low-level config:
+//
+//	"/trash-purge/": {
+//	    "handler": "trashpurge",
+//	    "handlerArgs": {
+//	        "search": "/my-search/",
+//	        "sign": "/sighelper/",
+//	        "target": "/bs/",
+//	        "interval": "24h",
+//	        "retention": "720h"
+//	    }
+//	}
+type TrashPurgeHandler struct {
+	search    *search.Handler
+	sign      *signhandler.Handler
+	target    blobserver.StatReceiver
+	interval  time.Duration
+	retention time.Duration
+
+	mu      sync.Mutex
+	purged  int
+	lastRun time.Time
+	lastErr error
+}
+
+var (
+	_ http.Handler             = (*TrashPurgeHandler)(nil)
+	_ blobserver.HandlerIniter = (*TrashPurgeHandler)(nil)
+)
+
+func init() {
+	blobserver.RegisterHandlerConstructor("trashpurge", newTrashPurgeFromConfig)
+}
+
+func newTrashPurgeFromConfig(ld blobserver.Loader, conf jsonconfig.Obj) (http.Handler, error) {
+	searchPrefix := conf.RequiredString("search")
+	signPrefix := conf.RequiredString("sign")
+	targetPrefix := conf.RequiredString("target")
+	intervalStr := conf.OptionalString("interval", defaultTrashPurgeInterval.String())
+	retentionStr := conf.OptionalString("retention", defaultTrashPurgeRetention.String())
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil {
+		return nil, fmt.Errorf("trashpurge: invalid interval %q: %v", intervalStr, err)
+	}
+	retention, err := time.ParseDuration(retentionStr)
+	if err != nil {
+		return nil, fmt.Errorf("trashpurge: invalid retention %q: %v", retentionStr, err)
+	}
+
+	searchHandler, err := ld.GetHandler(searchPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sh, ok := searchHandler.(*search.Handler)
+	if !ok {
+		return nil, fmt.Errorf("trashpurge: %q is a %T, not a search handler", searchPrefix, searchHandler)
+	}
+	signHandler, err := ld.GetHandler(signPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sig, ok := signHandler.(*signhandler.Handler)
+	if !ok {
+		return nil, fmt.Errorf("trashpurge: %q is a %T, not a jsonsign handler", signPrefix, signHandler)
+	}
+	target, err := ld.GetStorage(targetPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &TrashPurgeHandler{
+		search:    sh,
+		sign:      sig,
+		target:    target,
+		interval:  interval,
+		retention: retention,
+	}
+	go h.loop()
+	return h, nil
+}
+
+// loop runs one purge immediately, then one every h.interval, until
+// the process exits.
+func (h *TrashPurgeHandler) loop() {
+	ctx := context.Background()
+	h.purgeOnce(ctx)
+	t := time.NewTicker(h.interval)
+	defer t.Stop()
+	for range t.C {
+		h.purgeOnce(ctx)
+	}
+}
+
+func (h *TrashPurgeHandler) purgeOnce(ctx context.Context) {
+	cutoff := time.Now().Add(-h.retention)
+	res, err := h.search.Query(ctx, &search.SearchQuery{
+		Constraint: &search.Constraint{
+			Permanode: &search.PermanodeConstraint{
+				Attr:     nodeattr.Trashed,
+				NumValue: &search.IntConstraint{Min: 1},
+			},
+		},
+		Describe: &search.DescribeRequest{},
+	})
+	if err != nil {
+		h.recordErr(fmt.Errorf("querying trashed permanodes: %v", err))
+		return
+	}
+
+	toDelete := permanodesToPurge(res, cutoff)
+
+	purged := 0
+	for _, pn := range toDelete {
+		signed, err := h.sign.Sign(ctx, schema.NewDeleteClaim(pn))
+		if err != nil {
+			h.recordErr(fmt.Errorf("signing delete claim for %v: %v", pn, err))
+			continue
+		}
+		if _, err := blobserver.ReceiveString(ctx, h.target, signed); err != nil {
+			h.recordErr(fmt.Errorf("uploading delete claim for %v: %v", pn, err))
+			continue
+		}
+		purged++
+		log.Printf("trashpurge: purged %v (trashed longer than %v)", pn, h.retention)
+	}
+
+	h.mu.Lock()
+	h.purged += purged
+	h.lastRun = time.Now()
+	if purged > 0 {
+		h.lastErr = nil
+	}
+	h.mu.Unlock()
+}
+
+// permanodesToPurge returns the permanodes in res whose nodeattr.Trashed
+// value is a valid RFC 3339 timestamp at or before cutoff. Permanodes
+// with no (or an unparsable) Trashed value are skipped, since they
+// can't reliably be that old.
+func permanodesToPurge(res *search.SearchResult, cutoff time.Time) []blob.Ref {
+	var toDelete []blob.Ref
+	for _, sb := range res.Blobs {
+		db := res.Describe.Meta.Get(sb.Blob)
+		if db == nil || db.Permanode == nil {
+			continue
+		}
+		trashedAt, err := time.Parse(time.RFC3339, db.Permanode.Attr.Get(nodeattr.Trashed))
+		if err != nil {
+			continue
+		}
+		if trashedAt.After(cutoff) {
+			continue // not trashed long enough yet
+		}
+		toDelete = append(toDelete, sb.Blob)
+	}
+	return toDelete
+}
+
+func (h *TrashPurgeHandler) recordErr(err error) {
+	log.Printf("trashpurge: %v", err)
+	h.mu.Lock()
+	h.lastErr = err
+	h.mu.Unlock()
+}
+
+func (h *TrashPurgeHandler) InitHandler(hl blobserver.FindHandlerByTyper) error {
+	return nil
+}
+
+func (h *TrashPurgeHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method == "POST" {
+		h.purgeOnce(req.Context())
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(rw, "Trash purge (retaining %v, every %v):\n", h.retention, h.interval)
+	fmt.Fprintf(rw, "  purged so far: %d\n", h.purged)
+	if !h.lastRun.IsZero() {
+		fmt.Fprintf(rw, "  last run: %v\n", h.lastRun.Format(time.RFC3339))
+	}
+	if h.lastErr != nil {
+		fmt.Fprintf(rw, "Last error: %v\n", h.lastErr)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"perkeep.org/pkg/blob"
+	"perkeep.org/pkg/search"
+)
+
+func TestPermanodesToPurge(t *testing.T) {
+	cutoff := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	old := blob.RefFromString("old")
+	recent := blob.RefFromString("recent")
+	notTrashed := blob.RefFromString("not-trashed")
+	unparsable := blob.RefFromString("unparsable")
+
+	res := &search.SearchResult{
+		Blobs: []*search.SearchResultBlob{
+			{Blob: old}, {Blob: recent}, {Blob: notTrashed}, {Blob: unparsable},
+		},
+		Describe: &search.DescribeResponse{
+			Meta: search.MetaMap{
+				old.String(): {
+					BlobRef:   old,
+					Permanode: &search.DescribedPermanode{Attr: url.Values{"camliTrashed": {"2026-01-01T00:00:00Z"}}},
+				},
+				recent.String(): {
+					BlobRef:   recent,
+					Permanode: &search.DescribedPermanode{Attr: url.Values{"camliTrashed": {"2026-01-20T00:00:00Z"}}},
+				},
+				notTrashed.String(): {
+					BlobRef:   notTrashed,
+					Permanode: &search.DescribedPermanode{Attr: url.Values{}},
+				},
+				unparsable.String(): {
+					BlobRef:   unparsable,
+					Permanode: &search.DescribedPermanode{Attr: url.Values{"camliTrashed": {"not-a-time"}}},
+				},
+			},
+		},
+	}
+
+	got := permanodesToPurge(res, cutoff)
+	if len(got) != 1 || got[0] != old {
+		t.Errorf("permanodesToPurge = %v; want just %v", got, old)
+	}
+}
@@ -270,6 +270,9 @@ func (ui *UIHandler) InitHandler(hl blobserver.FindHandlerByTyper) error {
 		sh = h.(*search.Handler)
 		ui.search = sh
 	}
+	if ui.thumbMeta != nil {
+		sh.SetThumbnailChecker(ui.thumbMeta)
+	}
 	camliRootQuery := func(camliRoot string) (*search.SearchResult, error) {
 		return sh.Query(context.TODO(), &search.SearchQuery{
 			Limit: 1,
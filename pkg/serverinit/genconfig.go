@@ -1001,6 +1001,9 @@ func (b *lowBuilder) build() (*Config, error) {
 		return nil, errors.New("can't use both packBlobs (for 'diskpacked') and packRelated (for 'blobpacked')")
 	}
 	low["https"] = conf.HTTPS
+	if conf.Compress {
+		low["compress"] = conf.Compress
+	}
 	low["auth"] = conf.Auth
 
 	numIndexers := numSet(conf.LevelDB, conf.Mongo, conf.MySQL, conf.PostgreSQL, conf.SQLite, conf.KVFile, conf.MemoryIndex)
@@ -0,0 +1,107 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverinit
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// maintenanceMode is process-global (rather than per-Config) because a
+// server only ever installs one Config's handlers at a time, and the
+// admin endpoint that flips it doesn't have a convenient way to reach
+// back into the handlerLoader that set up the handler it's toggling.
+var maintenanceMode int32 // atomic bool; 0 = normal, 1 = maintenance
+
+// retryAfterSeconds is the value of the Retry-After header sent to clients
+// while the server is in maintenance mode.
+const retryAfterSeconds = 60
+
+// InMaintenanceMode reports whether the server is currently rejecting
+// non-admin requests with 503s.
+func InMaintenanceMode() bool {
+	return atomic.LoadInt32(&maintenanceMode) != 0
+}
+
+// SetMaintenanceMode turns maintenance mode on or off.
+//
+// While on, handlers wrapped with maintenanceWrap respond 503 with a
+// Retry-After header instead of being invoked. The check only happens
+// before a handler starts serving a request, so requests already being
+// served when maintenance mode is enabled are left to finish normally.
+func SetMaintenanceMode(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&maintenanceMode, v)
+	log.Printf("serverinit: maintenance mode set to %v", on)
+}
+
+// maintenanceWrap wraps h so it responds 503 with a Retry-After header
+// instead of running while the server is in maintenance mode. It's used
+// for all handlers except the ones (like status and the /debug/ admin
+// endpoints) that operators need to keep working during maintenance.
+func maintenanceWrap(h http.Handler) http.Handler {
+	return maintenanceHandler{h}
+}
+
+type maintenanceHandler struct {
+	h http.Handler
+}
+
+// Unwrap returns the wrapped handler, for tools (and tests) that peel back
+// handler-wrapping layers, in the style of net/http's ResponseController.
+func (m maintenanceHandler) Unwrap() http.Handler { return m.h }
+
+func (m maintenanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if InMaintenanceMode() {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		http.Error(w, "server is in maintenance mode; please retry later", http.StatusServiceUnavailable)
+		return
+	}
+	m.h.ServeHTTP(w, r)
+}
+
+// maintenanceAdminHandler serves the /debug/maintenance admin endpoint,
+// which reports and toggles maintenance mode.
+//
+// GET returns "on" or "off". POST with a "mode" form value of "on" or
+// "off" sets the mode and returns the new value the same way.
+type maintenanceAdminHandler struct{}
+
+func (maintenanceAdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		switch r.FormValue("mode") {
+		case "on":
+			SetMaintenanceMode(true)
+		case "off":
+			SetMaintenanceMode(false)
+		default:
+			http.Error(w, `mode must be "on" or "off"`, http.StatusBadRequest)
+			return
+		}
+	}
+	if InMaintenanceMode() {
+		io.WriteString(w, "on\n")
+	} else {
+		io.WriteString(w, "off\n")
+	}
+}
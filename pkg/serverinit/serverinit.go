@@ -22,6 +22,7 @@ package serverinit // import "perkeep.org/pkg/serverinit"
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"expvar"
@@ -39,12 +40,14 @@ import (
 	rpprof "runtime/pprof"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"perkeep.org/internal/httputil"
 	"perkeep.org/internal/osutil"
 	"perkeep.org/pkg/auth"
 	"perkeep.org/pkg/blobserver"
 	"perkeep.org/pkg/blobserver/handlers"
+	"perkeep.org/pkg/blobserver/otelblob"
 	"perkeep.org/pkg/index"
 	"perkeep.org/pkg/jsonsign/signhandler"
 	"perkeep.org/pkg/server"
@@ -89,8 +92,9 @@ type HandlerInstaller interface {
 }
 
 type storageAndConfig struct {
-	blobserver.Storage
-	config *blobserver.Config
+	blobserver.Storage // wrapped with otelblob.WrapStorage; used to actually serve requests
+	config             *blobserver.Config
+	unwrapped          blobserver.Storage // the storage as passed to makeCamliHandler, before tracing was added
 }
 
 // parseCamliPath looks for "/camli/" in the path and returns
@@ -115,7 +119,7 @@ func (s *storageAndConfig) Config() *blobserver.Config {
 // GetStorage returns the unwrapped blobserver.Storage interface value for
 // callers to type-assert optional interface implementations on. (e.g. EnumeratorConfig)
 func (s *storageAndConfig) GetStorage() blobserver.Storage {
-	return s.Storage
+	return s.unwrapped
 }
 
 // action is the part following "/camli/" in the URL. It's either a
@@ -170,8 +174,8 @@ func makeCamliHandler(prefix, baseURL string, storage blobserver.Storage, hf blo
 	// TODO(bradfitz): set to false if this is App Engine, or provide some way to disable
 
 	storageConfig := &storageAndConfig{
-		storage,
-		&blobserver.Config{
+		Storage: otelblob.WrapStorage(storage),
+		config: &blobserver.Config{
 			Writable:      true,
 			Readable:      true,
 			Deletable:     false,
@@ -179,6 +183,7 @@ func makeCamliHandler(prefix, baseURL string, storage blobserver.Storage, hf blo
 			CanLongPoll:   canLongPoll,
 			HandlerFinder: hf,
 		},
+		unwrapped: storage,
 	}
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		action, err := parseCamliPath(req.URL.Path[len(prefix)-1:])
@@ -188,7 +193,7 @@ func makeCamliHandler(prefix, baseURL string, storage blobserver.Storage, hf blo
 			unsupportedHandler(rw, req)
 			return
 		}
-		handler := auth.RequireAuth(camliHandlerUsingStorage(req, action, storageConfig))
+		handler := otelblob.Middleware(action, auth.RequireAuth(camliHandlerUsingStorage(req, action, storageConfig)))
 		handler.ServeHTTP(rw, req)
 	})
 }
@@ -330,7 +335,7 @@ func (hl *handlerLoader) setupHandler(prefix string) {
 		if h.internal {
 			hl.installer.Handle(prefix, unauthorizedHandler{})
 		} else {
-			hl.installer.Handle(prefix+"camli/", makeCamliHandler(prefix, hl.baseURL, pstorage, hl))
+			hl.installer.Handle(prefix+"camli/", maintenanceWrap(makeCamliHandler(prefix, hl.baseURL, pstorage, hl)))
 		}
 		if cl, ok := pstorage.(blobserver.ShutdownStorage); ok {
 			hl.closers = append(hl.closers, cl)
@@ -380,6 +385,12 @@ func (hl *handlerLoader) setupHandler(prefix string) {
 		if handlerTypeWantsAuth(h.htype) {
 			wrappedHandler = auth.Handler{Handler: wrappedHandler}
 		}
+		// The status handler stays reachable during maintenance mode, so
+		// operators can still check on the server while it's draining or
+		// reindexing.
+		if h.htype != "status" {
+			wrappedHandler = maintenanceWrap(wrappedHandler)
+		}
 	}
 	hl.installer.Handle(prefix, wrappedHandler)
 }
@@ -416,6 +427,7 @@ type Config struct {
 	https      bool
 	baseURL    string // optional, without trailing slash
 	listenAddr string // the optional net.Listen-style TCP listen address
+	compress   bool   // optional, gzip-compress API and UI responses
 
 	installedHandlers bool   // whether InstallHandlers (which validates the config too) has been called
 	uiPath            string // Not valid until after InstallHandlers
@@ -427,6 +439,9 @@ type Config struct {
 	// It is stored in the Config, so we can call UploadPublicKey on on it as
 	// soon as perkeepd is ready for it.
 	signHandler *signhandler.Handler
+	// index is the first index.Index found during InstallHandlers, or nil.
+	// It backs the /debug/indexdump admin endpoint.
+	index *index.Index
 }
 
 // UIPath returns the relative path to the server's user interface
@@ -457,6 +472,10 @@ func (c *Config) HTTPSKey() string { return c.httpsKey }
 // HTTPS reports whether this configuration wants to serve HTTPS.
 func (c *Config) HTTPS() bool { return c.https }
 
+// WantsCompression reports whether this configuration wants API and UI
+// responses gzip-compressed.
+func (c *Config) WantsCompression() bool { return c.compress }
+
 // IsTailscaleListener reports whether c is configured to run in
 // Tailscale tsnet mode.
 func (c *Config) IsTailscaleListener() bool {
@@ -576,6 +595,7 @@ func (c *Config) readFields() error {
 	c.httpsCert = c.jconf.OptionalString("httpsCert", "")
 	c.httpsKey = c.jconf.OptionalString("httpsKey", "")
 	c.https = c.jconf.OptionalBool("https", false)
+	c.compress = c.jconf.OptionalBool("compress", false)
 
 	_, explicitHTTPS := c.jconf["https"]
 	if c.httpsCert != "" && !explicitHTTPS {
@@ -738,6 +758,9 @@ func (c *Config) InstallHandlers(hi HandlerInstaller, baseURL string) (shutdown
 		if signHandler, ok := handler.(*signhandler.Handler); ok {
 			config.signHandler = signHandler
 		}
+		if idx, ok := handler.(*index.Index); ok && config.index == nil {
+			config.index = idx
+		}
 		if in, ok := handler.(blobserver.HandlerIniter); ok {
 			if err := in.InitHandler(hl); err != nil {
 				return nil, fmt.Errorf("Error calling InitHandler on %s: %v", pfx, err)
@@ -754,6 +777,10 @@ func (c *Config) InstallHandlers(hi HandlerInstaller, baseURL string) (shutdown
 	hi.Handle("/debug/goroutines", auth.RequireAuth(http.HandlerFunc(dumpGoroutines), auth.OpRead))
 	hi.Handle("/debug/config", auth.RequireAuth(configHandler{config}, auth.OpAll))
 	hi.Handle("/debug/logs/", auth.RequireAuth(http.HandlerFunc(logsHandler), auth.OpAll))
+	hi.Handle("/debug/maintenance", auth.RequireAuth(maintenanceAdminHandler{}, auth.OpAll))
+	if config.index != nil {
+		hi.Handle("/debug/indexdump", auth.RequireAuth(indexDumpHandler{config.index}, auth.OpAll))
+	}
 	config.installedHandlers = true
 	return multiCloser(hl.closers), nil
 }
@@ -859,6 +886,66 @@ func (h configHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 	w.Write(b)
 }
 
+// indexDumpHandler serves /debug/indexdump, an admin-only endpoint that
+// dumps raw index.Index key/value rows for debugging why something is,
+// or isn't, found by search. It takes two optional query parameters:
+// "prefix" restricts the dump to keys starting with that literal prefix,
+// and "blobref" further restricts it to rows whose key or value contains
+// that substring (typically a blobref, to find every raw entry that
+// references it regardless of the key type's layout). With neither
+// parameter, it dumps the whole index, up to the row limit.
+// See pkg/index's keys.go for the documented key formats (e.g. "recpn",
+// "claim", "signerattrvalue").
+type indexDumpHandler struct {
+	ix *index.Index
+}
+
+// indexDumpRow is the JSON shape of one row returned by indexDumpHandler.
+// Key and Value hold the raw entry, unless it isn't valid UTF-8, in which
+// case it's base64-encoded instead and the matching *Base64 flag is set.
+type indexDumpRow struct {
+	Key         string `json:"key"`
+	KeyBase64   bool   `json:"keyBase64,omitempty"`
+	Value       string `json:"value"`
+	ValueBase64 bool   `json:"valueBase64,omitempty"`
+}
+
+// indexDumpEscape returns s if it's valid UTF-8, or its base64 encoding
+// (and true) otherwise, so binary index rows can still be represented in
+// the handler's JSON output.
+func indexDumpEscape(s string) (out string, base64Encoded bool) {
+	if utf8.ValidString(s) {
+		return s, false
+	}
+	return base64.StdEncoding.EncodeToString([]byte(s)), true
+}
+
+func (h indexDumpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := r.FormValue("prefix")
+	substr := r.FormValue("blobref")
+	var rows []indexDumpRow
+	truncated, err := h.ix.ForeachRow(prefix, substr, func(row index.DebugRow) bool {
+		key, keyB64 := indexDumpEscape(row.Key)
+		value, valueB64 := indexDumpEscape(row.Value)
+		rows = append(rows, indexDumpRow{
+			Key:         key,
+			KeyBase64:   keyB64,
+			Value:       value,
+			ValueBase64: valueB64,
+		})
+		return true
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(struct {
+		Rows      []indexDumpRow `json:"rows"`
+		Truncated bool           `json:"truncated,omitempty"`
+	}{rows, truncated})
+}
+
 // profileHandler publishes server profile information.
 type profileHandler struct{}
 
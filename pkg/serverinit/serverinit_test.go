@@ -399,6 +399,9 @@ func TestInstallHandlers(t *testing.T) {
 			continue
 		}
 		h, _ := hi.Handler(req)
+		if mh, ok := h.(interface{ Unwrap() http.Handler }); ok {
+			h = mh.Unwrap()
+		}
 		if v.authWrapped {
 			ah, ok := h.(auth.Handler)
 			if !ok {
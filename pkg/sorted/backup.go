@@ -0,0 +1,128 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sorted
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// backupMagic identifies the stream format written by Backup, so
+// Restore can fail fast on unrelated input.
+const backupMagic = "camlibak1"
+
+// Backup writes every key/value pair in kv to w, in key order, as a
+// simple length-prefixed stream that Restore can read back.
+//
+// If kv implements TransactionalReader, Backup reads from a single
+// read transaction, so the backup reflects one consistent point in
+// time even if kv is concurrently written to. Otherwise, Backup reads
+// kv directly with Foreach, and the resulting backup may mix state
+// from before and after concurrent writes.
+func Backup(kv KeyValue, w io.Writer) (err error) {
+	bw := bufio.NewWriter(w)
+	if _, err := io.WriteString(bw, backupMagic); err != nil {
+		return err
+	}
+
+	writePair := func(key, value string) error {
+		return writeBackupPair(bw, key, value)
+	}
+
+	if txr, ok := kv.(TransactionalReader); ok {
+		tx := txr.BeginReadTx()
+		defer func() {
+			if closeErr := tx.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		it := tx.Find("", "")
+		for it.Next() {
+			if err := writePair(it.Key(), it.Value()); err != nil {
+				it.Close()
+				return err
+			}
+		}
+		if err := it.Close(); err != nil {
+			return err
+		}
+	} else if err := Foreach(kv, writePair); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func writeBackupPair(w *bufio.Writer, key, value string) error {
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, s := range [...]string{key, value} {
+		n := binary.PutUvarint(lenBuf[:], uint64(len(s)))
+		if _, err := w.Write(lenBuf[:n]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore reads a stream written by Backup and applies its key/value
+// pairs to kv with a single batch mutation. It does not first clear
+// kv; restoring on top of an already-populated store is the caller's
+// responsibility to avoid (e.g. by using a freshly Wiped store).
+func Restore(kv KeyValue, r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(backupMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return fmt.Errorf("sorted: reading backup header: %v", err)
+	}
+	if string(magic) != backupMagic {
+		return fmt.Errorf("sorted: not a backup stream (bad header)")
+	}
+
+	b := kv.BeginBatch()
+	for {
+		key, err := readBackupString(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("sorted: reading backup key: %v", err)
+		}
+		value, err := readBackupString(br)
+		if err != nil {
+			return fmt.Errorf("sorted: reading backup value: %v", err)
+		}
+		b.Set(key, value)
+	}
+	return kv.CommitBatch(b)
+}
+
+func readBackupString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
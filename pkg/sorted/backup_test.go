@@ -0,0 +1,75 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sorted_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"perkeep.org/pkg/sorted"
+)
+
+func TestBackupRestore(t *testing.T) {
+	src := sorted.NewMemoryKeyValue()
+	want := map[string]string{
+		"a": "1",
+		"b": "2",
+		"c": strings.Repeat("x", 4096),
+		"":  "empty key",
+		"d": "",
+	}
+	for k, v := range want {
+		if err := src.Set(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := sorted.Backup(src, &buf); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	dst := sorted.NewMemoryKeyValue()
+	if err := sorted.Restore(dst, &buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	got := map[string]string{}
+	if err := sorted.Foreach(dst, func(key, value string) error {
+		got[key] = value
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("restored %d pairs; want %d", len(got), len(want))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("restored[%q] = %q; want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestRestoreBadHeader(t *testing.T) {
+	dst := sorted.NewMemoryKeyValue()
+	if err := sorted.Restore(dst, strings.NewReader("not a backup")); err == nil {
+		t.Error("Restore of garbage input: got nil error, want one")
+	}
+}
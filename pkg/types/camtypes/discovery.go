@@ -58,6 +58,17 @@ type Discovery struct {
 	SyncHandlers []SyncHandlerDiscovery `json:"syncHandlers,omitempty"`
 	// Signing contains discovery information for signing.
 	Signing *SignDiscovery `json:"signing,omitempty"`
+
+	// Features advertises optional server capabilities by name (e.g.
+	// "batchReceive", "streamingSearch"), so clients can adapt instead
+	// of guessing from a server version. Its presence, not its value,
+	// is what a client should generally check: a flag absent from an
+	// older server's discovery document must be treated the same as if
+	// it were explicitly false. Clients should likewise ignore feature
+	// names they don't recognize, for forward compatibility with newer
+	// servers.
+	Features map[string]bool `json:"features,omitempty"`
+
 	// UIDiscovery contains discovery information for the UI.
 	*UIDiscovery
 }
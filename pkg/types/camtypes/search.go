@@ -115,6 +115,11 @@ type FileInfo struct {
 	// This will be zero for non-regular files, and may also be zero
 	// for files above a certain size threshold.
 	WholeRef blob.Ref `json:"wholeRef,omitempty"`
+
+	// Taken is the image's EXIF "date taken", if any. Unlike Time and
+	// ModTime, it never falls back to a filesystem modtime, so its
+	// presence means the file actually carried that metadata.
+	Taken *types.Time3339 `json:"taken,omitempty"`
 }
 
 func (fi *FileInfo) IsText() bool {
@@ -155,6 +160,11 @@ type ImageInfo struct {
 	Width uint16 `json:"width"`
 	// Height is the visible height of the image (after any necessary EXIF rotation).
 	Height uint16 `json:"height"`
+	// DominantColors is the image's most common colors, most common
+	// first, as lowercase "RRGGBB" hex triplets. It may be shorter
+	// than the number of colors the indexer looked for, or empty, if
+	// the image couldn't be decoded.
+	DominantColors []string `json:"dominantColors,omitempty"`
 }
 
 type Path struct {
@@ -62,6 +62,8 @@ type Config struct {
 	HTTPSCert string `json:"httpsCert,omitempty"` // path to the HTTPS certificate file.
 	HTTPSKey  string `json:"httpsKey,omitempty"`  // path to the HTTPS key file.
 
+	Compress bool `json:"compress,omitempty"` // gzip-compress API and UI responses above a size threshold.
+
 	// Index.
 	RunIndex          invertedBool `json:"runIndex,omitempty"`          // if logically false: no search, no UI, etc.
 	CopyIndexToMemory invertedBool `json:"copyIndexToMemory,omitempty"` // copy disk-based index to memory on start-up.
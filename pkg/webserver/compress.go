@@ -0,0 +1,176 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// compressMinSize is the minimum response size, in bytes, before
+// CompressHandler bothers compressing it. Small responses aren't worth
+// the CPU cost and gzip's own framing overhead.
+const compressMinSize = 1024
+
+// compressibleContentType reports whether a response Content-Type is
+// worth gzip-compressing. Perkeep's own API and UI responses (JSON,
+// HTML, JS, CSS) are all text-based and highly compressible; blobs are
+// served with whatever Content-Type they were stored with (often
+// already-compressed formats like images or video) and are excluded by
+// only ever matching this conservative allowlist rather than trying to
+// detect "already compressed" content types.
+func compressibleContentType(ct string) bool {
+	ct = strings.TrimSpace(strings.SplitN(ct, ";", 2)[0])
+	switch {
+	case strings.HasPrefix(ct, "text/"),
+		ct == "application/json",
+		ct == "application/javascript",
+		ct == "application/xml",
+		ct == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(enc, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// compressResponseWriter buffers up to compressMinSize bytes of a
+// response so it can decide, once it knows the actual Content-Type and
+// has enough bytes to judge the response isn't tiny, whether to gzip
+// the rest of the response.
+type compressResponseWriter struct {
+	http.ResponseWriter
+
+	wroteHeader bool
+	statusCode  int
+	buf         bytes.Buffer
+
+	decided  bool
+	compress bool
+	gz       *gzip.Writer
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = code
+}
+
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.compress {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	n, _ := w.buf.Write(p)
+	if w.buf.Len() >= compressMinSize {
+		if err := w.decide(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// decide picks whether to compress based on the response headers set
+// so far, then flushes the buffered prefix (compressed or not) and any
+// headers to the underlying ResponseWriter.
+func (w *compressResponseWriter) decide() error {
+	w.decided = true
+	w.compress = w.ResponseWriter.Header().Get("Content-Encoding") == "" &&
+		compressibleContentType(w.ResponseWriter.Header().Get("Content-Type"))
+	if w.compress {
+		h := w.ResponseWriter.Header()
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length") // length of the compressed body isn't known yet
+		h.Add("Vary", "Accept-Encoding")
+		w.gz = gzipWriterPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	if w.compress {
+		_, err := w.gz.Write(w.buf.Bytes())
+		return err
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
+
+// Flush forces any buffered (or gzip-buffered) bytes out to the
+// underlying ResponseWriter and, if it's also an http.Flusher, flushes
+// that too. Without this, a handler like serveRestart that writes a
+// small response and calls Flush before doing something from which it
+// never returns (e.g. syscall.Exec) would have its response silently
+// stuck in w.buf or the gzip.Writer forever, since Close is only
+// reached after the handler returns.
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return
+		}
+	} else if w.compress {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finalizes the response: a response smaller than
+// compressMinSize never reaches decide via Write, so it's decided
+// (and, being small, never compressed) here; a gzip.Writer in use is
+// flushed and returned to the pool.
+func (w *compressResponseWriter) Close() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.decided = true
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		if w.buf.Len() > 0 {
+			w.ResponseWriter.Write(w.buf.Bytes())
+		}
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriterPool.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+// flushRecorder is an httptest.ResponseRecorder that also counts calls to
+// Flush, so tests can verify that compressResponseWriter.Flush actually
+// forwards to the wrapped ResponseWriter's own Flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (f *flushRecorder) Flush() { f.flushes++ }
+
+// TestCompressResponseWriterFlush verifies that Flush pushes a small,
+// still-buffered response (one under compressMinSize, which would
+// otherwise sit in w.buf until Close) out to the underlying
+// ResponseWriter, and forwards to that writer's own Flush. This is the
+// interaction serveRestart depends on: it writes a small redirect body,
+// then calls Flush right before replacing the process image, a point
+// after which Close never runs.
+func TestCompressResponseWriterFlush(t *testing.T) {
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := &compressResponseWriter{ResponseWriter: rec}
+
+	const body = "short redirect body"
+	if _, err := w.Write([]byte(body)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("body reached the recorder before Flush: %q", rec.Body.String())
+	}
+
+	w.Flush()
+
+	if got := rec.Body.String(); got != body {
+		t.Errorf("after Flush, recorder body = %q, want %q", got, body)
+	}
+	if rec.flushes != 1 {
+		t.Errorf("underlying Flush called %d times, want 1", rec.flushes)
+	}
+}
+
+// TestCompressResponseWriterFlushGzip verifies that, once compressing,
+// Flush pushes out whatever's been gzip-written so far without ending
+// the gzip stream, so later Writes can still be appended and read back
+// correctly.
+func TestCompressResponseWriterFlushGzip(t *testing.T) {
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := &compressResponseWriter{ResponseWriter: rec}
+	w.Header().Set("Content-Type", "text/plain")
+
+	if _, err := w.Write(bytes.Repeat([]byte("a"), compressMinSize)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !w.decided || !w.compress {
+		t.Fatalf("expected compression to have been decided on after %d bytes", compressMinSize)
+	}
+
+	w.Flush()
+	if rec.flushes != 1 {
+		t.Errorf("underlying Flush called %d times, want 1", rec.flushes)
+	}
+	if rec.Body.Len() == 0 {
+		t.Fatal("no gzip bytes reached the recorder after Flush")
+	}
+
+	if _, err := w.Write([]byte("more")); err != nil {
+		t.Fatalf("Write after Flush: %v", err)
+	}
+	w.Close()
+
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	got, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatalf("reading gzip stream: %v", err)
+	}
+	want := bytes.Repeat([]byte("a"), compressMinSize)
+	want = append(want, "more"...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("decompressed body mismatch: got %d bytes, want %d bytes", len(got), len(want))
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// IPQuota configures per-client-IP limits enforced by Server, to give
+// an internet-exposed instance some basic abuse protection without
+// requiring a separate reverse proxy or WAF in front of it. The zero
+// value disables both limits.
+type IPQuota struct {
+	// MaxConcurrentPerIP, if positive, caps the number of requests from
+	// a single client IP being served at once. This stands in for a
+	// true per-connection cap: most HTTP clients keep at most one
+	// request in flight per open connection, so this bounds concurrent
+	// connections too, without needing to hook the underlying
+	// net.Conns. A long-lived streaming response (e.g. an event feed)
+	// holds its one slot for as long as it stays open, same as any
+	// other request; it isn't charged more than that for taking a
+	// while. Zero means unlimited.
+	MaxConcurrentPerIP int
+
+	// MaxRequestsPerMinute, if positive, caps the sustained rate of
+	// requests accepted from a single client IP, as a token bucket
+	// that refills at MaxRequestsPerMinute/60 tokens per second and
+	// allows bursting up to a full minute's worth of tokens at once.
+	// Zero means unlimited.
+	MaxRequestsPerMinute float64
+
+	// TrustedProxies lists the CIDRs of reverse proxies allowed to set
+	// X-Forwarded-For. A request's client IP for quota purposes is
+	// taken from the first (left-most, i.e. nearest the original
+	// client) address in X-Forwarded-For only when the TCP peer
+	// (RemoteAddr) is in TrustedProxies; otherwise RemoteAddr is used
+	// as-is, so a direct client can't forge its way around its quota
+	// by sending its own X-Forwarded-For header.
+	TrustedProxies []*net.IPNet
+
+	// Allowlist exempts the given CIDRs, such as a monitoring host or
+	// an internal network, from both limits entirely.
+	Allowlist []*net.IPNet
+}
+
+func (q *IPQuota) enabled() bool {
+	return q.MaxConcurrentPerIP > 0 || q.MaxRequestsPerMinute > 0
+}
+
+// clientIP returns req's client IP for quota purposes, per the rules
+// documented on IPQuota.TrustedProxies.
+func clientIP(req *http.Request, trustedProxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil {
+		return nil
+	}
+	if len(trustedProxies) == 0 || !ipInAny(remote, trustedProxies) {
+		return remote
+	}
+	xff := req.Header.Get("X-Forwarded-For")
+	if xff == "" {
+		return remote
+	}
+	first, _, _ := strings.Cut(xff, ",")
+	if fwded := net.ParseIP(strings.TrimSpace(first)); fwded != nil {
+		return fwded
+	}
+	return remote
+}
+
+func ipInAny(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// limiterIdleTTL is how long a client IP's rate limiter is kept after its
+// last request before it's evicted as idle. Unlike st.concurrent, which
+// deletes its own entry as soon as a client's in-flight count hits zero,
+// a rate.Limiter has no natural "empty" moment to delete it at, so
+// instead every entry not used for this long is swept away, bounding
+// st.limiters on a long-running, internet-exposed server even though it
+// sees an unbounded number of distinct client IPs over its lifetime.
+const limiterIdleTTL = 10 * time.Minute
+
+// limiterSweepInterval is how often checkIPQuota piggybacks an idle
+// sweep of st.limiters onto an incoming request, rather than running a
+// dedicated goroutine Server would need to shut down cleanly.
+const limiterSweepInterval = time.Minute
+
+// limiterEntry is one client IP's rate.Limiter, plus enough bookkeeping
+// to evict it once it's been idle for limiterIdleTTL.
+type limiterEntry struct {
+	lim      *rate.Limiter
+	lastUsed time.Time
+}
+
+// ipQuotaState is Server's mutable bookkeeping for IPQuota enforcement.
+type ipQuotaState struct {
+	mu         sync.Mutex
+	limiters   map[string]*limiterEntry // client IP string -> requests-per-minute bucket
+	concurrent map[string]int           // client IP string -> in-flight request count
+	lastSweep  time.Time
+}
+
+// sweepLimiters deletes any st.limiters entry idle for at least
+// limiterIdleTTL. Callers must hold st.mu.
+func (st *ipQuotaState) sweepLimiters(now time.Time) {
+	if now.Sub(st.lastSweep) < limiterSweepInterval {
+		return
+	}
+	st.lastSweep = now
+	for key, e := range st.limiters {
+		if now.Sub(e.lastUsed) >= limiterIdleTTL {
+			delete(st.limiters, key)
+		}
+	}
+}
+
+// checkIPQuota reports whether req, from the client IP resolved per
+// s.IPQuota, may proceed. When it may, and IPQuota.MaxConcurrentPerIP
+// holds a slot open for it, the returned release func must be called
+// once the request finishes to free that slot; release is always
+// non-nil and safe to call even when ok is false or quotas are unset.
+func (s *Server) checkIPQuota(req *http.Request) (release func(), ok bool) {
+	noop := func() {}
+	q := &s.IPQuota
+	if !q.enabled() {
+		return noop, true
+	}
+	ip := clientIP(req, q.TrustedProxies)
+	if ip == nil || ipInAny(ip, q.Allowlist) {
+		return noop, true
+	}
+	key := ip.String()
+
+	s.ipQuotaOnce.Do(func() {
+		s.ipQuota = &ipQuotaState{
+			limiters:   make(map[string]*limiterEntry),
+			concurrent: make(map[string]int),
+		}
+	})
+	st := s.ipQuota
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	st.sweepLimiters(now)
+
+	if q.MaxRequestsPerMinute > 0 {
+		e, ok := st.limiters[key]
+		if !ok {
+			perSec := rate.Limit(q.MaxRequestsPerMinute / 60)
+			burst := int(q.MaxRequestsPerMinute)
+			if burst < 1 {
+				burst = 1
+			}
+			e = &limiterEntry{lim: rate.NewLimiter(perSec, burst)}
+			st.limiters[key] = e
+		}
+		e.lastUsed = now
+		if !e.lim.Allow() {
+			return noop, false
+		}
+	}
+
+	if q.MaxConcurrentPerIP > 0 {
+		if st.concurrent[key] >= q.MaxConcurrentPerIP {
+			return noop, false
+		}
+		st.concurrent[key]++
+		return func() {
+			st.mu.Lock()
+			st.concurrent[key]--
+			if st.concurrent[key] <= 0 {
+				delete(st.concurrent, key)
+			}
+			st.mu.Unlock()
+		}, true
+	}
+
+	return noop, true
+}
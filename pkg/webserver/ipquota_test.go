@@ -0,0 +1,214 @@
+/*
+Copyright 2026 The Perkeep Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webserver
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("ParseCIDR(%q): %v", s, err)
+	}
+	return n
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []*net.IPNet{mustCIDR(t, "10.0.0.0/8")}
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		xff            string
+		trustedProxies []*net.IPNet
+		want           string
+	}{
+		{
+			name:       "no proxies configured, XFF ignored",
+			remoteAddr: "1.2.3.4:1234",
+			xff:        "9.9.9.9",
+			want:       "1.2.3.4",
+		},
+		{
+			name:           "untrusted peer, XFF ignored",
+			remoteAddr:     "203.0.113.1:1234",
+			xff:            "9.9.9.9",
+			trustedProxies: trusted,
+			want:           "203.0.113.1",
+		},
+		{
+			name:           "trusted proxy, left-most XFF address used",
+			remoteAddr:     "10.1.2.3:1234",
+			xff:            "9.9.9.9, 10.1.2.3",
+			trustedProxies: trusted,
+			want:           "9.9.9.9",
+		},
+		{
+			name:           "trusted proxy, no XFF header",
+			remoteAddr:     "10.1.2.3:1234",
+			trustedProxies: trusted,
+			want:           "10.1.2.3",
+		},
+		{
+			name:           "trusted proxy, unparsable XFF",
+			remoteAddr:     "10.1.2.3:1234",
+			xff:            "not-an-ip",
+			trustedProxies: trusted,
+			want:           "10.1.2.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			got := clientIP(req, tt.trustedProxies)
+			if got == nil {
+				t.Fatalf("clientIP = nil, want %s", tt.want)
+			}
+			if got.String() != tt.want {
+				t.Errorf("clientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckIPQuotaAllowlist(t *testing.T) {
+	s := &Server{
+		IPQuota: IPQuota{
+			MaxConcurrentPerIP: 1,
+			Allowlist:          []*net.IPNet{mustCIDR(t, "192.168.0.0/16")},
+		},
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+
+	for i := 0; i < 5; i++ {
+		release, ok := s.checkIPQuota(req)
+		if !ok {
+			t.Fatalf("request %d: denied for an allowlisted IP", i)
+		}
+		release()
+	}
+}
+
+func TestCheckIPQuotaConcurrent(t *testing.T) {
+	s := &Server{IPQuota: IPQuota{MaxConcurrentPerIP: 2}}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+
+	release1, ok := s.checkIPQuota(req)
+	if !ok {
+		t.Fatal("1st request denied, want allowed")
+	}
+	release2, ok := s.checkIPQuota(req)
+	if !ok {
+		t.Fatal("2nd request denied, want allowed")
+	}
+	if _, ok := s.checkIPQuota(req); ok {
+		t.Fatal("3rd concurrent request allowed, want denied at MaxConcurrentPerIP=2")
+	}
+
+	release1()
+	if _, ok := s.checkIPQuota(req); !ok {
+		t.Fatal("request denied after releasing a slot, want allowed")
+	}
+	release2()
+
+	other := httptest.NewRequest("GET", "/", nil)
+	other.RemoteAddr = "5.6.7.8:1234"
+	if _, ok := s.checkIPQuota(other); !ok {
+		t.Fatal("a different client IP was denied by another IP's concurrency cap")
+	}
+}
+
+func TestCheckIPQuotaRate(t *testing.T) {
+	s := &Server{IPQuota: IPQuota{MaxRequestsPerMinute: 2}}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+
+	for i := 0; i < 2; i++ {
+		if _, ok := s.checkIPQuota(req); !ok {
+			t.Fatalf("request %d denied within burst of 2, want allowed", i)
+		}
+	}
+	if _, ok := s.checkIPQuota(req); ok {
+		t.Fatal("request beyond burst allowed, want denied")
+	}
+}
+
+// TestCheckIPQuotaLimiterEviction verifies that an idle client IP's rate
+// limiter is swept from ipQuotaState.limiters, rather than kept forever,
+// once it's older than limiterIdleTTL.
+func TestCheckIPQuotaLimiterEviction(t *testing.T) {
+	s := &Server{IPQuota: IPQuota{MaxRequestsPerMinute: 60}}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+
+	if _, ok := s.checkIPQuota(req); !ok {
+		t.Fatal("request denied, want allowed")
+	}
+	st := s.ipQuota
+	st.mu.Lock()
+	if len(st.limiters) != 1 {
+		st.mu.Unlock()
+		t.Fatalf("limiters = %d entries, want 1", len(st.limiters))
+	}
+	// Back-date the entry and the last sweep so the next request forces
+	// a sweep that finds it idle, without sleeping in the test.
+	for _, e := range st.limiters {
+		e.lastUsed = time.Now().Add(-2 * limiterIdleTTL)
+	}
+	st.lastSweep = time.Now().Add(-2 * limiterSweepInterval)
+	st.mu.Unlock()
+
+	other := httptest.NewRequest("GET", "/", nil)
+	other.RemoteAddr = "5.6.7.8:1234"
+	if _, ok := s.checkIPQuota(other); !ok {
+		t.Fatal("request denied, want allowed")
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, stillThere := st.limiters["1.2.3.4"]; stillThere {
+		t.Error("idle limiter for 1.2.3.4 was not evicted")
+	}
+	if _, ok := st.limiters["5.6.7.8"]; !ok {
+		t.Error("newly-used limiter for 5.6.7.8 was evicted or never added")
+	}
+}
+
+func TestCheckIPQuotaDisabled(t *testing.T) {
+	s := &Server{}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	release, ok := s.checkIPQuota(req)
+	if !ok {
+		t.Fatal("denied with a zero-value IPQuota, want allowed")
+	}
+	release() // must not panic
+	if s.ipQuota != nil {
+		t.Error("ipQuotaState allocated even though IPQuota is disabled")
+	}
+}
@@ -66,10 +66,78 @@ type Server struct {
 	// tsnetServer is non-nil when running in Tailscale tsnet mode.
 	tsnetServer *tsnet.Server
 
+	// ReadHeaderTimeout, IdleTimeout, and MaxHeaderBytes configure the
+	// underlying http.Server, to harden internet-facing deployments
+	// against slowloris-style attacks. Zero means the net/http default
+	// applies (no timeout, or 1 MB of header for MaxHeaderBytes). These
+	// are connection-wide, since headers are read before a request's
+	// handler (and thus its Limits, if any) is known; see
+	// HandleWithLimits for per-handler-prefix limits.
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// EnableCompression, if true, gzip-compresses text-based responses
+	// (JSON, HTML, JS, CSS) above a minimum size when a client's
+	// Accept-Encoding allows it. Blob content isn't affected, since it's
+	// served with a Content-Type this doesn't recognize as compressible.
+	EnableCompression bool
+
+	// IPQuota, if set, caps how much of the server a single client IP
+	// can use at once. See IPQuota's doc for details.
+	IPQuota IPQuota
+
+	ipQuotaOnce sync.Once
+	ipQuota     *ipQuotaState
+
 	mu   sync.Mutex
 	reqs int64
 }
 
+// Limits configures resource limits applied to requests served by a
+// single handler, registered with HandleWithLimits or
+// HandleFuncWithLimits. Unlike Server's ReadHeaderTimeout and
+// IdleTimeout, these can vary per handler prefix: e.g. a blob upload
+// endpoint can allow a much larger request body, and a longer write
+// timeout so a slow upload isn't killed mid-transfer, than a small JSON
+// API endpoint.
+type Limits struct {
+	// MaxRequestBody, if positive, is the maximum number of bytes
+	// read from a request's body; reading beyond it fails with an
+	// error. Zero means no per-handler limit.
+	MaxRequestBody int64
+
+	// ReadTimeout and WriteTimeout, if positive, are deadlines for
+	// reading the request and writing the response, starting when the
+	// handler is invoked. Zero leaves the connection's existing
+	// deadline (if any) in place.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// wrap returns h wrapped to enforce l, or h unchanged if l is the zero
+// Limits.
+func (l Limits) wrap(h http.Handler) http.Handler {
+	if l == (Limits{}) {
+		return h
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rc := http.NewResponseController(rw)
+		if l.ReadTimeout > 0 {
+			// Best effort: some ResponseWriters (e.g. in tests) don't
+			// support per-request deadlines.
+			rc.SetReadDeadline(time.Now().Add(l.ReadTimeout))
+		}
+		if l.WriteTimeout > 0 {
+			rc.SetWriteDeadline(time.Now().Add(l.WriteTimeout))
+		}
+		if l.MaxRequestBody > 0 {
+			req.Body = http.MaxBytesReader(rw, req.Body, l.MaxRequestBody)
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
 func New() *Server {
 	verbose, _ := strconv.ParseBool(os.Getenv("CAMLI_HTTP_DEBUG"))
 	return &Server{
@@ -142,17 +210,49 @@ func (s *Server) Handle(pattern string, handler http.Handler) {
 	s.mux.Handle(pattern, handler)
 }
 
+// HandleWithLimits is like Handle, but enforces limits (request body
+// size, read/write deadlines) on requests matching pattern. See Limits.
+func (s *Server) HandleWithLimits(pattern string, handler http.Handler, limits Limits) {
+	s.mux.Handle(pattern, limits.wrap(handler))
+}
+
+// HandleFuncWithLimits is the HandlerFunc form of HandleWithLimits.
+func (s *Server) HandleFuncWithLimits(pattern string, fn func(http.ResponseWriter, *http.Request), limits Limits) {
+	s.HandleWithLimits(pattern, http.HandlerFunc(fn), limits)
+}
+
 func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	release, ok := s.checkIPQuota(req)
+	defer release()
+	if !ok {
+		http.Error(rw, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
 	var n int64
 	if s.verbose {
 		s.mu.Lock()
 		s.reqs++
 		n = s.reqs
 		s.mu.Unlock()
-		s.printf("Request #%d: %s %s (from %s) ...", n, req.Method, req.RequestURI, req.RemoteAddr)
+		// reqID, if the client sent one (see pkg/client's
+		// requestIDHeader), lets an operator correlate this log line
+		// with the client operation that produced it.
+		reqID := req.Header.Get("X-Camlistore-Request-Id")
+		if reqID == "" {
+			s.printf("Request #%d: %s %s (from %s) ...", n, req.Method, req.RequestURI, req.RemoteAddr)
+		} else {
+			s.printf("Request #%d [%s]: %s %s (from %s) ...", n, reqID, req.Method, req.RequestURI, req.RemoteAddr)
+		}
 		rw = &trackResponseWriter{ResponseWriter: rw}
 	}
-	s.mux.ServeHTTP(rw, req)
+	if s.EnableCompression && acceptsGzip(req) {
+		cw := &compressResponseWriter{ResponseWriter: rw}
+		s.mux.ServeHTTP(cw, req)
+		cw.Close()
+	} else {
+		s.mux.ServeHTTP(rw, req)
+	}
 	if s.verbose {
 		tw := rw.(*trackResponseWriter)
 		s.printf("Request #%d: %s %s = code %d, %d bytes", n, req.Method, req.RequestURI, tw.code, tw.resSize)
@@ -338,7 +438,10 @@ func (s *Server) Serve() {
 	go runTestHarnessIntegration(s.listener)
 
 	srv := &http.Server{
-		Handler: s,
+		Handler:           s,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
 	}
 	// TODO: allow configuring src.ErrorLog (and plumb through to
 	// Google Cloud Logging when run on GCE, eventually)
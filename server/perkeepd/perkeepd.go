@@ -48,20 +48,26 @@ import (
 	// for init side-effects + LogWriter
 
 	// Storage options:
+	_ "perkeep.org/pkg/blobserver/appendonly"
 	_ "perkeep.org/pkg/blobserver/azure"
+	_ "perkeep.org/pkg/blobserver/b2"
 	"perkeep.org/pkg/blobserver/blobpacked"
+	_ "perkeep.org/pkg/blobserver/breaker"
 	_ "perkeep.org/pkg/blobserver/cond"
 	_ "perkeep.org/pkg/blobserver/diskpacked"
 	_ "perkeep.org/pkg/blobserver/encrypt"
+	_ "perkeep.org/pkg/blobserver/faultinject"
 	_ "perkeep.org/pkg/blobserver/google/cloudstorage"
 	_ "perkeep.org/pkg/blobserver/google/drive"
 	_ "perkeep.org/pkg/blobserver/localdisk"
 	_ "perkeep.org/pkg/blobserver/mongo"
 	_ "perkeep.org/pkg/blobserver/overlay"
 	_ "perkeep.org/pkg/blobserver/proxycache"
+	_ "perkeep.org/pkg/blobserver/readonly"
 	_ "perkeep.org/pkg/blobserver/remote"
 	_ "perkeep.org/pkg/blobserver/replica"
 	_ "perkeep.org/pkg/blobserver/s3"
+	_ "perkeep.org/pkg/blobserver/scrub"
 	_ "perkeep.org/pkg/blobserver/shard"
 	_ "perkeep.org/pkg/blobserver/union"
 
@@ -422,6 +428,7 @@ func main() {
 	}
 
 	ws := webserver.New()
+	ws.EnableCompression = config.WantsCompression()
 	baseURL, err := listen(ws, config)
 	if err != nil {
 		exitf("Error starting webserver: %v", err)